@@ -63,6 +63,33 @@ grafeas:
 `)
 }
 
+func userMemStoreConfig(t *testing.T) *MemStoreConfig {
+	t.Helper()
+	return &MemStoreConfig{
+		SnapshotPath:     "/some/path/memstore.snapshot",
+		SnapshotInterval: "5m",
+	}
+}
+
+func userConfig_memstore_snapshot_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+    certfile: abc
+    keyfile: def
+    cafile:  ghi
+    cors_allowed_origins:
+      - "http://example.com"
+      - "https://somewhere.else.com"
+  storage_type: "memstore"
+  memstore:
+    snapshotpath: "/some/path/memstore.snapshot"
+    snapshotinterval: "5m"
+`)
+}
+
 func userEmbeddedConfig(t *testing.T) *EmbeddedStoreConfig {
 	t.Helper()
 	return &EmbeddedStoreConfig{
@@ -109,6 +136,10 @@ func TestLoadConfig_ReturnsDefaultConfig_NoInput(t *testing.T) {
 	if cfg.StorageConfig != nil {
 		t.Errorf("storage configuration is not nil")
 	}
+
+	if cfg.Cache != nil {
+		t.Errorf("cache configuration is not nil")
+	}
 }
 
 func TestLoadConfig_ReturnsConfig_UserSuppliedValues_Memstore(t *testing.T) {
@@ -150,6 +181,47 @@ func TestLoadConfig_ReturnsConfig_UserSuppliedValues_Memstore(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_MemstoreSnapshot(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_memstore_snapshot_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Error(err)
+	}
+
+	if cfg.StorageType != "memstore" {
+		t.Errorf("Storage type %s is not memstore", cfg.StorageType)
+	}
+
+	if cfg.StorageConfig == nil {
+		t.Fatalf("storage configuration is nil")
+	}
+
+	var storeConfig MemStoreConfig
+
+	if err = ConvertGenericConfigToSpecificType(*cfg.StorageConfig, &storeConfig); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !cmp.Equal(storeConfig, *userMemStoreConfig(t)) {
+		t.Errorf("Values in storage configuration are not correct\n%s", cmp.Diff(storeConfig, *userMemStoreConfig(t)))
+	}
+}
+
 func TestLoadConfig_ReturnsConfig_UserSuppliedValues_Embedded(t *testing.T) {
 	file, err := ioutil.TempFile("", "config.*.yaml")
 	if err != nil {
@@ -199,16 +271,671 @@ func TestLoadConfig_ReturnsConfig_UserSuppliedValues_Embedded(t *testing.T) {
 	}
 }
 
+func userCacheConfig(t *testing.T) *CacheConfig {
+	t.Helper()
+	return &CacheConfig{
+		Notes:       500,
+		Occurrences: 2000,
+		TTL:         "30s",
+	}
+}
+
+func userConfig_cache_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+  storage_type: "embedded"
+  embedded:
+    path: /some/path
+  cache:
+    notes: 500
+    occurrences: 2000
+    ttl: "30s"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_Cache(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_cache_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.StorageType != "embedded" {
+		t.Errorf("Storage type %s is not embedded", cfg.StorageType)
+	}
+
+	if cfg.Cache == nil {
+		t.Fatalf("cache configuration is nil")
+	}
+
+	if !cmp.Equal(cfg.Cache, userCacheConfig(t)) {
+		t.Errorf("Values in cache configuration are not correct\n%s", cmp.Diff(cfg.Cache, userCacheConfig(t)))
+	}
+}
+
+func userConfig_storage_middleware_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+  storage_type: "memstore"
+  storage_middleware:
+    - type: latency
+    - type: retry
+      attempts: 3
+      backoff: "50ms"
+      codes: ["UNAVAILABLE"]
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_StorageMiddleware(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_storage_middleware_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	for _, m := range cfg.StorageMiddleware {
+		types = append(types, m.Type)
+	}
+	if want := []string{"latency", "retry"}; !cmp.Equal(types, want) {
+		t.Fatalf("Storage middleware types are not correct\n%s", cmp.Diff(types, want))
+	}
+
+	var retryConfig RetryMiddlewareConfig
+	if err = ConvertGenericConfigToSpecificType(cfg.StorageMiddleware[1].Config, &retryConfig); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	want := RetryMiddlewareConfig{Attempts: 3, Backoff: "50ms", Codes: []string{"UNAVAILABLE"}}
+	if !cmp.Equal(retryConfig, want) {
+		t.Errorf("Values in retry middleware configuration are not correct\n%s", cmp.Diff(retryConfig, want))
+	}
+}
+
+func userConfig_tracing_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+    tracing:
+      endpoint: "localhost:4317"
+      insecure: true
+      service_name: "grafeas-test"
+      sample_ratio: 0.25
+  storage_type: "memstore"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_Tracing(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_tracing_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &TracingConfig{
+		Endpoint:    "localhost:4317",
+		Insecure:    true,
+		ServiceName: "grafeas-test",
+		SampleRatio: 0.25,
+	}
+	if !cmp.Equal(cfg.API.Tracing, want) {
+		t.Errorf("Values in tracing configuration are not correct\n%s", cmp.Diff(cfg.API.Tracing, want))
+	}
+}
+
+func userConfig_log_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+    log:
+      level: "debug"
+      format: "text"
+  storage_type: "memstore"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_Log(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_log_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &LogConfig{Level: "debug", Format: "text"}
+	if !cmp.Equal(cfg.API.Log, want) {
+		t.Errorf("Values in log configuration are not correct\n%s", cmp.Diff(cfg.API.Log, want))
+	}
+}
+
+func userConfig_audit_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+    audit:
+      sink: "file"
+      path: "/var/log/grafeas/audit.log"
+  storage_type: "memstore"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_Audit(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_audit_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &AuditConfig{Sink: "file", Path: "/var/log/grafeas/audit.log"}
+	if !cmp.Equal(cfg.API.Audit, want) {
+		t.Errorf("Values in audit configuration are not correct\n%s", cmp.Diff(cfg.API.Audit, want))
+	}
+}
+
+func userConfig_rateLimits_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+    rate_limits:
+      principal:
+        read: {rate: 50, burst: 100}
+        write: {rate: 0.5}
+      project:
+        write: {rate: 20, burst: 40}
+    quotas:
+      max_notes: 1000
+      max_occurrences: 100000
+  storage_type: "memstore"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_RateLimits(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_rateLimits_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantLimits := &RateLimitsConfig{
+		Principal: &RateLimitConfig{
+			Read:  &TokenBucketConfig{Rate: 50, Burst: 100},
+			Write: &TokenBucketConfig{Rate: 0.5},
+		},
+		Project: &RateLimitConfig{
+			Write: &TokenBucketConfig{Rate: 20, Burst: 40},
+		},
+	}
+	if !cmp.Equal(cfg.API.RateLimits, wantLimits) {
+		t.Errorf("Values in rate limits configuration are not correct\n%s", cmp.Diff(cfg.API.RateLimits, wantLimits))
+	}
+	wantQuotas := &QuotasConfig{MaxNotes: 1000, MaxOccurrences: 100000}
+	if !cmp.Equal(cfg.API.Quotas, wantQuotas) {
+		t.Errorf("Values in quotas configuration are not correct\n%s", cmp.Diff(cfg.API.Quotas, wantQuotas))
+	}
+}
+
+func userConfig_apiLimits_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+    api_limits:
+      max_request_bytes: 1048576
+      max_description_length: 4096
+      max_related_urls: -1
+      default_page_size: 50
+      max_page_size: 10000
+      max_batch_size: 500
+      validation:
+        mode: warn
+        kinds:
+          vulnerability: enforce
+          spdx_file: "off"
+  storage_type: "memstore"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_APILimits(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_apiLimits_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &APILimitsConfig{
+		MaxRequestBytes:      1048576,
+		MaxDescriptionLength: 4096,
+		MaxRelatedURLs:       -1,
+		DefaultPageSize:      50,
+		MaxPageSize:          10000,
+		MaxBatchSize:         500,
+		Validation: &ValidationConfig{
+			Mode:  "warn",
+			Kinds: map[string]string{"vulnerability": "enforce", "spdx_file": "off"},
+		},
+	}
+	if !cmp.Equal(cfg.API.APILimits, want) {
+		t.Errorf("Values in API limits configuration are not correct\n%s", cmp.Diff(cfg.API.APILimits, want))
+	}
+}
+
+func userConfig_tls_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+    certfile: abc
+    keyfile: def
+    client_auth: "verify_if_given"
+    min_tls_version: "1.3"
+    cipher_suites:
+      - TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+      - TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+  storage_type: "memstore"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_TLS(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_tls_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &ServerConfig{
+		Address:       "0.0.0.0:8081",
+		CertFile:      "abc",
+		KeyFile:       "def",
+		ClientAuth:    "verify_if_given",
+		MinTLSVersion: "1.3",
+		CipherSuites: []string{
+			"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+			"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		},
+	}
+	if !cmp.Equal(cfg.API, want) {
+		t.Errorf("Values in TLS configuration are not correct\n%s", cmp.Diff(cfg.API, want))
+	}
+}
+
+func userConfig_listeners_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    grpc:
+      address: "0.0.0.0:8081"
+      certfile: abc
+      keyfile: def
+      cafile: ghi
+    rest:
+      address: "0.0.0.0:8082"
+      certfile: abc
+      keyfile: def
+      client_auth: "none"
+    admin:
+      address: "127.0.0.1:9090"
+  storage_type: "memstore"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_Listeners(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_listeners_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &ServerConfig{
+		GRPC:  &ListenerConfig{Address: "0.0.0.0:8081", CertFile: "abc", KeyFile: "def", CAFile: "ghi"},
+		REST:  &ListenerConfig{Address: "0.0.0.0:8082", CertFile: "abc", KeyFile: "def", ClientAuth: "none"},
+		Admin: &ListenerConfig{Address: "127.0.0.1:9090"},
+	}
+	if !cmp.Equal(cfg.API, want) {
+		t.Errorf("Values in listener configuration are not correct\n%s", cmp.Diff(cfg.API, want))
+	}
+}
+
+func userConfig_auth_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+    auth:
+      type: "mtls"
+      bindings:
+        - identities: ["spiffe://example.org/scanner"]
+          projects: ["scans"]
+          permissions: ["notes.create", "occurrences.*"]
+        - identities: ["*"]
+          projects: ["*"]
+          permissions: ["notes.get"]
+  storage_type: "memstore"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_Auth(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_auth_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &AuthConfig{
+		Type: "mtls",
+		Bindings: []AuthBinding{
+			{
+				Identities:  []string{"spiffe://example.org/scanner"},
+				Projects:    []string{"scans"},
+				Permissions: []string{"notes.create", "occurrences.*"},
+			},
+			{
+				Identities:  []string{"*"},
+				Projects:    []string{"*"},
+				Permissions: []string{"notes.get"},
+			},
+		},
+	}
+	if !cmp.Equal(cfg.API.Auth, want) {
+		t.Errorf("Values in auth configuration are not correct\n%s", cmp.Diff(cfg.API.Auth, want))
+	}
+}
+
+func userConfig_oidc_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+    auth:
+      type: "oidc"
+      bindings:
+        - identities: ["group:ci"]
+          projects: ["*"]
+          permissions: ["occurrences.create"]
+      issuers:
+        - issuer: "https://token.actions.githubusercontent.com"
+          audiences: ["grafeas"]
+          jwks_url: "https://token.actions.githubusercontent.com/.well-known/jwks"
+          user_claim: "repository"
+          groups_claim: "repository_owner"
+        - issuer: "https://idp.example.com"
+          jwks_file: "/etc/grafeas/jwks.json"
+  storage_type: "memstore"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_OIDC(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_oidc_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &AuthConfig{
+		Type: "oidc",
+		Bindings: []AuthBinding{{
+			Identities:  []string{"group:ci"},
+			Projects:    []string{"*"},
+			Permissions: []string{"occurrences.create"},
+		}},
+		Issuers: []OIDCIssuerConfig{
+			{
+				Issuer:      "https://token.actions.githubusercontent.com",
+				Audiences:   []string{"grafeas"},
+				JWKSURL:     "https://token.actions.githubusercontent.com/.well-known/jwks",
+				UserClaim:   "repository",
+				GroupsClaim: "repository_owner",
+			},
+			{
+				Issuer:   "https://idp.example.com",
+				JWKSFile: "/etc/grafeas/jwks.json",
+			},
+		},
+	}
+	if !cmp.Equal(cfg.API.Auth, want) {
+		t.Errorf("Values in auth configuration are not correct\n%s", cmp.Diff(cfg.API.Auth, want))
+	}
+}
+
+func userConfig_policyfile_yaml(t *testing.T) []byte {
+	t.Helper()
+	return []byte(`
+grafeas:
+  api:
+    address: "0.0.0.0:8081"
+    cafile: ca.crt
+    auth:
+      type: "mtls"
+      policy_file: "/etc/grafeas/policy.yaml"
+  storage_type: "memstore"
+`)
+}
+
+func TestLoadConfig_ReturnsConfig_UserSuppliedValues_PolicyFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "config.*.yaml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Write(userConfig_policyfile_yaml(t)); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err = file.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cfg, err := LoadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &AuthConfig{Type: "mtls", PolicyFile: "/etc/grafeas/policy.yaml"}
+	if !cmp.Equal(cfg.API.Auth, want) {
+		t.Errorf("Values in auth configuration are not correct\n%s", cmp.Diff(cfg.API.Auth, want))
+	}
+}
+
 // TODO(#341) move these 2 supporting functions and the test case to the new project
 func userPostgresConfig(t *testing.T) *PgSQLConfig {
 	t.Helper()
 	return &PgSQLConfig{
-		Host:          "127.0.0.1:5432",
-		DbName:        "postgres",
-		User:          "postgres",
-		Password:      "password",
-		SSLMode:       "require",
-		PaginationKey: "",
+		Host:             "127.0.0.1:5432",
+		DbName:           "postgres",
+		User:             "postgres",
+		Password:         "password",
+		SSLMode:          "require",
+		PaginationKey:    "",
+		SSLRootCert:      "/certs/ca.crt",
+		MaxOpenConns:     20,
+		MaxIdleConns:     5,
+		ConnMaxLifetime:  "1h",
+		StatementTimeout: "30s",
 	}
 }
 
@@ -232,6 +959,11 @@ grafeas:
     password: "password"
     sslmode: "require"
     paginationkey:
+    sslrootcert: "/certs/ca.crt"
+    maxopenconns: 20
+    maxidleconns: 5
+    connmaxlifetime: "1h"
+    statementtimeout: "30s"
 `)
 }
 
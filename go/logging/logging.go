@@ -0,0 +1,185 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging is the structured log of the Grafeas server. Its Logger implements the Logger
+// of the Grafeas API.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/grafeas/grafeas/go/config"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader is the metadata key, and HTTP header, of request IDs.
+const RequestIDHeader = "x-request-id"
+
+// Logger writes structured log entries. Entries logged with a context prepared by PrepareCtx
+// carry the project ID, request ID, RPC method and end-user ID of the call.
+type Logger struct {
+	logger    *zap.SugaredLogger
+	endUserID func(ctx context.Context) (string, error)
+}
+
+type fieldsKey struct{}
+
+type requestIDKey struct{}
+
+// New returns a Logger that writes to w as config says. A nil config logs info and higher levels
+// as JSON.
+func New(config *config.LogConfig, w io.Writer) (*Logger, error) {
+	var level, format string
+	if config != nil {
+		level, format = config.Level, config.Format
+	}
+
+	var l zapcore.Level
+	switch strings.ToLower(level) {
+	case "":
+		l = zapcore.InfoLevel
+	case "warning":
+		l = zapcore.WarnLevel
+	default:
+		if err := l.UnmarshalText([]byte(level)); err != nil || l > zapcore.ErrorLevel {
+			return nil, errors.New(fmt.Sprintf("unknown log level %q", level))
+		}
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = "time"
+	encoderConfig.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	var encoder zapcore.Encoder
+	switch strings.ToLower(format) {
+	case "", "json":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "text":
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown log format %q", format))
+	}
+
+	core := zapcore.NewCore(encoder, zapcore.Lock(zapcore.AddSync(w)), l)
+	// Skip the frame of Logger, so that entries carry the caller of its methods.
+	return &Logger{logger: zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1)).Sugar()}, nil
+}
+
+// SetEndUserID sets the function PrepareCtx looks up the end-user ID of a call with, typically the
+// EndUserID method of the Auth of the API.
+func (l *Logger) SetEndUserID(endUserID func(ctx context.Context) (string, error)) {
+	l.endUserID = endUserID
+}
+
+// RedirectStdLog sends the output of the standard library's log package to l, at info level. It
+// returns a function that restores the standard log.
+func (l *Logger) RedirectStdLog() func() {
+	return zap.RedirectStdLog(l.logger.Desugar().WithOptions(zap.AddCallerSkip(-1)))
+}
+
+// Sync flushes buffered log entries.
+func (l *Logger) Sync() error {
+	return l.logger.Sync()
+}
+
+// PrepareCtx returns ctx with the fields of the call it belongs to, which are added to the entries
+// logged with it: the project ID, the request ID, the gRPC method, the end-user ID and the trace
+// ID, as far as they are known.
+func (l *Logger) PrepareCtx(ctx context.Context, projectID string) context.Context {
+	fields := []interface{}{"project_id", projectID}
+	if id := RequestID(ctx); id != "" {
+		fields = append(fields, "request_id", id)
+	}
+	if method, ok := grpc.Method(ctx); ok {
+		fields = append(fields, "method", method)
+	}
+	if l.endUserID != nil {
+		if id, err := l.endUserID(ctx); err == nil && id != "" {
+			fields = append(fields, "end_user_id", id)
+		}
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields, "trace_id", sc.TraceID().String())
+	}
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// Info logs args at info level.
+func (l *Logger) Info(ctx context.Context, args ...interface{}) {
+	l.with(ctx).Info(args...)
+}
+
+// Infof logs a formatted message at info level.
+func (l *Logger) Infof(ctx context.Context, format string, args ...interface{}) {
+	l.with(ctx).Infof(format, args...)
+}
+
+// Warning logs args at warning level.
+func (l *Logger) Warning(ctx context.Context, args ...interface{}) {
+	l.with(ctx).Warn(args...)
+}
+
+// Warningf logs a formatted message at warning level.
+func (l *Logger) Warningf(ctx context.Context, format string, args ...interface{}) {
+	l.with(ctx).Warnf(format, args...)
+}
+
+// Error logs args at error level.
+func (l *Logger) Error(ctx context.Context, args ...interface{}) {
+	l.with(ctx).Error(args...)
+}
+
+// Errorf logs a formatted message at error level.
+func (l *Logger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	l.with(ctx).Errorf(format, args...)
+}
+
+// with returns the logger of the call ctx belongs to.
+func (l *Logger) with(ctx context.Context) *zap.SugaredLogger {
+	if fields, ok := ctx.Value(fieldsKey{}).([]interface{}); ok {
+		return l.logger.With(fields...)
+	}
+	return l.logger
+}
+
+// RequestID returns the request ID of the call ctx belongs to, as assigned by
+// RequestIDInterceptor, or "" if it has none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDInterceptor assigns every unary RPC a request ID, which is returned in the response
+// header. Calls keep the request ID of their request metadata, if any.
+func RequestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(RequestIDHeader); len(v) > 0 {
+			id = v[0]
+		}
+	}
+	if id == "" {
+		id = uuid.New().String()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+	return handler(context.WithValue(ctx, requestIDKey{}, id), req)
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// entries decodes the JSON entries written to buf.
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var es []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("entry %q is not JSON: %s", line, err)
+		}
+		es = append(es, e)
+	}
+	return es
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(nil, &buf)
+	if err != nil {
+		t.Fatalf("New got %v want success", err)
+	}
+	l.SetEndUserID(func(context.Context) (string, error) { return "alice", nil })
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDHeader, "req-1"))
+	_, err = RequestIDInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/grafeas.v1beta1.GrafeasV1Beta1/CreateOccurrence"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		ctx = l.PrepareCtx(ctx, "p")
+		l.Warningf(ctx, "invalid occurrence %q, fail open", "o")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("RequestIDInterceptor got %v want success", err)
+	}
+	l.Info(context.Background(), "no call")
+
+	es := entries(t, &buf)
+	if len(es) != 2 {
+		t.Fatalf("Logger wrote %d entries want 2:\n%s", len(es), buf.String())
+	}
+	want := map[string]interface{}{
+		"level":       "warn",
+		"msg":         `invalid occurrence "o", fail open`,
+		"project_id":  "p",
+		"request_id":  "req-1",
+		"end_user_id": "alice",
+	}
+	for k, v := range want {
+		if es[0][k] != v {
+			t.Errorf("entry[%s] got %v want %v", k, es[0][k], v)
+		}
+	}
+	if _, ok := es[1]["project_id"]; ok {
+		t.Errorf("entry without prepared context got project_id %v want none", es[1]["project_id"])
+	}
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&config.LogConfig{Level: "warning"}, &buf)
+	if err != nil {
+		t.Fatalf("New got %v want success", err)
+	}
+	l.Info(context.Background(), "dropped")
+	l.Error(context.Background(), "kept")
+	if es := entries(t, &buf); len(es) != 1 || es[0]["msg"] != "kept" {
+		t.Errorf("Logger at warning level wrote %v want only the error", es)
+	}
+
+	buf.Reset()
+	l, err = New(&config.LogConfig{Format: "text"}, &buf)
+	if err != nil {
+		t.Fatalf("New got %v want success", err)
+	}
+	l.Info(context.Background(), "plain")
+	if got := buf.String(); !strings.Contains(got, "\tinfo\t") || strings.HasPrefix(got, "{") {
+		t.Errorf("Logger in text format wrote %q want a text entry", got)
+	}
+
+	for _, c := range []*config.LogConfig{{Level: "verbose"}, {Level: "fatal"}, {Format: "xml"}} {
+		if _, err := New(c, &buf); err == nil {
+			t.Errorf("New(%+v) got success want error", c)
+		}
+	}
+}
+
+func TestRequestIDInterceptor(t *testing.T) {
+	var id string
+	if _, err := RequestIDInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		id = RequestID(ctx)
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("RequestIDInterceptor got %v want success", err)
+	}
+	if id == "" {
+		t.Errorf("RequestID got none want a generated request ID")
+	}
+}
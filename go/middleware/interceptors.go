@@ -0,0 +1,242 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafeas/grafeas/go/config"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultLatencyBuckets are the histogram buckets of the latency middleware in seconds, if the
+// configuration does not say.
+var DefaultLatencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Latency is the latency middleware. It records a histogram of the latency of each storage method,
+// which the server exports as a Prometheus metric when metrics are enabled.
+type Latency struct {
+	buckets    []float64
+	mu         sync.Mutex
+	histograms map[string]*Histogram
+}
+
+// Histogram is a latency histogram.
+type Histogram struct {
+	// Buckets are the upper bounds of the buckets in seconds, and Counts[i] is the number of calls
+	// that took at most Buckets[i] seconds.
+	Buckets []float64
+	Counts  []uint64
+	// Count is the number of calls, and Sum the total time they took in seconds.
+	Count uint64
+	Sum   float64
+}
+
+// NewLatency returns a latency middleware with the given buckets, in seconds.
+func NewLatency(buckets []float64) *Latency {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Latency{
+		buckets:    buckets,
+		histograms: map[string]*Histogram{},
+	}
+}
+
+// Intercept records the latency of call.
+func (l *Latency) Intercept(ctx context.Context, method string, call func(ctx context.Context) error) error {
+	start := time.Now()
+	err := call(ctx)
+	l.observe(method, time.Since(start).Seconds())
+	return err
+}
+
+func (l *Latency) observe(method string, seconds float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.histograms[method]
+	if !ok {
+		h = &Histogram{Buckets: l.buckets, Counts: make([]uint64, len(l.buckets))}
+		l.histograms[method] = h
+	}
+	for i := len(l.buckets) - 1; i >= 0 && seconds <= l.buckets[i]; i-- {
+		h.Counts[i]++
+	}
+	h.Count++
+	h.Sum += seconds
+}
+
+// Histograms returns a copy of the histograms of the methods called so far, by method name.
+func (l *Latency) Histograms() map[string]Histogram {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hs := make(map[string]Histogram, len(l.histograms))
+	for method, h := range l.histograms {
+		c := *h
+		c.Counts = append([]uint64(nil), h.Counts...)
+		hs[method] = c
+	}
+	return hs
+}
+
+func latencyMiddlewareProvider(middlewareConfig *config.StorageConfiguration) (Interceptor, error) {
+	var c config.LatencyMiddlewareConfig
+	if err := config.ConvertGenericConfigToSpecificType(middlewareConfig, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Buckets) == 0 {
+		c.Buckets = DefaultLatencyBuckets
+	}
+	return NewLatency(c.Buckets), nil
+}
+
+// SlowLog returns a middleware that logs calls that take longer than threshold.
+func SlowLog(threshold time.Duration) Interceptor {
+	return InterceptorFunc(func(ctx context.Context, method string, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		if d := time.Since(start); d > threshold {
+			log.Printf("Slow storage call %s took %s, error: %v", method, d, err)
+		}
+		return err
+	})
+}
+
+func slowLogMiddlewareProvider(middlewareConfig *config.StorageConfiguration) (Interceptor, error) {
+	var c config.SlowLogMiddlewareConfig
+	if err := config.ConvertGenericConfigToSpecificType(middlewareConfig, &c); err != nil {
+		return nil, err
+	}
+	threshold, err := parseDuration("threshold", c.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	if threshold <= 0 {
+		return nil, fmt.Errorf("threshold must be set")
+	}
+	return SlowLog(threshold), nil
+}
+
+// Retry returns a middleware that retries calls failing with any of codes, up to attempts calls
+// in total, waiting backoff before the first retry and twice as long before each further one.
+// Calls that change storage are only retried if writes is true.
+func Retry(attempts int, backoff time.Duration, retryCodes []codes.Code, writes bool) Interceptor {
+	retryable := map[codes.Code]bool{}
+	for _, c := range retryCodes {
+		retryable[c] = true
+	}
+	return InterceptorFunc(func(ctx context.Context, method string, call func(ctx context.Context) error) error {
+		wait := backoff
+		for attempt := 1; ; attempt++ {
+			err := call(ctx)
+			if err == nil || attempt >= attempts || !retryable[status.Code(err)] || (IsWrite(method) && !writes) {
+				return err
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return err
+			}
+			wait *= 2
+		}
+	})
+}
+
+func retryMiddlewareProvider(middlewareConfig *config.StorageConfiguration) (Interceptor, error) {
+	var c config.RetryMiddlewareConfig
+	if err := config.ConvertGenericConfigToSpecificType(middlewareConfig, &c); err != nil {
+		return nil, err
+	}
+	if c.Attempts == 0 {
+		c.Attempts = 3
+	}
+	if c.Attempts < 1 {
+		return nil, fmt.Errorf("invalid attempts %d", c.Attempts)
+	}
+	backoff, err := parseDuration("backoff", c.Backoff)
+	if err != nil {
+		return nil, err
+	}
+	if c.Backoff == "" {
+		backoff = 100 * time.Millisecond
+	}
+	retryCodes := []codes.Code{codes.Unavailable, codes.Aborted}
+	if len(c.Codes) > 0 {
+		retryCodes = nil
+		for _, name := range c.Codes {
+			code, err := parseCode(name)
+			if err != nil {
+				return nil, err
+			}
+			retryCodes = append(retryCodes, code)
+		}
+	}
+	return Retry(c.Attempts, backoff, retryCodes, c.Writes), nil
+}
+
+// Faults returns a middleware for testing that delays calls of methods by delay, and fails
+// errorRate of them with code instead of calling storage. If methods is empty, all methods are
+// affected.
+func Faults(errorRate float64, code codes.Code, delay time.Duration, methods []string) Interceptor {
+	affected := map[string]bool{}
+	for _, m := range methods {
+		affected[m] = true
+	}
+	return InterceptorFunc(func(ctx context.Context, method string, call func(ctx context.Context) error) error {
+		if len(affected) > 0 && !affected[method] {
+			return call(ctx)
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return status.FromContextError(ctx.Err()).Err()
+			}
+		}
+		if errorRate > 0 && rand.Float64() < errorRate {
+			return status.Errorf(code, "injected fault in %s", method)
+		}
+		return call(ctx)
+	})
+}
+
+func faultMiddlewareProvider(middlewareConfig *config.StorageConfiguration) (Interceptor, error) {
+	var c config.FaultMiddlewareConfig
+	if err := config.ConvertGenericConfigToSpecificType(middlewareConfig, &c); err != nil {
+		return nil, err
+	}
+	if c.ErrorRate < 0 || c.ErrorRate > 1 {
+		return nil, fmt.Errorf("invalid errorrate %v, must be between 0 and 1", c.ErrorRate)
+	}
+	code := codes.Unavailable
+	if c.Code != "" {
+		var err error
+		if code, err = parseCode(c.Code); err != nil {
+			return nil, err
+		}
+	}
+	delay, err := parseDuration("delay", c.Delay)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Injecting storage faults: errorrate %v, code %s, delay %s", c.ErrorRate, code, delay)
+	return Faults(c.ErrorRate, code, delay, c.Methods), nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package middleware provides interceptors that run around every storage call, e.g. to measure
+// latency or retry transient errors, independent of the API version. The storage packages of
+// each API version wrap storage with them.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafeas/grafeas/go/config"
+	"google.golang.org/grpc/codes"
+)
+
+// Interceptor runs around storage calls.
+type Interceptor interface {
+	// Intercept handles a call of the storage method named method, e.g. "GetNote". It usually
+	// calls call, possibly with a different context, and returns its error.
+	Intercept(ctx context.Context, method string, call func(ctx context.Context) error) error
+}
+
+// InterceptorFunc is a function that implements Interceptor.
+type InterceptorFunc func(ctx context.Context, method string, call func(ctx context.Context) error) error
+
+// Intercept calls f.
+func (f InterceptorFunc) Intercept(ctx context.Context, method string, call func(ctx context.Context) error) error {
+	return f(ctx, method, call)
+}
+
+// chain is an Interceptor that runs its interceptors in order, the first being the outermost.
+type chain []Interceptor
+
+// Chain returns an Interceptor that runs interceptors in order, the first being the outermost.
+func Chain(interceptors ...Interceptor) Interceptor {
+	if len(interceptors) == 1 {
+		return interceptors[0]
+	}
+	return chain(interceptors)
+}
+
+func (c chain) Intercept(ctx context.Context, method string, call func(ctx context.Context) error) error {
+	for i := len(c) - 1; i >= 0; i-- {
+		i, next := c[i], call
+		call = func(ctx context.Context) error {
+			return i.Intercept(ctx, method, next)
+		}
+	}
+	return call(ctx)
+}
+
+// Interceptors returns the interceptors in i, looking through chains.
+func Interceptors(i Interceptor) []Interceptor {
+	c, ok := i.(chain)
+	if !ok {
+		return []Interceptor{i}
+	}
+	var is []Interceptor
+	for _, i := range c {
+		is = append(is, Interceptors(i)...)
+	}
+	return is
+}
+
+// IsWrite returns whether the storage method named method changes storage.
+func IsWrite(method string) bool {
+	return !strings.HasPrefix(method, "Get") && !strings.HasPrefix(method, "List")
+}
+
+var registeredMiddlewareProviders = map[string]func(middlewareConfig *config.StorageConfiguration) (Interceptor, error){}
+
+// RegisterMiddlewareProvider registers a new provider to create a specific type of middleware.
+func RegisterMiddlewareProvider(middlewareType string, provider func(middlewareConfig *config.StorageConfiguration) (Interceptor, error)) error {
+	if _, present := registeredMiddlewareProviders[middlewareType]; present {
+		return errors.New(fmt.Sprintf("Middleware provider %s already exists", middlewareType))
+	}
+	registeredMiddlewareProviders[middlewareType] = provider
+	return nil
+}
+
+// RegisterDefaultMiddlewareProviders adds support for the latency, slowlog, retry and faults
+// middleware types.
+func RegisterDefaultMiddlewareProviders() error {
+	for middlewareType, provider := range map[string]func(*config.StorageConfiguration) (Interceptor, error){
+		"latency": latencyMiddlewareProvider,
+		"slowlog": slowLogMiddlewareProvider,
+		"retry":   retryMiddlewareProvider,
+		"faults":  faultMiddlewareProvider,
+	} {
+		if err := RegisterMiddlewareProvider(middlewareType, provider); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// New creates the middleware in configs and returns an Interceptor that runs them in order, or
+// nil if configs is empty.
+func New(configs []config.MiddlewareConfig) (Interceptor, error) {
+	var interceptors []Interceptor
+	for _, c := range configs {
+		provider, present := registeredMiddlewareProviders[c.Type]
+		if !present {
+			return nil, errors.New(fmt.Sprintf("Unsupported middleware type %s", c.Type))
+		}
+		middlewareConfig := c.Config
+		i, err := provider(&middlewareConfig)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Unable to create %s middleware, %s", c.Type, err))
+		}
+		interceptors = append(interceptors, i)
+	}
+	if len(interceptors) == 0 {
+		return nil, nil
+	}
+	return Chain(interceptors...), nil
+}
+
+// parseCode parses a gRPC code name, e.g. "UNAVAILABLE" or "Unavailable".
+func parseCode(name string) (codes.Code, error) {
+	want := strings.ReplaceAll(name, "_", "")
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.EqualFold(c.String(), want) {
+			return c, nil
+		}
+	}
+	return codes.Unknown, fmt.Errorf("unknown code %q", name)
+}
+
+// parseDuration parses d, which may be empty.
+func parseDuration(key, d string) (dur time.Duration, err error) {
+	if d == "" {
+		return 0, nil
+	}
+	if dur, err = time.ParseDuration(d); err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", key, d, err)
+	}
+	return dur, nil
+}
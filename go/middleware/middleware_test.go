@@ -0,0 +1,210 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafeas/grafeas/go/config"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	if err := RegisterDefaultMiddlewareProviders(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// calls returns a storage call that fails with errs in turn, then succeeds, and counts its calls.
+func calls(n *int, errs ...error) func(context.Context) error {
+	return func(context.Context) error {
+		*n++
+		if *n <= len(errs) {
+			return errs[*n-1]
+		}
+		return nil
+	}
+}
+
+func TestChain(t *testing.T) {
+	var got []string
+	record := func(name string) Interceptor {
+		return InterceptorFunc(func(ctx context.Context, method string, call func(context.Context) error) error {
+			got = append(got, name+" "+method)
+			return call(ctx)
+		})
+	}
+	c := Chain(record("a"), Chain(record("b"), record("c")))
+	var n int
+	if err := c.Intercept(context.Background(), "GetNote", calls(&n)); err != nil || n != 1 {
+		t.Fatalf("Intercept got %v after %d calls, want success after 1 call", err, n)
+	}
+	if want := []string{"a GetNote", "b GetNote", "c GetNote"}; !cmp.Equal(got, want) {
+		t.Errorf("Interceptors ran in the wrong order\n%s", cmp.Diff(got, want))
+	}
+	if got := len(Interceptors(c)); got != 3 {
+		t.Errorf("Interceptors got %d interceptors want 3", got)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	r := Retry(3, time.Millisecond, []codes.Code{codes.Unavailable}, false)
+
+	tests := []struct {
+		desc      string
+		method    string
+		errs      []error
+		wantCode  codes.Code
+		wantCalls int
+	}{
+		{"transient error", "GetNote", []error{unavailable, unavailable}, codes.OK, 3},
+		{"too many transient errors", "GetNote", []error{unavailable, unavailable, unavailable}, codes.Unavailable, 3},
+		{"permanent error", "GetNote", []error{status.Error(codes.NotFound, "not found")}, codes.NotFound, 1},
+		{"write", "CreateNote", []error{unavailable}, codes.Unavailable, 1},
+	}
+	for _, tt := range tests {
+		var n int
+		err := r.Intercept(ctx, tt.method, calls(&n, tt.errs...))
+		if status.Code(err) != tt.wantCode || n != tt.wantCalls {
+			t.Errorf("%s: Intercept got %v after %d calls, want %v after %d calls", tt.desc, err, n, tt.wantCode, tt.wantCalls)
+		}
+	}
+
+	var n int
+	w := Retry(3, time.Millisecond, []codes.Code{codes.Unavailable}, true)
+	if err := w.Intercept(ctx, "CreateNote", calls(&n, unavailable)); err != nil || n != 2 {
+		t.Errorf("Intercept with writes got %v after %d calls, want success after 2 calls", err, n)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	ctx := context.Background()
+	f := Faults(1, codes.Aborted, 0, []string{"GetNote"})
+
+	var n int
+	if err := f.Intercept(ctx, "GetNote", calls(&n)); status.Code(err) != codes.Aborted || n != 0 {
+		t.Errorf("Intercept(GetNote) got %v after %d calls, want Aborted after 0 calls", err, n)
+	}
+	if err := f.Intercept(ctx, "ListNotes", calls(&n)); err != nil || n != 1 {
+		t.Errorf("Intercept(ListNotes) got %v after %d calls, want success after 1 call", err, n)
+	}
+
+	d := Faults(0, codes.Unavailable, 20*time.Millisecond, nil)
+	start := time.Now()
+	if err := d.Intercept(ctx, "GetNote", calls(&n)); err != nil {
+		t.Errorf("Intercept got %v want success", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Intercept took %s want at least 20ms", elapsed)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	l := NewLatency([]float64{10, 0.01})
+	var n int
+	for i := 0; i < 2; i++ {
+		if err := l.Intercept(context.Background(), "GetNote", calls(&n)); err != nil {
+			t.Fatalf("Intercept got %v want success", err)
+		}
+	}
+	if err := l.Intercept(context.Background(), "ListNotes", func(context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}); err != nil {
+		t.Fatalf("Intercept got %v want success", err)
+	}
+
+	hs := l.Histograms()
+	want := map[string][]uint64{"GetNote": {2, 2}, "ListNotes": {0, 1}}
+	for method, counts := range want {
+		h := hs[method]
+		if !cmp.Equal(h.Buckets, []float64{0.01, 10}) || !cmp.Equal(h.Counts, counts) || h.Count != counts[1] {
+			t.Errorf("Histograms()[%s] got %+v want counts %v", method, h, counts)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	var generic []interface{}
+	if err := config.ConvertGenericConfigToSpecificType([]map[string]interface{}{
+		{"type": "latency"},
+		{"type": "slowlog", "threshold": "1s"},
+		{"type": "retry", "attempts": 2, "codes": []string{"DEADLINE_EXCEEDED"}},
+		{"type": "faults", "errorrate": 0.5},
+	}, &generic); err != nil {
+		t.Fatalf("%s", err)
+	}
+	var configs []config.MiddlewareConfig
+	for _, g := range generic {
+		configs = append(configs, config.MiddlewareConfig{Type: g.(map[string]interface{})["type"].(string), Config: g})
+	}
+	i, err := New(configs)
+	if err != nil {
+		t.Fatalf("New got %v want success", err)
+	}
+	is := Interceptors(i)
+	if len(is) != 4 {
+		t.Fatalf("New got %d interceptors want 4", len(is))
+	}
+	if _, ok := is[0].(*Latency); !ok {
+		t.Errorf("New got %T want *Latency first", is[0])
+	}
+
+	if i, err := New(nil); i != nil || err != nil {
+		t.Errorf("New(nil) got %v, %v want nil, nil", i, err)
+	}
+	for _, c := range []config.MiddlewareConfig{
+		{Type: "unknown"},
+		{Type: "slowlog"},
+		{Type: "retry", Config: map[string]interface{}{"codes": []string{"SOMETIMES"}}},
+		{Type: "faults", Config: map[string]interface{}{"errorrate": 2}},
+	} {
+		if _, err := New([]config.MiddlewareConfig{c}); err == nil {
+			t.Errorf("New(%+v) got success want error", c)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	tr := Tracing("memstore")
+	var n int
+	if err := tr.Intercept(context.Background(), "GetNote", calls(&n, status.Error(codes.NotFound, "not found"))); status.Code(err) != codes.NotFound {
+		t.Fatalf("Intercept got %v want NotFound", err)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Intercept recorded %d spans want 1", len(spans))
+	}
+	if s := spans[0]; s.Name() != "storage.GetNote" || s.Status().Code != otelcodes.Error {
+		t.Errorf("Intercept recorded span %s with status %v, want storage.GetNote with an error", s.Name(), s.Status())
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+// Tracing returns an interceptor that records an OpenTelemetry span for every storage call, with
+// the tracer provider registered with otel.SetTracerProvider. Backend names the storage type.
+func Tracing(backend string) Interceptor {
+	tracer := otel.Tracer("github.com/grafeas/grafeas/go/middleware")
+	return InterceptorFunc(func(ctx context.Context, method string, call func(ctx context.Context) error) error {
+		ctx, span := tracer.Start(ctx, "storage."+method, trace.WithAttributes(
+			attribute.String("grafeas.storage.backend", backend),
+			attribute.String("grafeas.storage.method", method),
+		))
+		defer span.End()
+		err := call(ctx)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, status.Convert(err).Message())
+			span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err))))
+		}
+		return err
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafeas/grafeas/go/middleware"
+	"github.com/grafeas/grafeas/go/v1/api"
+	gpb "github.com/grafeas/grafeas/proto/v1/grafeas_go_proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyStorage fails GetNote with Unavailable a number of times before it succeeds. Its other
+// methods are not implemented.
+type flakyStorage struct {
+	grafeas.Storage
+	failures int
+	calls    int
+}
+
+func (s *flakyStorage) GetNote(ctx context.Context, pID, nID string) (*gpb.Note, error) {
+	s.calls++
+	if s.calls <= s.failures {
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	}
+	return &gpb.Note{Name: "projects/" + pID + "/notes/" + nID}, nil
+}
+
+func TestInterceptedStore(t *testing.T) {
+	f := &flakyStorage{failures: 2}
+	latency := middleware.NewLatency(middleware.DefaultLatencyBuckets)
+	s := NewInterceptedStore(f, middleware.Chain(latency, middleware.Retry(3, time.Millisecond, []codes.Code{codes.Unavailable}, false)))
+
+	n, err := s.GetNote(context.Background(), "p", "n")
+	if err != nil || n.Name != "projects/p/notes/n" {
+		t.Fatalf("GetNote got %v, %v want projects/p/notes/n", n, err)
+	}
+	if f.calls != 3 {
+		t.Errorf("GetNote called storage %d times want 3", f.calls)
+	}
+	if h := latency.Histograms()["GetNote"]; h.Count != 1 {
+		t.Errorf("Histograms()[GetNote] got %+v want 1 call", h)
+	}
+	if s.Unwrap() != grafeas.Storage(f) {
+		t.Errorf("Unwrap got %v want the wrapped storage", s.Unwrap())
+	}
+
+	w, err := WithMiddleware(f, nil)
+	if err != nil || w != grafeas.Storage(f) {
+		t.Errorf("WithMiddleware(nil) got %v, %v want the storage unchanged", w, err)
+	}
+}
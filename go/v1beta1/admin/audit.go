@@ -0,0 +1,46 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/grafeas/grafeas/go/v1beta1/audit"
+)
+
+// runVerifyAudit checks that the entries of an audit log file have not been changed, removed or
+// reordered since they were written.
+func runVerifyAudit(args []string) error {
+	fs := newFlagSet("verify-audit")
+	in := fs.String("in", "", "Audit log file to verify")
+	fs.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	n, err := audit.Verify(f)
+	if err != nil {
+		return err
+	}
+	log.Printf("verified %d entries of %s", n, *in)
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grafeas/grafeas/go/v1beta1/server"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+)
+
+// runBackup downloads a backup from the backup endpoint of a running server.
+func runBackup(args []string) error {
+	fs := newFlagSet("backup")
+	addr := fs.String("server", "https://localhost:9090", "URL of the admin listener of the Grafeas server")
+	out := fs.String("out", "grafeas.db", "File to write the backup to")
+	certFile := fs.String("certfile", "", "PEM encoded client certificate file")
+	keyFile := fs.String("keyfile", "", "PEM encoded client private key file")
+	caFile := fs.String("cafile", "", "PEM encoded CA certificate file used to verify the server")
+	fs.Parse(args)
+
+	tlsConfig := &tls.Config{}
+	if *certFile != "" || *keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate files: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if *caFile != "" {
+		caCert, err := ioutil.ReadFile(*caFile)
+		if err != nil {
+			return err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		tlsConfig.RootCAs.AppendCertsFromPEM(caCert)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+	resp, err := client.Get(strings.TrimSuffix(*addr, "/") + server.BackupPath)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	// Write to a temporary file first so that a failed backup does not replace a previous one.
+	tmp, err := ioutil.TempFile(filepath.Dir(*out), filepath.Base(*out)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, resp.Body)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("backup interrupted after %d bytes: %v", n, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := storage.CheckEmbeddedStoreBackup(tmp.Name()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), *out); err != nil {
+		return err
+	}
+	log.Printf("wrote %d byte backup to %s", n, *out)
+	return nil
+}
+
+// runRestore replaces the embedded store with a backup.
+func runRestore(args []string) error {
+	fs := newFlagSet("restore")
+	path := fs.String("path", "", "Embedded store directory")
+	configFile := fs.String("config", "", "Grafeas config file to read the embedded store directory from, if -path is not set")
+	in := fs.String("in", "", "Backup file to restore")
+	fs.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	storeConfig, err := embeddedStoreConfig(*path, *configFile)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := storage.RestoreEmbeddedStore(storeConfig, f); err != nil {
+		return err
+	}
+	log.Printf("restored %s to %s", *in, storeConfig.Path)
+	return nil
+}
+
+// runCompact compacts the embedded store.
+func runCompact(args []string) error {
+	fs := newFlagSet("compact")
+	path := fs.String("path", "", "Embedded store directory")
+	configFile := fs.String("config", "", "Grafeas config file to read the embedded store directory from, if -path is not set")
+	fs.Parse(args)
+
+	storeConfig, err := embeddedStoreConfig(*path, *configFile)
+	if err != nil {
+		return err
+	}
+	before, after, err := storage.CompactEmbeddedStore(storeConfig)
+	if err != nil {
+		return err
+	}
+	log.Printf("compacted %s from %d to %d bytes", storeConfig.Path, before, after)
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"log"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/dump"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	"golang.org/x/net/context"
+)
+
+// runExport writes the contents of the configured storage to a dump.
+func runExport(args []string) error {
+	fs := newFlagSet("export")
+	configFile := fs.String("config", "", "Grafeas config file of the storage to export")
+	out := fs.String("out", "", "Directory to write the dump to")
+	fs.Parse(args)
+
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+	s, err := openStorage(*configFile)
+	if err != nil {
+		return err
+	}
+	defer s.Gs.Close()
+	m, err := dump.Export(context.Background(), s, s.Gs, *out)
+	if err != nil {
+		return err
+	}
+	log.Printf("exported %d projects, %d notes and %d occurrences to %s", m.Projects, m.Notes, m.Occurrences, *out)
+	return nil
+}
+
+// runImport stores the contents of a dump in the configured storage.
+func runImport(args []string) error {
+	fs := newFlagSet("import")
+	configFile := fs.String("config", "", "Grafeas config file of the storage to import into")
+	in := fs.String("in", "", "Directory holding the dump")
+	fs.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	s, err := openStorage(*configFile)
+	if err != nil {
+		return err
+	}
+	m, err := dump.Import(context.Background(), s, s.Gs, *in)
+	// Stores such as a MemStore with a snapshot path only persist the import when closed.
+	if cerr := s.Gs.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("imported %d projects, %d notes and %d occurrences from %s", m.Projects, m.Notes, m.Occurrences, *in)
+	return nil
+}
+
+// openStorage creates the storage configured in configFile. The caller closes it.
+func openStorage(configFile string) (*storage.Storage, error) {
+	if configFile == "" {
+		return nil, errors.New("-config is required")
+	}
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.RegisterDefaultStorageTypeProviders(); err != nil {
+		return nil, err
+	}
+	return storage.CreateStorageOfType(cfg.StorageType, cfg.StorageConfig)
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command grafeas-admin performs maintenance tasks on Grafeas storage.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/grafeas/grafeas/go/config"
+)
+
+// command is a grafeas-admin subcommand.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"backup", "take a backup of the embedded store of a running server", runBackup},
+	{"restore", "replace the embedded store with a backup (server must be stopped)", runRestore},
+	{"compact", "rewrite the embedded store to reclaim space (server must be stopped)", runCompact},
+	{"export", "write all projects, notes and occurrences to a backend-neutral dump", runExport},
+	{"import", "store the contents of a dump, keeping names and timestamps", runImport},
+	{"verify-audit", "check the hash chain of an audit log file", runVerifyAudit},
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("grafeas-admin: ")
+	if len(os.Args) > 1 {
+		for _, c := range commands {
+			if c.name == os.Args[1] {
+				if err := c.run(os.Args[2:]); err != nil {
+					log.Fatalf("%s: %v", c.name, err)
+				}
+				return
+			}
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: grafeas-admin <command> [flags]\n\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun grafeas-admin <command> -h for the flags of a command.")
+	os.Exit(2)
+}
+
+// newFlagSet returns the flag set of the named command.
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("grafeas-admin "+name, flag.ExitOnError)
+}
+
+// embeddedStoreConfig returns the embedded store configuration given by either the path flag or
+// the embedded section of the config file.
+func embeddedStoreConfig(path, configFile string) (*config.EmbeddedStoreConfig, error) {
+	if path != "" {
+		return &config.EmbeddedStoreConfig{Path: path}, nil
+	}
+	if configFile == "" {
+		return nil, errors.New("one of -path or -config is required")
+	}
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.StorageType != "embedded" || cfg.StorageConfig == nil {
+		return nil, fmt.Errorf("%s does not configure embedded storage", configFile)
+	}
+	var storeConfig config.EmbeddedStoreConfig
+	if err := config.ConvertGenericConfigToSpecificType(cfg.StorageConfig, &storeConfig); err != nil {
+		return nil, err
+	}
+	return &storeConfig, nil
+}
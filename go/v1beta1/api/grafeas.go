@@ -17,8 +17,10 @@ package grafeas
 
 import (
 	"github.com/grafeas/grafeas/go/iam"
+	"github.com/grafeas/grafeas/go/v1beta1/api/validators/grafeas"
 	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
 	"golang.org/x/net/context"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
 	fieldmaskpb "google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -56,12 +58,52 @@ const (
 	// NotesAttachOccurrence is the permission to attach occurrences for a note you own.
 	NotesAttachOccurrence = iam.Permission("notes.attachOccurrence")
 
+	// NotesGetIamPolicy is the permission to get the IAM policy of a note.
+	NotesGetIamPolicy = iam.Permission("notes.getIamPolicy")
+	// NotesSetIamPolicy is the permission to set the IAM policy of a note.
+	NotesSetIamPolicy = iam.Permission("notes.setIamPolicy")
+	// OccurrencesGetIamPolicy is the permission to get the IAM policy of an occurrence.
+	OccurrencesGetIamPolicy = iam.Permission("occurrences.getIamPolicy")
+	// OccurrencesSetIamPolicy is the permission to set the IAM policy of an occurrence.
+	OccurrencesSetIamPolicy = iam.Permission("occurrences.setIamPolicy")
+
+	// AuditEntriesList is the permission to list the audit entries of a project.
+	AuditEntriesList = iam.Permission("auditEntries.list")
+
 	// Notes is the resource type for notes.
 	Notes = iam.Resource("notes")
 	// Occurrences is the resource type for occurrences.
 	Occurrences = iam.Resource("occurrences")
 )
 
+// Permissions are the permissions of this API.
+var Permissions = []iam.Permission{
+	NotesGet, NotesList, NotesCreate, NotesUpdate, NotesDelete,
+	NotesListOccurrences, NotesAttachOccurrence, NotesGetIamPolicy, NotesSetIamPolicy,
+	OccurrencesGet, OccurrencesList, OccurrencesCreate, OccurrencesUpdate, OccurrencesDelete,
+	OccurrencesGetIamPolicy, OccurrencesSetIamPolicy, AuditEntriesList,
+}
+
+// Roles are the predefined roles, which bundle permissions, by name.
+var Roles = map[string][]iam.Permission{
+	"viewer": {
+		NotesGet, NotesList, NotesListOccurrences, OccurrencesGet, OccurrencesList,
+	},
+	"note-attacher": {
+		NotesGet, NotesAttachOccurrence,
+	},
+	"occurrence-writer": {
+		NotesGet, NotesList, NotesListOccurrences, NotesAttachOccurrence,
+		OccurrencesGet, OccurrencesList, OccurrencesCreate, OccurrencesUpdate, OccurrencesDelete,
+	},
+	"note-owner": {
+		NotesGet, NotesList, NotesCreate, NotesUpdate, NotesDelete,
+		NotesListOccurrences, NotesAttachOccurrence, NotesGetIamPolicy, NotesSetIamPolicy,
+		OccurrencesGet, OccurrencesList,
+	},
+	"admin": Permissions,
+}
+
 // Storage provides storage functions for this API.
 type Storage interface {
 	// GetOccurrence gets the specified occurrence from storage.
@@ -96,6 +138,34 @@ type Storage interface {
 	ListNoteOccurrences(ctx context.Context, projectID, nID, filter, pageToken string, pageSize int32) ([]*gpb.Occurrence, string, error)
 	// GetVulnerabilityOccurrencesSummary gets a summary of vulnerability occurrences from storage.
 	GetVulnerabilityOccurrencesSummary(ctx context.Context, projectID, filter string) (*gpb.VulnerabilityOccurrencesSummary, error)
+
+	// Close flushes pending writes and releases the resources of the storage, which is not used
+	// afterwards.
+	Close() error
+}
+
+// PolicyStorage provides storage functions for the IAM policies of notes and occurrences, which
+// are identified by their resource names.
+type PolicyStorage interface {
+	// GetIamPolicy gets the policy of the resource from storage, or nil if it has none.
+	GetIamPolicy(ctx context.Context, resource string) (*iampb.Policy, error)
+	// SetIamPolicy replaces the policy of the resource in storage. If etag is not nil, the policy
+	// is only replaced if the etag of the current policy is etag, where a resource without a
+	// policy has an empty etag, and otherwise an Aborted error is returned.
+	SetIamPolicy(ctx context.Context, resource string, p *iampb.Policy, etag []byte) error
+	// DeleteIamPolicy deletes the policy of the resource from storage, if it has one.
+	DeleteIamPolicy(ctx context.Context, resource string) error
+}
+
+// PurgedNoteStorage provides storage functions for the names of deleted notes, on which the
+// bindings of a policy file no longer grant permissions.
+type PurgedNoteStorage interface {
+	// AddPurgedNote records the deleted note in storage, if it is not recorded yet.
+	AddPurgedNote(ctx context.Context, note string) error
+	// ListPurgedNotes lists the names of the recorded notes from storage.
+	ListPurgedNotes(ctx context.Context) ([]string, error)
+	// DeletePurgedNote deletes the record of the note from storage, if it has one.
+	DeletePurgedNote(ctx context.Context, note string) error
 }
 
 // Auth provides authorization functions for this API.
@@ -113,6 +183,22 @@ type Auth interface {
 	PurgePolicy(ctx context.Context, projectID string, entityID string, r iam.Resource) error
 }
 
+// occurrenceProjectKey is the context key of the project an occurrence is attached to a note in.
+type occurrenceProjectKey struct{}
+
+// WithOccurrenceProject returns a copy of ctx that carries the project projectID of the occurrence
+// a note is attached to, which the API sets when it checks the NotesAttachOccurrence permission.
+func WithOccurrenceProject(ctx context.Context, projectID string) context.Context {
+	return context.WithValue(ctx, occurrenceProjectKey{}, projectID)
+}
+
+// OccurrenceProject returns the project of the occurrence a note is attached to that ctx carries,
+// if any.
+func OccurrenceProject(ctx context.Context) (string, bool) {
+	projectID, ok := ctx.Value(occurrenceProjectKey{}).(string)
+	return projectID, ok
+}
+
 // Filter provides functions for parsing filter strings.
 type Filter interface {
 	// Validate determines whether the specified filter string is a valid filter.
@@ -133,24 +219,74 @@ type Logger interface {
 
 // API implements the methods in the v1beta1 Grafeas API.
 type API struct {
-	Storage           Storage
-	Auth              Auth
-	Filter            Filter
-	Logger            Logger
+	Storage Storage
+	Auth    Auth
+	Filter  Filter
+	Logger  Logger
+	// EnforceValidation is whether invalid notes and occurrences are rejected, or only logged,
+	// unless ValidationModes or DefaultValidationMode has a mode for them.
 	EnforceValidation bool
+	// ValidationModes, if set, are the validation modes of the notes and occurrences of the
+	// kinds it has, by the lower case name of the kind, e.g. "vulnerability".
+	ValidationModes map[string]ValidationMode
+	// DefaultValidationMode, if set, is the validation mode of the notes and occurrences that
+	// ValidationModes has no mode for, including those of no kind, instead of EnforceValidation.
+	DefaultValidationMode *ValidationMode
+	// Limits are the limits of list pages and batch creates.
+	Limits Limits
+	// SizeLimits are the maximum sizes of the fields of notes and occurrences, which are enforced
+	// whether or not EnforceValidation is set. The zero value is unlimited.
+	SizeLimits grafeas.SizeLimits
+	// Policies, if set, stores the IAM policies of notes and occurrences. Without it, the IAM
+	// policy methods are unimplemented.
+	Policies PolicyStorage
+	// Quotas, if set, limit the numbers of notes and occurrences in each project.
+	Quotas *Quotas
+}
+
+// Limits are the limits of list pages and batch creates of the API. Zero values use the defaults.
+type Limits struct {
+	// DefaultPageSize is the page size of list calls that do not give one, 20 by default.
+	DefaultPageSize int32
+	// MaxPageSize is the largest page size list calls can give, 1000 by default.
+	MaxPageSize int32
+	// MaxBatchSize is the largest number of notes or occurrences a batch create can take, 1000 by
+	// default.
+	MaxBatchSize int32
+}
+
+// PageSizes returns the default and the maximum page sizes of list calls.
+func (l Limits) PageSizes() (def, max int32) {
+	def, max = l.DefaultPageSize, l.MaxPageSize
+	if def == 0 {
+		def = defaultPageSize
+	}
+	if max == 0 {
+		max = maxPageSize
+	}
+	return def, max
 }
 
 // validatePageSize returns the default page size if the specified page size is 0, otherwise it
 // validates the specified page size.
-func validatePageSize(ps int32) (int32, error) {
+func (l Limits) validatePageSize(ps int32) (int32, error) {
+	defPS, maxPS := l.PageSizes()
 	switch {
 	case ps == 0:
-		return defaultPageSize, nil
-	case ps > maxPageSize:
-		return 0, status.Errorf(codes.InvalidArgument, "page size %d cannot be large than max page size %d", ps, maxPageSize)
+		return defPS, nil
+	case ps > maxPS:
+		return 0, status.Errorf(codes.InvalidArgument, "page size %d cannot be large than max page size %d", ps, maxPS)
 	case ps < 0:
 		return 0, status.Errorf(codes.InvalidArgument, "page size %d cannot be negative", ps)
 	}
 
 	return ps, nil
 }
+
+// maxBatchSize returns the largest number of notes or occurrences a batch create can take.
+func (l Limits) maxBatchSize() int {
+	if l.MaxBatchSize == 0 {
+		return maxBatchSize
+	}
+	return int(l.MaxBatchSize)
+}
@@ -43,7 +43,7 @@ type fakeStorage struct {
 	// The following errors are for simulating an internal database error.
 	getOccErr, listOccsErr, createOccErr, batchCreateOccsErr, updateOccErr, deleteOccErr       bool
 	getNoteErr, listNotesErr, createNoteErr, batchCreateNotesErr, updateNoteErr, deleteNoteErr bool
-	getOccNoteErr, listNoteOccsErr, getVulnSummaryErr                                          bool
+	getOccNoteErr, listNoteOccsErr, getVulnSummaryErr, countErr                                bool
 }
 
 func newFakeStorage() *fakeStorage {
@@ -77,7 +77,7 @@ func (s *fakeStorage) ListOccurrences(ctx context.Context, pID, filter, pageToke
 	if s.listOccsErr {
 		return nil, "", status.Errorf(codes.Internal, "failed to list occurrences for project %q", pID)
 	}
-	validatedPageSize, err := validatePageSize(pageSize)
+	validatedPageSize, err := Limits{}.validatePageSize(pageSize)
 	if err != nil || pageSize != validatedPageSize {
 		return nil, "", status.Errorf(codes.Internal, "received non validated pageSize: %v", pageSize)
 	}
@@ -212,7 +212,7 @@ func (s *fakeStorage) ListNotes(ctx context.Context, pID, filter, pageToken stri
 	if s.listNotesErr {
 		return nil, "", status.Errorf(codes.Internal, "failed to list notes for project %q", pID)
 	}
-	validatedPageSize, err := validatePageSize(pageSize)
+	validatedPageSize, err := Limits{}.validatePageSize(pageSize)
 	if err != nil || pageSize != validatedPageSize {
 		return nil, "", status.Errorf(codes.Internal, "received non validated pageSize: %v", pageSize)
 	}
@@ -368,7 +368,7 @@ func (s *fakeStorage) ListNoteOccurrences(ctx context.Context, pID, nID, filter,
 	if s.listNoteOccsErr {
 		return nil, "", status.Errorf(codes.Internal, "failed to get occurrences for note %q", nID)
 	}
-	validatedPageSize, err := validatePageSize(pageSize)
+	validatedPageSize, err := Limits{}.validatePageSize(pageSize)
 	if err != nil || pageSize != validatedPageSize {
 		return nil, "", status.Errorf(codes.Internal, "received non validated pageSize: %v", pageSize)
 	}
@@ -390,6 +390,24 @@ func (s *fakeStorage) ListNoteOccurrences(ctx context.Context, pID, nID, filter,
 	return foundOccs, "", nil
 }
 
+func (s *fakeStorage) CountNotes(ctx context.Context, pID string) (int64, error) {
+	if s.countErr {
+		return 0, fmt.Errorf("failed to count notes of project %q", pID)
+	}
+	return int64(len(s.notes[pID])), nil
+}
+
+func (s *fakeStorage) CountOccurrences(ctx context.Context, pID string) (int64, error) {
+	if s.countErr {
+		return 0, fmt.Errorf("failed to count occurrences of project %q", pID)
+	}
+	return int64(len(s.occurrences[pID])), nil
+}
+
+func (s *fakeStorage) Close() error {
+	return nil
+}
+
 func (s *fakeStorage) GetVulnerabilityOccurrencesSummary(ctx context.Context, projectID, filter string) (*gpb.VulnerabilityOccurrencesSummary, error) {
 	if s.getVulnSummaryErr {
 		return nil, fmt.Errorf("failed to get vulnerability occurrences summary for project %q", projectID)
@@ -539,7 +557,7 @@ func TestValidatePageSize(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		ps, err := validatePageSize(tt.ps)
+		ps, err := Limits{}.validatePageSize(tt.ps)
 		if err != nil {
 			t.Errorf("%q: validatePageSize(%d): got error %v, want success", tt.desc, tt.ps, err)
 		}
@@ -578,7 +596,7 @@ func TestValidatePageSizeErrors(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		_, err := validatePageSize(tt.ps)
+		_, err := Limits{}.validatePageSize(tt.ps)
 		t.Logf("%q: error: %v", tt.desc, err)
 		if err == nil {
 			t.Errorf("%q: validatePageSize(%d): got success, want error code %q", tt.desc, tt.ps, tt.wantErrCode)
@@ -0,0 +1,213 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafeas
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+
+	"github.com/grafeas/grafeas/go/iam"
+	"github.com/grafeas/grafeas/go/name"
+	"golang.org/x/net/context"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// emptyPolicyEtag is the etag of the policy of a note or occurrence that has no policy. Setting a
+// policy with it fails if a policy has been set meanwhile.
+var emptyPolicyEtag = []byte{0}
+
+// policyResource is a note or occurrence, which IAM policies are set on.
+type policyResource struct {
+	name      string
+	projectID string
+	entityID  string
+	resource  iam.Resource
+}
+
+// parsePolicyResource parses the name of a note or occurrence.
+func parsePolicyResource(resource string) (*policyResource, error) {
+	if pID, nID, err := name.ParseNote(resource); err == nil {
+		return &policyResource{name: resource, projectID: pID, entityID: nID, resource: Notes}, nil
+	}
+	if pID, oID, err := name.ParseOccurrence(resource); err == nil {
+		return &policyResource{name: resource, projectID: pID, entityID: oID, resource: Occurrences}, nil
+	}
+	return nil, status.Errorf(codes.InvalidArgument, `resource must be in the form "projects/[PROJECT_ID]/notes/[NOTE_ID]" or "projects/[PROJECT_ID]/occurrences/[OCCURRENCE_ID]", got %q`, resource)
+}
+
+// permission returns onNotes if r is a note, and onOccurrences if it is an occurrence.
+func (r *policyResource) permission(onNotes, onOccurrences iam.Permission) iam.Permission {
+	if r.resource == Notes {
+		return onNotes
+	}
+	return onOccurrences
+}
+
+// checkExists returns a NotFound error if the note or occurrence r does not exist.
+func (g *API) checkExists(ctx context.Context, r *policyResource) error {
+	if r.resource == Notes {
+		_, err := g.Storage.GetNote(ctx, r.projectID, r.entityID)
+		return err
+	}
+	_, err := g.Storage.GetOccurrence(ctx, r.projectID, r.entityID)
+	return err
+}
+
+// GetIamPolicy gets the IAM policy of the specified note or occurrence, which is empty if none has
+// been set.
+func (g *API) GetIamPolicy(ctx context.Context, req *iampb.GetIamPolicyRequest) (*iampb.Policy, error) {
+	if g.Policies == nil {
+		return nil, status.Error(codes.Unimplemented, "the storage does not support IAM policies")
+	}
+	r, err := parsePolicyResource(req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = g.Logger.PrepareCtx(ctx, r.projectID)
+
+	if err := g.Auth.CheckAccessAndProject(ctx, r.projectID, r.entityID, r.permission(NotesGetIamPolicy, OccurrencesGetIamPolicy)); err != nil {
+		return nil, err
+	}
+	if err := g.checkExists(ctx, r); err != nil {
+		return nil, err
+	}
+
+	p, err := g.Policies.GetIamPolicy(ctx, r.name)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = &iampb.Policy{Version: 1, Etag: emptyPolicyEtag}
+	}
+	return p, nil
+}
+
+// SetIamPolicy replaces the IAM policy of the specified note or occurrence. If the policy has an
+// etag, it is only replaced if it has not changed since it was read with that etag.
+func (g *API) SetIamPolicy(ctx context.Context, req *iampb.SetIamPolicyRequest) (*iampb.Policy, error) {
+	if g.Policies == nil {
+		return nil, status.Error(codes.Unimplemented, "the storage does not support IAM policies")
+	}
+	r, err := parsePolicyResource(req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = g.Logger.PrepareCtx(ctx, r.projectID)
+
+	if err := g.Auth.CheckAccessAndProject(ctx, r.projectID, r.entityID, r.permission(NotesSetIamPolicy, OccurrencesSetIamPolicy)); err != nil {
+		return nil, err
+	}
+
+	if req.Policy == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "a policy must be specified")
+	}
+	for i, b := range req.Policy.Bindings {
+		if _, ok := Roles[b.Role]; !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "binding %d has unknown role %q", i, b.Role)
+		}
+		if len(b.Members) == 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "binding %d has no members", i)
+		}
+		if b.Condition != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "binding %d has a condition, which is not supported", i)
+		}
+	}
+
+	if err := g.checkExists(ctx, r); err != nil {
+		return nil, err
+	}
+
+	var ifEtag []byte
+	switch {
+	case len(req.Policy.Etag) == 0:
+	case bytes.Equal(req.Policy.Etag, emptyPolicyEtag):
+		ifEtag = []byte{}
+	default:
+		ifEtag = req.Policy.Etag
+	}
+	etag := make([]byte, 8)
+	if _, err := rand.Read(etag); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate etag")
+	}
+	p := &iampb.Policy{Version: 1, Bindings: req.Policy.Bindings, Etag: etag}
+	if err := g.Policies.SetIamPolicy(ctx, r.name, p, ifEtag); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// TestIamPermissions returns the permissions the caller has on the specified note or occurrence,
+// out of the requested ones. If the note or occurrence does not exist, it has none.
+func (g *API) TestIamPermissions(ctx context.Context, req *iampb.TestIamPermissionsRequest) (*iampb.TestIamPermissionsResponse, error) {
+	r, err := parsePolicyResource(req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = g.Logger.PrepareCtx(ctx, r.projectID)
+
+	for _, p := range req.Permissions {
+		if !strings.HasPrefix(p, string(r.resource)+".") || !isPermission(p) {
+			return nil, status.Errorf(codes.InvalidArgument, "%q is not a permission on %s", p, r.resource)
+		}
+	}
+	if err := g.checkExists(ctx, r); status.Code(err) == codes.NotFound {
+		return &iampb.TestIamPermissionsResponse{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	resp := &iampb.TestIamPermissionsResponse{}
+	for _, p := range req.Permissions {
+		if err := g.Auth.CheckAccessAndProject(ctx, r.projectID, r.entityID, iam.Permission(p)); err == nil {
+			resp.Permissions = append(resp.Permissions, p)
+		}
+	}
+	return resp, nil
+}
+
+// isPermission returns whether p is one of Permissions.
+func isPermission(p string) bool {
+	for _, q := range Permissions {
+		if p == string(q) {
+			return true
+		}
+	}
+	return false
+}
+
+// purgePolicy deletes the IAM policy of the deleted note or occurrence, both from the Auth and
+// from the policy storage, so that an entity created later with the same name does not inherit
+// it. Failures are logged, but do not fail the deletion.
+func (g *API) purgePolicy(ctx context.Context, pID, entityID string, r iam.Resource) {
+	if err := g.Auth.PurgePolicy(ctx, pID, entityID, r); err != nil {
+		g.Logger.Warningf(ctx, "Error deleting policies for %s %q in project %q: %v", r, entityID, pID, err)
+	}
+	if g.Policies == nil {
+		return
+	}
+	resource := name.FormatOccurrence(pID, entityID)
+	if r == Notes {
+		resource = name.FormatNote(pID, entityID)
+	}
+	if err := g.Policies.DeleteIamPolicy(ctx, resource); err != nil {
+		g.Logger.Warningf(ctx, "Error deleting IAM policy of %s: %v", resource, err)
+	}
+}
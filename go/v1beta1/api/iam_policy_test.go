@@ -0,0 +1,287 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafeas
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	"golang.org/x/net/context"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	exprpb "google.golang.org/genproto/googleapis/type/expr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakePolicyStorage implements the Grafeas policy storage interface using an in-memory map for
+// tests.
+type fakePolicyStorage struct {
+	policies map[string]*iampb.Policy
+}
+
+func newFakePolicyStorage() *fakePolicyStorage {
+	return &fakePolicyStorage{policies: map[string]*iampb.Policy{}}
+}
+
+func (s *fakePolicyStorage) GetIamPolicy(ctx context.Context, resource string) (*iampb.Policy, error) {
+	return s.policies[resource], nil
+}
+
+func (s *fakePolicyStorage) SetIamPolicy(ctx context.Context, resource string, p *iampb.Policy, etag []byte) error {
+	if etag != nil && !bytes.Equal(s.policies[resource].GetEtag(), etag) {
+		return status.Errorf(codes.Aborted, "etag mismatch")
+	}
+	s.policies[resource] = p
+	return nil
+}
+
+func (s *fakePolicyStorage) DeleteIamPolicy(ctx context.Context, resource string) error {
+	delete(s.policies, resource)
+	return nil
+}
+
+func TestSetAndGetIamPolicy(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeStorage()
+	ps := newFakePolicyStorage()
+	g := &API{
+		Storage:           s,
+		Auth:              &fakeAuth{},
+		Filter:            &fakeFilter{},
+		Logger:            &fakeLogger{},
+		EnforceValidation: true,
+		Policies:          ps,
+	}
+	if _, err := s.CreateNote(ctx, "goog-vulnz", "CVE-UH-OH", "", vulnzNote(t)); err != nil {
+		t.Fatalf("Failed to create note %v", err)
+	}
+	resource := "projects/goog-vulnz/notes/CVE-UH-OH"
+
+	empty, err := g.GetIamPolicy(ctx, &iampb.GetIamPolicyRequest{Resource: resource})
+	if err != nil {
+		t.Fatalf("GetIamPolicy got %v, want success", err)
+	}
+	if len(empty.Bindings) != 0 || len(empty.Etag) == 0 {
+		t.Errorf("GetIamPolicy without policy got %v, want an empty policy with an etag", empty)
+	}
+
+	bindings := []*iampb.Binding{{Role: "note-attacher", Members: []string{"consumer"}}}
+	set, err := g.SetIamPolicy(ctx, &iampb.SetIamPolicyRequest{
+		Resource: resource,
+		Policy:   &iampb.Policy{Bindings: bindings, Etag: empty.Etag},
+	})
+	if err != nil {
+		t.Fatalf("SetIamPolicy got %v, want success", err)
+	}
+	if bytes.Equal(set.Etag, empty.Etag) {
+		t.Errorf("SetIamPolicy got etag %x, want a new etag", set.Etag)
+	}
+	got, err := g.GetIamPolicy(ctx, &iampb.GetIamPolicyRequest{Resource: resource})
+	if err != nil || !proto.Equal(got, set) {
+		t.Errorf("GetIamPolicy got %v, %v, want %v", got, err, set)
+	}
+
+	// Setting a policy read before the last change fails.
+	if _, err := g.SetIamPolicy(ctx, &iampb.SetIamPolicyRequest{
+		Resource: resource,
+		Policy:   &iampb.Policy{Etag: empty.Etag},
+	}); status.Code(err) != codes.Aborted {
+		t.Errorf("SetIamPolicy with stale etag got %v, want Aborted", err)
+	}
+	// Setting a policy without an etag replaces it unconditionally.
+	if _, err := g.SetIamPolicy(ctx, &iampb.SetIamPolicyRequest{
+		Resource: resource,
+		Policy:   &iampb.Policy{Bindings: bindings},
+	}); err != nil {
+		t.Errorf("SetIamPolicy without etag got %v, want success", err)
+	}
+
+	// Deleting the note deletes its policy.
+	if _, err := g.DeleteNote(ctx, &gpb.DeleteNoteRequest{Name: resource}); err != nil {
+		t.Fatalf("DeleteNote got %v, want success", err)
+	}
+	if p := ps.policies[resource]; p != nil {
+		t.Errorf("policy of deleted note got %v, want none", p)
+	}
+}
+
+func TestSetIamPolicyErrors(t *testing.T) {
+	ctx := context.Background()
+	validPolicy := &iampb.Policy{Bindings: []*iampb.Binding{{Role: "viewer", Members: []string{"alice"}}}}
+
+	tests := []struct {
+		desc          string
+		req           *iampb.SetIamPolicyRequest
+		authErr       bool
+		noPolicies    bool
+		wantErrStatus codes.Code
+	}{
+		{
+			desc:          "invalid resource",
+			req:           &iampb.SetIamPolicyRequest{Resource: "projects/goog-vulnz", Policy: validPolicy},
+			wantErrStatus: codes.InvalidArgument,
+		},
+		{
+			desc:          "auth error",
+			req:           &iampb.SetIamPolicyRequest{Resource: "projects/goog-vulnz/notes/CVE-UH-OH", Policy: validPolicy},
+			authErr:       true,
+			wantErrStatus: codes.PermissionDenied,
+		},
+		{
+			desc:          "no policy storage",
+			req:           &iampb.SetIamPolicyRequest{Resource: "projects/goog-vulnz/notes/CVE-UH-OH", Policy: validPolicy},
+			noPolicies:    true,
+			wantErrStatus: codes.Unimplemented,
+		},
+		{
+			desc:          "missing policy",
+			req:           &iampb.SetIamPolicyRequest{Resource: "projects/goog-vulnz/notes/CVE-UH-OH"},
+			wantErrStatus: codes.InvalidArgument,
+		},
+		{
+			desc: "unknown role",
+			req: &iampb.SetIamPolicyRequest{
+				Resource: "projects/goog-vulnz/notes/CVE-UH-OH",
+				Policy:   &iampb.Policy{Bindings: []*iampb.Binding{{Role: "owner", Members: []string{"alice"}}}},
+			},
+			wantErrStatus: codes.InvalidArgument,
+		},
+		{
+			desc: "binding without members",
+			req: &iampb.SetIamPolicyRequest{
+				Resource: "projects/goog-vulnz/notes/CVE-UH-OH",
+				Policy:   &iampb.Policy{Bindings: []*iampb.Binding{{Role: "viewer"}}},
+			},
+			wantErrStatus: codes.InvalidArgument,
+		},
+		{
+			desc: "binding with condition",
+			req: &iampb.SetIamPolicyRequest{
+				Resource: "projects/goog-vulnz/notes/CVE-UH-OH",
+				Policy: &iampb.Policy{Bindings: []*iampb.Binding{{
+					Role:      "viewer",
+					Members:   []string{"alice"},
+					Condition: &exprpb.Expr{Expression: "true"},
+				}}},
+			},
+			wantErrStatus: codes.InvalidArgument,
+		},
+		{
+			desc:          "note doesn't exist, not found error",
+			req:           &iampb.SetIamPolicyRequest{Resource: "projects/goog-vulnz/notes/CVE-UH-HUH", Policy: validPolicy},
+			wantErrStatus: codes.NotFound,
+		},
+		{
+			desc:          "occurrence doesn't exist, not found error",
+			req:           &iampb.SetIamPolicyRequest{Resource: "projects/goog-vulnz/occurrences/1234", Policy: validPolicy},
+			wantErrStatus: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		s := newFakeStorage()
+		g := &API{
+			Storage:           s,
+			Auth:              &fakeAuth{authErr: tt.authErr},
+			Filter:            &fakeFilter{},
+			Logger:            &fakeLogger{},
+			EnforceValidation: true,
+		}
+		if !tt.noPolicies {
+			g.Policies = newFakePolicyStorage()
+		}
+		if _, err := s.CreateNote(ctx, "goog-vulnz", "CVE-UH-OH", "", vulnzNote(t)); err != nil {
+			t.Fatalf("Failed to create note %v", err)
+		}
+
+		_, err := g.SetIamPolicy(ctx, tt.req)
+		t.Logf("%q: error: %v", tt.desc, err)
+		if status.Code(err) != tt.wantErrStatus {
+			t.Errorf("%q: got error status %v, want %v", tt.desc, status.Code(err), tt.wantErrStatus)
+		}
+	}
+}
+
+func TestTestIamPermissions(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeStorage()
+	g := &API{
+		Storage: s,
+		Auth: &allowListAuth{allowList: []projectPermission{
+			{NotesAttachOccurrence, "goog-vulnz"},
+		}},
+		Filter:            &fakeFilter{},
+		Logger:            &fakeLogger{},
+		EnforceValidation: true,
+		Policies:          newFakePolicyStorage(),
+	}
+	if _, err := s.CreateNote(ctx, "goog-vulnz", "CVE-UH-OH", "", vulnzNote(t)); err != nil {
+		t.Fatalf("Failed to create note %v", err)
+	}
+
+	tests := []struct {
+		desc          string
+		req           *iampb.TestIamPermissionsRequest
+		want          []string
+		wantErrStatus codes.Code
+	}{
+		{
+			desc: "granted permissions",
+			req: &iampb.TestIamPermissionsRequest{
+				Resource:    "projects/goog-vulnz/notes/CVE-UH-OH",
+				Permissions: []string{"notes.attachOccurrence", "notes.update"},
+			},
+			want: []string{"notes.attachOccurrence"},
+		},
+		{
+			desc: "note doesn't exist, no permissions",
+			req: &iampb.TestIamPermissionsRequest{
+				Resource:    "projects/goog-vulnz/notes/CVE-UH-HUH",
+				Permissions: []string{"notes.attachOccurrence"},
+			},
+		},
+		{
+			desc: "unknown permission",
+			req: &iampb.TestIamPermissionsRequest{
+				Resource:    "projects/goog-vulnz/notes/CVE-UH-OH",
+				Permissions: []string{"notes.attach"},
+			},
+			wantErrStatus: codes.InvalidArgument,
+		},
+		{
+			desc: "permission on another resource type",
+			req: &iampb.TestIamPermissionsRequest{
+				Resource:    "projects/goog-vulnz/notes/CVE-UH-OH",
+				Permissions: []string{"occurrences.get"},
+			},
+			wantErrStatus: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		resp, err := g.TestIamPermissions(ctx, tt.req)
+		if status.Code(err) != tt.wantErrStatus {
+			t.Errorf("%q: got error status %v, want %v", tt.desc, status.Code(err), tt.wantErrStatus)
+			continue
+		}
+		if err == nil && !cmp.Equal(resp.Permissions, tt.want, cmpopts.EquateEmpty()) {
+			t.Errorf("%q: got permissions %q, want %q", tt.desc, resp.Permissions, tt.want)
+		}
+	}
+}
@@ -45,11 +45,16 @@ func (g *API) CreateNote(ctx context.Context, req *gpb.CreateNoteRequest) (*gpb.
 	if req.Note == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "a note must be specified")
 	}
-	if err := grafeas.ValidateNote(req.Note); err != nil {
-		if g.EnforceValidation {
-			return nil, err
+	if err := grafeas.ValidateNoteSize(req.Note, g.SizeLimits); err != nil {
+		return nil, err
+	}
+	if mode := g.validationMode(noteKind(req.Note)); mode != ValidationOff {
+		if err := grafeas.ValidateNote(req.Note); err != nil {
+			if mode == ValidationEnforce {
+				return nil, err
+			}
+			g.Logger.Warningf(ctx, "CreateNote %+v for project %q: invalid note, fail open, would have failed with: %v", req.Note, pID, err)
 		}
-		g.Logger.Warningf(ctx, "CreateNote %+v for project %q: invalid note, fail open, would have failed with: %v", req.Note, pID, err)
 	}
 
 	uID, err := g.Auth.EndUserID(ctx)
@@ -57,8 +62,13 @@ func (g *API) CreateNote(ctx context.Context, req *gpb.CreateNoteRequest) (*gpb.
 		return nil, err
 	}
 
+	if err := g.Quotas.reserveNotes(ctx, pID, 1); err != nil {
+		return nil, err
+	}
+
 	n, err := g.Storage.CreateNote(ctx, pID, req.NoteId, uID, req.Note)
 	if err != nil {
+		g.Quotas.forget(quotaNotes, pID)
 		return nil, err
 	}
 
@@ -81,20 +91,38 @@ func (g *API) BatchCreateNotes(ctx context.Context, req *gpb.BatchCreateNotesReq
 	if len(req.Notes) == 0 {
 		return nil, status.Errorf(codes.InvalidArgument, "at least one note must be specified")
 	}
-	if len(req.Notes) > maxBatchSize {
-		return nil, status.Errorf(codes.InvalidArgument, "%d is too many notes to batch create, a maximum of %d notes is allowed per batch create", len(req.Notes), maxBatchSize)
+	if len(req.Notes) > g.Limits.maxBatchSize() {
+		return nil, status.Errorf(codes.InvalidArgument, "%d is too many notes to batch create, a maximum of %d notes is allowed per batch create", len(req.Notes), g.Limits.maxBatchSize())
+	}
+	sizeErrs := []error{}
+	for i, n := range req.Notes {
+		if err := grafeas.ValidateNoteSize(n, g.SizeLimits); err != nil {
+			sizeErrs = append(sizeErrs, fmt.Errorf("notes[%q]: %v", i, err))
+		}
+	}
+	if len(sizeErrs) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "one or more notes are too large, no notes were created: %v", sizeErrs)
 	}
-	validationErrs := []error{}
+	validationErrs, warnErrs := []error{}, []error{}
 	for i, n := range req.Notes {
+		mode := g.validationMode(noteKind(n))
+		if mode == ValidationOff {
+			continue
+		}
 		if err := grafeas.ValidateNote(n); err != nil {
-			validationErrs = append(validationErrs, fmt.Errorf("notes[%q]: %v", i, err))
+			err = fmt.Errorf("notes[%q]: %v", i, err)
+			if mode == ValidationEnforce {
+				validationErrs = append(validationErrs, err)
+			} else {
+				warnErrs = append(warnErrs, err)
+			}
 		}
 	}
 	if len(validationErrs) > 0 {
-		if g.EnforceValidation {
-			return nil, status.Errorf(codes.InvalidArgument, "one or more notes are invalid, no notes were created: %v", validationErrs)
-		}
-		g.Logger.Warningf(ctx, "BatchCreateNotes %+v for project %q: invalid note(s), fail open, would have failed with: %v", req.Notes, pID, validationErrs)
+		return nil, status.Errorf(codes.InvalidArgument, "one or more notes are invalid, no notes were created: %v", validationErrs)
+	}
+	if len(warnErrs) > 0 {
+		g.Logger.Warningf(ctx, "BatchCreateNotes %+v for project %q: invalid note(s), fail open, would have failed with: %v", req.Notes, pID, warnErrs)
 	}
 
 	uID, err := g.Auth.EndUserID(ctx)
@@ -102,8 +130,13 @@ func (g *API) BatchCreateNotes(ctx context.Context, req *gpb.BatchCreateNotesReq
 		return nil, err
 	}
 
+	if err := g.Quotas.reserveNotes(ctx, pID, len(req.Notes)); err != nil {
+		return nil, err
+	}
+
 	created, errs := g.Storage.BatchCreateNotes(ctx, pID, uID, req.Notes)
 	if len(errs) > 0 {
+		g.Quotas.forget(quotaNotes, pID)
 		// Report any storage layer errors as invalid argument for now, find a better way to do this.
 		return nil, status.Errorf(codes.InvalidArgument, "errors encountered when batch creating notes: %d of %d notes failed: %v", len(errs), len(req.Notes), errs)
 	}
@@ -151,6 +184,9 @@ func (g *API) UpdateNote(ctx context.Context, req *gpb.UpdateNoteRequest) (*gpb.
 	if req.Note == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "an note must be specified")
 	}
+	if err := grafeas.ValidateNoteSize(req.Note, g.SizeLimits); err != nil {
+		return nil, err
+	}
 
 	n, err := g.Storage.UpdateNote(ctx, pID, nID, req.Note, req.UpdateMask)
 	if err != nil {
@@ -176,12 +212,10 @@ func (g *API) DeleteNote(ctx context.Context, req *gpb.DeleteNoteRequest) (*empt
 	if err := g.Storage.DeleteNote(ctx, pID, nID); err != nil {
 		return nil, err
 	}
+	g.Quotas.deleted(quotaNotes, pID, 1)
 
 	// Purge any IAM policies set on this entity.
-	if err := g.Auth.PurgePolicy(ctx, pID, nID, Notes); err != nil {
-		// This fails open, should not block on policy deletion failure.
-		g.Logger.Warningf(ctx, "Error deleting policies for note %q in project %q: %v", nID, pID, err)
-	}
+	g.purgePolicy(ctx, pID, nID, Notes)
 
 	return &emptypb.Empty{}, nil
 }
@@ -199,7 +233,7 @@ func (g *API) ListNotes(ctx context.Context, req *gpb.ListNotesRequest) (*gpb.Li
 		return nil, err
 	}
 
-	ps, err := validatePageSize(req.PageSize)
+	ps, err := g.Limits.validatePageSize(req.PageSize)
 	if err != nil {
 		return nil, err
 	}
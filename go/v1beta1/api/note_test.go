@@ -20,6 +20,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/google/go-cmp/cmp"
+	"github.com/grafeas/grafeas/go/v1beta1/api/validators/grafeas"
 	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
 	vpb "github.com/grafeas/grafeas/proto/v1beta1/vulnerability_go_proto"
 	"golang.org/x/net/context"
@@ -896,3 +897,39 @@ func vulnzNotes(t *testing.T, num int) map[string]*gpb.Note {
 	}
 	return notes
 }
+
+func TestNoteSizeLimits(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeStorage()
+	g := &API{
+		Storage: s,
+		Auth:    &fakeAuth{},
+		Filter:  &fakeFilter{},
+		Logger:  &fakeLogger{},
+		// Size limits are enforced even when validation is not.
+		EnforceValidation: false,
+		SizeLimits:        grafeas.SizeLimits{MaxDescriptionLength: 10},
+	}
+	large := vulnzNote(t)
+	large.LongDescription = "a long description"
+
+	_, err := g.CreateNote(ctx, &gpb.CreateNoteRequest{Parent: "projects/goog-vulnz", NoteId: "n", Note: large})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateNote of large note got %v want InvalidArgument", err)
+	}
+	_, err = g.BatchCreateNotes(ctx, &gpb.BatchCreateNotesRequest{Parent: "projects/goog-vulnz", Notes: map[string]*gpb.Note{"small": vulnzNote(t), "large": large}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchCreateNotes with large note got %v want InvalidArgument", err)
+	}
+	if len(s.notes["goog-vulnz"]) != 0 {
+		t.Errorf("large notes created %d notes want none", len(s.notes["goog-vulnz"]))
+	}
+
+	if _, err := g.CreateNote(ctx, &gpb.CreateNoteRequest{Parent: "projects/goog-vulnz", NoteId: "n", Note: vulnzNote(t)}); err != nil {
+		t.Fatalf("CreateNote got %v want success", err)
+	}
+	_, err = g.UpdateNote(ctx, &gpb.UpdateNoteRequest{Name: "projects/goog-vulnz/notes/n", Note: large})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpdateNote to large note got %v want InvalidArgument", err)
+	}
+}
@@ -60,7 +60,7 @@ func (g *API) ListOccurrences(ctx context.Context, req *gpb.ListOccurrencesReque
 		return nil, err
 	}
 
-	ps, err := validatePageSize(req.PageSize)
+	ps, err := g.Limits.validatePageSize(req.PageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -103,15 +103,20 @@ func (g *API) CreateOccurrence(ctx context.Context, req *gpb.CreateOccurrenceReq
 	if err != nil {
 		return nil, err
 	}
-	if err := g.Auth.CheckAccessAndProject(ctx, notePID, nID, NotesAttachOccurrence); err != nil {
+	if err := g.Auth.CheckAccessAndProject(WithOccurrenceProject(ctx, pID), notePID, nID, NotesAttachOccurrence); err != nil {
 		return nil, err
 	}
 
-	if err := grafeas.ValidateOccurrence(req.Occurrence); err != nil {
-		if g.EnforceValidation {
-			return nil, err
+	if err := grafeas.ValidateOccurrenceSize(req.Occurrence, g.SizeLimits); err != nil {
+		return nil, err
+	}
+	if mode := g.validationMode(occurrenceKind(req.Occurrence)); mode != ValidationOff {
+		if err := grafeas.ValidateOccurrence(req.Occurrence); err != nil {
+			if mode == ValidationEnforce {
+				return nil, err
+			}
+			g.Logger.Warningf(ctx, "CreateOccurrence %+v for project %q: invalid occurrence, fail open, would have failed with: %v", req.Occurrence, pID, err)
 		}
-		g.Logger.Warningf(ctx, "CreateOccurrence %+v for project %q: invalid occurrence, fail open, would have failed with: %v", req.Occurrence, pID, err)
 	}
 
 	uID, err := g.Auth.EndUserID(ctx)
@@ -119,8 +124,13 @@ func (g *API) CreateOccurrence(ctx context.Context, req *gpb.CreateOccurrenceReq
 		return nil, err
 	}
 
+	if err := g.Quotas.reserveOccurrences(ctx, pID, 1); err != nil {
+		return nil, err
+	}
+
 	o, err := g.Storage.CreateOccurrence(ctx, pID, uID, req.Occurrence)
 	if err != nil {
+		g.Quotas.forget(quotaOccurrences, pID)
 		return nil, err
 	}
 
@@ -143,8 +153,8 @@ func (g *API) BatchCreateOccurrences(ctx context.Context, req *gpb.BatchCreateOc
 	if len(req.Occurrences) == 0 {
 		return nil, status.Errorf(codes.InvalidArgument, "at least one occurrence must be specified")
 	}
-	if len(req.Occurrences) > maxBatchSize {
-		return nil, status.Errorf(codes.InvalidArgument, "%d is too many occurrence to batch create, a maximum of %d occurrence is allowed per batch create", len(req.Occurrences), maxBatchSize)
+	if len(req.Occurrences) > g.Limits.maxBatchSize() {
+		return nil, status.Errorf(codes.InvalidArgument, "%d is too many occurrence to batch create, a maximum of %d occurrence is allowed per batch create", len(req.Occurrences), g.Limits.maxBatchSize())
 	}
 
 	// Creating occurrences requires an additional notes attacher permissions check before we can
@@ -155,7 +165,7 @@ func (g *API) BatchCreateOccurrences(ctx context.Context, req *gpb.BatchCreateOc
 		if err != nil {
 			return nil, err
 		}
-		if err := g.Auth.CheckAccessAndProject(ctx, notePID, nID, NotesAttachOccurrence); err != nil {
+		if err := g.Auth.CheckAccessAndProject(WithOccurrenceProject(ctx, pID), notePID, nID, NotesAttachOccurrence); err != nil {
 			authErrs = append(authErrs, fmt.Errorf("occurrences[%d]: %s", i, err))
 		}
 	}
@@ -163,17 +173,35 @@ func (g *API) BatchCreateOccurrences(ctx context.Context, req *gpb.BatchCreateOc
 		return nil, status.Errorf(codes.PermissionDenied, "one or more occurrences had auth errors, no occurrences were created: %v", authErrs)
 	}
 
-	validationErrs := []error{}
+	sizeErrs := []error{}
+	for i, o := range req.Occurrences {
+		if err := grafeas.ValidateOccurrenceSize(o, g.SizeLimits); err != nil {
+			sizeErrs = append(sizeErrs, fmt.Errorf("occurrences[%d]: %v", i, err))
+		}
+	}
+	if len(sizeErrs) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "one or more occurrences are too large, no occurrences were created: %v", sizeErrs)
+	}
+	validationErrs, warnErrs := []error{}, []error{}
 	for i, o := range req.Occurrences {
+		mode := g.validationMode(occurrenceKind(o))
+		if mode == ValidationOff {
+			continue
+		}
 		if err := grafeas.ValidateOccurrence(o); err != nil {
-			validationErrs = append(validationErrs, fmt.Errorf("occurrences[%d]: %v", i, err))
+			err = fmt.Errorf("occurrences[%d]: %v", i, err)
+			if mode == ValidationEnforce {
+				validationErrs = append(validationErrs, err)
+			} else {
+				warnErrs = append(warnErrs, err)
+			}
 		}
 	}
 	if len(validationErrs) > 0 {
-		if g.EnforceValidation {
-			return nil, status.Errorf(codes.InvalidArgument, "one or more occurrences are invalid, no occurrences were created: %v", validationErrs)
-		}
-		g.Logger.Warningf(ctx, "BatchCreateOccurrences %+v for project %q: invalid occurrences(s), fail open, would have failed with: %v", req.Occurrences, pID, validationErrs)
+		return nil, status.Errorf(codes.InvalidArgument, "one or more occurrences are invalid, no occurrences were created: %v", validationErrs)
+	}
+	if len(warnErrs) > 0 {
+		g.Logger.Warningf(ctx, "BatchCreateOccurrences %+v for project %q: invalid occurrences(s), fail open, would have failed with: %v", req.Occurrences, pID, warnErrs)
 	}
 
 	uID, err := g.Auth.EndUserID(ctx)
@@ -181,8 +209,13 @@ func (g *API) BatchCreateOccurrences(ctx context.Context, req *gpb.BatchCreateOc
 		return nil, err
 	}
 
+	if err := g.Quotas.reserveOccurrences(ctx, pID, len(req.Occurrences)); err != nil {
+		return nil, err
+	}
+
 	created, errs := g.Storage.BatchCreateOccurrences(ctx, pID, uID, req.Occurrences)
 	if len(errs) != 0 {
+		g.Quotas.forget(quotaOccurrences, pID)
 		// Report any storage layer errors as invalid argument for now, find a better way to do this.
 		return nil, status.Errorf(codes.InvalidArgument, "errors encountered when batch creating occurrences: %d of %d occurrences failed: %v", len(errs), len(req.Occurrences), errs)
 	}
@@ -205,6 +238,9 @@ func (g *API) UpdateOccurrence(ctx context.Context, req *gpb.UpdateOccurrenceReq
 	if req.Occurrence == nil {
 		return nil, status.Errorf(codes.InvalidArgument, "an occurrence must be specified")
 	}
+	if err := grafeas.ValidateOccurrenceSize(req.Occurrence, g.SizeLimits); err != nil {
+		return nil, err
+	}
 
 	if err := g.Auth.CheckAccessAndProject(ctx, pID, oID, OccurrencesUpdate); err != nil {
 		return nil, err
@@ -220,7 +256,7 @@ func (g *API) UpdateOccurrence(ctx context.Context, req *gpb.UpdateOccurrenceReq
 		if err != nil {
 			return nil, err
 		}
-		if err := g.Auth.CheckAccessAndProject(ctx, notePID, nID, NotesAttachOccurrence); err != nil {
+		if err := g.Auth.CheckAccessAndProject(WithOccurrenceProject(ctx, pID), notePID, nID, NotesAttachOccurrence); err != nil {
 			return nil, err
 		}
 	}
@@ -232,7 +268,7 @@ func (g *API) UpdateOccurrence(ctx context.Context, req *gpb.UpdateOccurrenceReq
 		if err != nil {
 			return nil, err
 		}
-		if err := g.Auth.CheckAccessAndProject(ctx, notePID, nID, NotesAttachOccurrence); err != nil {
+		if err := g.Auth.CheckAccessAndProject(WithOccurrenceProject(ctx, pID), notePID, nID, NotesAttachOccurrence); err != nil {
 			return nil, err
 		}
 	}
@@ -267,7 +303,7 @@ func (g *API) DeleteOccurrence(ctx context.Context, req *gpb.DeleteOccurrenceReq
 		if err != nil {
 			return nil, err
 		}
-		if err := g.Auth.CheckAccessAndProject(ctx, notePID, nID, NotesAttachOccurrence); err != nil {
+		if err := g.Auth.CheckAccessAndProject(WithOccurrenceProject(ctx, pID), notePID, nID, NotesAttachOccurrence); err != nil {
 			return nil, err
 		}
 	}
@@ -275,12 +311,10 @@ func (g *API) DeleteOccurrence(ctx context.Context, req *gpb.DeleteOccurrenceReq
 	if err := g.Storage.DeleteOccurrence(ctx, pID, oID); err != nil {
 		return nil, err
 	}
+	g.Quotas.deleted(quotaOccurrences, pID, 1)
 
 	// Purge any IAM policies set on this entity.
-	if err := g.Auth.PurgePolicy(ctx, pID, oID, Occurrences); err != nil {
-		// This fails open, should not block on policy deletion failure.
-		g.Logger.Warningf(ctx, "Error deleting policies for occurrence %q in project %q: %v", oID, pID, err)
-	}
+	g.purgePolicy(ctx, pID, oID, Occurrences)
 
 	return &emptypb.Empty{}, nil
 }
@@ -298,7 +332,7 @@ func (g *API) ListNoteOccurrences(ctx context.Context, req *gpb.ListNoteOccurren
 		return nil, err
 	}
 
-	ps, err := validatePageSize(req.PageSize)
+	ps, err := g.Limits.validatePageSize(req.PageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/google/go-cmp/cmp"
+	"github.com/grafeas/grafeas/go/v1beta1/api/validators/grafeas"
 	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
 	pkgpb "github.com/grafeas/grafeas/proto/v1beta1/package_go_proto"
 	provpb "github.com/grafeas/grafeas/proto/v1beta1/provenance_go_proto"
@@ -1140,3 +1141,42 @@ func vulnzOccs(t *testing.T, pID, noteName, imageNamePrefix string, num int) []*
 	}
 	return occs
 }
+
+func TestOccurrenceSizeLimits(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeStorage()
+	g := &API{
+		Storage: s,
+		Auth:    &fakeAuth{},
+		Filter:  &fakeFilter{},
+		Logger:  &fakeLogger{},
+		// Size limits are enforced even when validation is not.
+		EnforceValidation: false,
+		SizeLimits:        grafeas.SizeLimits{MaxStringLength: 200},
+	}
+	noteName := "projects/goog-vulnz/notes/CVE-UH-OH"
+	large := vulnzOcc(t, "consumer1", noteName, "debian")
+	large.Remediation = string(make([]byte, 201))
+
+	_, err := g.CreateOccurrence(ctx, &gpb.CreateOccurrenceRequest{Parent: "projects/consumer1", Occurrence: large})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateOccurrence of large occurrence got %v want InvalidArgument", err)
+	}
+	occs := []*gpb.Occurrence{vulnzOcc(t, "consumer1", noteName, "debian"), large}
+	_, err = g.BatchCreateOccurrences(ctx, &gpb.BatchCreateOccurrencesRequest{Parent: "projects/consumer1", Occurrences: occs})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchCreateOccurrences with large occurrence got %v want InvalidArgument", err)
+	}
+	if len(s.occurrences["consumer1"]) != 0 {
+		t.Errorf("large occurrences created %d occurrences want none", len(s.occurrences["consumer1"]))
+	}
+
+	o, err := g.CreateOccurrence(ctx, &gpb.CreateOccurrenceRequest{Parent: "projects/consumer1", Occurrence: vulnzOcc(t, "consumer1", noteName, "debian")})
+	if err != nil {
+		t.Fatalf("CreateOccurrence got %v want success", err)
+	}
+	_, err = g.UpdateOccurrence(ctx, &gpb.UpdateOccurrenceRequest{Name: o.Name, Occurrence: large})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UpdateOccurrence to large occurrence got %v want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafeas
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/grafeas/grafeas/go/name"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProjectCounter provides the numbers of notes and occurrences in a project.
+type ProjectCounter interface {
+	// CountNotes returns the number of notes in the project.
+	CountNotes(ctx context.Context, projectID string) (int64, error)
+	// CountOccurrences returns the number of occurrences in the project.
+	CountOccurrences(ctx context.Context, projectID string) (int64, error)
+}
+
+// quotaCountTTL is how long the count of the notes or occurrences of a project is cached before
+// the project is counted again.
+const quotaCountTTL = 30 * time.Second
+
+// The kinds of objects quotas limit.
+const (
+	quotaNotes       = "notes"
+	quotaOccurrences = "occurrences"
+)
+
+// Quotas limit the numbers of notes and occurrences in each project. Creating notes or occurrences
+// that would exceed a quota fails with a ResourceExhausted error with QuotaFailure and RetryInfo
+// details, the retry delay being the time until the project is counted again. Projects are counted at most once
+// every quotaCountTTL, and the counts are kept up to date with the creates and deletes of this API
+// in between, so the quotas hold for the calls to this API; other writers of the storage, such as
+// other servers, can exceed them until the projects are counted again.
+type Quotas struct {
+	// MaxNotes is the maximum number of notes in a project, or 0 for no maximum.
+	MaxNotes int64
+	// MaxOccurrences is the maximum number of occurrences in a project, or 0 for no maximum.
+	MaxOccurrences int64
+	// Counter counts the notes and occurrences of projects.
+	Counter ProjectCounter
+
+	mu     sync.Mutex
+	counts map[quotaKey]*quotaCount
+	swept  time.Time
+}
+
+// quotaKey identifies the count of a kind of object in a project.
+type quotaKey struct {
+	kind, projectID string
+}
+
+// quotaCount is the cached count of a kind of object in a project.
+type quotaCount struct {
+	// n is the count of the storage, plus the objects reserved and less those deleted since.
+	n       int64
+	counted time.Time
+}
+
+// reserve checks that n more of the kind of object counted by count fit in max in the project, and
+// adds them to its count if they do. Creates that fail afterwards must forget the count.
+func (q *Quotas) reserve(ctx context.Context, pID string, n int, max int64, kind string, count func(context.Context, string) (int64, error)) error {
+	if q == nil || max == 0 {
+		return nil
+	}
+	key := quotaKey{kind, pID}
+	q.mu.Lock()
+	c := q.counts[key]
+	if c == nil || time.Since(c.counted) > quotaCountTTL {
+		// Count without the lock, and keep the count of a concurrent reserve that counted first.
+		q.mu.Unlock()
+		counted, err := count(ctx, pID)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to count the %s of project %q: %v", kind, pID, err)
+		}
+		q.mu.Lock()
+		if current := q.counts[key]; current == nil || current == c {
+			q.sweep()
+			q.counts[key] = &quotaCount{n: counted, counted: time.Now()}
+		}
+		c = q.counts[key]
+	}
+	current := c.n
+	if current+int64(n) <= max {
+		c.n += int64(n)
+		q.mu.Unlock()
+		return nil
+	}
+	// Deletes by other writers of the storage are only seen once the project is counted again.
+	retry := quotaCountTTL - time.Since(c.counted)
+	q.mu.Unlock()
+
+	st := status.Newf(codes.ResourceExhausted, "project %q has %d %s, creating %d more would exceed its quota of %d", pID, current, kind, n, max)
+	st, err := st.WithDetails(&errdetails.QuotaFailure{
+		Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     name.FormatProject(pID),
+			Description: "quota of " + kind + " per project exceeded",
+		}},
+	}, &errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(retry)})
+	if err != nil {
+		return status.Errorf(codes.ResourceExhausted, "project %q would exceed its quota of %d %s", pID, max, kind)
+	}
+	return st.Err()
+}
+
+// sweep drops the expired counts at most once every quotaCountTTL, so that projects that are no
+// longer written to are not kept. It must be called with q.mu held.
+func (q *Quotas) sweep() {
+	if q.counts == nil {
+		q.counts = map[quotaKey]*quotaCount{}
+	}
+	if time.Since(q.swept) < quotaCountTTL {
+		return
+	}
+	for key, c := range q.counts {
+		if time.Since(c.counted) > quotaCountTTL {
+			delete(q.counts, key)
+		}
+	}
+	q.swept = time.Now()
+}
+
+// forget drops the cached count of the kind of object in the project, so that it is counted again
+// by the next reserve, e.g. because a create that reserved objects failed.
+func (q *Quotas) forget(kind, pID string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.counts, quotaKey{kind, pID})
+}
+
+// deleted subtracts n deleted objects of the kind from the cached count of the project, if any.
+func (q *Quotas) deleted(kind, pID string, n int) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if c, ok := q.counts[quotaKey{kind, pID}]; ok && c.n >= int64(n) {
+		c.n -= int64(n)
+	}
+}
+
+// reserveNotes reserves n notes in the project, see reserve.
+func (q *Quotas) reserveNotes(ctx context.Context, pID string, n int) error {
+	if q == nil {
+		return nil
+	}
+	return q.reserve(ctx, pID, n, q.MaxNotes, quotaNotes, q.Counter.CountNotes)
+}
+
+// reserveOccurrences reserves n occurrences in the project, see reserve.
+func (q *Quotas) reserveOccurrences(ctx context.Context, pID string, n int) error {
+	if q == nil {
+		return nil
+	}
+	return q.reserve(ctx, pID, n, q.MaxOccurrences, quotaOccurrences, q.Counter.CountOccurrences)
+}
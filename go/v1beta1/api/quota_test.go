@@ -0,0 +1,247 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafeas
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// wantQuotaExceeded checks that err is a ResourceExhausted error with a quota failure on project
+// pID and a retry delay of at most quotaCountTTL.
+func wantQuotaExceeded(t *testing.T, desc string, err error, pID string) {
+	t.Helper()
+	s := status.Convert(err)
+	if s.Code() != codes.ResourceExhausted {
+		t.Errorf("%s got error %v want ResourceExhausted", desc, err)
+		return
+	}
+	var quotaFailure, retryInfo bool
+	for _, d := range s.Details() {
+		switch d := d.(type) {
+		case *errdetails.QuotaFailure:
+			quotaFailure = len(d.Violations) == 1 && d.Violations[0].Subject == "projects/"+pID
+		case *errdetails.RetryInfo:
+			delay := d.RetryDelay.AsDuration()
+			retryInfo = delay >= 0 && delay <= quotaCountTTL
+		}
+	}
+	if !quotaFailure || !retryInfo {
+		t.Errorf("%s got details %v want a quota failure of projects/%s and a retry delay of at most %v", desc, s.Details(), pID, quotaCountTTL)
+	}
+}
+
+func TestNoteQuota(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeStorage()
+	g := &API{
+		Storage:           s,
+		Auth:              &fakeAuth{},
+		Filter:            &fakeFilter{},
+		Logger:            &fakeLogger{},
+		EnforceValidation: true,
+		Quotas:            &Quotas{MaxNotes: 3, Counter: s},
+	}
+
+	if _, err := g.CreateNote(ctx, &gpb.CreateNoteRequest{Parent: "projects/goog-vulnz", NoteId: "n1", Note: vulnzNote(t)}); err != nil {
+		t.Fatalf("CreateNote got %v want success", err)
+	}
+	_, err := g.BatchCreateNotes(ctx, &gpb.BatchCreateNotesRequest{Parent: "projects/goog-vulnz", Notes: vulnzNotes(t, 3)})
+	wantQuotaExceeded(t, "BatchCreateNotes over quota", err, "goog-vulnz")
+	if _, err := g.BatchCreateNotes(ctx, &gpb.BatchCreateNotesRequest{Parent: "projects/goog-vulnz", Notes: vulnzNotes(t, 2)}); err != nil {
+		t.Fatalf("BatchCreateNotes up to quota got %v want success", err)
+	}
+	_, err = g.CreateNote(ctx, &gpb.CreateNoteRequest{Parent: "projects/goog-vulnz", NoteId: "n2", Note: vulnzNote(t)})
+	wantQuotaExceeded(t, "CreateNote over quota", err, "goog-vulnz")
+
+	// Quotas are per project, and occurrences are unlimited.
+	if _, err := g.CreateNote(ctx, &gpb.CreateNoteRequest{Parent: "projects/other", NoteId: "n1", Note: vulnzNote(t)}); err != nil {
+		t.Errorf("CreateNote in other project got %v want success", err)
+	}
+	if _, err := g.CreateOccurrence(ctx, &gpb.CreateOccurrenceRequest{Parent: "projects/goog-vulnz", Occurrence: vulnzOcc(t, "goog-vulnz", "projects/goog-vulnz/notes/n1", "debian")}); err != nil {
+		t.Errorf("CreateOccurrence without occurrence quota got %v want success", err)
+	}
+}
+
+func TestOccurrenceQuota(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeStorage()
+	g := &API{
+		Storage:           s,
+		Auth:              &fakeAuth{},
+		Filter:            &fakeFilter{},
+		Logger:            &fakeLogger{},
+		EnforceValidation: true,
+		Quotas:            &Quotas{MaxOccurrences: 3, Counter: s},
+	}
+	noteName := "projects/goog-vulnz/notes/CVE-UH-OH"
+
+	if _, err := g.CreateOccurrence(ctx, &gpb.CreateOccurrenceRequest{Parent: "projects/consumer1", Occurrence: vulnzOcc(t, "consumer1", noteName, "debian")}); err != nil {
+		t.Fatalf("CreateOccurrence got %v want success", err)
+	}
+	_, err := g.BatchCreateOccurrences(ctx, &gpb.BatchCreateOccurrencesRequest{Parent: "projects/consumer1", Occurrences: vulnzOccs(t, "consumer1", noteName, "image", 3)})
+	wantQuotaExceeded(t, "BatchCreateOccurrences over quota", err, "consumer1")
+	if len(s.occurrences["consumer1"]) != 1 {
+		t.Errorf("BatchCreateOccurrences over quota created %d occurrences want none", len(s.occurrences["consumer1"])-1)
+	}
+	if _, err := g.BatchCreateOccurrences(ctx, &gpb.BatchCreateOccurrencesRequest{Parent: "projects/consumer1", Occurrences: vulnzOccs(t, "consumer1", noteName, "image", 2)}); err != nil {
+		t.Fatalf("BatchCreateOccurrences up to quota got %v want success", err)
+	}
+	_, err = g.CreateOccurrence(ctx, &gpb.CreateOccurrenceRequest{Parent: "projects/consumer1", Occurrence: vulnzOcc(t, "consumer1", noteName, "debian")})
+	wantQuotaExceeded(t, "CreateOccurrence over quota", err, "consumer1")
+
+	s.countErr = true
+	_, err = g.CreateOccurrence(ctx, &gpb.CreateOccurrenceRequest{Parent: "projects/consumer2", Occurrence: vulnzOcc(t, "consumer2", noteName, "debian")})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("CreateOccurrence with failing count got %v want Internal", err)
+	}
+}
+
+// lockedStorage serializes the occurrence creates and counts of a fakeStorage, which is not safe
+// for concurrent use.
+type lockedStorage struct {
+	mu sync.Mutex
+	*fakeStorage
+}
+
+func (s *lockedStorage) CreateOccurrence(ctx context.Context, pID string, userID string, o *gpb.Occurrence) (*gpb.Occurrence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fakeStorage.CreateOccurrence(ctx, pID, userID, o)
+}
+
+func (s *lockedStorage) CountOccurrences(ctx context.Context, pID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fakeStorage.CountOccurrences(ctx, pID)
+}
+
+func TestOccurrenceQuotaConcurrentCreates(t *testing.T) {
+	ctx := context.Background()
+	s := &lockedStorage{fakeStorage: newFakeStorage()}
+	g := &API{
+		Storage:           s,
+		Auth:              &fakeAuth{},
+		Filter:            &fakeFilter{},
+		Logger:            &fakeLogger{},
+		EnforceValidation: true,
+		Quotas:            &Quotas{MaxOccurrences: 5, Counter: s},
+	}
+	occ := vulnzOcc(t, "consumer1", "projects/goog-vulnz/notes/CVE-UH-OH", "debian")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.CreateOccurrence(ctx, &gpb.CreateOccurrenceRequest{Parent: "projects/consumer1", Occurrence: occ})
+		}()
+	}
+	wg.Wait()
+	if n := len(s.occurrences["consumer1"]); n != 5 {
+		t.Errorf("concurrent creates created %d occurrences want 5", n)
+	}
+}
+
+// countingCounter is a ProjectCounter that counts the calls to it.
+type countingCounter struct {
+	ProjectCounter
+	calls int
+}
+
+func (c *countingCounter) CountOccurrences(ctx context.Context, pID string) (int64, error) {
+	c.calls++
+	return c.ProjectCounter.CountOccurrences(ctx, pID)
+}
+
+func TestOccurrenceQuotaCachedCount(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeStorage()
+	counter := &countingCounter{ProjectCounter: s}
+	g := &API{
+		Storage:           s,
+		Auth:              &fakeAuth{},
+		Filter:            &fakeFilter{},
+		Logger:            &fakeLogger{},
+		EnforceValidation: true,
+		Quotas:            &Quotas{MaxOccurrences: 3, Counter: counter},
+	}
+	noteName := "projects/goog-vulnz/notes/CVE-UH-OH"
+	create := func() error {
+		_, err := g.CreateOccurrence(ctx, &gpb.CreateOccurrenceRequest{Parent: "projects/consumer1", Occurrence: vulnzOcc(t, "consumer1", noteName, "debian")})
+		return err
+	}
+
+	// Creates and deletes of the API are counted without counting the project again.
+	for i := 0; i < 3; i++ {
+		if err := create(); err != nil {
+			t.Fatalf("CreateOccurrence got %v want success", err)
+		}
+	}
+	wantQuotaExceeded(t, "CreateOccurrence over quota", create(), "consumer1")
+	var oID string
+	for id := range s.occurrences["consumer1"] {
+		oID = id
+	}
+	if _, err := g.DeleteOccurrence(ctx, &gpb.DeleteOccurrenceRequest{Name: "projects/consumer1/occurrences/" + oID}); err != nil {
+		t.Fatalf("DeleteOccurrence got %v want success", err)
+	}
+	if err := create(); err != nil {
+		t.Errorf("CreateOccurrence after delete got %v want success", err)
+	}
+	if counter.calls != 1 {
+		t.Errorf("CountOccurrences called %d times want once", counter.calls)
+	}
+
+	// Occurrences deleted by other writers of the storage are seen once the count expires.
+	for id := range s.occurrences["consumer1"] {
+		delete(s.occurrences["consumer1"], id)
+		break
+	}
+	wantQuotaExceeded(t, "CreateOccurrence with cached count", create(), "consumer1")
+	g.Quotas.counts[quotaKey{quotaOccurrences, "consumer1"}].counted = time.Now().Add(-2 * quotaCountTTL)
+	if err := create(); err != nil {
+		t.Errorf("CreateOccurrence after expiry got %v want success", err)
+	}
+	if counter.calls != 2 {
+		t.Errorf("CountOccurrences called %d times want twice", counter.calls)
+	}
+
+	// A failed create drops the count, and the project is counted again.
+	for id := range s.occurrences["consumer1"] {
+		oID = id
+	}
+	if _, err := g.DeleteOccurrence(ctx, &gpb.DeleteOccurrenceRequest{Name: "projects/consumer1/occurrences/" + oID}); err != nil {
+		t.Fatalf("DeleteOccurrence got %v want success", err)
+	}
+	s.createOccErr = true
+	if err := create(); status.Code(err) != codes.Internal {
+		t.Errorf("CreateOccurrence with failing storage got %v want Internal", err)
+	}
+	s.createOccErr = false
+	if err := create(); err != nil {
+		t.Errorf("CreateOccurrence after failed create got %v want success", err)
+	}
+	if counter.calls != 3 {
+		t.Errorf("CountOccurrences called %d times want 3 times", counter.calls)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafeas
+
+import (
+	"strings"
+
+	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+)
+
+// ValidationMode is what the API does with notes and occurrences that fail validation.
+type ValidationMode int
+
+const (
+	// ValidationEnforce rejects invalid notes and occurrences.
+	ValidationEnforce ValidationMode = iota
+	// ValidationWarn logs invalid notes and occurrences, and stores them.
+	ValidationWarn
+	// ValidationOff stores notes and occurrences without validating them.
+	ValidationOff
+)
+
+// validationMode returns the validation mode of the notes and occurrences of kind.
+func (g *API) validationMode(kind cpb.NoteKind) ValidationMode {
+	if kind != cpb.NoteKind_NOTE_KIND_UNSPECIFIED {
+		if m, ok := g.ValidationModes[strings.ToLower(kind.String())]; ok {
+			return m
+		}
+	}
+	if g.DefaultValidationMode != nil {
+		return *g.DefaultValidationMode
+	}
+	if g.EnforceValidation {
+		return ValidationEnforce
+	}
+	return ValidationWarn
+}
+
+// noteKind returns the kind of n from its type.
+func noteKind(n *gpb.Note) cpb.NoteKind {
+	switch n.GetType().(type) {
+	case *gpb.Note_Vulnerability:
+		return cpb.NoteKind_VULNERABILITY
+	case *gpb.Note_Build:
+		return cpb.NoteKind_BUILD
+	case *gpb.Note_BaseImage:
+		return cpb.NoteKind_IMAGE
+	case *gpb.Note_Package:
+		return cpb.NoteKind_PACKAGE
+	case *gpb.Note_Deployable:
+		return cpb.NoteKind_DEPLOYMENT
+	case *gpb.Note_Discovery:
+		return cpb.NoteKind_DISCOVERY
+	case *gpb.Note_AttestationAuthority:
+		return cpb.NoteKind_ATTESTATION
+	case *gpb.Note_Intoto:
+		return cpb.NoteKind_INTOTO
+	case *gpb.Note_Sbom:
+		return cpb.NoteKind_SBOM
+	case *gpb.Note_SpdxPackage:
+		return cpb.NoteKind_SPDX_PACKAGE
+	case *gpb.Note_SpdxFile:
+		return cpb.NoteKind_SPDX_FILE
+	case *gpb.Note_SpdxRelationship:
+		return cpb.NoteKind_SPDX_RELATIONSHIP
+	}
+	return cpb.NoteKind_NOTE_KIND_UNSPECIFIED
+}
+
+// occurrenceKind returns the kind of o from its details.
+func occurrenceKind(o *gpb.Occurrence) cpb.NoteKind {
+	switch o.GetDetails().(type) {
+	case *gpb.Occurrence_Vulnerability:
+		return cpb.NoteKind_VULNERABILITY
+	case *gpb.Occurrence_Build:
+		return cpb.NoteKind_BUILD
+	case *gpb.Occurrence_DerivedImage:
+		return cpb.NoteKind_IMAGE
+	case *gpb.Occurrence_Installation:
+		return cpb.NoteKind_PACKAGE
+	case *gpb.Occurrence_Deployment:
+		return cpb.NoteKind_DEPLOYMENT
+	case *gpb.Occurrence_Discovered:
+		return cpb.NoteKind_DISCOVERY
+	case *gpb.Occurrence_Attestation:
+		return cpb.NoteKind_ATTESTATION
+	case *gpb.Occurrence_Intoto:
+		return cpb.NoteKind_INTOTO
+	case *gpb.Occurrence_Sbom:
+		return cpb.NoteKind_SBOM
+	case *gpb.Occurrence_SpdxPackage:
+		return cpb.NoteKind_SPDX_PACKAGE
+	case *gpb.Occurrence_SpdxFile:
+		return cpb.NoteKind_SPDX_FILE
+	case *gpb.Occurrence_SpdxRelationship:
+		return cpb.NoteKind_SPDX_RELATIONSHIP
+	}
+	return cpb.NoteKind_NOTE_KIND_UNSPECIFIED
+}
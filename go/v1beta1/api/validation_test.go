@@ -0,0 +1,226 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafeas
+
+import (
+	"context"
+	"testing"
+
+	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidationMode(t *testing.T) {
+	tests := []struct {
+		desc    string
+		enforce bool
+		modes   map[string]ValidationMode
+		def     *ValidationMode
+		kind    cpb.NoteKind
+		want    ValidationMode
+	}{
+		{
+			desc:    "enforced by default",
+			enforce: true,
+			kind:    cpb.NoteKind_VULNERABILITY,
+			want:    ValidationEnforce,
+		},
+		{
+			desc: "warned by default",
+			kind: cpb.NoteKind_VULNERABILITY,
+			want: ValidationWarn,
+		},
+		{
+			desc:    "mode of the kind",
+			enforce: true,
+			modes:   map[string]ValidationMode{"vulnerability": ValidationOff},
+			kind:    cpb.NoteKind_VULNERABILITY,
+			want:    ValidationOff,
+		},
+		{
+			desc:    "mode of another kind",
+			enforce: true,
+			modes:   map[string]ValidationMode{"build": ValidationOff},
+			kind:    cpb.NoteKind_VULNERABILITY,
+			want:    ValidationEnforce,
+		},
+		{
+			desc:  "unspecified kind",
+			modes: map[string]ValidationMode{"note_kind_unspecified": ValidationOff},
+			kind:  cpb.NoteKind_NOTE_KIND_UNSPECIFIED,
+			want:  ValidationWarn,
+		},
+		{
+			desc:    "default mode",
+			enforce: true,
+			def:     validationModePtr(ValidationWarn),
+			kind:    cpb.NoteKind_VULNERABILITY,
+			want:    ValidationWarn,
+		},
+		{
+			desc:    "mode of the kind over the default mode",
+			enforce: true,
+			modes:   map[string]ValidationMode{"vulnerability": ValidationOff},
+			def:     validationModePtr(ValidationWarn),
+			kind:    cpb.NoteKind_VULNERABILITY,
+			want:    ValidationOff,
+		},
+		{
+			desc:    "default mode of unspecified kind",
+			enforce: true,
+			def:     validationModePtr(ValidationOff),
+			kind:    cpb.NoteKind_NOTE_KIND_UNSPECIFIED,
+			want:    ValidationOff,
+		},
+	}
+	for _, tt := range tests {
+		g := &API{EnforceValidation: tt.enforce, ValidationModes: tt.modes, DefaultValidationMode: tt.def}
+		if got := g.validationMode(tt.kind); got != tt.want {
+			t.Errorf("%q: validationMode(%v) got %v want %v", tt.desc, tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestValidationModes(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		mode       ValidationMode
+		wantStatus codes.Code
+	}{
+		{mode: ValidationEnforce, wantStatus: codes.InvalidArgument},
+		{mode: ValidationWarn, wantStatus: codes.OK},
+		{mode: ValidationOff, wantStatus: codes.OK},
+	}
+	for _, tt := range tests {
+		g := &API{
+			Storage:           newFakeStorage(),
+			Auth:              &fakeAuth{},
+			Filter:            &fakeFilter{},
+			Logger:            &fakeLogger{},
+			EnforceValidation: true,
+			// The mode of vulnerabilities applies, not EnforceValidation.
+			ValidationModes: map[string]ValidationMode{"vulnerability": tt.mode},
+		}
+
+		_, err := g.CreateNote(ctx, &gpb.CreateNoteRequest{Parent: "projects/goog-vulnz", NoteId: "n", Note: invalidVulnzNote(t)})
+		if status.Code(err) != tt.wantStatus {
+			t.Errorf("mode %v: CreateNote got %v want %v", tt.mode, err, tt.wantStatus)
+		}
+		_, err = g.BatchCreateNotes(ctx, &gpb.BatchCreateNotesRequest{Parent: "projects/goog-vulnz", Notes: map[string]*gpb.Note{"batch": invalidVulnzNote(t)}})
+		if status.Code(err) != tt.wantStatus {
+			t.Errorf("mode %v: BatchCreateNotes got %v want %v", tt.mode, err, tt.wantStatus)
+		}
+		_, err = g.CreateOccurrence(ctx, &gpb.CreateOccurrenceRequest{
+			Parent:     "projects/consumer1",
+			Occurrence: invalidVulnzOcc(t, "consumer1", "projects/goog-vulnz/notes/CVE-UH-OH"),
+		})
+		if status.Code(err) != tt.wantStatus {
+			t.Errorf("mode %v: CreateOccurrence got %v want %v", tt.mode, err, tt.wantStatus)
+		}
+		_, err = g.BatchCreateOccurrences(ctx, &gpb.BatchCreateOccurrencesRequest{
+			Parent:      "projects/consumer1",
+			Occurrences: []*gpb.Occurrence{invalidVulnzOcc(t, "consumer1", "projects/goog-vulnz/notes/CVE-UH-OH")},
+		})
+		if status.Code(err) != tt.wantStatus {
+			t.Errorf("mode %v: BatchCreateOccurrences got %v want %v", tt.mode, err, tt.wantStatus)
+		}
+	}
+}
+
+func TestDefaultValidationModeOfUntypedNotes(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		mode         ValidationMode
+		wantStatus   codes.Code
+		wantWarnings int
+	}{
+		{mode: ValidationEnforce, wantStatus: codes.InvalidArgument},
+		{mode: ValidationWarn, wantStatus: codes.OK, wantWarnings: 1},
+		{mode: ValidationOff, wantStatus: codes.OK},
+	}
+	for _, tt := range tests {
+		logger := &warningCountingLogger{}
+		g := &API{
+			Storage: newFakeStorage(),
+			Auth:    &fakeAuth{},
+			Filter:  &fakeFilter{},
+			Logger:  logger,
+			// A validation mode of "off" leaves EnforceValidation unset, which must not warn
+			// about notes of no kind.
+			DefaultValidationMode: validationModePtr(tt.mode),
+		}
+		untyped := &gpb.Note{ShortDescription: "untyped"}
+		_, err := g.CreateNote(ctx, &gpb.CreateNoteRequest{Parent: "projects/goog-vulnz", NoteId: "n", Note: untyped})
+		if status.Code(err) != tt.wantStatus {
+			t.Errorf("mode %v: CreateNote of untyped note got %v want %v", tt.mode, err, tt.wantStatus)
+		}
+		if logger.warnings != tt.wantWarnings {
+			t.Errorf("mode %v: CreateNote of untyped note logged %d warnings want %d", tt.mode, logger.warnings, tt.wantWarnings)
+		}
+	}
+}
+
+// warningCountingLogger counts the warnings logged.
+type warningCountingLogger struct {
+	fakeLogger
+	warnings int
+}
+
+func (l *warningCountingLogger) Warningf(ctx context.Context, format string, args ...interface{}) {
+	l.warnings++
+}
+
+func validationModePtr(m ValidationMode) *ValidationMode {
+	return &m
+}
+
+func TestLimits(t *testing.T) {
+	l := Limits{DefaultPageSize: 5, MaxPageSize: 10, MaxBatchSize: 2}
+	if ps, err := l.validatePageSize(0); err != nil || ps != 5 {
+		t.Errorf("validatePageSize(0) got %d, %v want 5", ps, err)
+	}
+	if ps, err := l.validatePageSize(10); err != nil || ps != 10 {
+		t.Errorf("validatePageSize(10) got %d, %v want 10", ps, err)
+	}
+	if _, err := l.validatePageSize(11); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("validatePageSize(11) got %v want InvalidArgument", err)
+	}
+
+	ctx := context.Background()
+	g := &API{
+		Storage:           newFakeStorage(),
+		Auth:              &fakeAuth{},
+		Filter:            &fakeFilter{},
+		Logger:            &fakeLogger{},
+		EnforceValidation: true,
+		Limits:            l,
+	}
+	_, err := g.BatchCreateNotes(ctx, &gpb.BatchCreateNotesRequest{Parent: "projects/goog-vulnz", Notes: vulnzNotes(t, 3)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchCreateNotes of 3 notes got %v want InvalidArgument", err)
+	}
+	_, err = g.BatchCreateOccurrences(ctx, &gpb.BatchCreateOccurrencesRequest{
+		Parent:      "projects/consumer1",
+		Occurrences: vulnzOccs(t, "consumer1", "projects/goog-vulnz/notes/CVE-UH-OH", "debian", 3),
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("BatchCreateOccurrences of 3 occurrences got %v want InvalidArgument", err)
+	}
+	if _, err := g.ListNotes(ctx, &gpb.ListNotesRequest{Parent: "projects/goog-vulnz", PageSize: 11}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListNotes of page size 11 got %v want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafeas
+
+import (
+	"fmt"
+
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// SizeLimits are the maximum sizes of the fields of notes and occurrences, which keep huge
+// payloads out of storage. String and bytes lengths are in bytes. Zero values are unlimited.
+type SizeLimits struct {
+	// MaxStringLength is the maximum length of string and bytes fields other than descriptions.
+	MaxStringLength int
+	// MaxDescriptionLength is the maximum length of short_description and long_description.
+	MaxDescriptionLength int
+	// MaxRelatedURLs is the maximum number of related_url of a note.
+	MaxRelatedURLs int
+	// MaxPackageIssues is the maximum number of package_issue of a vulnerability occurrence.
+	MaxPackageIssues int
+	// MaxRepeatedLength is the maximum number of elements of other repeated and map fields.
+	MaxRepeatedLength int
+}
+
+// ValidateNoteSize validates that the fields of a note are within the size limits.
+func ValidateNoteSize(n *gpb.Note, l SizeLimits) error {
+	if errs := l.check(n.ProtoReflect(), ""); len(errs) > 0 {
+		return status.Errorf(codes.InvalidArgument, "note is too large: %v", errs)
+	}
+	return nil
+}
+
+// ValidateOccurrenceSize validates that the fields of an occurrence are within the size limits.
+func ValidateOccurrenceSize(o *gpb.Occurrence, l SizeLimits) error {
+	if errs := l.check(o.ProtoReflect(), ""); len(errs) > 0 {
+		return status.Errorf(codes.InvalidArgument, "occurrence is too large: %v", errs)
+	}
+	return nil
+}
+
+// check returns the errors of the fields of m, and of the messages within them, that exceed the
+// limits, naming them after path. The elements of lists and maps that have too many of them are
+// not checked.
+func (l SizeLimits) check(m protoreflect.Message, path string) []error {
+	errs := []error{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := path + string(fd.Name())
+		switch {
+		case fd.IsList():
+			list := v.List()
+			if max := l.maxElements(fd); max > 0 && list.Len() > max {
+				errs = append(errs, fmt.Errorf("%s has %d elements, more than the maximum of %d", name, list.Len(), max))
+				return true
+			}
+			for i := 0; i < list.Len(); i++ {
+				errs = append(errs, l.checkValue(fd, list.Get(i), fmt.Sprintf("%s[%d]", name, i))...)
+			}
+		case fd.IsMap():
+			mp := v.Map()
+			if max := l.maxElements(fd); max > 0 && mp.Len() > max {
+				errs = append(errs, fmt.Errorf("%s has %d elements, more than the maximum of %d", name, mp.Len(), max))
+				return true
+			}
+			mp.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				elem := fmt.Sprintf("%s[%q]", name, k.String())
+				errs = append(errs, l.checkValue(fd.MapKey(), k.Value(), elem+" key")...)
+				errs = append(errs, l.checkValue(fd.MapValue(), v, elem)...)
+				return true
+			})
+		default:
+			errs = append(errs, l.checkValue(fd, v, name)...)
+		}
+		return true
+	})
+	return errs
+}
+
+// checkValue returns the errors of a single value of the field fd, named name.
+func (l SizeLimits) checkValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, name string) []error {
+	var n int
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return l.check(v.Message(), name+".")
+	case protoreflect.StringKind:
+		n = len(v.String())
+	case protoreflect.BytesKind:
+		n = len(v.Bytes())
+	default:
+		return nil
+	}
+	if max := l.maxLength(fd); max > 0 && n > max {
+		return []error{fmt.Errorf("%s is %d bytes long, more than the maximum of %d", name, n, max)}
+	}
+	return nil
+}
+
+// maxLength returns the maximum length of the string or bytes field fd.
+func (l SizeLimits) maxLength(fd protoreflect.FieldDescriptor) int {
+	switch fd.Name() {
+	case "short_description", "long_description":
+		return l.MaxDescriptionLength
+	}
+	return l.MaxStringLength
+}
+
+// maxElements returns the maximum number of elements of the repeated or map field fd.
+func (l SizeLimits) maxElements(fd protoreflect.FieldDescriptor) int {
+	switch fd.Name() {
+	case "related_url":
+		return l.MaxRelatedURLs
+	case "package_issue":
+		return l.MaxPackageIssues
+	}
+	return l.MaxRepeatedLength
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafeas
+
+import (
+	"strings"
+	"testing"
+
+	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	vpb "github.com/grafeas/grafeas/proto/v1beta1/vulnerability_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testSizeLimits = SizeLimits{
+	MaxStringLength:      10,
+	MaxDescriptionLength: 20,
+	MaxRelatedURLs:       2,
+	MaxPackageIssues:     1,
+	MaxRepeatedLength:    3,
+}
+
+func TestValidateNoteSize(t *testing.T) {
+	tests := []struct {
+		desc     string
+		n        *gpb.Note
+		l        SizeLimits
+		wantErrs []string
+	}{
+		{
+			desc: "within limits",
+			n: &gpb.Note{
+				ShortDescription: strings.Repeat("a", 20),
+				RelatedUrl:       []*cpb.RelatedUrl{{Url: "u"}, {Url: "v"}},
+			},
+			l: testSizeLimits,
+		},
+		{
+			desc: "long descriptions",
+			n: &gpb.Note{
+				ShortDescription: strings.Repeat("a", 21),
+				LongDescription:  strings.Repeat("a", 100),
+			},
+			l:        testSizeLimits,
+			wantErrs: []string{"short_description is 21 bytes long", "long_description is 100 bytes long"},
+		},
+		{
+			desc: "too many related URLs",
+			n: &gpb.Note{
+				RelatedUrl: []*cpb.RelatedUrl{{}, {}, {}},
+			},
+			l:        testSizeLimits,
+			wantErrs: []string{"related_url has 3 elements, more than the maximum of 2"},
+		},
+		{
+			desc: "long nested string",
+			n: &gpb.Note{
+				RelatedUrl: []*cpb.RelatedUrl{{}, {Url: strings.Repeat("u", 11)}},
+			},
+			l:        testSizeLimits,
+			wantErrs: []string{"related_url[1].url is 11 bytes long, more than the maximum of 10"},
+		},
+		{
+			desc: "too many vulnerability details",
+			n: &gpb.Note{
+				Type: &gpb.Note_Vulnerability{
+					Vulnerability: &vpb.Vulnerability{
+						Details: []*vpb.Vulnerability_Detail{{}, {}, {}, {}},
+					},
+				},
+			},
+			l:        testSizeLimits,
+			wantErrs: []string{"vulnerability.details has 4 elements"},
+		},
+		{
+			desc: "zero limits are unlimited",
+			n: &gpb.Note{
+				LongDescription: strings.Repeat("a", 100),
+				RelatedUrl:      []*cpb.RelatedUrl{{}, {}, {}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		err := ValidateNoteSize(tt.n, tt.l)
+		checkSizeErr(t, tt.desc, err, tt.wantErrs)
+	}
+}
+
+func TestValidateOccurrenceSize(t *testing.T) {
+	tests := []struct {
+		desc     string
+		o        *gpb.Occurrence
+		wantErrs []string
+	}{
+		{
+			desc: "within limits",
+			o: &gpb.Occurrence{
+				Resource: &gpb.Resource{Uri: "gcr.io/a"},
+				Details: &gpb.Occurrence_Vulnerability{
+					Vulnerability: &vpb.Details{PackageIssue: []*vpb.PackageIssue{{}}},
+				},
+			},
+		},
+		{
+			desc: "too many package issues",
+			o: &gpb.Occurrence{
+				Details: &gpb.Occurrence_Vulnerability{
+					Vulnerability: &vpb.Details{PackageIssue: []*vpb.PackageIssue{{}, {}}},
+				},
+			},
+			wantErrs: []string{"vulnerability.package_issue has 2 elements, more than the maximum of 1"},
+		},
+		{
+			desc: "long resource URI and remediation",
+			o: &gpb.Occurrence{
+				Resource:    &gpb.Resource{Uri: strings.Repeat("u", 11)},
+				Remediation: strings.Repeat("r", 11),
+			},
+			wantErrs: []string{"resource.uri is 11 bytes long", "remediation is 11 bytes long"},
+		},
+	}
+
+	for _, tt := range tests {
+		err := ValidateOccurrenceSize(tt.o, testSizeLimits)
+		checkSizeErr(t, tt.desc, err, tt.wantErrs)
+	}
+}
+
+// checkSizeErr checks that err is nil if wantErrs is empty, and otherwise an InvalidArgument error
+// that names each of wantErrs.
+func checkSizeErr(t *testing.T, desc string, err error, wantErrs []string) {
+	t.Helper()
+	if len(wantErrs) == 0 {
+		if err != nil {
+			t.Errorf("%q: got error %v, want success", desc, err)
+		}
+		return
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("%q: got error %v, want InvalidArgument", desc, err)
+		return
+	}
+	for _, want := range wantErrs {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got error %v, want it to contain %q", desc, err, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records who created, updated or deleted which projects, notes, occurrences and
+// IAM policies, and implements the v1beta1 Audit API that lists the recorded entries.
+//
+// Entries are recorded by the gRPC interceptor returned by Interceptor and written to a Sink: a
+// file or stream in which every entry is chained to the previous one by its hash, so that changes
+// to the log can be detected with Verify, or the storage, if it implements Storage.
+package audit
+
+import (
+	"context"
+
+	"github.com/grafeas/grafeas/go/name"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 1000
+)
+
+// Sink is where audit entries are written.
+type Sink interface {
+	// Write writes the entry to the sink.
+	Write(ctx context.Context, e *apb.AuditEntry) error
+}
+
+// Lister is implemented by sinks whose entries can be listed.
+type Lister interface {
+	// ListAuditEntries lists the entries of the project, oldest first.
+	ListAuditEntries(ctx context.Context, projectID, pageToken string, pageSize int32) ([]*apb.AuditEntry, string, error)
+}
+
+// Storage is implemented by the storages that keep audit entries in a table of their own.
+type Storage interface {
+	// WriteAuditEntry appends the entry to storage.
+	WriteAuditEntry(ctx context.Context, e *apb.AuditEntry) error
+	// ListAuditEntries lists the entries of the project from storage, oldest first.
+	ListAuditEntries(ctx context.Context, projectID, pageToken string, pageSize int32) ([]*apb.AuditEntry, string, error)
+}
+
+// StorageSink is a Sink that writes entries to a Storage.
+type StorageSink struct {
+	s Storage
+}
+
+// NewStorageSink returns the sink that writes entries to s.
+func NewStorageSink(s Storage) *StorageSink {
+	return &StorageSink{s: s}
+}
+
+// Write appends the entry to the storage.
+func (s *StorageSink) Write(ctx context.Context, e *apb.AuditEntry) error {
+	return s.s.WriteAuditEntry(ctx, e)
+}
+
+// ListAuditEntries lists the entries of the project from the storage.
+func (s *StorageSink) ListAuditEntries(ctx context.Context, projectID, pageToken string, pageSize int32) ([]*apb.AuditEntry, string, error) {
+	return s.s.ListAuditEntries(ctx, projectID, pageToken, pageSize)
+}
+
+// API implements the methods in the v1beta1 Audit API.
+type API struct {
+	// Entries lists the recorded entries. If nil, the sink cannot be listed and ListAuditEntries
+	// is unimplemented.
+	Entries Lister
+	Auth    grafeas.Auth
+}
+
+// ListAuditEntries lists the audit entries of the specified project.
+func (a *API) ListAuditEntries(ctx context.Context, req *apb.ListAuditEntriesRequest) (*apb.ListAuditEntriesResponse, error) {
+	pID, err := name.ParseProject(req.Parent)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid parent %q", req.Parent)
+	}
+	if err := a.Auth.CheckAccessAndProject(ctx, pID, "", grafeas.AuditEntriesList); err != nil {
+		return nil, err
+	}
+	if a.Entries == nil {
+		return nil, status.Error(codes.Unimplemented, "The audit sink does not support listing entries")
+	}
+	ps, err := validatePageSize(req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	entries, nextToken, err := a.Entries.ListAuditEntries(ctx, pID, req.PageToken, ps)
+	if err != nil {
+		return nil, err
+	}
+	return &apb.ListAuditEntriesResponse{Entries: entries, NextPageToken: nextToken}, nil
+}
+
+// validatePageSize returns the default page size if the specified page size is 0, otherwise it
+// validates the specified page size.
+func validatePageSize(ps int32) (int32, error) {
+	switch {
+	case ps == 0:
+		return defaultPageSize, nil
+	case ps > maxPageSize:
+		return 0, status.Errorf(codes.InvalidArgument, "page size %d cannot be large than max page size %d", ps, maxPageSize)
+	case ps < 0:
+		return 0, status.Errorf(codes.InvalidArgument, "page size %d cannot be negative", ps)
+	}
+	return ps, nil
+}
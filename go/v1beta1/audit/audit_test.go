@@ -0,0 +1,138 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/iam"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAuth allows the permission it is given on the project it is given.
+type fakeAuth struct {
+	projectID string
+	perm      iam.Permission
+}
+
+func (a *fakeAuth) CheckAccessAndProject(ctx context.Context, projectID string, entityID string, p iam.Permission) error {
+	if projectID != a.projectID || p != a.perm {
+		return status.Errorf(codes.PermissionDenied, "permission %s denied on %s", p, projectID)
+	}
+	return nil
+}
+
+func (a *fakeAuth) EndUserID(ctx context.Context) (string, error) {
+	return "alice", nil
+}
+
+func (a *fakeAuth) PurgePolicy(ctx context.Context, projectID string, entityID string, r iam.Resource) error {
+	return nil
+}
+
+// fakeStorage is an audit Storage of a list of entries, of which it lists all at once.
+type fakeStorage struct {
+	entries []*apb.AuditEntry
+}
+
+func (s *fakeStorage) WriteAuditEntry(ctx context.Context, e *apb.AuditEntry) error {
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func (s *fakeStorage) ListAuditEntries(ctx context.Context, projectID, pageToken string, pageSize int32) ([]*apb.AuditEntry, string, error) {
+	var es []*apb.AuditEntry
+	for _, e := range s.entries {
+		if e.Project == projectID {
+			es = append(es, e)
+		}
+	}
+	return es, "", nil
+}
+
+func TestListAuditEntries(t *testing.T) {
+	ctx := context.Background()
+	sink := NewStorageSink(&fakeStorage{})
+	for _, p := range []string{"p", "other", "p"} {
+		if err := sink.Write(ctx, testEntry(p, 0)); err != nil {
+			t.Fatalf("Write got %v want success", err)
+		}
+	}
+	a := &API{Entries: sink, Auth: &fakeAuth{projectID: "p", perm: grafeas.AuditEntriesList}}
+
+	resp, err := a.ListAuditEntries(ctx, &apb.ListAuditEntriesRequest{Parent: "projects/p"})
+	if err != nil {
+		t.Fatalf("ListAuditEntries got %v want success", err)
+	}
+	if len(resp.Entries) != 2 {
+		t.Errorf("ListAuditEntries got %d entries want 2", len(resp.Entries))
+	}
+
+	tests := []struct {
+		desc          string
+		req           *apb.ListAuditEntriesRequest
+		noEntries     bool
+		wantErrStatus codes.Code
+	}{
+		{
+			desc:          "invalid parent",
+			req:           &apb.ListAuditEntriesRequest{Parent: "p"},
+			wantErrStatus: codes.InvalidArgument,
+		},
+		{
+			desc:          "auth error",
+			req:           &apb.ListAuditEntriesRequest{Parent: "projects/other"},
+			wantErrStatus: codes.PermissionDenied,
+		},
+		{
+			desc:          "negative page size",
+			req:           &apb.ListAuditEntriesRequest{Parent: "projects/p", PageSize: -1},
+			wantErrStatus: codes.InvalidArgument,
+		},
+		{
+			desc:          "page size too large",
+			req:           &apb.ListAuditEntriesRequest{Parent: "projects/p", PageSize: maxPageSize + 1},
+			wantErrStatus: codes.InvalidArgument,
+		},
+		{
+			desc:          "sink cannot be listed",
+			req:           &apb.ListAuditEntriesRequest{Parent: "projects/p"},
+			noEntries:     true,
+			wantErrStatus: codes.Unimplemented,
+		},
+	}
+	for _, tt := range tests {
+		a := &API{Entries: sink, Auth: &fakeAuth{projectID: "p", perm: grafeas.AuditEntriesList}}
+		if tt.noEntries {
+			a.Entries = nil
+		}
+		if _, err := a.ListAuditEntries(ctx, tt.req); status.Code(err) != tt.wantErrStatus {
+			t.Errorf("%q: got error status %v, want %v", tt.desc, status.Code(err), tt.wantErrStatus)
+		}
+	}
+}
+
+func TestListAuditEntriesOfStreamSink(t *testing.T) {
+	// Sinks that cannot be listed leave the API unimplemented.
+	var sink Sink = NewStreamSink(&bytes.Buffer{})
+	if _, ok := sink.(Lister); ok {
+		t.Errorf("StreamSink is a Lister, want it not to be")
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// record is a line of a hash-chained audit log. Hash is the hex-encoded SHA-256 of PrevHash, the
+// hash of the previous record or "" for the first one, followed by Entry.
+type record struct {
+	Entry    json.RawMessage `json:"entry"`
+	PrevHash string          `json:"prev_hash"`
+	Hash     string          `json:"hash"`
+}
+
+// chainHash returns the hash of the record of entry that follows the record of hash prevHash.
+func chainHash(prevHash string, entry []byte) string {
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write(entry)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// StreamSink is a Sink that writes entries to a stream as hash-chained JSON lines.
+type StreamSink struct {
+	mu       sync.Mutex
+	w        io.Writer
+	prevHash string
+}
+
+// NewStreamSink returns the sink that writes entries to w, chained from the start.
+func NewStreamSink(w io.Writer) *StreamSink {
+	return &StreamSink{w: w}
+}
+
+// Write writes the entry to the stream as the next record of the chain.
+func (s *StreamSink) Write(ctx context.Context, e *apb.AuditEntry) error {
+	data, err := protojson.Marshal(proto.MessageV2(e))
+	if err != nil {
+		return err
+	}
+	// The entry is hashed as it is stored, compacted.
+	var entry bytes.Buffer
+	if err := json.Compact(&entry, data); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := record{Entry: entry.Bytes(), PrevHash: s.prevHash, Hash: chainHash(s.prevHash, entry.Bytes())}
+	var line bytes.Buffer
+	enc := json.NewEncoder(&line)
+	// Keep the entry as hashed, instead of escaping HTML characters in it.
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(line.Bytes()); err != nil {
+		return err
+	}
+	s.prevHash = r.Hash
+	return nil
+}
+
+// FileSink is a Sink that appends entries to a file as hash-chained JSON lines, and lists them by
+// reading the file.
+type FileSink struct {
+	StreamSink
+	path string
+	f    *os.File
+}
+
+// NewFileSink returns the sink that appends entries to the file at path, which is created if it
+// does not exist. Entries are chained to those already in the file, which is verified first.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, prevHash, err := verify(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("audit log %s is not intact: %v", path, err)
+	}
+	return &FileSink{StreamSink: StreamSink{w: f, prevHash: prevHash}, path: path, f: f}, nil
+}
+
+// ListAuditEntries lists the entries of the project in the file. The page token is the byte offset
+// of the record the page starts at. Records appended while the file is read are not listed.
+func (s *FileSink) ListAuditEntries(ctx context.Context, projectID, pageToken string, pageSize int32) ([]*apb.AuditEntry, string, error) {
+	var start int64
+	if pageToken != "" {
+		var err error
+		if start, err = strconv.ParseInt(pageToken, 10, 64); err != nil || start < 0 {
+			return nil, "", status.Errorf(codes.InvalidArgument, "Invalid page token %q", pageToken)
+		}
+	}
+
+	// Only the size of the file is taken under the lock, as records are appended whole while it
+	// is held; the records up to that size are read without it.
+	s.mu.Lock()
+	info, err := s.f.Stat()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, "", status.Errorf(codes.Internal, "Failed to stat audit log: %v", err)
+	}
+	size := info.Size()
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, "", status.Errorf(codes.Internal, "Failed to open audit log: %v", err)
+	}
+	defer f.Close()
+	if start > size || !recordStart(f, start) {
+		return nil, "", status.Errorf(codes.InvalidArgument, "Invalid page token %q", pageToken)
+	}
+
+	var (
+		entries []*apb.AuditEntry
+		next    int64
+	)
+	err = readRecords(io.NewSectionReader(f, start, size-start), func(r *record, offset int64) error {
+		e := &apb.AuditEntry{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(r.Entry, proto.MessageV2(e)); err != nil {
+			return err
+		}
+		if e.Project != projectID {
+			return nil
+		}
+		if len(entries) >= int(pageSize) {
+			next = start + offset
+			return errPageFull
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	switch {
+	case err == errPageFull:
+		return entries, strconv.FormatInt(next, 10), nil
+	case err != nil:
+		return nil, "", status.Errorf(codes.Internal, "Failed to read audit log: %v", err)
+	}
+	return entries, "", nil
+}
+
+// recordStart returns whether a record of the file f starts at the byte offset, i.e. whether it
+// is the start of the file or follows the end of a line.
+func recordStart(f *os.File, offset int64) bool {
+	if offset == 0 {
+		return true
+	}
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, offset-1); err != nil {
+		return false
+	}
+	return b[0] == '\n'
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// errPageFull stops reading records once a page is full.
+var errPageFull = errors.New("page full")
+
+// Verify checks that the records read from r form an unbroken hash chain, i.e. that no record has
+// been changed, inserted or removed, except at the end. It returns the number of records checked,
+// and an error naming the first line that breaks the chain.
+func Verify(r io.Reader) (int, error) {
+	n, _, err := verify(r)
+	return n, err
+}
+
+// verify is Verify, additionally returning the hash of the last record.
+func verify(r io.Reader) (int, string, error) {
+	var (
+		n        int
+		prevHash string
+	)
+	err := readRecords(r, func(rec *record, offset int64) error {
+		line := n + 1
+		if rec.PrevHash != prevHash {
+			return fmt.Errorf("line %d: previous hash %q does not match the hash %q of the previous line", line, rec.PrevHash, prevHash)
+		}
+		if hash := chainHash(prevHash, rec.Entry); rec.Hash != hash {
+			return fmt.Errorf("line %d: hash %q does not match the entry, want %q", line, rec.Hash, hash)
+		}
+		n, prevHash = line, rec.Hash
+		return nil
+	})
+	if err != nil {
+		return n, "", err
+	}
+	return n, prevHash, nil
+}
+
+// readRecords calls fn with the records read from r in order, and the byte offset in r each starts
+// at, until fn returns an error.
+func readRecords(r io.Reader, fn func(rec *record, offset int64) error) error {
+	br := bufio.NewReader(r)
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if err == io.EOF && len(data) == 0 {
+			return nil
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := fn(&rec, offset); err != nil {
+			return err
+		}
+		offset += int64(len(data))
+	}
+}
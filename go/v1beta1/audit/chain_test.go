@@ -0,0 +1,191 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func testEntry(project string, i int) *apb.AuditEntry {
+	return &apb.AuditEntry{
+		Method:    "/grafeas.v1beta1.GrafeasV1Beta1/DeleteNote",
+		Principal: "alice",
+		Project:   project,
+		Resource:  fmt.Sprintf("projects/%s/notes/n%d", project, i),
+		Outcome:   "OK",
+	}
+}
+
+func TestStreamSinkVerify(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	s := NewStreamSink(&buf)
+	for i := 0; i < 3; i++ {
+		e := testEntry("p", i)
+		// HTML characters are kept as they are hashed.
+		e.ErrorMessage = "<note> & <occurrence>"
+		if err := s.Write(ctx, e); err != nil {
+			t.Fatalf("Write got %v want success", err)
+		}
+	}
+	log := buf.String()
+	if n, err := Verify(strings.NewReader(log)); n != 3 || err != nil {
+		t.Fatalf("Verify got %d, %v want 3, nil", n, err)
+	}
+
+	lines := strings.SplitAfter(log, "\n")
+	for _, c := range []struct {
+		desc   string
+		log    string
+		wantN  int
+		wantIn string
+	}{
+		{
+			desc:   "changed entry",
+			log:    lines[0] + strings.Replace(lines[1], "alice", "mallory", 1) + lines[2],
+			wantN:  1,
+			wantIn: "line 2",
+		},
+		{
+			desc:   "removed entry",
+			log:    lines[0] + lines[2],
+			wantN:  1,
+			wantIn: "line 2",
+		},
+		{
+			desc:   "reordered entries",
+			log:    lines[1] + lines[0] + lines[2],
+			wantN:  0,
+			wantIn: "line 1",
+		},
+		{
+			desc:   "garbage",
+			log:    lines[0] + "not json\n",
+			wantN:  1,
+			wantIn: "line 2",
+		},
+	} {
+		n, err := Verify(strings.NewReader(c.log))
+		if n != c.wantN || err == nil || !strings.Contains(err.Error(), c.wantIn) {
+			t.Errorf("%q: Verify got %d, %v want %d and an error on %s", c.desc, n, err, c.wantN, c.wantIn)
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("TempDir got %v want success", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	var want []*apb.AuditEntry
+	// Entries written after reopening the file are chained to those written before.
+	for open := 0; open < 2; open++ {
+		s, err := NewFileSink(path)
+		if err != nil {
+			t.Fatalf("NewFileSink got %v want success", err)
+		}
+		for i := 0; i < 3; i++ {
+			e := testEntry("p", open*3+i)
+			if err := s.Write(ctx, e); err != nil {
+				t.Fatalf("Write got %v want success", err)
+			}
+			want = append(want, e)
+			if err := s.Write(ctx, testEntry("other", i)); err != nil {
+				t.Fatalf("Write got %v want success", err)
+			}
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close got %v want success", err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open got %v want success", err)
+	}
+	n, err := Verify(f)
+	f.Close()
+	if n != 12 || err != nil {
+		t.Errorf("Verify got %d, %v want 12, nil", n, err)
+	}
+
+	s, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink got %v want success", err)
+	}
+	var got []*apb.AuditEntry
+	pageToken := ""
+	for pages := 0; pages < 6; pages++ {
+		es, next, err := s.ListAuditEntries(ctx, "p", pageToken, 4)
+		if err != nil {
+			t.Fatalf("ListAuditEntries got %v want success", err)
+		}
+		got = append(got, es...)
+		if pageToken = next; pageToken == "" {
+			break
+		}
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("ListAuditEntries returned diff (want -> got):\n%s", diff)
+	}
+	for _, token := range []string{"bogus", "-1", "1", "1000000"} {
+		if _, _, err := s.ListAuditEntries(ctx, "p", token, 4); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListAuditEntries with invalid page token %q got %v want InvalidArgument", token, err)
+		}
+	}
+
+	// Entries appended after a page was listed are listed on the following pages.
+	es, next, err := s.ListAuditEntries(ctx, "p", "", 5)
+	if err != nil || len(es) != 5 || next == "" {
+		t.Fatalf("ListAuditEntries got %d entries, %q, %v want 5 entries and a page token", len(es), next, err)
+	}
+	appended := testEntry("p", 100)
+	if err := s.Write(ctx, appended); err != nil {
+		t.Fatalf("Write got %v want success", err)
+	}
+	if es, next, err = s.ListAuditEntries(ctx, "p", next, 5); err != nil || next != "" || len(es) != 2 || !proto.Equal(es[1], appended) {
+		t.Errorf("ListAuditEntries after append got %v, %q, %v want the last entry and the appended one", es, next, err)
+	}
+	s.Close()
+
+	// A log that has been tampered with is not appended to.
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile got %v want success", err)
+	}
+	if err := ioutil.WriteFile(path, bytes.Replace(data, []byte("alice"), []byte("mallory"), 1), 0600); err != nil {
+		t.Fatalf("WriteFile got %v want success", err)
+	}
+	if _, err := NewFileSink(path); err == nil {
+		t.Errorf("NewFileSink of tampered log got success want error")
+	}
+}
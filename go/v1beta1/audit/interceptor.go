@@ -0,0 +1,149 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/grafeas/grafeas/go/logging"
+	"github.com/grafeas/grafeas/go/name"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	fieldmaskpb "google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Interceptor returns a unary server interceptor that writes an entry to sink for every call that
+// creates, updates or deletes a project, note, occurrence or IAM policy, whether it succeeded or
+// not. The principal of an entry is looked up with endUserID, typically the EndUserID method of the
+// Auth of the API. Calls that create several resources get an entry for each. Entries that cannot
+// be written are logged, and do not fail the call.
+func Interceptor(sink Sink, endUserID func(ctx context.Context) (string, error)) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		t, ok := targetOf(req, resp)
+		if !ok {
+			return resp, err
+		}
+		// Calls by unauthenticated callers are recorded without a principal.
+		principal, _ := endUserID(ctx)
+		var errMsg string
+		if err != nil {
+			errMsg = status.Convert(err).Message()
+		}
+		resources := t.resources
+		if len(resources) == 0 {
+			resources = []string{""}
+		}
+		now := ptypes.TimestampNow()
+		for _, r := range resources {
+			e := &apb.AuditEntry{
+				Time:         now,
+				Method:       info.FullMethod,
+				Principal:    principal,
+				Project:      t.projectID,
+				Resource:     r,
+				UpdateMask:   t.mask,
+				Outcome:      status.Code(err).String(),
+				ErrorMessage: errMsg,
+				RequestId:    logging.RequestID(ctx),
+			}
+			if wErr := sink.Write(ctx, e); wErr != nil {
+				log.Printf("failed to write audit entry of %s on %q: %v", info.FullMethod, r, wErr)
+			}
+		}
+		return resp, err
+	}
+}
+
+// target is what a call that is audited changes.
+type target struct {
+	projectID string
+	resources []string
+	mask      *fieldmaskpb.FieldMask
+}
+
+// targetOf returns the target of the call of req that returned resp, and false if the call does
+// not create, update or delete anything. The resources created are taken from resp, and are
+// unknown if the call failed.
+func targetOf(req, resp interface{}) (*target, bool) {
+	var (
+		parent    string
+		resources []string
+		mask      *fieldmaskpb.FieldMask
+	)
+	switch r := req.(type) {
+	case *gpb.CreateNoteRequest:
+		parent = r.Parent
+		if pID, err := name.ParseProject(r.Parent); err == nil && r.NoteId != "" {
+			resources = []string{name.FormatNote(pID, r.NoteId)}
+		}
+	case *gpb.BatchCreateNotesRequest:
+		parent = r.Parent
+		if created, ok := resp.(*gpb.BatchCreateNotesResponse); ok {
+			for _, n := range created.GetNotes() {
+				resources = append(resources, n.Name)
+			}
+		}
+	case *gpb.UpdateNoteRequest:
+		resources, mask = []string{r.Name}, r.UpdateMask
+	case *gpb.DeleteNoteRequest:
+		resources = []string{r.Name}
+	case *gpb.CreateOccurrenceRequest:
+		parent = r.Parent
+		if created, ok := resp.(*gpb.Occurrence); ok && created.GetName() != "" {
+			resources = []string{created.Name}
+		}
+	case *gpb.BatchCreateOccurrencesRequest:
+		parent = r.Parent
+		if created, ok := resp.(*gpb.BatchCreateOccurrencesResponse); ok {
+			for _, o := range created.GetOccurrences() {
+				resources = append(resources, o.Name)
+			}
+		}
+	case *gpb.UpdateOccurrenceRequest:
+		resources, mask = []string{r.Name}, r.UpdateMask
+	case *gpb.DeleteOccurrenceRequest:
+		resources = []string{r.Name}
+	case *prpb.CreateProjectRequest:
+		resources = []string{r.GetProject().GetName()}
+	case *prpb.DeleteProjectRequest:
+		resources = []string{r.Name}
+	case *iampb.SetIamPolicyRequest:
+		resources = []string{r.Resource}
+	default:
+		return nil, false
+	}
+	if parent == "" && len(resources) > 0 {
+		parent = resources[0]
+	}
+	return &target{projectID: projectOf(parent), resources: resources, mask: mask}, true
+}
+
+// projectOf returns the ID of the project of the resource name, or "" if it is not the name of a
+// project or a resource in one.
+func projectOf(resource string) string {
+	parts := strings.SplitN(resource, "/", 3)
+	if len(parts) < 2 || parts[0] != "projects" {
+		return ""
+	}
+	return parts[1]
+}
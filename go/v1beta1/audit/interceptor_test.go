@@ -0,0 +1,205 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/grafeas/grafeas/go/logging"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	fieldmaskpb "google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// fakeSink collects the entries written to it.
+type fakeSink struct {
+	entries []*apb.AuditEntry
+	err     error
+}
+
+func (s *fakeSink) Write(ctx context.Context, e *apb.AuditEntry) error {
+	s.entries = append(s.entries, e)
+	return s.err
+}
+
+func TestInterceptor(t *testing.T) {
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"short_description"}}
+	tests := []struct {
+		desc    string
+		method  string
+		req     interface{}
+		resp    interface{}
+		err     error
+		userErr error
+		want    []*apb.AuditEntry
+	}{
+		{
+			desc:   "create note",
+			method: "/grafeas.v1beta1.GrafeasV1Beta1/CreateNote",
+			req:    &gpb.CreateNoteRequest{Parent: "projects/p", NoteId: "n"},
+			resp:   &gpb.Note{Name: "projects/p/notes/n"},
+			want:   []*apb.AuditEntry{{Project: "p", Resource: "projects/p/notes/n", Outcome: "OK"}},
+		},
+		{
+			desc:   "denied create note",
+			method: "/grafeas.v1beta1.GrafeasV1Beta1/CreateNote",
+			req:    &gpb.CreateNoteRequest{Parent: "projects/p", NoteId: "n"},
+			resp:   (*gpb.Note)(nil),
+			err:    status.Error(codes.PermissionDenied, "denied"),
+			want: []*apb.AuditEntry{{
+				Project: "p", Resource: "projects/p/notes/n", Outcome: "PermissionDenied", ErrorMessage: "denied",
+			}},
+		},
+		{
+			desc:   "create occurrence",
+			method: "/grafeas.v1beta1.GrafeasV1Beta1/CreateOccurrence",
+			req:    &gpb.CreateOccurrenceRequest{Parent: "projects/p"},
+			resp:   &gpb.Occurrence{Name: "projects/p/occurrences/o"},
+			want:   []*apb.AuditEntry{{Project: "p", Resource: "projects/p/occurrences/o", Outcome: "OK"}},
+		},
+		{
+			desc:   "failed create occurrence",
+			method: "/grafeas.v1beta1.GrafeasV1Beta1/CreateOccurrence",
+			req:    &gpb.CreateOccurrenceRequest{Parent: "projects/p"},
+			resp:   (*gpb.Occurrence)(nil),
+			err:    status.Error(codes.InvalidArgument, "invalid"),
+			want:   []*apb.AuditEntry{{Project: "p", Outcome: "InvalidArgument", ErrorMessage: "invalid"}},
+		},
+		{
+			desc:   "batch create occurrences",
+			method: "/grafeas.v1beta1.GrafeasV1Beta1/BatchCreateOccurrences",
+			req:    &gpb.BatchCreateOccurrencesRequest{Parent: "projects/p"},
+			resp: &gpb.BatchCreateOccurrencesResponse{Occurrences: []*gpb.Occurrence{
+				{Name: "projects/p/occurrences/o1"}, {Name: "projects/p/occurrences/o2"},
+			}},
+			want: []*apb.AuditEntry{
+				{Project: "p", Resource: "projects/p/occurrences/o1", Outcome: "OK"},
+				{Project: "p", Resource: "projects/p/occurrences/o2", Outcome: "OK"},
+			},
+		},
+		{
+			desc:   "update note",
+			method: "/grafeas.v1beta1.GrafeasV1Beta1/UpdateNote",
+			req:    &gpb.UpdateNoteRequest{Name: "projects/p/notes/n", UpdateMask: mask},
+			resp:   &gpb.Note{Name: "projects/p/notes/n"},
+			want:   []*apb.AuditEntry{{Project: "p", Resource: "projects/p/notes/n", UpdateMask: mask, Outcome: "OK"}},
+		},
+		{
+			desc:   "delete occurrence",
+			method: "/grafeas.v1beta1.GrafeasV1Beta1/DeleteOccurrence",
+			req:    &gpb.DeleteOccurrenceRequest{Name: "projects/p/occurrences/o"},
+			resp:   &empty.Empty{},
+			want:   []*apb.AuditEntry{{Project: "p", Resource: "projects/p/occurrences/o", Outcome: "OK"}},
+		},
+		{
+			desc:   "create project",
+			method: "/grafeas.v1beta1.project.Projects/CreateProject",
+			req:    &prpb.CreateProjectRequest{Project: &prpb.Project{Name: "projects/p"}},
+			resp:   &prpb.Project{Name: "projects/p"},
+			want:   []*apb.AuditEntry{{Project: "p", Resource: "projects/p", Outcome: "OK"}},
+		},
+		{
+			desc:   "set IAM policy",
+			method: "/google.iam.v1.IAMPolicy/SetIamPolicy",
+			req:    &iampb.SetIamPolicyRequest{Resource: "projects/p/notes/n"},
+			resp:   &iampb.Policy{},
+			want:   []*apb.AuditEntry{{Project: "p", Resource: "projects/p/notes/n", Outcome: "OK"}},
+		},
+		{
+			desc:    "unauthenticated caller",
+			method:  "/grafeas.v1beta1.project.Projects/DeleteProject",
+			req:     &prpb.DeleteProjectRequest{Name: "projects/p"},
+			resp:    (*empty.Empty)(nil),
+			err:     status.Error(codes.PermissionDenied, "denied"),
+			userErr: errors.New("no identity"),
+			want: []*apb.AuditEntry{{
+				Project: "p", Resource: "projects/p", Outcome: "PermissionDenied", ErrorMessage: "denied",
+			}},
+		},
+		{
+			desc:   "get note",
+			method: "/grafeas.v1beta1.GrafeasV1Beta1/GetNote",
+			req:    &gpb.GetNoteRequest{Name: "projects/p/notes/n"},
+			resp:   &gpb.Note{Name: "projects/p/notes/n"},
+		},
+	}
+
+	for _, tt := range tests {
+		sink := &fakeSink{}
+		endUserID := func(context.Context) (string, error) {
+			if tt.userErr != nil {
+				return "", tt.userErr
+			}
+			return "alice", nil
+		}
+		interceptor := Interceptor(sink, endUserID)
+		info := &grpc.UnaryServerInfo{FullMethod: tt.method}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return tt.resp, tt.err
+		}
+		var resp interface{}
+		var err error
+		// Run the interceptor inside the request ID interceptor, as the server does.
+		logging.RequestIDInterceptor(context.Background(), tt.req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			resp, err = interceptor(ctx, req, info, handler)
+			return resp, err
+		})
+		if resp != tt.resp || err != tt.err {
+			t.Errorf("%q: interceptor got %v, %v want %v, %v", tt.desc, resp, err, tt.resp, tt.err)
+		}
+
+		for _, e := range tt.want {
+			e.Method = tt.method
+			if tt.userErr == nil {
+				e.Principal = "alice"
+			}
+		}
+		opts := cmp.Options{protocmp.Transform(), protocmp.IgnoreFields(&apb.AuditEntry{}, "time", "request_id"), cmpopts.EquateEmpty()}
+		if diff := cmp.Diff(tt.want, sink.entries, opts); diff != "" {
+			t.Errorf("%q: entries returned diff (want -> got):\n%s", tt.desc, diff)
+		}
+		for _, e := range sink.entries {
+			if e.Time == nil || e.RequestId == "" {
+				t.Errorf("%q: entry %v has no time or request ID", tt.desc, e)
+			}
+		}
+	}
+}
+
+func TestInterceptorSinkError(t *testing.T) {
+	sink := &fakeSink{err: errors.New("disk full")}
+	interceptor := Interceptor(sink, func(context.Context) (string, error) { return "alice", nil })
+	info := &grpc.UnaryServerInfo{FullMethod: "/grafeas.v1beta1.GrafeasV1Beta1/DeleteNote"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &empty.Empty{}, nil
+	}
+	if _, err := interceptor(context.Background(), &gpb.DeleteNoteRequest{Name: "projects/p/notes/n"}, info, handler); err != nil {
+		t.Errorf("interceptor with failing sink got %v want success", err)
+	}
+	if len(sink.entries) != 1 {
+		t.Errorf("interceptor wrote %d entries want 1", len(sink.entries))
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grafeas/grafeas/go/iam"
+	"github.com/grafeas/grafeas/go/name"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+)
+
+// projectMemberPrefix is the prefix of the members of IAM policies that are projects.
+const projectMemberPrefix = "project:"
+
+// EntityPolicies grants identities the permissions of the IAM policies set on notes and
+// occurrences, in addition to those granted by another Authorizer. The members of the bindings of
+// a policy are identities as in the bindings of the server config, or "project:" followed by the ID
+// of a project, which grants the NotesAttachOccurrence permission to callers attaching occurrences
+// of that project to the note.
+type EntityPolicies struct {
+	base     Authorizer
+	policies grafeas.PolicyStorage
+}
+
+// NewEntityPolicies returns the EntityPolicies that extends base with the IAM policies stored in
+// policies.
+func NewEntityPolicies(base Authorizer, policies grafeas.PolicyStorage) *EntityPolicies {
+	return &EntityPolicies{base: base, policies: policies}
+}
+
+// Allows returns whether the base Authorizer allows one of the identities the permission perm, or
+// else whether the IAM policy of the note or occurrence entityID grants it, if perm is a
+// permission on notes or occurrences respectively. Project members match the project of the
+// occurrence ctx carries.
+func (e *EntityPolicies) Allows(ctx context.Context, identities []string, projectID, entityID string, perm iam.Permission) (bool, error) {
+	if ok, err := e.base.Allows(ctx, identities, projectID, entityID, perm); ok || err != nil {
+		return ok, err
+	}
+	if len(identities) == 0 || entityID == "" {
+		return false, nil
+	}
+	var resource string
+	switch resourceOf(perm) {
+	case string(grafeas.Notes):
+		resource = name.FormatNote(projectID, entityID)
+	case string(grafeas.Occurrences):
+		resource = name.FormatOccurrence(projectID, entityID)
+	default:
+		return false, nil
+	}
+	p, err := e.policies.GetIamPolicy(ctx, resource)
+	if err != nil {
+		return false, err
+	}
+	occurrenceProject, _ := grafeas.OccurrenceProject(ctx)
+	if perm != grafeas.NotesAttachOccurrence {
+		occurrenceProject = ""
+	}
+	for _, b := range p.GetBindings() {
+		if roleGrants(b.Role, perm) && matchesMember(b.Members, identities, occurrenceProject) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchesMember returns whether one of the members is one of the identities, or is the project
+// occurrenceProject if it is not empty. Project members never match identities, so that an
+// identity named like a project member is not granted its permissions.
+func matchesMember(members, identities []string, occurrenceProject string) bool {
+	for _, m := range members {
+		if projectID := strings.TrimPrefix(m, projectMemberPrefix); projectID != m {
+			if occurrenceProject != "" && projectID == occurrenceProject {
+				return true
+			}
+			continue
+		}
+		if m == "*" || contains(identities, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Purge purges the entity from the base Authorizer. The IAM policy of the entity is deleted by
+// the API.
+func (e *EntityPolicies) Purge(ctx context.Context, projectID, entityID string, r iam.Resource) error {
+	return e.base.Purge(ctx, projectID, entityID, r)
+}
+
+// roleGrants returns whether the predefined role grants the permission p.
+func roleGrants(role string, p iam.Permission) bool {
+	for _, q := range grafeas.Roles[role] {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
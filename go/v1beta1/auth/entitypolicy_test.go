@@ -0,0 +1,129 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/iam"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+)
+
+// fakePolicies is a grafeas.PolicyStorage of fixed policies.
+type fakePolicies struct {
+	policies map[string]*iampb.Policy
+	err      error
+}
+
+func (f *fakePolicies) GetIamPolicy(ctx context.Context, resource string) (*iampb.Policy, error) {
+	return f.policies[resource], f.err
+}
+
+func (f *fakePolicies) SetIamPolicy(ctx context.Context, resource string, p *iampb.Policy, etag []byte) error {
+	return errors.New("not implemented")
+}
+
+func (f *fakePolicies) DeleteIamPolicy(ctx context.Context, resource string) error {
+	return errors.New("not implemented")
+}
+
+func TestEntityPolicies(t *testing.T) {
+	base, err := NewPolicy([]config.AuthBinding{
+		{Identities: []string{"group:vendor"}, Projects: []string{"vulns"}, Permissions: []string{"notes.*"}},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy got %v want success", err)
+	}
+	policies := &fakePolicies{policies: map[string]*iampb.Policy{
+		"projects/vulns/notes/CVE-1": {Bindings: []*iampb.Binding{
+			{Role: "note-attacher", Members: []string{"spiffe://example.org/consumer", "group:consumers"}},
+		}},
+		"projects/scans/occurrences/o1": {Bindings: []*iampb.Binding{
+			{Role: "viewer", Members: []string{"*"}},
+		}},
+	}}
+	e := NewEntityPolicies(base, policies)
+
+	consumer := []string{"spiffe://example.org/consumer"}
+	for _, c := range []struct {
+		identities []string
+		project    string
+		entity     string
+		perm       iam.Permission
+		want       bool
+	}{
+		{[]string{"group:vendor"}, "vulns", "CVE-2", grafeas.NotesUpdate, true},
+		{consumer, "vulns", "CVE-1", grafeas.NotesAttachOccurrence, true},
+		{[]string{"bob", "group:consumers"}, "vulns", "CVE-1", grafeas.NotesGet, true},
+		{consumer, "vulns", "CVE-1", grafeas.NotesUpdate, false},
+		{consumer, "vulns", "CVE-2", grafeas.NotesAttachOccurrence, false},
+		{consumer, "other", "CVE-1", grafeas.NotesAttachOccurrence, false},
+		{consumer, "vulns", "", grafeas.NotesCreate, false},
+		{[]string{"someone"}, "vulns", "CVE-1", grafeas.NotesAttachOccurrence, false},
+		{[]string{"someone"}, "scans", "o1", grafeas.OccurrencesGet, true},
+		{[]string{"someone"}, "scans", "o1", grafeas.OccurrencesDelete, false},
+		// A policy on an occurrence does not grant permissions on the note of the same ID.
+		{[]string{"someone"}, "scans", "o1", grafeas.NotesGet, false},
+		{nil, "scans", "o1", grafeas.OccurrencesGet, false},
+	} {
+		if got, err := e.Allows(context.Background(), c.identities, c.project, c.entity, c.perm); got != c.want || err != nil {
+			t.Errorf("Allows(%q, %q, %q, %s) got %v, %v want %v", c.identities, c.project, c.entity, c.perm, got, err, c.want)
+		}
+	}
+
+	policies.err = errors.New("storage is down")
+	if _, err := e.Allows(context.Background(), consumer, "vulns", "CVE-1", grafeas.NotesAttachOccurrence); err == nil {
+		t.Errorf("Allows with failing storage got success want error")
+	}
+	if ok, err := e.Allows(context.Background(), []string{"group:vendor"}, "vulns", "CVE-1", grafeas.NotesGet); !ok || err != nil {
+		t.Errorf("Allows granted by base with failing storage got %v, %v want true, nil", ok, err)
+	}
+}
+
+func TestEntityPoliciesProjectMembers(t *testing.T) {
+	base, err := NewPolicy(nil)
+	if err != nil {
+		t.Fatalf("NewPolicy got %v want success", err)
+	}
+	e := NewEntityPolicies(base, &fakePolicies{policies: map[string]*iampb.Policy{
+		"projects/vulns/notes/CVE-1": {Bindings: []*iampb.Binding{
+			{Role: "note-attacher", Members: []string{"project:scans"}},
+		}},
+	}})
+
+	scans := grafeas.WithOccurrenceProject(context.Background(), "scans")
+	other := grafeas.WithOccurrenceProject(context.Background(), "other")
+	for _, c := range []struct {
+		desc       string
+		ctx        context.Context
+		identities []string
+		perm       iam.Permission
+		want       bool
+	}{
+		{"attach in member project", scans, []string{"someone"}, grafeas.NotesAttachOccurrence, true},
+		{"attach in other project", other, []string{"someone"}, grafeas.NotesAttachOccurrence, false},
+		{"attach without project", context.Background(), []string{"someone"}, grafeas.NotesAttachOccurrence, false},
+		{"other permission of role", scans, []string{"someone"}, grafeas.NotesGet, false},
+		{"identity named like member", other, []string{"project:scans"}, grafeas.NotesAttachOccurrence, false},
+	} {
+		if got, err := e.Allows(c.ctx, c.identities, "vulns", "CVE-1", c.perm); got != c.want || err != nil {
+			t.Errorf("%s: Allows got %v, %v want %v", c.desc, got, err, c.want)
+		}
+	}
+}
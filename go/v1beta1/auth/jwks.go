@@ -0,0 +1,252 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksRefreshInterval is how often a key set is reloaded.
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval is how often a key set is reloaded at most, when tokens are signed
+	// with keys it does not have.
+	jwksMinRefreshInterval = time.Minute
+	// jwksFetchTimeout limits how long fetching a key set from a URL may take.
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// keySet is a JSON Web Key Set, loaded from a file or a URL. Only the public RSA and EC signing
+// keys of the set are used.
+type keySet struct {
+	file, url string
+	client    *http.Client
+
+	mu        sync.Mutex
+	keys      *publicKeys
+	loaded    bool
+	attempted time.Time
+	// refreshed is closed when the refresh in flight is done, and is nil if there is none. err is
+	// the error of the last refresh.
+	refreshed chan struct{}
+	err       error
+}
+
+// publicKeys are the keys of a key set, by key ID, and in a list for those without one.
+type publicKeys struct {
+	byID  map[string]crypto.PublicKey
+	noIDs []crypto.PublicKey
+}
+
+// newKeySet returns the key set in file or at url, which is loaded when its keys are first used.
+func newKeySet(file, url string) *keySet {
+	return &keySet{file: file, url: url, client: &http.Client{Timeout: jwksFetchTimeout}}
+}
+
+// get returns the keys that may have signed a token of the key ID kid, or all keys if kid is
+// empty. The set is refreshed in the background if it is older than jwksRefreshInterval, and
+// waited for if it does not have the key and is older than jwksMinRefreshInterval. Concurrent
+// calls share one refresh, which is not canceled with ctx.
+func (s *keySet) get(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	s.mu.Lock()
+	keys := s.keys.lookup(kid)
+	since := time.Since(s.attempted)
+	var refreshed chan struct{}
+	if !s.loaded || since > jwksRefreshInterval || (len(keys) == 0 && since > jwksMinRefreshInterval) || s.refreshed != nil {
+		refreshed = s.refresh()
+	}
+	s.mu.Unlock()
+	if len(keys) > 0 {
+		return keys, nil
+	}
+
+	if refreshed != nil {
+		select {
+		case <-refreshed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		s.mu.Lock()
+		keys, err := s.keys.lookup(kid), s.err
+		s.mu.Unlock()
+		if len(keys) == 0 && err != nil {
+			return nil, err
+		}
+		if len(keys) > 0 {
+			return keys, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("unknown signing key %q", kid))
+}
+
+// refresh starts reloading the set unless a refresh is in flight, and returns the channel that is
+// closed when the refresh is done. s.mu must be held.
+func (s *keySet) refresh() chan struct{} {
+	if s.refreshed != nil {
+		return s.refreshed
+	}
+	refreshed := make(chan struct{})
+	s.refreshed, s.attempted = refreshed, time.Now()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+		defer cancel()
+		keys, err := s.load(ctx)
+		s.mu.Lock()
+		if err == nil {
+			s.keys, s.loaded = keys, true
+		}
+		s.err, s.refreshed = err, nil
+		s.mu.Unlock()
+		close(refreshed)
+	}()
+	return refreshed
+}
+
+// lookup returns the keys that may have signed a token of the key ID kid: the key with that ID,
+// or else the keys without an ID. If kid is empty, it returns all keys.
+func (k *publicKeys) lookup(kid string) []crypto.PublicKey {
+	if k == nil {
+		return nil
+	}
+	if kid == "" {
+		keys := append([]crypto.PublicKey(nil), k.noIDs...)
+		for _, key := range k.byID {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+	if key, ok := k.byID[kid]; ok {
+		return []crypto.PublicKey{key}
+	}
+	return k.noIDs
+}
+
+// load reads the set from its file or URL.
+func (s *keySet) load(ctx context.Context) (*publicKeys, error) {
+	var data []byte
+	if s.file != "" {
+		b, err := ioutil.ReadFile(s.file)
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("failed to fetch %s: %s", s.url, err))
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.New(fmt.Sprintf("failed to fetch %s: %s", s.url, resp.Status))
+		}
+		if data, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, errors.New(fmt.Sprintf("failed to fetch %s: %s", s.url, err))
+		}
+	}
+	return parseKeySet(data)
+}
+
+// jsonWebKey is a JSON Web Key, as in RFC 7517.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseKeySet returns the public RSA and EC signing keys of a JSON Web Key Set.
+func parseKeySet(data []byte) (*publicKeys, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid key set: %s", err))
+	}
+	keys := &publicKeys{byID: map[string]crypto.PublicKey{}}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			n, errN := decodeBigInt(k.N)
+			e, errE := decodeBigInt(k.E)
+			if errN != nil || errE != nil || !e.IsInt64() {
+				return nil, errors.New(fmt.Sprintf("invalid RSA key %d of key set", i))
+			}
+			key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				return nil, errors.New(fmt.Sprintf("unsupported curve %q of key %d of key set", k.Crv, i))
+			}
+			x, errX := decodeBigInt(k.X)
+			y, errY := decodeBigInt(k.Y)
+			if errX != nil || errY != nil || !curve.IsOnCurve(x, y) {
+				return nil, errors.New(fmt.Sprintf("invalid EC key %d of key set", i))
+			}
+			key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		default:
+			continue
+		}
+		if k.Kid == "" {
+			keys.noIDs = append(keys.noIDs, key)
+		} else {
+			keys.byID[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian integer.
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
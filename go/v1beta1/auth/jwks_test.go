@@ -0,0 +1,127 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeySetWithoutKeyIDs(t *testing.T) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	for i := 0; i < 2; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey got %v want success", err)
+		}
+		var one struct {
+			Keys []json.RawMessage `json:"keys"`
+		}
+		if err := json.Unmarshal(keySetJSON(t, map[string]crypto.Signer{"": key}), &one); err != nil {
+			t.Fatalf("json.Unmarshal got %v want success", err)
+		}
+		set.Keys = append(set.Keys, one.Keys...)
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("json.Marshal got %v want success", err)
+	}
+	keys, err := parseKeySet(data)
+	if err != nil {
+		t.Fatalf("parseKeySet got %v want success", err)
+	}
+	// Keys without an ID do not replace each other, and may have signed tokens of any key ID.
+	if got := len(keys.lookup("")); got != 2 {
+		t.Errorf("lookup of all keys got %d keys want 2", got)
+	}
+	if got := len(keys.lookup("other")); got != 2 {
+		t.Errorf("lookup of an unknown key ID got %d keys want 2", got)
+	}
+}
+
+func TestKeySetRefresh(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey got %v want success", err)
+	}
+	var (
+		mu      sync.Mutex
+		fetches int
+	)
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		w.Write(keySetJSON(t, map[string]crypto.Signer{"key": key}))
+	}))
+	defer s.Close()
+	ks := newKeySet("", s.URL)
+
+	// A canceled call does not cancel the fetch, which the next call shares.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ks.get(ctx, "key"); err != context.Canceled {
+		t.Errorf("get with a canceled context got %v want %v", err, context.Canceled)
+	}
+	got := make(chan error)
+	go func() {
+		_, err := ks.get(context.Background(), "key")
+		got <- err
+	}()
+	close(release)
+	if err := <-got; err != nil {
+		t.Fatalf("get got %v want success", err)
+	}
+
+	// A token of an unknown key waits for a refresh, without holding up tokens of known keys.
+	release = make(chan struct{})
+	ks.mu.Lock()
+	ks.attempted = time.Now().Add(-2 * jwksMinRefreshInterval)
+	ks.mu.Unlock()
+	go func() {
+		_, err := ks.get(context.Background(), "unknown")
+		got <- err
+	}()
+	for refreshing := false; !refreshing; time.Sleep(time.Millisecond) {
+		ks.mu.Lock()
+		refreshing = ks.refreshed != nil
+		ks.mu.Unlock()
+	}
+	if keys, err := ks.get(context.Background(), "key"); err != nil || len(keys) != 1 {
+		t.Errorf("get of a known key during a refresh got %v, %v want the key", keys, err)
+	}
+	close(release)
+	if err := <-got; err == nil {
+		t.Errorf("get of an unknown key got success want error")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fetches != 2 {
+		t.Errorf("key set fetches got %d want 2", fetches)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// jwt is a JSON Web Token in JWS compact serialization, as in RFC 7519, whose signature has not
+// necessarily been verified.
+type jwt struct {
+	alg, kid  string
+	claims    map[string]interface{}
+	signed    []byte
+	signature []byte
+}
+
+// parseJWT parses a JSON Web Token without verifying it.
+func parseJWT(token string) (*jwt, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJSON(parts[0], &header); err != nil {
+		return nil, errors.New(fmt.Sprintf("malformed token header: %s", err))
+	}
+	t := &jwt{alg: header.Alg, kid: header.Kid, signed: []byte(parts[0] + "." + parts[1])}
+	if err := decodeJSON(parts[1], &t.claims); err != nil || t.claims == nil {
+		return nil, errors.New("malformed token claims")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	t.signature = signature
+	return t, nil
+}
+
+// decodeJSON decodes the base64url-encoded JSON s into v.
+func decodeJSON(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// hashes maps the digits of the supported signature algorithms to their hashes.
+var hashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// curves maps the ECDSA signature algorithms to the curves of their keys.
+var curves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// verify returns whether t is signed with key. Only the RS, PS and ES algorithms are supported;
+// in particular unsigned and HMAC-signed tokens are rejected.
+func (t *jwt) verify(key crypto.PublicKey) error {
+	if len(t.alg) != 5 {
+		return errors.New(fmt.Sprintf("unsupported signature algorithm %q", t.alg))
+	}
+	h, ok := hashes[t.alg[2:]]
+	if !ok {
+		return errors.New(fmt.Sprintf("unsupported signature algorithm %q", t.alg))
+	}
+	hasher := h.New()
+	hasher.Write(t.signed)
+	digest := hasher.Sum(nil)
+
+	switch t.alg[:2] {
+	case "RS", "PS":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New(fmt.Sprintf("signature algorithm %q does not match key", t.alg))
+		}
+		if t.alg[:2] == "RS" {
+			return rsa.VerifyPKCS1v15(k, h, digest, t.signature)
+		}
+		return rsa.VerifyPSS(k, h, digest, t.signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || k.Curve != curves[t.alg] {
+			return errors.New(fmt.Sprintf("signature algorithm %q does not match key", t.alg))
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(t.signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("unsupported signature algorithm %q", t.alg))
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/grafeas/grafeas/go/iam"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ForwardedIdentityKey is the metadata key the REST gateway forwards the identity of REST
+	// clients in.
+	ForwardedIdentityKey = "x-grafeas-forwarded-identity"
+	// GatewayTokenKey is the metadata key of the token with which the REST gateway proves that it
+	// forwarded a call.
+	GatewayTokenKey = "x-grafeas-gateway-token"
+)
+
+// MTLS authenticates callers by their verified client certificates and authorizes them with an
+// Authorizer. The identity of a caller is the first URI subject alternative name of its
+// certificate, e.g. a SPIFFE ID, or else its subject common name.
+//
+// Calls over the REST gateway carry the gRPC peer certificate of the gateway, which therefore
+// forwards the identity of the REST client, along with a token only the server knows, in the
+// metadata returned by GatewayMetadata.
+type MTLS struct {
+	authz        Authorizer
+	gatewayToken string
+}
+
+// NewMTLS returns an MTLS Auth that authorizes callers with authz.
+func NewMTLS(authz Authorizer) (*MTLS, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	return &MTLS{authz: authz, gatewayToken: hex.EncodeToString(token)}, nil
+}
+
+// Identity returns the identity of a client certificate.
+func Identity(c *x509.Certificate) string {
+	if len(c.URIs) > 0 {
+		return c.URIs[0].String()
+	}
+	return c.Subject.CommonName
+}
+
+// GatewayMetadata returns the metadata the REST gateway adds to the call of the REST request r:
+// the gateway token, and the identity of the client if it presented a verified certificate.
+func (a *MTLS) GatewayMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	md := metadata.Pairs(GatewayTokenKey, a.gatewayToken)
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		md.Set(ForwardedIdentityKey, Identity(r.TLS.VerifiedChains[0][0]))
+	}
+	return md
+}
+
+// IsGatewayHeader returns whether the HTTP header key is reserved for metadata of the gateway,
+// which REST clients must not set.
+func IsGatewayHeader(key string) bool {
+	key = strings.TrimPrefix(strings.ToLower(key), "grpc-metadata-")
+	return key == ForwardedIdentityKey || key == GatewayTokenKey
+}
+
+// identity returns the identity of the caller of the call ctx belongs to. Identities that name
+// groups are rejected, as certificates do not carry groups.
+func (a *MTLS) identity(ctx context.Context) (string, error) {
+	id, err := a.certificateIdentity(ctx)
+	if err == nil && strings.HasPrefix(id, GroupPrefix) {
+		return "", status.Errorf(codes.Unauthenticated, "invalid client certificate identity %q", id)
+	}
+	return id, err
+}
+
+// certificateIdentity returns the identity of the verified client certificate of the caller.
+func (a *MTLS) certificateIdentity(ctx context.Context) (string, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if tokens := md.Get(GatewayTokenKey); len(tokens) > 0 {
+			if len(tokens) != 1 || subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(a.gatewayToken)) != 1 {
+				return "", status.Error(codes.Unauthenticated, "invalid gateway token")
+			}
+			if ids := md.Get(ForwardedIdentityKey); len(ids) == 1 && ids[0] != "" {
+				return ids[0], nil
+			}
+			return "", status.Error(codes.Unauthenticated, "no verified client certificate")
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			if id := Identity(info.State.VerifiedChains[0][0]); id != "" {
+				return id, nil
+			}
+		}
+	}
+	return "", status.Error(codes.Unauthenticated, "no verified client certificate")
+}
+
+// CheckAccessAndProject allows the call if authz grants the caller p on the entity.
+func (a *MTLS) CheckAccessAndProject(ctx context.Context, projectID string, entityID string, p iam.Permission) error {
+	id, err := a.identity(ctx)
+	if err != nil {
+		return status.Errorf(codes.PermissionDenied, "permission %s denied on project %q", p, projectID)
+	}
+	return checkAllowed(ctx, a.authz, []string{id}, projectID, entityID, p)
+}
+
+// EndUserID returns the identity of the caller.
+func (a *MTLS) EndUserID(ctx context.Context) (string, error) {
+	return a.identity(ctx)
+}
+
+// PurgePolicy revokes the permissions granted on the deleted entity.
+func (a *MTLS) PurgePolicy(ctx context.Context, projectID string, entityID string, r iam.Resource) error {
+	return a.authz.Purge(ctx, projectID, entityID, r)
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// peerContext returns a context of a call whose peer presented the verified certificate c, or no
+// certificate if c is nil.
+func peerContext(c *x509.Certificate) context.Context {
+	var state tls.ConnectionState
+	if c != nil {
+		state.VerifiedChains = [][]*x509.Certificate{{c}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+}
+
+func TestMTLS(t *testing.T) {
+	p, err := NewPolicy([]config.AuthBinding{{
+		Identities:  []string{"spiffe://example.org/scanner", "group:admins"},
+		Projects:    []string{"scans"},
+		Permissions: []string{"occurrences.create"},
+	}})
+	if err != nil {
+		t.Fatalf("NewPolicy got %v want success", err)
+	}
+	a, err := NewMTLS(p)
+	if err != nil {
+		t.Fatalf("NewMTLS got %v want success", err)
+	}
+
+	spiffeID, _ := url.Parse("spiffe://example.org/scanner")
+	scanner := &x509.Certificate{Subject: pkix.Name{CommonName: "scanner"}, URIs: []*url.URL{spiffeID}}
+	gateway := &x509.Certificate{Subject: pkix.Name{CommonName: "localhost"}}
+	group := &x509.Certificate{Subject: pkix.Name{CommonName: "group:admins"}}
+
+	// The gateway forwards the identity of a REST client that presented scanner.
+	r := httptest.NewRequest("POST", "/v1beta1/projects/scans/occurrences", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{scanner}}}
+	forwarded := metadata.NewIncomingContext(peerContext(gateway), a.GatewayMetadata(context.Background(), r))
+	// Without a client certificate, the gateway forwards no identity.
+	r.TLS = nil
+	anonymous := metadata.NewIncomingContext(peerContext(gateway), a.GatewayMetadata(context.Background(), r))
+	spoofed := metadata.NewIncomingContext(peerContext(gateway), metadata.Pairs(GatewayTokenKey, "guess", ForwardedIdentityKey, "spiffe://example.org/scanner"))
+
+	for _, c := range []struct {
+		name    string
+		ctx     context.Context
+		project string
+		wantID  string
+		wantErr codes.Code
+	}{
+		{name: "peer certificate", ctx: peerContext(scanner), project: "scans", wantID: "spiffe://example.org/scanner", wantErr: codes.OK},
+		{name: "other project", ctx: peerContext(scanner), project: "other", wantID: "spiffe://example.org/scanner", wantErr: codes.PermissionDenied},
+		{name: "no certificate", ctx: peerContext(nil), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "forwarded", ctx: forwarded, project: "scans", wantID: "spiffe://example.org/scanner", wantErr: codes.OK},
+		{name: "forwarded without certificate", ctx: anonymous, project: "scans", wantErr: codes.PermissionDenied},
+		{name: "spoofed", ctx: spoofed, project: "scans", wantErr: codes.PermissionDenied},
+		{name: "group identity", ctx: peerContext(group), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "gateway certificate", ctx: peerContext(gateway), project: "scans", wantID: "localhost", wantErr: codes.PermissionDenied},
+	} {
+		err := a.CheckAccessAndProject(c.ctx, c.project, "", grafeas.OccurrencesCreate)
+		if status.Code(err) != c.wantErr {
+			t.Errorf("CheckAccessAndProject with %s got %v want %v", c.name, err, c.wantErr)
+		}
+		id, err := a.EndUserID(c.ctx)
+		if id != c.wantID || (err != nil) != (c.wantID == "") {
+			t.Errorf("EndUserID with %s got %q, %v want %q", c.name, id, err, c.wantID)
+		}
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	if got := Identity(&x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}); got != "alice" {
+		t.Errorf("Identity got %q want the common name alice", got)
+	}
+}
+
+func TestIsGatewayHeader(t *testing.T) {
+	for key, want := range map[string]bool{
+		"Grpc-Metadata-X-Grafeas-Forwarded-Identity": true,
+		"X-Grafeas-Gateway-Token":                    true,
+		"Grpc-Metadata-X-Request-Id":                 false,
+	} {
+		if got := IsGatewayHeader(key); got != want {
+			t.Errorf("IsGatewayHeader(%q) got %v want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/iam"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tokenLeeway is how much the clocks of the server and of token issuers may differ.
+const tokenLeeway = time.Minute
+
+// OIDC authenticates callers by the JWT bearer tokens of configured issuers in their
+// Authorization header, which the REST gateway forwards as metadata, and authorizes them with an
+// Authorizer. The identities of a caller are the user ID claim of its token and the groups of its
+// groups claim.
+type OIDC struct {
+	authz   Authorizer
+	issuers map[string]*issuer
+}
+
+// issuer is an issuer of tokens and the keys they are signed with.
+type issuer struct {
+	config config.OIDCIssuerConfig
+	keys   *keySet
+}
+
+// NewOIDC returns an OIDC Auth that accepts tokens of issuers and authorizes callers with authz.
+// Key sets in files are loaded before it returns, key sets at URLs when they are first used.
+func NewOIDC(authz Authorizer, issuers []config.OIDCIssuerConfig) (*OIDC, error) {
+	if len(issuers) == 0 {
+		return nil, errors.New("no issuers")
+	}
+	a := &OIDC{authz: authz, issuers: map[string]*issuer{}}
+	for i, c := range issuers {
+		switch {
+		case c.Issuer == "":
+			return nil, errors.New(fmt.Sprintf("issuer %d has no issuer", i))
+		case a.issuers[c.Issuer] != nil:
+			return nil, errors.New(fmt.Sprintf("issuer %q is configured twice", c.Issuer))
+		case len(c.Audiences) == 0:
+			// Shared issuers mint tokens for other services too, which must not be accepted.
+			return nil, errors.New(fmt.Sprintf("issuer %q has no audiences", c.Issuer))
+		case (c.JWKSFile == "") == (c.JWKSURL == ""):
+			return nil, errors.New(fmt.Sprintf("issuer %q needs exactly one of jwks_file and jwks_url", c.Issuer))
+		}
+		if c.UserClaim == "" {
+			c.UserClaim = "sub"
+		}
+		if c.GroupsClaim == "" {
+			c.GroupsClaim = "groups"
+		}
+		keys := newKeySet(c.JWKSFile, c.JWKSURL)
+		if c.JWKSFile != "" {
+			if _, err := keys.get(context.Background(), ""); err != nil {
+				return nil, errors.New(fmt.Sprintf("failed to load keys of issuer %q: %s", c.Issuer, err))
+			}
+		}
+		a.issuers[c.Issuer] = &issuer{config: c, keys: keys}
+	}
+	return a, nil
+}
+
+// identities returns the identities of the caller of the call ctx belongs to: its user ID,
+// followed by its groups prefixed with GroupPrefix.
+func (a *OIDC) identities(ctx context.Context) ([]string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	headers := md.Get("authorization")
+	if len(headers) != 1 {
+		return nil, errors.New("no bearer token")
+	}
+	if len(headers[0]) < 7 || !strings.EqualFold(headers[0][:7], "bearer ") {
+		return nil, errors.New("no bearer token")
+	}
+	t, err := parseJWT(strings.TrimSpace(headers[0][7:]))
+	if err != nil {
+		return nil, err
+	}
+	iss, _ := t.claims["iss"].(string)
+	i, ok := a.issuers[iss]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown issuer %q", iss))
+	}
+	keys, err := i.keys.get(ctx, t.kid)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if err = t.verify(k); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := i.validate(t.claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	user, _ := t.claims[i.config.UserClaim].(string)
+	if user == "" || strings.HasPrefix(user, GroupPrefix) {
+		return nil, errors.New(fmt.Sprintf("invalid %s claim %q", i.config.UserClaim, user))
+	}
+	ids := []string{user}
+	switch groups := t.claims[i.config.GroupsClaim].(type) {
+	case string:
+		ids = append(ids, GroupPrefix+groups)
+	case []interface{}:
+		for _, g := range groups {
+			if g, ok := g.(string); ok {
+				ids = append(ids, GroupPrefix+g)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// validate returns an error if claims are not those of a token of i that is valid at now.
+func (i *issuer) validate(claims map[string]interface{}, now time.Time) error {
+	var auds []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		auds = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if a, ok := a.(string); ok {
+				auds = append(auds, a)
+			}
+		}
+	}
+	if !matchesAny(i.config.Audiences, auds) {
+		return errors.New(fmt.Sprintf("token audience %v is not one of %v", auds, i.config.Audiences))
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token has no expiry")
+	}
+	if now.Add(-tokenLeeway).After(time.Unix(int64(exp), 0)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(tokenLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not valid yet")
+	}
+	return nil
+}
+
+// CheckAccessAndProject allows the call if authz grants the caller p on the entity.
+func (a *OIDC) CheckAccessAndProject(ctx context.Context, projectID string, entityID string, p iam.Permission) error {
+	ids, err := a.identities(ctx)
+	if err != nil {
+		return status.Errorf(codes.PermissionDenied, "permission %s denied on project %q: %s", p, projectID, err)
+	}
+	return checkAllowed(ctx, a.authz, ids, projectID, entityID, p)
+}
+
+// EndUserID returns the user ID of the caller.
+func (a *OIDC) EndUserID(ctx context.Context) (string, error) {
+	ids, err := a.identities(ctx)
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+	return ids[0], nil
+}
+
+// PurgePolicy revokes the permissions granted on the deleted entity.
+func (a *OIDC) PurgePolicy(ctx context.Context, projectID string, entityID string, r iam.Resource) error {
+	return a.authz.Purge(ctx, projectID, entityID, r)
+}
@@ -0,0 +1,276 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testIssuer = "https://issuer.example.org"
+
+// signToken returns a token of claims signed with key by the algorithm alg, whose header names the
+// key kid.
+func signToken(t *testing.T, key crypto.Signer, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal got %v want success", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		s, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("rsa.SignPKCS1v15 got %v want success", err)
+		}
+		sig = s
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("ecdsa.Sign got %v want success", err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// keySetJSON returns the JSON Web Key Set of the public keys of keys by key ID.
+func keySetJSON(t *testing.T, keys map[string]crypto.Signer) []byte {
+	t.Helper()
+	encode := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	var set []map[string]string
+	for kid, key := range keys {
+		switch k := key.Public().(type) {
+		case *rsa.PublicKey:
+			set = append(set, map[string]string{"kty": "RSA", "kid": kid, "n": encode(k.N), "e": encode(big.NewInt(int64(k.E)))})
+		case *ecdsa.PublicKey:
+			set = append(set, map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": encode(k.X), "y": encode(k.Y)})
+		}
+	}
+	b, err := json.Marshal(map[string]interface{}{"keys": set})
+	if err != nil {
+		t.Fatalf("json.Marshal got %v want success", err)
+	}
+	return b
+}
+
+// bearerContext returns the context of a call with the bearer token.
+func bearerContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestOIDC(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey got %v want success", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey got %v want success", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey got %v want success", err)
+	}
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(jwksFile, keySetJSON(t, map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey}), 0600); err != nil {
+		t.Fatalf("WriteFile got %v want success", err)
+	}
+
+	p, err := NewPolicy([]config.AuthBinding{{
+		Identities:  []string{"group:ci"},
+		Projects:    []string{"scans"},
+		Permissions: []string{"occurrences.create"},
+	}})
+	if err != nil {
+		t.Fatalf("NewPolicy got %v want success", err)
+	}
+	a, err := NewOIDC(p, []config.OIDCIssuerConfig{{Issuer: testIssuer, Audiences: []string{"grafeas"}, JWKSFile: jwksFile}})
+	if err != nil {
+		t.Fatalf("NewOIDC got %v want success", err)
+	}
+
+	now := time.Now()
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":    testIssuer,
+			"aud":    []string{"other", "grafeas"},
+			"sub":    "build-bot",
+			"groups": []string{"ci"},
+			"exp":    now.Add(time.Hour).Unix(),
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	valid := signToken(t, rsaKey, "RS256", "rsa", claims(nil))
+	unsigned := valid[:len(valid)-10] + "AAAAAAAAAA"
+
+	for _, c := range []struct {
+		name    string
+		ctx     context.Context
+		project string
+		wantID  string
+		wantErr codes.Code
+	}{
+		{name: "RSA token", ctx: bearerContext(valid), project: "scans", wantID: "build-bot", wantErr: codes.OK},
+		{name: "EC token", ctx: bearerContext(signToken(t, ecKey, "ES256", "ec", claims(nil))), project: "scans", wantID: "build-bot", wantErr: codes.OK},
+		{name: "token without key ID", ctx: bearerContext(signToken(t, ecKey, "ES256", "", claims(nil))), project: "scans", wantID: "build-bot", wantErr: codes.OK},
+		{name: "single group", ctx: bearerContext(signToken(t, ecKey, "ES256", "ec", claims(map[string]interface{}{"groups": "ci"}))), project: "scans", wantID: "build-bot", wantErr: codes.OK},
+		{name: "other project", ctx: bearerContext(valid), project: "other", wantID: "build-bot", wantErr: codes.PermissionDenied},
+		{name: "other group", ctx: bearerContext(signToken(t, ecKey, "ES256", "ec", claims(map[string]interface{}{"groups": []string{"dev"}}))), project: "scans", wantID: "build-bot", wantErr: codes.PermissionDenied},
+		{name: "group as user", ctx: bearerContext(signToken(t, ecKey, "ES256", "ec", claims(map[string]interface{}{"sub": "group:ci", "groups": nil}))), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "no token", ctx: context.Background(), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "bad signature", ctx: bearerContext(unsigned), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "unknown key", ctx: bearerContext(signToken(t, otherKey, "ES256", "other", claims(nil))), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "key of another type", ctx: bearerContext(signToken(t, ecKey, "ES256", "rsa", claims(nil))), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "other issuer", ctx: bearerContext(signToken(t, rsaKey, "RS256", "rsa", claims(map[string]interface{}{"iss": "https://evil.example.org"}))), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "no audience", ctx: bearerContext(signToken(t, rsaKey, "RS256", "rsa", claims(map[string]interface{}{"aud": nil}))), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "other audience", ctx: bearerContext(signToken(t, rsaKey, "RS256", "rsa", claims(map[string]interface{}{"aud": "other"}))), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "expired", ctx: bearerContext(signToken(t, rsaKey, "RS256", "rsa", claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()}))), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "no expiry", ctx: bearerContext(signToken(t, rsaKey, "RS256", "rsa", claims(map[string]interface{}{"exp": nil}))), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "not valid yet", ctx: bearerContext(signToken(t, rsaKey, "RS256", "rsa", claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()}))), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "algorithm none", ctx: bearerContext(signToken(t, rsaKey, "none", "rsa", claims(nil))), project: "scans", wantErr: codes.PermissionDenied},
+		{name: "algorithm HS256", ctx: bearerContext(signToken(t, rsaKey, "HS256", "rsa", claims(nil))), project: "scans", wantErr: codes.PermissionDenied},
+	} {
+		err := a.CheckAccessAndProject(c.ctx, c.project, "", grafeas.OccurrencesCreate)
+		if status.Code(err) != c.wantErr {
+			t.Errorf("CheckAccessAndProject with %s got %v want %v", c.name, err, c.wantErr)
+		}
+		id, err := a.EndUserID(c.ctx)
+		if id != c.wantID || (err != nil) != (c.wantID == "") {
+			t.Errorf("EndUserID with %s got %q, %v want %q", c.name, id, err, c.wantID)
+		}
+	}
+}
+
+func TestOIDCKeySetURL(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey got %v want success", err)
+	}
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey got %v want success", err)
+	}
+	var mu sync.Mutex
+	jwks := keySetJSON(t, map[string]crypto.Signer{"old": oldKey})
+	fetches := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		w.Write(jwks)
+	}))
+	defer s.Close()
+
+	p, err := NewPolicy([]config.AuthBinding{{Identities: []string{"*"}, Projects: []string{"*"}, Permissions: []string{"*"}}})
+	if err != nil {
+		t.Fatalf("NewPolicy got %v want success", err)
+	}
+	a, err := NewOIDC(p, []config.OIDCIssuerConfig{{Issuer: testIssuer, Audiences: []string{"grafeas"}, JWKSURL: s.URL, UserClaim: "email"}})
+	if err != nil {
+		t.Fatalf("NewOIDC got %v want success", err)
+	}
+	claims := map[string]interface{}{"iss": testIssuer, "aud": "grafeas", "email": "alice@example.org", "exp": time.Now().Add(time.Hour).Unix()}
+
+	if id, err := a.EndUserID(bearerContext(signToken(t, oldKey, "ES256", "old", claims))); err != nil || id != "alice@example.org" {
+		t.Errorf("EndUserID with key at URL got %q, %v want alice@example.org", id, err)
+	}
+
+	// The issuer rotates its key; the set is refetched for a token signed with the new key, but not
+	// more often than jwksMinRefreshInterval.
+	mu.Lock()
+	jwks = keySetJSON(t, map[string]crypto.Signer{"new": newKey})
+	mu.Unlock()
+	newToken := signToken(t, newKey, "ES256", "new", claims)
+	if _, err := a.EndUserID(bearerContext(newToken)); err == nil {
+		t.Errorf("EndUserID with rotated key right after fetching got success want error")
+	}
+	a.issuers[testIssuer].keys.attempted = time.Now().Add(-2 * jwksMinRefreshInterval)
+	if id, err := a.EndUserID(bearerContext(newToken)); err != nil || id != "alice@example.org" {
+		t.Errorf("EndUserID with rotated key got %q, %v want alice@example.org", id, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fetches != 2 {
+		t.Errorf("key set fetches got %d want 2", fetches)
+	}
+}
+
+func TestNewOIDC(t *testing.T) {
+	p, err := NewPolicy(nil)
+	if err != nil {
+		t.Fatalf("NewPolicy got %v want success", err)
+	}
+	invalid := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(invalid, []byte("{"), 0600); err != nil {
+		t.Fatalf("WriteFile got %v want success", err)
+	}
+	for _, c := range []struct {
+		name    string
+		issuers []config.OIDCIssuerConfig
+	}{
+		{name: "no issuers"},
+		{name: "no issuer", issuers: []config.OIDCIssuerConfig{{Audiences: []string{"grafeas"}, JWKSURL: "https://example.org/jwks"}}},
+		{name: "no audiences", issuers: []config.OIDCIssuerConfig{{Issuer: testIssuer, JWKSURL: "https://example.org/jwks"}}},
+		{name: "no key set", issuers: []config.OIDCIssuerConfig{{Issuer: testIssuer, Audiences: []string{"grafeas"}}}},
+		{name: "two key sets", issuers: []config.OIDCIssuerConfig{{Issuer: testIssuer, Audiences: []string{"grafeas"}, JWKSFile: invalid, JWKSURL: "https://example.org/jwks"}}},
+		{name: "missing key set file", issuers: []config.OIDCIssuerConfig{{Issuer: testIssuer, Audiences: []string{"grafeas"}, JWKSFile: invalid + ".missing"}}},
+		{name: "invalid key set file", issuers: []config.OIDCIssuerConfig{{Issuer: testIssuer, Audiences: []string{"grafeas"}, JWKSFile: invalid}}},
+		{name: "duplicate issuer", issuers: []config.OIDCIssuerConfig{
+			{Issuer: testIssuer, Audiences: []string{"grafeas"}, JWKSURL: "https://example.org/jwks"},
+			{Issuer: testIssuer, Audiences: []string{"grafeas"}, JWKSURL: "https://example.org/jwks"},
+		}},
+	} {
+		if _, err := NewOIDC(p, c.issuers); err == nil {
+			t.Errorf("NewOIDC with %s got success want error", c.name)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth implements the Auth of the Grafeas API: callers are authenticated by their client
+// certificates or OIDC tokens, and authorized by the bindings of the server config or of a policy
+// file, and by the IAM policies of notes and occurrences.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/iam"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Authorizer decides which permissions the identities of callers have.
+type Authorizer interface {
+	// Allows returns whether one of the identities of a caller has the permission p on the entity
+	// entityID of the project projectID. entityID is empty for permissions on the project itself,
+	// such as notes.create. The identities of a caller are its ID and its groups, prefixed with
+	// GroupPrefix.
+	Allows(ctx context.Context, identities []string, projectID, entityID string, p iam.Permission) (bool, error)
+	// Purge revokes the permissions granted on the entity entityID of type r of the project
+	// projectID, which has been deleted.
+	Purge(ctx context.Context, projectID, entityID string, r iam.Resource) error
+}
+
+// checkAllowed returns a PermissionDenied error unless authz allows one of the identities p on
+// the entity entityID of the project projectID.
+func checkAllowed(ctx context.Context, authz Authorizer, identities []string, projectID, entityID string, p iam.Permission) error {
+	ok, err := authz.Allows(ctx, identities, projectID, entityID, p)
+	if err != nil {
+		return status.Errorf(codes.PermissionDenied, "permission %s denied on project %q: %s", p, projectID, err)
+	}
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "permission %s denied on project %q", p, projectID)
+	}
+	return nil
+}
+
+// Policy grants identities permissions on projects, as bindings of the server config say.
+type Policy struct {
+	bindings []config.AuthBinding
+}
+
+// NewPolicy returns the Policy of bindings, or an error if they grant unknown permissions.
+func NewPolicy(bindings []config.AuthBinding) (*Policy, error) {
+	for i, b := range bindings {
+		for _, p := range b.Permissions {
+			if !knownPermission(p) {
+				return nil, errors.New(fmt.Sprintf("binding %d grants unknown permission %q", i, p))
+			}
+		}
+	}
+	return &Policy{bindings: bindings}, nil
+}
+
+// knownPermission returns whether p is a permission of the Grafeas API, all permissions on one of
+// its resources, or "*".
+func knownPermission(p string) bool {
+	if p == "*" {
+		return true
+	}
+	for _, q := range grafeas.Permissions {
+		if p == string(q) || p == resourceOf(q)+".*" {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceOf returns the resource p is a permission on, e.g. "notes" for "notes.create".
+func resourceOf(p iam.Permission) string {
+	return strings.SplitN(string(p), ".", 2)[0]
+}
+
+// GroupPrefix is the prefix of the identities of groups in bindings.
+const GroupPrefix = "group:"
+
+// Allows returns whether a binding grants one of the identities the permission perm on the
+// project projectID.
+func (p *Policy) Allows(ctx context.Context, identities []string, projectID, entityID string, perm iam.Permission) (bool, error) {
+	if len(identities) == 0 {
+		return false, nil
+	}
+	for _, b := range p.bindings {
+		if matchesAny(b.Identities, identities) && matches(b.Projects, projectID) && grants(b.Permissions, perm) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Purge does nothing, as bindings of the server config are not on entities.
+func (p *Policy) Purge(ctx context.Context, projectID, entityID string, r iam.Resource) error {
+	return nil
+}
+
+// matches returns whether values has v or "*".
+func matches(values []string, v string) bool {
+	for _, w := range values {
+		if w == v || w == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny returns whether values has one of vs, or "*".
+func matchesAny(values []string, vs []string) bool {
+	for _, v := range vs {
+		if matches(values, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// grants returns whether permissions has p, all permissions on its resource, or "*".
+func grants(permissions []string, p iam.Permission) bool {
+	for _, q := range permissions {
+		if q == string(p) || q == resourceOf(p)+".*" || q == "*" {
+			return true
+		}
+	}
+	return false
+}
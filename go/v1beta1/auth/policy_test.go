@@ -0,0 +1,76 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/iam"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+)
+
+func TestPolicy(t *testing.T) {
+	p, err := NewPolicy([]config.AuthBinding{
+		{
+			Identities:  []string{"spiffe://example.org/scanner"},
+			Projects:    []string{"scans"},
+			Permissions: []string{"notes.create", "occurrences.*"},
+		},
+		{
+			Identities:  []string{"*"},
+			Projects:    []string{"*"},
+			Permissions: []string{"notes.get"},
+		},
+		{
+			Identities:  []string{"group:admins"},
+			Projects:    []string{"*"},
+			Permissions: []string{"*"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy got %v want success", err)
+	}
+	for _, c := range []struct {
+		identities []string
+		project    string
+		perm       iam.Permission
+		want       bool
+	}{
+		{[]string{"spiffe://example.org/scanner"}, "scans", grafeas.NotesCreate, true},
+		{[]string{"spiffe://example.org/scanner"}, "scans", grafeas.OccurrencesCreate, true},
+		{[]string{"spiffe://example.org/scanner"}, "scans", grafeas.NotesDelete, false},
+		{[]string{"spiffe://example.org/scanner"}, "other", grafeas.OccurrencesCreate, false},
+		{[]string{"spiffe://example.org/scanner"}, "other", grafeas.NotesGet, true},
+		{[]string{"someone"}, "other", grafeas.NotesGet, true},
+		{[]string{"someone"}, "other", grafeas.NotesList, false},
+		{[]string{"someone", "group:admins"}, "other", grafeas.NotesAttachOccurrence, true},
+		{[]string{"group:admins"}, "other", grafeas.NotesDelete, true},
+		{nil, "other", grafeas.NotesGet, false},
+	} {
+		if got, err := p.Allows(context.Background(), c.identities, c.project, "", c.perm); got != c.want || err != nil {
+			t.Errorf("Allows(%q, %q, %s) got %v, %v want %v", c.identities, c.project, c.perm, got, err, c.want)
+		}
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	for _, perm := range []string{"notes.craete", "projects.*", "notes"} {
+		if _, err := NewPolicy([]config.AuthBinding{{Identities: []string{"*"}, Projects: []string{"*"}, Permissions: []string{perm}}}); err == nil {
+			t.Errorf("NewPolicy granting %q got success want error", perm)
+		}
+	}
+}
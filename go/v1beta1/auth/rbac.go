@@ -0,0 +1,270 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/grafeas/grafeas/go/iam"
+	"github.com/grafeas/grafeas/go/name"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"gopkg.in/yaml.v2"
+)
+
+// rbacReloadInterval is how often the policy file is checked for changes.
+const rbacReloadInterval = 30 * time.Second
+
+// builtinRoles are the roles every policy file can bind, by name: the roles of the Grafeas API.
+var builtinRoles = func() map[string][]string {
+	roles := map[string][]string{}
+	for name, perms := range grafeas.Roles {
+		for _, p := range perms {
+			roles[name] = append(roles[name], string(p))
+		}
+	}
+	return roles
+}()
+
+// rbacPolicy is the content of a policy file.
+type rbacPolicy struct {
+	// Roles are custom roles by name, in addition to the built-in roles.
+	Roles map[string][]string `yaml:"roles,omitempty"`
+	// Bindings grant identities the permissions of roles.
+	Bindings []rbacBinding `yaml:"bindings"`
+}
+
+// rbacBinding grants identities the permissions of a role on projects, or on notes of a project.
+type rbacBinding struct {
+	Role       string   `yaml:"role"`
+	Identities []string `yaml:"identities"`
+	Projects   []string `yaml:"projects"`
+	// Notes, if set, are the IDs of the notes of the only project of Projects the permissions on
+	// notes of the role are granted on. Other permissions of the role are not granted.
+	Notes []string `yaml:"notes,omitempty"`
+}
+
+// RBAC grants identities the permissions of roles on projects and notes, as bindings of a policy
+// file say. The file is reloaded when it changes, and is never written. Bindings on a note are
+// revoked when the note is deleted, by recording its name in storage, until the note is removed
+// from the file.
+type RBAC struct {
+	file   string
+	purges grafeas.PurgedNoteStorage
+
+	mu       sync.RWMutex
+	policy   *rbacPolicy
+	contents []byte
+	purged   map[string]bool
+
+	// purgeMu serializes recording purges with refreshing purged, so that a refresh does not drop
+	// a purge recorded while it lists the purged notes.
+	purgeMu sync.Mutex
+}
+
+// NewRBAC returns the RBAC of the policy file, which is loaded before it returns, with the
+// deleted notes recorded in purges.
+func NewRBAC(ctx context.Context, file string, purges grafeas.PurgedNoteStorage) (*RBAC, error) {
+	r := &RBAC{file: file, purges: purges}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	if err := r.refreshPurged(ctx); err != nil {
+		return nil, errors.New(fmt.Sprintf("failed to load purged notes: %s", err))
+	}
+	return r, nil
+}
+
+// Run reloads the policy file and the purged notes every rbacReloadInterval until ctx is done.
+func (r *RBAC) Run(ctx context.Context, logger grafeas.Logger) {
+	t := time.NewTicker(rbacReloadInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		changed, err := r.reload()
+		switch {
+		case err != nil:
+			logger.Errorf(ctx, "failed to reload policy file, keeping the previous policy: %s", err)
+		case changed:
+			logger.Infof(ctx, "reloaded policy file %s", r.file)
+		}
+		if err := r.refreshPurged(ctx); err != nil {
+			logger.Errorf(ctx, "failed to reload purged notes, keeping the previous ones: %s", err)
+		}
+	}
+}
+
+// reload loads the policy file if it changed since it was last loaded, and returns whether it did.
+func (r *RBAC) reload() (bool, error) {
+	b, err := ioutil.ReadFile(r.file)
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.policy != nil && bytes.Equal(b, r.contents) {
+		return false, nil
+	}
+	p, err := parseRBACPolicy(b)
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("invalid policy file %s: %s", r.file, err))
+	}
+	r.policy, r.contents = p, b
+	return true, nil
+}
+
+// refreshPurged loads the purged notes from storage. Notes that no binding of the policy is on
+// any more are deleted from storage, so that a note added to a binding again is granted.
+func (r *RBAC) refreshPurged(ctx context.Context) error {
+	r.purgeMu.Lock()
+	defer r.purgeMu.Unlock()
+	notes, err := r.purges.ListPurgedNotes(ctx)
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	bound := r.policy.notes()
+	r.mu.RUnlock()
+	purged := map[string]bool{}
+	for _, n := range notes {
+		if !bound[n] {
+			if err := r.purges.DeletePurgedNote(ctx, n); err != nil {
+				return err
+			}
+			continue
+		}
+		purged[n] = true
+	}
+	r.mu.Lock()
+	r.purged = purged
+	r.mu.Unlock()
+	return nil
+}
+
+// parseRBACPolicy parses and validates the contents of a policy file.
+func parseRBACPolicy(b []byte) (*rbacPolicy, error) {
+	var p rbacPolicy
+	if err := yaml.UnmarshalStrict(b, &p); err != nil {
+		return nil, err
+	}
+	for name, perms := range p.Roles {
+		if _, ok := builtinRoles[name]; ok {
+			return nil, errors.New(fmt.Sprintf("role %q is built in", name))
+		}
+		for _, perm := range perms {
+			if !knownPermission(perm) {
+				return nil, errors.New(fmt.Sprintf("role %q grants unknown permission %q", name, perm))
+			}
+		}
+	}
+	for i, b := range p.Bindings {
+		switch {
+		case p.permissions(b.Role) == nil:
+			return nil, errors.New(fmt.Sprintf("binding %d has unknown role %q", i, b.Role))
+		case len(b.Identities) == 0:
+			return nil, errors.New(fmt.Sprintf("binding %d has no identities", i))
+		case len(b.Projects) == 0:
+			return nil, errors.New(fmt.Sprintf("binding %d has no projects", i))
+		case len(b.Notes) > 0 && (len(b.Projects) != 1 || b.Projects[0] == "*"):
+			return nil, errors.New(fmt.Sprintf("binding %d on notes must have exactly one project", i))
+		}
+	}
+	return &p, nil
+}
+
+// notes returns the names of the notes bindings of the policy are on.
+func (p *rbacPolicy) notes() map[string]bool {
+	notes := map[string]bool{}
+	for _, b := range p.Bindings {
+		for _, n := range b.Notes {
+			notes[name.FormatNote(b.Projects[0], n)] = true
+		}
+	}
+	return notes
+}
+
+// permissions returns the permissions of the role, or nil if there is no such role.
+func (p *rbacPolicy) permissions(role string) []string {
+	if perms, ok := builtinRoles[role]; ok {
+		return perms
+	}
+	return p.Roles[role]
+}
+
+// Allows returns whether a binding grants one of the identities the permission perm on the
+// project projectID, or on the note entityID of the project if perm is a permission on notes and
+// the note was not purged.
+func (r *RBAC) Allows(ctx context.Context, identities []string, projectID, entityID string, perm iam.Permission) (bool, error) {
+	if len(identities) == 0 {
+		return false, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, b := range r.policy.Bindings {
+		if !matchesAny(b.Identities, identities) || !matches(b.Projects, projectID) || !grants(r.policy.permissions(b.Role), perm) {
+			continue
+		}
+		if len(b.Notes) == 0 {
+			return true, nil
+		}
+		if resourceOf(perm) == string(grafeas.Notes) && entityID != "" && contains(b.Notes, entityID) && !r.purged[name.FormatNote(projectID, entityID)] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Purge revokes the bindings on the deleted note entityID of the project projectID, so that a note
+// created later with the same ID is not granted them, by recording the note in storage.
+func (r *RBAC) Purge(ctx context.Context, projectID, entityID string, res iam.Resource) error {
+	if res != grafeas.Notes {
+		return nil
+	}
+	note := name.FormatNote(projectID, entityID)
+	r.mu.RLock()
+	bound := r.policy.notes()[note]
+	r.mu.RUnlock()
+	if !bound {
+		return nil
+	}
+	r.purgeMu.Lock()
+	defer r.purgeMu.Unlock()
+	if err := r.purges.AddPurgedNote(ctx, note); err != nil {
+		return errors.New(fmt.Sprintf("failed to record purged note %s: %s", note, err))
+	}
+	r.mu.Lock()
+	r.purged[note] = true
+	r.mu.Unlock()
+	return nil
+}
+
+// contains returns whether values has v.
+func contains(values []string, v string) bool {
+	for _, w := range values {
+		if w == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/iam"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+)
+
+const testPolicyFile = `
+roles:
+  scanner: ["occurrences.create", "notes.attachOccurrence"]
+bindings:
+  - role: viewer
+    identities: ["*"]
+    projects: ["*"]
+  - role: scanner
+    identities: ["spiffe://example.org/scanner"]
+    projects: ["scans"]
+  - role: note-owner
+    identities: ["group:security"]
+    projects: ["vulns"]
+    notes: ["CVE-1", "CVE-2"]
+  - role: admin
+    identities: ["group:admins"]
+    projects: ["*"]
+`
+
+// allows returns whether authz allows one of the identities p on the entity entityID of the
+// project projectID, and false if it fails.
+func allows(authz Authorizer, identities []string, projectID, entityID string, p iam.Permission) bool {
+	ok, err := authz.Allows(context.Background(), identities, projectID, entityID, p)
+	return ok && err == nil
+}
+
+// writePolicyFile writes contents to a policy file in a new directory and returns its name.
+func writePolicyFile(t *testing.T, contents string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := ioutil.WriteFile(file, []byte(contents), 0600); err != nil {
+		t.Fatalf("WriteFile got %v want success", err)
+	}
+	return file
+}
+
+func TestRBAC(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewRBAC(ctx, writePolicyFile(t, testPolicyFile), storage.NewMemStore())
+	if err != nil {
+		t.Fatalf("NewRBAC got %v want success", err)
+	}
+	scanner := []string{"spiffe://example.org/scanner"}
+	security := []string{"bob", "group:security"}
+	for _, c := range []struct {
+		identities []string
+		project    string
+		entity     string
+		perm       iam.Permission
+		want       bool
+	}{
+		{[]string{"someone"}, "scans", "CVE-1", grafeas.NotesGet, true},
+		{[]string{"someone"}, "scans", "", grafeas.OccurrencesList, true},
+		{[]string{"someone"}, "scans", "", grafeas.OccurrencesCreate, false},
+		{scanner, "scans", "", grafeas.OccurrencesCreate, true},
+		{scanner, "scans", "CVE-1", grafeas.NotesAttachOccurrence, true},
+		{scanner, "scans", "o1", grafeas.OccurrencesDelete, false},
+		{scanner, "other", "", grafeas.OccurrencesCreate, false},
+		{security, "vulns", "CVE-1", grafeas.NotesUpdate, true},
+		{security, "vulns", "CVE-2", grafeas.NotesDelete, true},
+		{security, "vulns", "CVE-3", grafeas.NotesUpdate, false},
+		{security, "vulns", "", grafeas.NotesCreate, false},
+		{security, "other", "CVE-1", grafeas.NotesUpdate, false},
+		{security, "vulns", "CVE-1", grafeas.OccurrencesGet, true},
+		{[]string{"group:admins"}, "any", "o1", grafeas.OccurrencesDelete, true},
+		{nil, "scans", "CVE-1", grafeas.NotesGet, false},
+	} {
+		if got, err := r.Allows(ctx, c.identities, c.project, c.entity, c.perm); got != c.want || err != nil {
+			t.Errorf("Allows(%q, %q, %q, %s) got %v, %v want %v", c.identities, c.project, c.entity, c.perm, got, err, c.want)
+		}
+	}
+}
+
+func TestRBACPurge(t *testing.T) {
+	ctx := context.Background()
+	file := writePolicyFile(t, testPolicyFile)
+	s := storage.NewMemStore()
+	r, err := NewRBAC(ctx, file, s)
+	if err != nil {
+		t.Fatalf("NewRBAC got %v want success", err)
+	}
+	security := []string{"group:security"}
+
+	for _, c := range []struct {
+		project, entity string
+		res             iam.Resource
+	}{
+		{"other", "CVE-1", grafeas.Notes},
+		{"vulns", "CVE-3", grafeas.Notes},
+		{"vulns", "CVE-1", grafeas.Occurrences},
+		{"vulns", "CVE-1", grafeas.Notes},
+	} {
+		if err := r.Purge(ctx, c.project, c.entity, c.res); err != nil {
+			t.Fatalf("Purge(%q, %q, %s) got %v want success", c.project, c.entity, c.res, err)
+		}
+	}
+	if allows(r, security, "vulns", "CVE-1", grafeas.NotesUpdate) {
+		t.Errorf("Allows on purged note got true want false")
+	}
+	if !allows(r, security, "vulns", "CVE-2", grafeas.NotesUpdate) {
+		t.Errorf("Allows on other note got false want true")
+	}
+	if notes, err := s.ListPurgedNotes(ctx); err != nil || len(notes) != 1 || notes[0] != "projects/vulns/notes/CVE-1" {
+		t.Errorf("ListPurgedNotes got %v, %v want only the purged note bound in the file", notes, err)
+	}
+	if b, err := ioutil.ReadFile(file); err != nil || string(b) != testPolicyFile {
+		t.Errorf("policy file after purge got %q, %v want it unchanged", b, err)
+	}
+
+	// The purge is stored, and thus survives a restart.
+	r, err = NewRBAC(ctx, file, s)
+	if err != nil {
+		t.Fatalf("NewRBAC after purge got %v want success", err)
+	}
+	if allows(r, security, "vulns", "CVE-1", grafeas.NotesUpdate) {
+		t.Errorf("Allows on purged note after restart got true want false")
+	}
+
+	// Once the note is removed from the file, the purge is forgotten and the note can be bound again.
+	if err := ioutil.WriteFile(file, []byte("bindings: [{role: note-owner, identities: [group:security], projects: [vulns], notes: [CVE-2]}]"), 0600); err != nil {
+		t.Fatalf("WriteFile got %v want success", err)
+	}
+	if _, err := r.reload(); err != nil {
+		t.Fatalf("reload got %v want success", err)
+	}
+	if err := r.refreshPurged(ctx); err != nil {
+		t.Fatalf("refreshPurged got %v want success", err)
+	}
+	if notes, err := s.ListPurgedNotes(ctx); err != nil || len(notes) != 0 {
+		t.Errorf("ListPurgedNotes after removing the note got %v, %v want none", notes, err)
+	}
+	if err := ioutil.WriteFile(file, []byte(testPolicyFile), 0600); err != nil {
+		t.Fatalf("WriteFile got %v want success", err)
+	}
+	if _, err := r.reload(); err != nil {
+		t.Fatalf("reload got %v want success", err)
+	}
+	if err := r.refreshPurged(ctx); err != nil {
+		t.Fatalf("refreshPurged got %v want success", err)
+	}
+	if !allows(r, security, "vulns", "CVE-1", grafeas.NotesUpdate) {
+		t.Errorf("Allows on note bound again got false want true")
+	}
+}
+
+func TestRBACReload(t *testing.T) {
+	file := writePolicyFile(t, testPolicyFile)
+	r, err := NewRBAC(context.Background(), file, storage.NewMemStore())
+	if err != nil {
+		t.Fatalf("NewRBAC got %v want success", err)
+	}
+	if changed, err := r.reload(); changed || err != nil {
+		t.Errorf("reload of unchanged file got %v, %v want false, nil", changed, err)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("bindings: [{role: nope, identities: [x], projects: [y]}]"), 0600); err != nil {
+		t.Fatalf("WriteFile got %v want success", err)
+	}
+	if _, err := r.reload(); err == nil {
+		t.Errorf("reload of invalid file got success want error")
+	}
+	if !allows(r, []string{"someone"}, "scans", "", grafeas.NotesList) {
+		t.Errorf("Allows after invalid reload got false want the previous policy")
+	}
+
+	if err := ioutil.WriteFile(file, []byte("bindings: [{role: viewer, identities: [alice], projects: [p]}]"), 0600); err != nil {
+		t.Fatalf("WriteFile got %v want success", err)
+	}
+	if changed, err := r.reload(); !changed || err != nil {
+		t.Errorf("reload of changed file got %v, %v want true, nil", changed, err)
+	}
+	if allows(r, []string{"someone"}, "scans", "", grafeas.NotesList) || !allows(r, []string{"alice"}, "p", "", grafeas.NotesList) {
+		t.Errorf("Allows after reload does not follow the new policy")
+	}
+}
+
+func TestNewRBAC(t *testing.T) {
+	for name, contents := range map[string]string{
+		"unknown field":         "bindings: [{role: viewer, members: [x], projects: [y]}]",
+		"unknown role":          "bindings: [{role: owner, identities: [x], projects: [y]}]",
+		"unknown permission":    "roles: {r: [notes.craete]}",
+		"redefined role":        "roles: {admin: [notes.get]}",
+		"no identities":         "bindings: [{role: viewer, projects: [y]}]",
+		"no projects":           "bindings: [{role: viewer, identities: [x]}]",
+		"notes of all projects": "bindings: [{role: viewer, identities: [x], projects: ['*'], notes: [n]}]",
+		"notes of two projects": "bindings: [{role: viewer, identities: [x], projects: [a, b], notes: [n]}]",
+	} {
+		if _, err := NewRBAC(context.Background(), writePolicyFile(t, contents), storage.NewMemStore()); err == nil {
+			t.Errorf("NewRBAC with %s got success want error", name)
+		}
+	}
+	if _, err := NewRBAC(context.Background(), filepath.Join(t.TempDir(), "missing.yaml"), storage.NewMemStore()); err == nil {
+		t.Errorf("NewRBAC with missing file got success want error")
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dump exports the contents of Grafeas storage to a backend-neutral format and imports it
+// back, e.g. to move data between storage types.
+//
+// A dump is a directory holding the projects, notes and occurrences as newline-delimited protojson
+// in projects.ndjson, notes.ndjson and occurrences.ndjson, and a manifest.json that is written
+// last, so that a dump without a manifest is known to be incomplete.
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grafeas/grafeas/go/name"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// Format identifies Grafeas dumps in their manifest.
+	Format = "grafeas-dump"
+	// Version is the version of the dump format written by Export.
+	Version = 1
+
+	manifestFile    = "manifest.json"
+	projectsFile    = "projects.ndjson"
+	notesFile       = "notes.ndjson"
+	occurrencesFile = "occurrences.ndjson"
+
+	// pageSize is the page size used to list records during export.
+	pageSize = 1000
+)
+
+// Manifest describes a dump.
+type Manifest struct {
+	Format      string    `json:"format"`
+	Version     int       `json:"version"`
+	APIVersion  string    `json:"apiVersion"`
+	CreateTime  time.Time `json:"createTime"`
+	Projects    int64     `json:"projects"`
+	Notes       int64     `json:"notes"`
+	Occurrences int64     `json:"occurrences"`
+}
+
+// Importer is implemented by storage that can store notes and occurrences with the names and
+// timestamps they were exported with, replacing existing records with the same names.
+type Importer interface {
+	ImportNote(ctx context.Context, n *pb.Note) error
+	ImportOccurrence(ctx context.Context, o *pb.Occurrence) error
+}
+
+// Export writes all projects, and the notes and occurrences in them, to a dump in dir.
+func Export(ctx context.Context, ps project.Storage, gs grafeas.Storage, dir string) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// Remove the manifest of an earlier dump first, so that a failed export cannot be mistaken
+	// for a complete one.
+	if err := os.Remove(filepath.Join(dir, manifestFile)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Format:     Format,
+		Version:    Version,
+		APIVersion: "v1beta1",
+		CreateTime: time.Now().UTC(),
+	}
+	var pIDs []string
+	if err := writeRecords(filepath.Join(dir, projectsFile), &m.Projects, func(write func(proto.Message) error) error {
+		token := ""
+		for {
+			projects, next, err := ps.ListProjects(ctx, "", pageSize, token)
+			if err != nil {
+				return err
+			}
+			for _, p := range projects {
+				pID, err := name.ParseProject(p.Name)
+				if err != nil {
+					return err
+				}
+				pIDs = append(pIDs, pID)
+				if err := write(p); err != nil {
+					return err
+				}
+			}
+			if next == "" || next == token {
+				return nil
+			}
+			token = next
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("failed to export projects: %v", err)
+	}
+
+	if err := writeRecords(filepath.Join(dir, notesFile), &m.Notes, func(write func(proto.Message) error) error {
+		for _, pID := range pIDs {
+			token := ""
+			for {
+				notes, next, err := gs.ListNotes(ctx, pID, "", token, pageSize)
+				if err != nil {
+					return err
+				}
+				for _, n := range notes {
+					// Some stores list by name prefix, which also matches projects whose ID
+					// starts with pID.
+					if nPID, _, err := name.ParseNote(n.Name); err != nil || nPID != pID {
+						continue
+					}
+					if err := write(n); err != nil {
+						return err
+					}
+				}
+				if next == "" || next == token {
+					break
+				}
+				token = next
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to export notes: %v", err)
+	}
+
+	if err := writeRecords(filepath.Join(dir, occurrencesFile), &m.Occurrences, func(write func(proto.Message) error) error {
+		for _, pID := range pIDs {
+			token := ""
+			for {
+				occs, next, err := gs.ListOccurrences(ctx, pID, "", token, pageSize)
+				if err != nil {
+					return err
+				}
+				for _, o := range occs {
+					if oPID, _, err := name.ParseOccurrence(o.Name); err != nil || oPID != pID {
+						continue
+					}
+					if err := write(o); err != nil {
+						return err
+					}
+				}
+				if next == "" || next == token {
+					break
+				}
+				token = next
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to export occurrences: %v", err)
+	}
+
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, manifestFile), append(buf, '\n'), 0600); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Import stores the contents of the dump in dir. Notes and occurrences keep their names and
+// timestamps and replace existing records with the same names, so importing a dump repeatedly
+// has the same result as importing it once. gs must implement Importer.
+func Import(ctx context.Context, ps project.Storage, gs grafeas.Storage, dir string) (*Manifest, error) {
+	importer, ok := gs.(Importer)
+	if !ok {
+		return nil, errors.New("storage does not support importing")
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := readRecords(filepath.Join(dir, projectsFile), m.Projects, func() proto.Message { return &prpb.Project{} }, func(msg proto.Message) error {
+		p := msg.(*prpb.Project)
+		pID, err := name.ParseProject(p.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := ps.CreateProject(ctx, pID, p); err != nil && status.Code(err) != codes.AlreadyExists {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to import projects: %v", err)
+	}
+	// Occurrences can refer to notes in any project, so all notes are imported before the first
+	// occurrence.
+	if err := readRecords(filepath.Join(dir, notesFile), m.Notes, func() proto.Message { return &pb.Note{} }, func(msg proto.Message) error {
+		return importer.ImportNote(ctx, msg.(*pb.Note))
+	}); err != nil {
+		return nil, fmt.Errorf("failed to import notes: %v", err)
+	}
+	if err := readRecords(filepath.Join(dir, occurrencesFile), m.Occurrences, func() proto.Message { return &pb.Occurrence{} }, func(msg proto.Message) error {
+		return importer.ImportOccurrence(ctx, msg.(*pb.Occurrence))
+	}); err != nil {
+		return nil, fmt.Errorf("failed to import occurrences: %v", err)
+	}
+	return m, nil
+}
+
+// ReadManifest reads and checks the manifest of the dump in dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, manifestFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s is not a complete dump: %s is missing", dir, manifestFile)
+	} else if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if m.Format != Format {
+		return nil, fmt.Errorf("%s is not a Grafeas dump", dir)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("unsupported dump version %d, want %d", m.Version, Version)
+	}
+	return &m, nil
+}
+
+// writeRecords creates file and calls fn with a function that writes a record to it, counting the
+// records written in count.
+func writeRecords(file string, count *int64, fn func(write func(proto.Message) error) error) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = fn(func(msg proto.Message) error {
+		buf, err := protojson.Marshal(proto.MessageV2(msg))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(buf, '\n')); err != nil {
+			return err
+		}
+		*count++
+		return nil
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readRecords calls fn for each record in file, and checks that it holds want records.
+func readRecords(file string, want int64, newMsg func() proto.Message, fn func(proto.Message) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var n int64
+	for {
+		// Records can be larger than bufio.Scanner's maximum token size.
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+		n++
+		msg := newMsg()
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(line, proto.MessageV2(msg)); err != nil {
+			return fmt.Errorf("%s:%d: %v", filepath.Base(file), n, err)
+		}
+		if err := fn(msg); err != nil {
+			return fmt.Errorf("%s:%d: %v", filepath.Base(file), n, err)
+		}
+	}
+	if n != want {
+		return fmt.Errorf("%s holds %d records, the manifest says %d", filepath.Base(file), n, want)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dump_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/name"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/dump"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
+	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
+	"golang.org/x/net/context"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestExportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	src := storage.NewMemStore()
+	// "p" is a prefix of "p1", which must not make records of "p1" appear twice in the dump.
+	var wantOccs []*pb.Occurrence
+	for _, pID := range []string{"p", "p1"} {
+		if _, err := src.CreateProject(ctx, pID, &prpb.Project{Name: name.FormatProject(pID)}); err != nil {
+			t.Fatalf("CreateProject got %v want success", err)
+		}
+		n, err := src.CreateNote(ctx, pID, "n", "userID", &pb.Note{Kind: cpb.NoteKind_BUILD})
+		if err != nil {
+			t.Fatalf("CreateNote got %v want success", err)
+		}
+		for i := 0; i < 3; i++ {
+			o, err := src.CreateOccurrence(ctx, pID, "userID", &pb.Occurrence{
+				NoteName: n.Name,
+				Resource: &pb.Resource{Uri: "https://example.com"},
+				Kind:     cpb.NoteKind_BUILD,
+			})
+			if err != nil {
+				t.Fatalf("CreateOccurrence got %v want success", err)
+			}
+			wantOccs = append(wantOccs, o)
+		}
+	}
+
+	dumpDir := filepath.Join(dir, "dump")
+	m, err := dump.Export(ctx, src, src, dumpDir)
+	if err != nil {
+		t.Fatalf("Export got %v want success", err)
+	}
+	if m.Projects != 2 || m.Notes != 2 || m.Occurrences != 6 {
+		t.Errorf("Export got manifest %+v, want 2 projects, 2 notes and 6 occurrences", m)
+	}
+
+	sqlite, err := storage.NewSQLiteStore(&config.SQLiteConfig{})
+	if err != nil {
+		t.Fatalf("NewSQLiteStore got %v want success", err)
+	}
+	for _, dst := range []struct {
+		name  string
+		store interface {
+			project.Storage
+			grafeas.Storage
+		}
+	}{
+		{"embedded", storage.NewEmbeddedStore(&config.EmbeddedStoreConfig{Path: filepath.Join(dir, "embedded")})},
+		{"sqlite", sqlite},
+	} {
+		// Importing twice has the same result as importing once.
+		for i := 0; i < 2; i++ {
+			if _, err := dump.Import(ctx, dst.store, dst.store, dumpDir); err != nil {
+				t.Fatalf("%s: Import got %v want success", dst.name, err)
+			}
+		}
+
+		for _, want := range wantOccs {
+			pID, oID, err := name.ParseOccurrence(want.Name)
+			if err != nil {
+				t.Fatalf("Error parsing projectID and occurrenceID %v", err)
+			}
+			got, err := dst.store.GetOccurrence(ctx, pID, oID)
+			if err != nil {
+				t.Errorf("%s: GetOccurrence(%s) got %v want success", dst.name, want.Name, err)
+				continue
+			}
+			// Names and timestamps are preserved.
+			if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("%s: GetOccurrence(%s) returned diff (want -> got):\n%s", dst.name, want.Name, diff)
+			}
+		}
+		for _, pID := range []string{"p", "p1"} {
+			got, _, err := dst.store.ListOccurrences(ctx, pID, "", "", 100)
+			if err != nil || len(got) != 3 {
+				t.Errorf("%s: ListOccurrences(%s) got %d occurrences, %v, want 3", dst.name, pID, len(got), err)
+			}
+			want, _ := src.GetNote(ctx, pID, "n")
+			gotNote, err := dst.store.GetNote(ctx, pID, "n")
+			if err != nil || !proto.Equal(want, gotNote) {
+				t.Errorf("%s: GetNote(%s) got %v, %v, want %v", dst.name, pID, gotNote, err, want)
+			}
+		}
+	}
+}
+
+func TestImportIncompleteDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	src := storage.NewMemStore()
+	for _, pID := range []string{"a", "b"} {
+		if _, err := src.CreateProject(ctx, pID, &prpb.Project{Name: name.FormatProject(pID)}); err != nil {
+			t.Fatalf("CreateProject got %v want success", err)
+		}
+	}
+	if _, err := dump.Export(ctx, src, src, dir); err != nil {
+		t.Fatalf("Export got %v want success", err)
+	}
+
+	// Drop the last project from the dump.
+	projects := filepath.Join(dir, "projects.ndjson")
+	buf, err := ioutil.ReadFile(projects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := buf[:len(buf)-1]
+	for len(lines) > 0 && lines[len(lines)-1] != '\n' {
+		lines = lines[:len(lines)-1]
+	}
+	if err := ioutil.WriteFile(projects, lines, 0600); err != nil {
+		t.Fatal(err)
+	}
+	dst := storage.NewMemStore()
+	if _, err := dump.Import(ctx, dst, dst, dir); err == nil {
+		t.Errorf("Import of a truncated dump got success, want error")
+	}
+
+	if err := os.Remove(filepath.Join(dir, "manifest.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dump.Import(ctx, dst, dst, dir); err == nil {
+		t.Errorf("Import of a dump without manifest got success, want error")
+	}
+}
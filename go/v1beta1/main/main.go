@@ -17,6 +17,7 @@ package main
 import (
 	"log"
 
+	"github.com/grafeas/grafeas/go/middleware"
 	"github.com/grafeas/grafeas/go/v1beta1/server"
 	"github.com/grafeas/grafeas/go/v1beta1/storage"
 )
@@ -25,6 +26,9 @@ func main() {
 	if err := storage.RegisterDefaultStorageTypeProviders(); err != nil {
 		log.Fatalf("Error when registering storage type providers, %s", err)
 	}
+	if err := middleware.RegisterDefaultMiddlewareProviders(); err != nil {
+		log.Fatalf("Error when registering storage middleware providers, %s", err)
+	}
 	if err := server.StartGrafeas(); err != nil {
 		log.Fatalf("Error starting Grafeas server, %s", err)
 	}
@@ -0,0 +1,106 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes"
+	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Limits are the rate limits of API calls. A nil Limiter does not limit.
+type Limits struct {
+	// PrincipalRead and PrincipalWrite are keyed by the principal making a call.
+	PrincipalRead, PrincipalWrite *Limiter
+	// ProjectRead and ProjectWrite are keyed by the project a call is on.
+	ProjectRead, ProjectWrite *Limiter
+}
+
+// Interceptor returns a unary server interceptor that takes a token from the buckets of l of the
+// principal making a call and of the project it is on, and fails the call with ResourceExhausted
+// and RetryInfo when a bucket is empty. Calls that get or list are reads, and all others are writes.
+// The principal of a call is looked up with endUserID, typically the EndUserID method of the Auth
+// of the API; calls without a principal, or that are not on a project, are not limited by the
+// respective buckets.
+func Interceptor(l *Limits, endUserID func(ctx context.Context) (string, error)) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		principalLimiter, projectLimiter, kind := l.PrincipalWrite, l.ProjectWrite, "write"
+		if isRead(info.FullMethod) {
+			principalLimiter, projectLimiter, kind = l.PrincipalRead, l.ProjectRead, "read"
+		}
+		if principalLimiter != nil {
+			if principal, err := endUserID(ctx); err == nil && principal != "" {
+				if err := take(principalLimiter, principal, fmt.Sprintf("%s calls by principal %q", kind, principal)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if projectLimiter != nil {
+			if pID := projectOf(req); pID != "" {
+				if err := take(projectLimiter, pID, fmt.Sprintf("%s calls on project %q", kind, pID)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// take takes a token of key from l, and returns the error of the call if there is none.
+func take(l *Limiter, key, what string) error {
+	ok, wait := l.Take(key)
+	if ok {
+		return nil
+	}
+	st := status.Newf(codes.ResourceExhausted, "rate limit of %s exceeded, retry in %s", what, wait)
+	if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(wait)}); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+// isRead returns whether the method only reads, from its name.
+func isRead(fullMethod string) bool {
+	m := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	return strings.HasPrefix(m, "Get") || strings.HasPrefix(m, "List") || m == "TestIamPermissions"
+}
+
+// projectOf returns the ID of the project that req is on, from the resource name in its parent,
+// name or resource field, or "" if it has none.
+func projectOf(req interface{}) string {
+	var resource string
+	switch r := req.(type) {
+	case *prpb.CreateProjectRequest:
+		resource = r.GetProject().GetName()
+	case interface{ GetParent() string }:
+		resource = r.GetParent()
+	case interface{ GetName() string }:
+		resource = r.GetName()
+	case interface{ GetResource() string }:
+		resource = r.GetResource()
+	}
+	parts := strings.SplitN(resource, "/", 3)
+	if len(parts) < 2 || parts[0] != "projects" {
+		return ""
+	}
+	return parts[1]
+}
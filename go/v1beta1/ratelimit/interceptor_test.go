@@ -0,0 +1,118 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// principalKey is the context key of the principal returned by testEndUserID.
+type principalKey struct{}
+
+func testEndUserID(ctx context.Context) (string, error) {
+	p, ok := ctx.Value(principalKey{}).(string)
+	if !ok {
+		return "", errors.New("unauthenticated")
+	}
+	return p, nil
+}
+
+func TestInterceptor(t *testing.T) {
+	l := &Limits{
+		PrincipalWrite: NewLimiter(Limit{Rate: 1, Burst: 2}),
+		ProjectRead:    NewLimiter(Limit{Rate: 1, Burst: 3}),
+	}
+	interceptor := Interceptor(l, testEndUserID)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(principal, method string, req interface{}) error {
+		ctx := context.Background()
+		if principal != "" {
+			ctx = context.WithValue(ctx, principalKey{}, principal)
+		}
+		_, err := interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+	createNote := "/grafeas.v1beta1.GrafeasV1Beta1/CreateNote"
+	listNotes := "/grafeas.v1beta1.GrafeasV1Beta1/ListNotes"
+
+	tests := []struct {
+		desc      string
+		principal string
+		method    string
+		req       interface{}
+		wantCode  codes.Code
+	}{
+		{"first write", "alice", createNote, &gpb.CreateNoteRequest{Parent: "projects/p"}, codes.OK},
+		{"second write", "alice", createNote, &gpb.CreateNoteRequest{Parent: "projects/q"}, codes.OK},
+		{"write beyond burst", "alice", createNote, &gpb.CreateNoteRequest{Parent: "projects/p"}, codes.ResourceExhausted},
+		{"write by other principal", "bob", "/grafeas.v1beta1.project.Projects/CreateProject", &prpb.CreateProjectRequest{Project: &prpb.Project{Name: "projects/p"}}, codes.OK},
+		{"write without principal", "", createNote, &gpb.CreateNoteRequest{Parent: "projects/p"}, codes.OK},
+		{"reads are not limited by principal", "alice", listNotes, &gpb.ListNotesRequest{Parent: "projects/p"}, codes.OK},
+		{"read by name", "bob", "/grafeas.v1beta1.GrafeasV1Beta1/GetNote", &gpb.GetNoteRequest{Name: "projects/p/notes/n"}, codes.OK},
+		{"read of IAM policy", "bob", "/google.iam.v1.IAMPolicy/GetIamPolicy", &iampb.GetIamPolicyRequest{Resource: "projects/p/notes/n"}, codes.OK},
+		{"read beyond project burst", "carol", listNotes, &gpb.ListNotesRequest{Parent: "projects/p"}, codes.ResourceExhausted},
+		{"read of other project", "carol", listNotes, &gpb.ListNotesRequest{Parent: "projects/q"}, codes.OK},
+		{"read without project", "carol", "/grafeas.v1beta1.project.Projects/ListProjects", &prpb.ListProjectsRequest{}, codes.OK},
+	}
+	for _, tt := range tests {
+		if err := call(tt.principal, tt.method, tt.req); status.Code(err) != tt.wantCode {
+			t.Errorf("%q: got %v want code %v", tt.desc, err, tt.wantCode)
+		}
+	}
+}
+
+func TestInterceptorRetryInfo(t *testing.T) {
+	limiter := NewLimiter(Limit{Rate: 0.5, Burst: 1})
+	interceptor := Interceptor(&Limits{ProjectWrite: limiter}, testEndUserID)
+	info := &grpc.UnaryServerInfo{FullMethod: "/grafeas.v1beta1.GrafeasV1Beta1/DeleteNote"}
+	req := &gpb.DeleteNoteRequest{Name: "projects/p/notes/n"}
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return nil, nil
+	}
+	for i := 0; i < 2; i++ {
+		interceptor(context.Background(), req, info, handler)
+	}
+	_, err := interceptor(context.Background(), req, info, handler)
+	if calls != 1 {
+		t.Errorf("handler called %d times want 1", calls)
+	}
+	var retry *errdetails.RetryInfo
+	for _, d := range status.Convert(err).Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	if retry == nil {
+		t.Fatalf("error %v has no retry info", err)
+	}
+	if d := retry.RetryDelay.AsDuration(); d <= 0 || d > 2*time.Second {
+		t.Errorf("retry delay got %v want within (0, 2s]", d)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when advanced.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestLimiter(l Limit) (*Limiter, *fakeClock) {
+	c := &fakeClock{t: time.Unix(1600000000, 0)}
+	lim := NewLimiter(l)
+	lim.now = c.now
+	return lim, c
+}
+
+func TestLimiter(t *testing.T) {
+	l, c := newTestLimiter(Limit{Rate: 2, Burst: 3})
+
+	// A new bucket is full.
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Take("alice"); !ok {
+			t.Fatalf("Take %d got false want true", i)
+		}
+	}
+	ok, wait := l.Take("alice")
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("Take of empty bucket got %v, %v want false, 500ms", ok, wait)
+	}
+	// Buckets are per key.
+	if ok, _ := l.Take("bob"); !ok {
+		t.Errorf("Take of other key got false want true")
+	}
+
+	c.advance(250 * time.Millisecond)
+	if ok, wait := l.Take("alice"); ok || wait != 250*time.Millisecond {
+		t.Errorf("Take of half refilled token got %v, %v want false, 250ms", ok, wait)
+	}
+	c.advance(250 * time.Millisecond)
+	if ok, _ := l.Take("alice"); !ok {
+		t.Errorf("Take of refilled token got false want true")
+	}
+
+	// Buckets do not refill beyond their burst.
+	c.advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Take("alice"); !ok {
+			t.Fatalf("Take %d after an hour got false want true", i)
+		}
+	}
+	if ok, _ := l.Take("alice"); ok {
+		t.Errorf("Take beyond burst got true want false")
+	}
+}
+
+func TestLimiterDefaultBurst(t *testing.T) {
+	for _, c := range []struct {
+		rate      float64
+		wantBurst int
+	}{
+		{rate: 0.5, wantBurst: 1},
+		{rate: 10, wantBurst: 10},
+		{rate: 2.5, wantBurst: 3},
+	} {
+		l, _ := newTestLimiter(Limit{Rate: c.rate})
+		n := 0
+		for ok, _ := l.Take("k"); ok; ok, _ = l.Take("k") {
+			n++
+		}
+		if n != c.wantBurst {
+			t.Errorf("Limiter of rate %v took %d tokens at once want %d", c.rate, n, c.wantBurst)
+		}
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l, c := newTestLimiter(Limit{Rate: 1, Burst: 100})
+	l.Take("idle")
+	for i := 0; i < 90; i++ {
+		l.Take("busy")
+	}
+	c.advance(sweepInterval)
+	l.Take("other")
+	if _, ok := l.buckets["idle"]; ok {
+		t.Errorf("bucket of idle key was kept, want it dropped once full")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Errorf("bucket of busy key was dropped, want it kept until full")
+	}
+}
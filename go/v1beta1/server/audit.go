@@ -0,0 +1,63 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/audit"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
+	"google.golang.org/grpc"
+)
+
+// newAuditSink returns the sink of the audit log configured by c, which stores entries in db if
+// its sink is "storage".
+func newAuditSink(c *config.AuditConfig, db grafeas.Storage) (audit.Sink, error) {
+	switch c.Sink {
+	case "file":
+		if c.Path == "" {
+			return nil, errors.New("the file audit sink requires a path")
+		}
+		s, err := audit.NewFileSink(c.Path)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("failed to open audit log: %s", err))
+		}
+		return s, nil
+	case "stdout":
+		return audit.NewStreamSink(os.Stdout), nil
+	case "storage":
+		var s audit.Storage
+		if !storage.As(db, &s) {
+			return nil, errors.New("the storage audit sink is configured, but the storage does not store audit entries")
+		}
+		return audit.NewStorageSink(s), nil
+	}
+	return nil, errors.New(fmt.Sprintf("unknown audit sink %q", c.Sink))
+}
+
+// registerAuditService registers the Audit service, which lists the entries of sink if it can be
+// listed, with s.
+func registerAuditService(s *grpc.Server, sink audit.Sink, a grafeas.Auth) {
+	svc := &audit.API{Auth: a}
+	if l, ok := sink.(audit.Lister); ok {
+		svc.Entries = l
+	}
+	apb.RegisterAuditServer(s, svc)
+}
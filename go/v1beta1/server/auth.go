@@ -0,0 +1,96 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/auth"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// newAuth returns the Auth of the API as config says, and the options of the REST gateway it
+// needs. A policy file is reloaded until ctx is done. If db stores IAM policies, the IAM policies of
+// notes and occurrences grant permissions too.
+func newAuth(ctx context.Context, config *config.ServerConfig, db grafeas.Storage, logger grafeas.Logger) (grafeas.Auth, []runtime.ServeMuxOption, error) {
+	if config.Auth == nil {
+		return &grafeas.NoOpAuth{}, nil, nil
+	}
+	authz, err := newAuthorizer(ctx, config.Auth, db, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	if policies := policyStorage(db); policies != nil {
+		authz = auth.NewEntityPolicies(authz, policies)
+	}
+	switch config.Auth.Type {
+	case "mtls":
+		grpcListener := config.GRPC
+		if grpcListener == nil {
+			grpcListener = mainListener(config)
+		}
+		if grpcListener.CAFile == "" {
+			return nil, nil, errors.New("auth type mtls requires a cafile to verify client certificates with")
+		}
+		a, err := auth.NewMTLS(authz)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("authorizing calls by client certificate")
+		return a, []runtime.ServeMuxOption{runtime.WithMetadata(a.GatewayMetadata)}, nil
+	case "oidc":
+		a, err := auth.NewOIDC(authz, config.Auth.Issuers)
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("invalid auth issuers: %s", err))
+		}
+		log.Printf("authorizing calls by bearer token of %d issuers", len(config.Auth.Issuers))
+		return a, nil, nil
+	default:
+		return nil, nil, errors.New(fmt.Sprintf("unknown auth type %q", config.Auth.Type))
+	}
+}
+
+// newAuthorizer returns the Authorizer of the bindings or the policy file of config. The policy
+// file is reloaded until ctx is done, and deleted notes its bindings are on are recorded in db.
+func newAuthorizer(ctx context.Context, config *config.AuthConfig, db grafeas.Storage, logger grafeas.Logger) (auth.Authorizer, error) {
+	if config.PolicyFile == "" {
+		policy, err := auth.NewPolicy(config.Bindings)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid auth bindings: %s", err))
+		}
+		log.Printf("granting permissions by %d bindings", len(config.Bindings))
+		return policy, nil
+	}
+	if len(config.Bindings) > 0 {
+		return nil, errors.New("auth bindings and policy_file cannot be used together")
+	}
+	var purges grafeas.PurgedNoteStorage
+	if !storage.As(db, &purges) {
+		return nil, errors.New("auth policy_file requires a storage that records deleted notes")
+	}
+	rbac, err := auth.NewRBAC(ctx, config.PolicyFile, purges)
+	if err != nil {
+		return nil, err
+	}
+	go rbac.Run(ctx, logger)
+	log.Printf("granting permissions by policy file %s", config.PolicyFile)
+	return rbac, nil
+}
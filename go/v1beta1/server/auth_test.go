@@ -0,0 +1,105 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+)
+
+func TestNewAuth(t *testing.T) {
+	for _, c := range []*config.ServerConfig{
+		{Auth: &config.AuthConfig{Type: "mtls"}},
+		{Auth: &config.AuthConfig{Type: "mtls"}, CAFile: "ca.crt", GRPC: &config.ListenerConfig{}},
+		{Auth: &config.AuthConfig{Type: "password"}, CAFile: "ca.crt"},
+		{Auth: &config.AuthConfig{Type: "oidc"}},
+		{Auth: &config.AuthConfig{Type: "oidc", Issuers: []config.OIDCIssuerConfig{{Issuer: "https://issuer.example.org"}}}},
+		{Auth: &config.AuthConfig{Type: "mtls", PolicyFile: "missing.yaml"}, CAFile: "ca.crt"},
+		{Auth: &config.AuthConfig{Type: "mtls", PolicyFile: "policy.yaml", Bindings: []config.AuthBinding{{Permissions: []string{"*"}}}}, CAFile: "ca.crt"},
+		{Auth: &config.AuthConfig{Type: "mtls", Bindings: []config.AuthBinding{{Permissions: []string{"notes.write"}}}}, CAFile: "ca.crt"},
+	} {
+		if _, _, err := newAuth(context.Background(), c, nil, &grafeas.NoOpLogger{}); err == nil {
+			t.Errorf("newAuth(%+v) got success want error", c.Auth)
+		}
+	}
+}
+
+func TestMTLSAuthGateway(t *testing.T) {
+	ctx := context.Background()
+	a, gwMuxOpts, err := newAuth(ctx, &config.ServerConfig{CAFile: "ca.crt", Auth: &config.AuthConfig{
+		Type: "mtls",
+		Bindings: []config.AuthBinding{{
+			Identities:  []string{"alice"},
+			Projects:    []string{"p"},
+			Permissions: []string{"notes.list"},
+		}},
+	}}, nil, &grafeas.NoOpLogger{})
+	if err != nil {
+		t.Fatalf("newAuth got %v want success", err)
+	}
+	s := storage.NewMemStore()
+	var (
+		db   grafeas.Storage = s
+		proj project.Storage = s
+	)
+	l, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen got %v want success", err)
+	}
+	grpcServer := newGrpcServer(nil, &db, &proj, a, &grafeas.NoOpLogger{}, apiSettings{})
+	go grpcServer.Serve(l)
+	defer grpcServer.Stop()
+	gwmux, err := newGrpcGatewayServer(ctx, dialTarget(l), nil, gwMuxOpts)
+	if err != nil {
+		t.Fatalf("newGrpcGatewayServer got %v want success", err)
+	}
+
+	alice := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}
+	for _, c := range []struct {
+		name     string
+		project  string
+		cert     *x509.Certificate
+		header   string
+		wantCode int
+	}{
+		{name: "alice", project: "p", cert: alice, wantCode: http.StatusOK},
+		{name: "alice in another project", project: "q", cert: alice, wantCode: http.StatusForbidden},
+		{name: "no certificate", project: "p", wantCode: http.StatusForbidden},
+		{name: "spoofed identity", project: "p", header: "Grpc-Metadata-X-Grafeas-Forwarded-Identity", wantCode: http.StatusForbidden},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1beta1/projects/"+c.project+"/notes", nil)
+		if c.cert != nil {
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{c.cert}}}
+		}
+		if c.header != "" {
+			r.Header.Set(c.header, "alice")
+		}
+		rec := httptest.NewRecorder()
+		gwmux.ServeHTTP(rec, r)
+		if rec.Code != c.wantCode {
+			t.Errorf("GET as %s got status %d want %d: %s", c.name, rec.Code, c.wantCode, rec.Body)
+		}
+	}
+}
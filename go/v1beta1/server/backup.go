@@ -0,0 +1,62 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+)
+
+// BackupPath is the HTTP path at which storage backups are served.
+const BackupPath = "/admin/backup"
+
+// backuper is implemented by storage that can write a consistent copy of its data while serving,
+// such as storage.EmbeddedStore.
+type backuper interface {
+	Backup(w io.Writer) (int64, error)
+}
+
+// storageBackuper returns the backuper in db, looking through the storage.Storage wrapper returned
+// by storage.CreateStorageOfType and storage layered over it, such as a cache.
+func storageBackuper(db grafeas.Storage) (backuper, bool) {
+	var b backuper
+	ok := storage.As(db, &b)
+	return b, ok
+}
+
+// backupHandler streams a backup of b to GET requests.
+func backupHandler(b backuper) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="grafeas.db"`)
+		n, err := b.Backup(w)
+		if err != nil {
+			log.Printf("Backup failed after %d bytes: %v", n, err)
+			// Abort the connection so that the client sees an incomplete response rather than a
+			// truncated backup.
+			panic(http.ErrAbortHandler)
+		}
+		log.Printf("Backup of %d bytes sent to %s", n, r.RemoteAddr)
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+)
+
+func TestBackupEndpointRequiresAdminListener(t *testing.T) {
+	s := storage.NewMemStore()
+	var (
+		db   grafeas.Storage = s
+		proj project.Storage = s
+	)
+	err := run(&config.ServerConfig{BackupEndpoint: true}, nil, &db, &proj)
+	if err == nil || !strings.Contains(err.Error(), "admin listener") {
+		t.Errorf("run with backup_endpoint and no admin listener got %v want an error", err)
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// certReloadInterval is how often the certificate files are checked for changes.
+	certReloadInterval = 30 * time.Second
+	// certExpiryWarning is how long before a certificate expires a warning is logged.
+	certExpiryWarning = 14 * 24 * time.Hour
+	// certExpiryWarningInterval is how often the warning for a certificate is repeated.
+	certExpiryWarningInterval = 24 * time.Hour
+)
+
+// certReloader serves the server certificate and the client CA bundle from files, which it
+// reloads when they change, e.g. when cert-manager rotates them. A change that cannot be loaded,
+// such as a certificate whose key has not been written yet, is retried and meanwhile the previous
+// certificates are kept.
+type certReloader struct {
+	certFile, keyFile, caFile string
+	logger                    grafeas.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	contents  [3][]byte
+	warned    map[string]time.Time
+	// series are the file and subject labels of the expiry series this reloader exported, which
+	// it deletes once its certificates no longer have them, leaving those of other listeners.
+	series map[[2]string]bool
+}
+
+// newCertReloader returns a certReloader of the given files, which are loaded once before it
+// returns. caFile may be empty, in which case there is no client CA bundle.
+func newCertReloader(certFile, keyFile, caFile string, logger grafeas.Logger) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("certfile and keyfile are required for TLS")
+	}
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		logger:   logger,
+		warned:   map[string]time.Time{},
+		series:   map[[2]string]bool{},
+	}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// run reloads the files every certReloadInterval until ctx is done.
+func (r *certReloader) run(ctx context.Context) {
+	t := time.NewTicker(certReloadInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		changed, err := r.reload()
+		switch {
+		case err != nil:
+			r.logger.Errorf(ctx, "failed to reload certificates, keeping the previous ones: %s", err)
+		case changed:
+			r.logger.Info(ctx, "reloaded certificates")
+		}
+	}
+}
+
+// reload loads the files if any of them changed since they were last loaded, and returns whether
+// they did.
+func (r *certReloader) reload() (bool, error) {
+	var contents [3][]byte
+	for i, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f == "" {
+			continue
+		}
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return false, err
+		}
+		contents[i] = b
+	}
+
+	r.mu.RLock()
+	changed := false
+	for i := range contents {
+		changed = changed || !bytes.Equal(contents[i], r.contents[i])
+	}
+	r.mu.RUnlock()
+	if !changed {
+		r.checkExpiry()
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(contents[0], contents[1])
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("failed to load %s and %s: %s", r.certFile, r.keyFile, err))
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return false, errors.New(fmt.Sprintf("failed to parse %s: %s", r.certFile, err))
+	}
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(contents[2]) {
+			return false, errors.New(fmt.Sprintf("no certificates found in %s", r.caFile))
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCAs, r.contents = &cert, clientCAs, contents
+	r.mu.Unlock()
+	r.checkExpiry()
+	return true, nil
+}
+
+// checkExpiry exports the expiry dates of the certificates, and logs a warning for those that
+// expire within certExpiryWarning.
+func (r *certReloader) checkExpiry() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	certs := map[string][]*x509.Certificate{r.certFile: {r.cert.Leaf}}
+	if r.caFile != "" {
+		rest := r.contents[2]
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if c, err := x509.ParseCertificate(block.Bytes); err == nil && block.Type == "CERTIFICATE" {
+				certs[r.caFile] = append(certs[r.caFile], c)
+			}
+		}
+	}
+
+	now := time.Now()
+	series := map[[2]string]bool{}
+	for file, cs := range certs {
+		for _, c := range cs {
+			subject := c.Subject.String()
+			tlsCertificateExpiry.WithLabelValues(file, subject).Set(float64(c.NotAfter.Unix()))
+			series[[2]string{file, subject}] = true
+			key := file + "\x00" + subject
+			if c.NotAfter.Sub(now) > certExpiryWarning || now.Sub(r.warned[key]) < certExpiryWarningInterval {
+				continue
+			}
+			r.warned[key] = now
+			r.logger.Warningf(context.Background(), "certificate %q in %s expires at %s", subject, file, c.NotAfter.Format(time.RFC3339))
+		}
+	}
+	for s := range r.series {
+		if !series[s] {
+			tlsCertificateExpiry.DeleteLabelValues(s[0], s[1])
+		}
+	}
+	r.series = series
+}
+
+// certificate returns the current server certificate.
+func (r *certReloader) certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// clientConfig returns a TLS config to connect to the server with, which presents the current
+// certificate as client certificate and trusts exactly the current certificate as server
+// certificate.
+func (r *certReloader) clientConfig(base *tls.Config) (*tls.Config, error) {
+	leaf := r.certificate().Leaf
+	c := base.Clone()
+	c.GetConfigForClient = nil
+	c.RootCAs = x509.NewCertPool()
+	c.RootCAs.AddCert(leaf)
+	switch {
+	case len(leaf.DNSNames) > 0:
+		c.ServerName = leaf.DNSNames[0]
+	case len(leaf.IPAddresses) > 0:
+		c.ServerName = leaf.IPAddresses[0].String()
+	default:
+		return nil, errors.New(fmt.Sprintf("certificate %s has no DNS or IP subject alternative name", r.certFile))
+	}
+	return c, nil
+}
+
+// gatewayCredentials returns the transport credentials the REST gateway connects to the gRPC
+// server with, which verify the server as clientConfig does on each handshake.
+func (r *certReloader) gatewayCredentials(base *tls.Config) credentials.TransportCredentials {
+	return &gatewayCredentials{TransportCredentials: credentials.NewTLS(base), certs: r, base: base}
+}
+
+// gatewayCredentials are TLS transport credentials whose client config is taken from certs on each
+// handshake, so that the gateway follows reloaded certificates.
+type gatewayCredentials struct {
+	credentials.TransportCredentials
+	certs *certReloader
+	base  *tls.Config
+}
+
+func (c *gatewayCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	config, err := c.certs.clientConfig(c.base)
+	if err != nil {
+		return nil, nil, err
+	}
+	return credentials.NewTLS(config).ClientHandshake(ctx, authority, conn)
+}
+
+func (c *gatewayCredentials) Clone() credentials.TransportCredentials {
+	return c.certs.gatewayCredentials(c.base)
+}
+
+// listenerTLS returns the TLS config of the listener l, which negotiates nextProtos, and the
+// transport credentials to connect to it with, or nils if l does not use TLS. Its certificates are
+// reloaded until ctx is done.
+func listenerTLS(ctx context.Context, l *config.ListenerConfig, logger grafeas.Logger, nextProtos ...string) (*tls.Config, credentials.TransportCredentials, error) {
+	if l.CertFile == "" && l.KeyFile == "" && l.CAFile == "" {
+		return nil, nil, nil
+	}
+	certs, err := newCertReloader(l.CertFile, l.KeyFile, l.CAFile, logger)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("failed to load certificate files %s", err))
+	}
+	go certs.run(ctx)
+	c := certs.tlsConfig()
+	if err := configureTLS(c, l.ClientAuth, l.MinTLSVersion, l.CipherSuites, l.CAFile != ""); err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("failed to create tls config %s", err))
+	}
+	c.NextProtos = nextProtos
+	return c, certs.gatewayCredentials(c), nil
+}
+
+// clientAuthTypes maps the client_auth values of the server config to client authentication
+// types.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// tlsVersions maps the min_tls_version values of the server config to TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// configureTLS sets the client authentication type, the minimum TLS version and the cipher
+// suites of c, as given by the names in the server config. hasCA is whether a client CA bundle is
+// configured, which client certificates are verified with.
+func configureTLS(c *tls.Config, clientAuth, minVersion string, cipherSuites []string, hasCA bool) error {
+	switch clientAuth {
+	case "":
+		c.ClientAuth = tls.NoClientCert
+		if hasCA {
+			c.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	default:
+		t, ok := clientAuthTypes[strings.ToLower(clientAuth)]
+		if !ok {
+			return errors.New(fmt.Sprintf("unknown client_auth %q", clientAuth))
+		}
+		if (t == tls.VerifyClientCertIfGiven || t == tls.RequireAndVerifyClientCert) && !hasCA {
+			return errors.New(fmt.Sprintf("client_auth %q requires cafile", clientAuth))
+		}
+		c.ClientAuth = t
+	}
+
+	c.MinVersion = tls.VersionTLS12
+	if minVersion != "" {
+		v, ok := tlsVersions[minVersion]
+		if !ok {
+			return errors.New(fmt.Sprintf("unknown min_tls_version %q", minVersion))
+		}
+		c.MinVersion = v
+	}
+
+	ids := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		ids[s.Name] = s.ID
+	}
+	c.CipherSuites = nil
+	for _, name := range cipherSuites {
+		id, ok := ids[name]
+		if !ok {
+			return errors.New(fmt.Sprintf("unknown or insecure cipher suite %q", name))
+		}
+		c.CipherSuites = append(c.CipherSuites, id)
+	}
+	return nil
+}
+
+// tlsConfig returns a TLS config that uses the current certificates on each handshake: the
+// certificate as server and client certificate, and the client CA bundle to verify clients with.
+// Changes made to the returned config before it is used apply to all handshakes.
+func (r *certReloader) tlsConfig() *tls.Config {
+	c := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.certificate(), nil
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.certificate(), nil
+		},
+	}
+	c.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cc := c.Clone()
+		cc.GetConfigForClient = nil
+		r.mu.RLock()
+		cc.ClientCAs = r.clientCAs
+		r.mu.RUnlock()
+		return cc, nil
+	}
+	return c
+}
@@ -0,0 +1,351 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// writeCert writes a self-signed certificate for localhost, which is also its own CA, and its key
+// to certFile and keyFile, and returns the certificate.
+func writeCert(t *testing.T, certFile, keyFile string, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey got %v want success", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate got %v want success", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey got %v want success", err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("%s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate got %v want success", err)
+	}
+	return cert
+}
+
+// handshake connects a client using client to a server using server, and returns the certificate
+// the server presented and the error of the handshake.
+func handshake(server, client *tls.Config) (*x509.Certificate, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	serverErr := make(chan error, 1)
+	go func() {
+		s, err := l.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer s.Close()
+		serverErr <- tls.Server(s, server).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), client)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// With TLS 1.3, the client completes its handshake before the server verifies it.
+	if err := <-serverErr; err != nil {
+		return nil, err
+	}
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+// trusting returns a client config of config, which trusts cert as server certificate.
+func trusting(config *tls.Config, cert *x509.Certificate) *tls.Config {
+	c := config.Clone()
+	c.ServerName = "localhost"
+	c.RootCAs = x509.NewCertPool()
+	c.RootCAs.AddCert(cert)
+	return c
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	old := writeCert(t, certFile, keyFile, time.Now().Add(365*24*time.Hour))
+
+	r, err := newCertReloader(certFile, keyFile, certFile, &grafeas.NoOpLogger{})
+	if err != nil {
+		t.Fatalf("newCertReloader got %v want success", err)
+	}
+	config := r.tlsConfig()
+	if err := configureTLS(config, "", "", nil, true); err != nil {
+		t.Fatalf("configureTLS got %v want success", err)
+	}
+	if got, err := handshake(config, trusting(config, old)); err != nil || !got.Equal(old) {
+		t.Fatalf("handshake got %v, %v want the initial certificate", got, err)
+	}
+	if changed, err := r.reload(); changed || err != nil {
+		t.Errorf("reload of unchanged files got %v, %v want false, nil", changed, err)
+	}
+
+	// The certificate changes before its key, which is kept until both are written.
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	rotated := writeCert(t, certFile, keyFile, time.Now().Add(24*time.Hour))
+	rotatedKey, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := r.reload(); err == nil {
+		t.Errorf("reload of a mismatched key got success want error")
+	}
+	if got, err := handshake(config, trusting(config, old)); err != nil || !got.Equal(old) {
+		t.Errorf("handshake after failed reload got %v, %v want the initial certificate", got, err)
+	}
+
+	if err := ioutil.WriteFile(keyFile, rotatedKey, 0600); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if changed, err := r.reload(); !changed || err != nil {
+		t.Fatalf("reload got %v, %v want true, nil", changed, err)
+	}
+	if got, err := handshake(config, trusting(config, rotated)); err != nil || !got.Equal(rotated) {
+		t.Errorf("handshake after reload got %v, %v want the rotated certificate", got, err)
+	}
+	// Clients are verified with the reloaded CA bundle, which no longer has the initial certificate.
+	client := trusting(config, rotated)
+	client.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return &tls.Certificate{Certificate: [][]byte{old.Raw}, PrivateKey: r.certificate().PrivateKey}, nil
+	}
+	if _, err := handshake(config, client); err == nil {
+		t.Errorf("handshake with a client certificate of the previous CA got success want error")
+	}
+	// The gateway trusts the current certificate.
+	client, err = r.clientConfig(config)
+	if err != nil {
+		t.Fatalf("clientConfig got %v want success", err)
+	}
+	if got, err := handshake(config, client); err != nil || !got.Equal(rotated) {
+		t.Errorf("handshake with clientConfig got %v, %v want the rotated certificate", got, err)
+	}
+
+	if got, want := testutil.ToFloat64(tlsCertificateExpiry.WithLabelValues(certFile, rotated.Subject.String())), float64(rotated.NotAfter.Unix()); got != want {
+		t.Errorf("%s got %v want %v", "grafeas_tls_certificate_expiry_timestamp_seconds", got, want)
+	}
+}
+
+// expiryFiles returns the files of the certificate expiry series.
+func expiryFiles(t *testing.T) map[string]bool {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 100)
+	tlsCertificateExpiry.Collect(ch)
+	close(ch)
+	files := map[string]bool{}
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatalf("Write got %v want success", err)
+		}
+		for _, l := range pb.Label {
+			if l.GetName() == "file" {
+				files[l.GetValue()] = true
+			}
+		}
+	}
+	return files
+}
+
+func TestCertReloaderExpirySeries(t *testing.T) {
+	dir := t.TempDir()
+	grpcCert, grpcKey, caFile := filepath.Join(dir, "grpc.crt"), filepath.Join(dir, "grpc.key"), filepath.Join(dir, "ca.crt")
+	restCert, restKey := filepath.Join(dir, "rest.crt"), filepath.Join(dir, "rest.key")
+	writeCert(t, grpcCert, grpcKey, time.Now().Add(24*time.Hour))
+	writeCert(t, caFile, filepath.Join(dir, "ca.key"), time.Now().Add(24*time.Hour))
+	writeCert(t, restCert, restKey, time.Now().Add(24*time.Hour))
+
+	grpcReloader, err := newCertReloader(grpcCert, grpcKey, caFile, &grafeas.NoOpLogger{})
+	if err != nil {
+		t.Fatalf("newCertReloader got %v want success", err)
+	}
+	restReloader, err := newCertReloader(restCert, restKey, "", &grafeas.NoOpLogger{})
+	if err != nil {
+		t.Fatalf("newCertReloader got %v want success", err)
+	}
+	// Each listener's check keeps the series of the others.
+	restReloader.checkExpiry()
+	grpcReloader.checkExpiry()
+	if files := expiryFiles(t); !files[grpcCert] || !files[caFile] || !files[restCert] {
+		t.Errorf("expiry series got files %v want %s, %s and %s", files, grpcCert, caFile, restCert)
+	}
+
+	// A CA bundle that is no longer used loses its series.
+	grpcReloader.mu.Lock()
+	grpcReloader.caFile = ""
+	grpcReloader.mu.Unlock()
+	grpcReloader.checkExpiry()
+	if files := expiryFiles(t); !files[grpcCert] || files[caFile] || !files[restCert] {
+		t.Errorf("expiry series got files %v want %s and %s", files, grpcCert, restCert)
+	}
+}
+
+func TestConfigureTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	cert := writeCert(t, certFile, keyFile, time.Now().Add(time.Hour))
+	r, err := newCertReloader(certFile, keyFile, "", &grafeas.NoOpLogger{})
+	if err != nil {
+		t.Fatalf("newCertReloader got %v want success", err)
+	}
+
+	// Without client authentication, clients need no certificate.
+	config := r.tlsConfig()
+	if err := configureTLS(config, "", "1.3", nil, false); err != nil {
+		t.Fatalf("configureTLS got %v want success", err)
+	}
+	if config.ClientAuth != tls.NoClientCert || config.MinVersion != tls.VersionTLS13 {
+		t.Errorf("configureTLS got client auth %s, version %x want none, TLS 1.3", config.ClientAuth, config.MinVersion)
+	}
+	client := &tls.Config{ServerName: "localhost", RootCAs: x509.NewCertPool()}
+	client.RootCAs.AddCert(cert)
+	if _, err := handshake(config, client); err != nil {
+		t.Errorf("handshake without a client certificate got %v want success", err)
+	}
+	client.MaxVersion = tls.VersionTLS12
+	if _, err := handshake(config, client); err == nil {
+		t.Errorf("handshake with TLS 1.2 got success want error")
+	}
+
+	config = r.tlsConfig()
+	if err := configureTLS(config, "require", "", []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}, false); err != nil {
+		t.Fatalf("configureTLS got %v want success", err)
+	}
+	if config.ClientAuth != tls.RequireAnyClientCert || config.MinVersion != tls.VersionTLS12 || len(config.CipherSuites) != 1 || config.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("configureTLS got client auth %s, version %x, cipher suites %v want require, TLS 1.2, one suite", config.ClientAuth, config.MinVersion, config.CipherSuites)
+	}
+
+	for _, c := range []struct {
+		clientAuth, minVersion string
+		cipherSuites           []string
+	}{
+		{clientAuth: "optional"},
+		{clientAuth: "require_and_verify"},
+		{clientAuth: "verify_if_given"},
+		{minVersion: "1.4"},
+		{cipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+	} {
+		if err := configureTLS(r.tlsConfig(), c.clientAuth, c.minVersion, c.cipherSuites, false); err == nil {
+			t.Errorf("configureTLS(%+v) without cafile got success want error", c)
+		}
+	}
+}
+
+func TestListenerTLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if c, creds, err := listenerTLS(ctx, &config.ListenerConfig{Address: "127.0.0.1:0"}, &grafeas.NoOpLogger{}); c != nil || creds != nil || err != nil {
+		t.Errorf("listenerTLS without certificates got %v, %v, %v want nils", c, creds, err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, time.Now().Add(time.Hour))
+	grpcTLS, creds, err := listenerTLS(ctx, &config.ListenerConfig{CertFile: certFile, KeyFile: keyFile, CAFile: certFile}, &grafeas.NoOpLogger{}, "h2")
+	if err != nil {
+		t.Fatalf("listenerTLS got %v want success", err)
+	}
+
+	// The gateway connects to a separate gRPC listener with mutual TLS.
+	s := storage.NewMemStore()
+	var (
+		db   grafeas.Storage = s
+		proj project.Storage = s
+	)
+	l, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen got %v want success", err)
+	}
+	grpcServer := newGrpcServer(grpcTLS, &db, &proj, &grafeas.NoOpAuth{}, &grafeas.NoOpLogger{}, apiSettings{})
+	go grpcServer.Serve(l)
+	defer grpcServer.Stop()
+
+	for _, c := range []struct {
+		name     string
+		withTLS  bool
+		wantCode int
+	}{
+		{name: "with TLS", withTLS: true, wantCode: http.StatusOK},
+		{name: "without TLS", withTLS: false, wantCode: http.StatusServiceUnavailable},
+	} {
+		gwCreds := creds
+		if !c.withTLS {
+			gwCreds = nil
+		}
+		gwmux, err := newGrpcGatewayServer(ctx, dialTarget(l), gwCreds, nil)
+		if err != nil {
+			t.Fatalf("newGrpcGatewayServer %s got %v want success", c.name, err)
+		}
+		rec := httptest.NewRecorder()
+		gwmux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1beta1/projects", nil))
+		if rec.Code != c.wantCode {
+			t.Errorf("GET through gateway %s got status %d want %d: %s", c.name, rec.Code, c.wantCode, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// HealthzPath is the HTTP path of the liveness probe, which succeeds while the server runs.
+	HealthzPath = "/healthz"
+	// ReadyzPath is the HTTP path of the readiness probe, which succeeds while the server can serve
+	// requests.
+	ReadyzPath = "/readyz"
+
+	healthCheckInterval = 10 * time.Second
+	healthCheckTimeout  = 5 * time.Second
+)
+
+// healthChecker reports the health of the server through the grpc.health.v1 service and the
+// readiness probe. The server is serving while its storage is healthy, as far as the storage
+// implements storage.HealthChecker.
+type healthChecker struct {
+	db     grafeas.Storage
+	server *health.Server
+
+	mu       sync.Mutex
+	serving  bool
+	stopped  bool
+	services []string
+}
+
+// newHealthChecker returns a healthChecker of the server using db, which is not serving until its
+// storage has been checked.
+func newHealthChecker(db grafeas.Storage) *healthChecker {
+	h := &healthChecker{db: db, server: health.NewServer()}
+	h.set(false)
+	return h
+}
+
+// register registers the health service with s, which reports the health of the server as a whole
+// and of each service registered with s so far.
+func (h *healthChecker) register(s *grpc.Server) {
+	h.mu.Lock()
+	for name := range s.GetServiceInfo() {
+		h.services = append(h.services, name)
+	}
+	sort.Strings(h.services)
+	h.mu.Unlock()
+	healthpb.RegisterHealthServer(s, h.server)
+	h.set(h.ready())
+}
+
+// run checks the health of the storage every healthCheckInterval until ctx is done.
+func (h *healthChecker) run(ctx context.Context) {
+	t := time.NewTicker(healthCheckInterval)
+	defer t.Stop()
+	for {
+		h.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// check checks the health of the storage once.
+func (h *healthChecker) check(ctx context.Context) {
+	var err error
+	var c storage.HealthChecker
+	if storage.As(h.db, &c) {
+		ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		defer cancel()
+		err = c.CheckHealth(ctx)
+	}
+	switch serving := h.ready(); {
+	case err != nil && serving:
+		log.Printf("storage is unhealthy, not serving: %s", err)
+	case err == nil && !serving:
+		log.Println("storage is healthy, serving")
+	}
+	h.set(err == nil)
+}
+
+// set sets the serving status of the server and its services.
+func (h *healthChecker) set(serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		return
+	}
+	h.serving = serving
+	h.server.SetServingStatus("", status)
+	for _, s := range h.services {
+		h.server.SetServingStatus(s, status)
+	}
+}
+
+// shutdown reports the server as not serving for good, as it is shutting down.
+func (h *healthChecker) shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.serving = false
+	h.stopped = true
+	h.server.Shutdown()
+}
+
+// ready returns whether the server is serving.
+func (h *healthChecker) ready() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.serving
+}
+
+// healthzHandler serves the liveness probe.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// readyzHandler serves the readiness probe, which fails with 503 Service Unavailable while the
+// server is not serving.
+func (h *healthChecker) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.ready() {
+		http.Error(w, "not serving", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,93 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// unhealthyStorage is a memstore whose health is set by the test.
+type unhealthyStorage struct {
+	*storage.MemStore
+	err error
+}
+
+func (s *unhealthyStorage) CheckHealth(ctx context.Context) error {
+	return s.err
+}
+
+func TestHealthChecker(t *testing.T) {
+	ctx := context.Background()
+	s := &unhealthyStorage{MemStore: storage.NewMemStore(), err: errors.New("unreachable")}
+	var (
+		db   grafeas.Storage = s
+		proj project.Storage = s
+	)
+	h := newHealthChecker(db)
+	grpcServer := newGrpcServer(nil, &db, &proj, &grafeas.NoOpAuth{}, &grafeas.NoOpLogger{}, apiSettings{})
+	registerServerServices(grpcServer, &config.ServerConfig{}, h)
+
+	check := func(want healthpb.HealthCheckResponse_ServingStatus, wantCode int) {
+		t.Helper()
+		for _, service := range []string{"", "grafeas.v1beta1.GrafeasV1Beta1"} {
+			resp, err := h.server.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			if err != nil || resp.Status != want {
+				t.Errorf("Check(%q) got %v, %v want %v", service, resp, err, want)
+			}
+		}
+		rec := httptest.NewRecorder()
+		h.readyzHandler(rec, httptest.NewRequest(http.MethodGet, ReadyzPath, nil))
+		if rec.Code != wantCode {
+			t.Errorf("%s got status %d want %d", ReadyzPath, rec.Code, wantCode)
+		}
+	}
+
+	check(healthpb.HealthCheckResponse_NOT_SERVING, http.StatusServiceUnavailable)
+	h.check(ctx)
+	check(healthpb.HealthCheckResponse_NOT_SERVING, http.StatusServiceUnavailable)
+	s.err = nil
+	h.check(ctx)
+	check(healthpb.HealthCheckResponse_SERVING, http.StatusOK)
+	h.shutdown()
+	h.check(ctx)
+	check(healthpb.HealthCheckResponse_NOT_SERVING, http.StatusServiceUnavailable)
+
+	if _, ok := grpcServer.GetServiceInfo()["grpc.health.v1.Health"]; !ok {
+		t.Errorf("GetServiceInfo got %v want the health service", grpcServer.GetServiceInfo())
+	}
+	if _, ok := grpcServer.GetServiceInfo()["grpc.reflection.v1alpha.ServerReflection"]; ok {
+		t.Errorf("GetServiceInfo got the reflection service want it disabled")
+	}
+}
+
+func TestHealthCheckerMemStore(t *testing.T) {
+	h := newHealthChecker(storage.NewMemStore())
+	h.register(grpc.NewServer())
+	h.check(context.Background())
+	if !h.ready() {
+		t.Errorf("ready got false want true for storage that does not check its health")
+	}
+}
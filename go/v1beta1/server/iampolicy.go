@@ -0,0 +1,119 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// iamPolicyMethods are the methods of the IAMPolicy service, by the verb of their REST route.
+var iamPolicyMethods = []struct {
+	verb       string
+	rpc        string
+	newRequest func() proto.Message
+	newReply   func() proto.Message
+}{
+	{
+		verb:       "setIamPolicy",
+		rpc:        "/google.iam.v1.IAMPolicy/SetIamPolicy",
+		newRequest: func() proto.Message { return &iampb.SetIamPolicyRequest{} },
+		newReply:   func() proto.Message { return &iampb.Policy{} },
+	},
+	{
+		verb:       "getIamPolicy",
+		rpc:        "/google.iam.v1.IAMPolicy/GetIamPolicy",
+		newRequest: func() proto.Message { return &iampb.GetIamPolicyRequest{} },
+		newReply:   func() proto.Message { return &iampb.Policy{} },
+	},
+	{
+		verb:       "testIamPermissions",
+		rpc:        "/google.iam.v1.IAMPolicy/TestIamPermissions",
+		newRequest: func() proto.Message { return &iampb.TestIamPermissionsRequest{} },
+		newReply:   func() proto.Message { return &iampb.TestIamPermissionsResponse{} },
+	},
+}
+
+// policyStorage returns the storage of the IAM policies of notes and occurrences of db, or nil if
+// db does not store them.
+func policyStorage(db grafeas.Storage) grafeas.PolicyStorage {
+	var ps grafeas.PolicyStorage
+	if !storage.As(db, &ps) {
+		return nil
+	}
+	return ps
+}
+
+// registerIAMPolicyHandlers registers the REST routes of the IAMPolicy service on notes and
+// occurrences with mux, which forward to conn like the generated gateway handlers do. The service
+// has no generated gateway, as its HTTP rules depend on the resources of the API it is part of.
+func registerIAMPolicyHandlers(mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	for _, collection := range []string{"notes", "occurrences"} {
+		for _, m := range iamPolicyMethods {
+			m := m
+			pattern := "/v1beta1/{resource=projects/*/" + collection + "/*}:" + m.verb
+			err := mux.HandlePath("POST", pattern, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+				ctx, cancel := context.WithCancel(req.Context())
+				defer cancel()
+				inboundMarshaler, outboundMarshaler := runtime.MarshalerForRequest(mux, req)
+				rctx, err := runtime.AnnotateContext(ctx, mux, req, m.rpc, runtime.WithHTTPPathPattern(pattern))
+				if err != nil {
+					runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
+					return
+				}
+
+				resp, md, err := requestIAMPolicy(rctx, inboundMarshaler, conn, m.rpc, m.newRequest(), m.newReply(), req, pathParams)
+				ctx = runtime.NewServerMetadataContext(ctx, md)
+				if err != nil {
+					runtime.HTTPError(ctx, mux, outboundMarshaler, w, req, err)
+					return
+				}
+				runtime.ForwardResponseMessage(ctx, mux, outboundMarshaler, w, req, resp, mux.GetForwardResponseOptions()...)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// requestIAMPolicy calls the IAMPolicy method rpc with protoReq, read from the body of req and
+// the resource path parameter, and returns reply.
+func requestIAMPolicy(ctx context.Context, marshaler runtime.Marshaler, conn *grpc.ClientConn, rpc string, protoReq, reply proto.Message, req *http.Request, pathParams map[string]string) (proto.Message, runtime.ServerMetadata, error) {
+	var metadata runtime.ServerMetadata
+	if err := marshaler.NewDecoder(req.Body).Decode(protoReq); err != nil && err != io.EOF {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	val, ok := pathParams["resource"]
+	if !ok {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "missing parameter %s", "resource")
+	}
+	if err := runtime.PopulateFieldFromPath(protoReq, "resource", val); err != nil {
+		return nil, metadata, status.Errorf(codes.InvalidArgument, "type mismatch, parameter: %s, error: %v", "resource", err)
+	}
+	err := conn.Invoke(ctx, rpc, protoReq, reply, grpc.Header(&metadata.HeaderMD), grpc.Trailer(&metadata.TrailerMD))
+	return reply, metadata, err
+}
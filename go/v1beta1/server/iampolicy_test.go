@@ -0,0 +1,120 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
+	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+)
+
+func TestIAMPolicyGateway(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemStore()
+	var (
+		db   grafeas.Storage = s
+		proj project.Storage = s
+	)
+	a, gwMuxOpts, err := newAuth(ctx, &config.ServerConfig{CAFile: "ca.crt", Auth: &config.AuthConfig{
+		Type: "mtls",
+		Bindings: []config.AuthBinding{
+			{Identities: []string{"vendor"}, Projects: []string{"vulns"}, Permissions: []string{"notes.*"}},
+			{Identities: []string{"consumer"}, Projects: []string{"scans", "other"}, Permissions: []string{"occurrences.*"}},
+		},
+	}}, db, &grafeas.NoOpLogger{})
+	if err != nil {
+		t.Fatalf("newAuth got %v want success", err)
+	}
+	l, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen got %v want success", err)
+	}
+	grpcServer := newGrpcServer(nil, &db, &proj, a, &grafeas.NoOpLogger{}, apiSettings{})
+	go grpcServer.Serve(l)
+	defer grpcServer.Stop()
+	gwmux, err := newGrpcGatewayServer(ctx, dialTarget(l), nil, gwMuxOpts)
+	if err != nil {
+		t.Fatalf("newGrpcGatewayServer got %v want success", err)
+	}
+
+	if _, err := s.CreateNote(ctx, "vulns", "CVE-1", "", &pb.Note{Kind: cpb.NoteKind_VULNERABILITY}); err != nil {
+		t.Fatalf("CreateNote got %v want success", err)
+	}
+	post := func(identity, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: identity}}}}}
+		rec := httptest.NewRecorder()
+		gwmux.ServeHTTP(rec, r)
+		return rec
+	}
+	const occurrence = `{"resource": {"uri": "https://example.org/image"}, "noteName": "projects/vulns/notes/CVE-1", "vulnerability": {"packageIssue": [{"affectedLocation": {"cpeUri": "cpe:/o:debian:debian_linux:9", "package": "icu", "version": {"kind": "MINIMUM"}}}]}}`
+
+	if rec := post("consumer", "/v1beta1/projects/scans/occurrences", occurrence); rec.Code != http.StatusForbidden {
+		t.Errorf("CreateOccurrence before grant got status %d want %d: %s", rec.Code, http.StatusForbidden, rec.Body)
+	}
+	if rec := post("consumer", "/v1beta1/projects/vulns/notes/CVE-1:setIamPolicy", `{"policy": {}}`); rec.Code != http.StatusForbidden {
+		t.Errorf("SetIamPolicy as consumer got status %d want %d: %s", rec.Code, http.StatusForbidden, rec.Body)
+	}
+
+	rec := post("vendor", "/v1beta1/projects/vulns/notes/CVE-1:getIamPolicy", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetIamPolicy got status %d want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var empty struct{ Etag string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &empty); err != nil || empty.Etag == "" {
+		t.Fatalf("GetIamPolicy got %s, %v want a policy with an etag", rec.Body, err)
+	}
+	grant := `{"policy": {"bindings": [{"role": "note-attacher", "members": ["consumer"]}], "etag": "` + empty.Etag + `"}}`
+	if rec := post("vendor", "/v1beta1/projects/vulns/notes/CVE-1:setIamPolicy", grant); rec.Code != http.StatusOK {
+		t.Fatalf("SetIamPolicy got status %d want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec := post("vendor", "/v1beta1/projects/vulns/notes/CVE-1:setIamPolicy", grant); rec.Code != http.StatusConflict {
+		t.Errorf("SetIamPolicy with stale etag got status %d want %d: %s", rec.Code, http.StatusConflict, rec.Body)
+	}
+
+	rec = post("consumer", "/v1beta1/projects/vulns/notes/CVE-1:testIamPermissions", `{"permissions": ["notes.attachOccurrence", "notes.update"]}`)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"permissions":["notes.attachOccurrence"]}` {
+		t.Errorf("TestIamPermissions got status %d, %s want the attach permission", rec.Code, rec.Body)
+	}
+	if rec := post("consumer", "/v1beta1/projects/scans/occurrences", occurrence); rec.Code != http.StatusOK {
+		t.Errorf("CreateOccurrence after grant got status %d want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	// A project member lets callers attach occurrences of the project, whoever they are.
+	grant = `{"policy": {"bindings": [{"role": "note-attacher", "members": ["project:scans"]}]}}`
+	if rec := post("vendor", "/v1beta1/projects/vulns/notes/CVE-1:setIamPolicy", grant); rec.Code != http.StatusOK {
+		t.Fatalf("SetIamPolicy with project member got status %d want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec := post("consumer", "/v1beta1/projects/scans/occurrences", occurrence); rec.Code != http.StatusOK {
+		t.Errorf("CreateOccurrence in granted project got status %d want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec := post("consumer", "/v1beta1/projects/other/occurrences", occurrence); rec.Code != http.StatusForbidden {
+		t.Errorf("CreateOccurrence in other project got status %d want %d: %s", rec.Code, http.StatusForbidden, rec.Body)
+	}
+}
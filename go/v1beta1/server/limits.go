@@ -0,0 +1,186 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	validators "github.com/grafeas/grafeas/go/v1beta1/api/validators/grafeas"
+	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
+)
+
+// defaultMaxRequestBytes is the default maximum size of requests, gRPC's default.
+const defaultMaxRequestBytes = 4 << 20
+
+// apiSettings are the settings of the Grafeas API that come from the configuration.
+type apiSettings struct {
+	quotas     *grafeas.Quotas
+	sizeLimits validators.SizeLimits
+	limits     grafeas.Limits
+	// validationMode is the mode of the kinds that validationModes has no mode for. The zero
+	// value enforces validation.
+	validationMode  grafeas.ValidationMode
+	validationModes map[string]grafeas.ValidationMode
+}
+
+// newAPISettings returns the API settings configured by c, where quotas are checked against the
+// counts of db.
+func newAPISettings(c *config.ServerConfig, db grafeas.Storage) (apiSettings, error) {
+	s := apiSettings{sizeLimits: sizeLimits(c.APILimits)}
+	var err error
+	if c.Quotas != nil {
+		if s.quotas, err = newQuotas(c.Quotas, db); err != nil {
+			return apiSettings{}, err
+		}
+	}
+	if c.APILimits != nil {
+		if s.limits, err = apiLimits(c.APILimits); err != nil {
+			return apiSettings{}, err
+		}
+		if c.APILimits.Validation != nil {
+			if s.validationMode, s.validationModes, err = validationModes(c.APILimits.Validation); err != nil {
+				return apiSettings{}, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// apiLimits returns the page and batch size limits configured by c. If c gives no default page
+// size, it is at most the max page size.
+func apiLimits(c *config.APILimitsConfig) (grafeas.Limits, error) {
+	if c.DefaultPageSize < 0 || c.MaxPageSize < 0 || c.MaxBatchSize < 0 {
+		return grafeas.Limits{}, errors.New("api_limits page and batch sizes cannot be negative")
+	}
+	l := grafeas.Limits{DefaultPageSize: c.DefaultPageSize, MaxPageSize: c.MaxPageSize, MaxBatchSize: c.MaxBatchSize}
+	switch def, max := l.PageSizes(); {
+	case def <= max:
+	case c.DefaultPageSize == 0:
+		// A max page size below the default default page size lowers it too.
+		l.DefaultPageSize = max
+	default:
+		return grafeas.Limits{}, errors.New(fmt.Sprintf("api_limits default_page_size %d is larger than max_page_size %d", def, max))
+	}
+	return l, nil
+}
+
+// validationModes returns the validation mode of all kinds and the modes of each kind configured
+// by c.
+func validationModes(c *config.ValidationConfig) (grafeas.ValidationMode, map[string]grafeas.ValidationMode, error) {
+	mode, err := validationMode(c.Mode)
+	if err != nil {
+		return 0, nil, err
+	}
+	modes := map[string]grafeas.ValidationMode{}
+	for kind, m := range c.Kinds {
+		kind = strings.ToLower(kind)
+		if v, ok := cpb.NoteKind_value[strings.ToUpper(kind)]; !ok || v == int32(cpb.NoteKind_NOTE_KIND_UNSPECIFIED) {
+			return 0, nil, errors.New(fmt.Sprintf("unknown kind %q of validation mode", kind))
+		}
+		if modes[kind], err = validationMode(m); err != nil {
+			return 0, nil, err
+		}
+	}
+	return mode, modes, nil
+}
+
+// validationMode returns the validation mode of name, which is enforce if it is empty.
+func validationMode(name string) (grafeas.ValidationMode, error) {
+	switch name {
+	case "", "enforce":
+		return grafeas.ValidationEnforce, nil
+	case "warn":
+		return grafeas.ValidationWarn, nil
+	case "off":
+		return grafeas.ValidationOff, nil
+	}
+	return 0, errors.New(fmt.Sprintf(`unknown validation mode %q, want "enforce", "warn" or "off" (quote "off" in YAML)`, name))
+}
+
+// sizeLimits returns the size limits of notes and occurrences configured by c. Fields are
+// unlimited unless c gives a positive limit, as strings such as provenance_bytes and signatures
+// can be large.
+func sizeLimits(c *config.APILimitsConfig) validators.SizeLimits {
+	var l validators.SizeLimits
+	if c == nil {
+		return l
+	}
+	for _, f := range []struct {
+		configured int
+		limit      *int
+	}{
+		{c.MaxDescriptionLength, &l.MaxDescriptionLength},
+		{c.MaxStringLength, &l.MaxStringLength},
+		{c.MaxRelatedURLs, &l.MaxRelatedURLs},
+		{c.MaxPackageIssues, &l.MaxPackageIssues},
+		{c.MaxRepeatedLength, &l.MaxRepeatedLength},
+	} {
+		if f.configured > 0 {
+			*f.limit = f.configured
+		}
+	}
+	return l
+}
+
+// maxRequestBytes returns the maximum size of requests configured by c, or 0 if it is unlimited.
+func maxRequestBytes(c *config.APILimitsConfig) int {
+	if c == nil {
+		return defaultMaxRequestBytes
+	}
+	return limit(c.MaxRequestBytes, defaultMaxRequestBytes)
+}
+
+// limit returns the configured limit, def if it is 0, and 0 for no limit if it is negative.
+func limit(configured, def int) int {
+	switch {
+	case configured == 0:
+		return def
+	case configured < 0:
+		return 0
+	}
+	return configured
+}
+
+// grpcMaxRecvMsgSize returns the maximum size of the gRPC messages received by the server, for a
+// maximum request size of n.
+func grpcMaxRecvMsgSize(n int) int {
+	if n == 0 {
+		return math.MaxInt32
+	}
+	return n
+}
+
+// limitRequestBody returns a handler that fails reading request bodies larger than the maximum
+// request size of c, before passing requests on to h.
+func limitRequestBody(c *config.ServerConfig, h http.Handler) http.Handler {
+	n := maxRequestBytes(c.APILimits)
+	if n == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > int64(n) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, int64(n))
+		h.ServeHTTP(w, r)
+	})
+}
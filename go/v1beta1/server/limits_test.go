@@ -0,0 +1,126 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	validators "github.com/grafeas/grafeas/go/v1beta1/api/validators/grafeas"
+)
+
+func TestSizeLimits(t *testing.T) {
+	if got := sizeLimits(nil); got != (validators.SizeLimits{}) {
+		t.Errorf("sizeLimits(nil) got %+v want unlimited", got)
+	}
+	if got := sizeLimits(&config.APILimitsConfig{MaxPageSize: 10}); got != (validators.SizeLimits{}) {
+		t.Errorf("sizeLimits without field limits got %+v want unlimited", got)
+	}
+	got := sizeLimits(&config.APILimitsConfig{MaxStringLength: 100, MaxPackageIssues: -1})
+	want := validators.SizeLimits{MaxStringLength: 100}
+	if got != want {
+		t.Errorf("sizeLimits got %+v want %+v", got, want)
+	}
+}
+
+func TestAPILimits(t *testing.T) {
+	got, err := apiLimits(&config.APILimitsConfig{MaxPageSize: 10000, MaxBatchSize: 50})
+	want := grafeas.Limits{MaxPageSize: 10000, MaxBatchSize: 50}
+	if err != nil || got != want {
+		t.Errorf("apiLimits got %+v, %v want %+v", got, err, want)
+	}
+	got, err = apiLimits(&config.APILimitsConfig{MaxPageSize: 5})
+	want = grafeas.Limits{DefaultPageSize: 5, MaxPageSize: 5}
+	if err != nil || got != want {
+		t.Errorf("apiLimits got %+v, %v want %+v", got, err, want)
+	}
+	for _, c := range []*config.APILimitsConfig{
+		{MaxBatchSize: -1},
+		{DefaultPageSize: -1},
+		{DefaultPageSize: 2000},
+		{DefaultPageSize: 20, MaxPageSize: 10},
+	} {
+		if _, err := apiLimits(c); err == nil {
+			t.Errorf("apiLimits(%+v) got success want error", c)
+		}
+	}
+}
+
+func TestValidationModes(t *testing.T) {
+	mode, modes, err := validationModes(&config.ValidationConfig{
+		Mode:  "warn",
+		Kinds: map[string]string{"vulnerability": "enforce", "SPDX_FILE": "off"},
+	})
+	if err != nil {
+		t.Fatalf("validationModes got %v want success", err)
+	}
+	want := map[string]grafeas.ValidationMode{"vulnerability": grafeas.ValidationEnforce, "spdx_file": grafeas.ValidationOff}
+	if mode != grafeas.ValidationWarn || !cmp.Equal(modes, want) {
+		t.Errorf("validationModes got %v, %v want %v, %v", mode, modes, grafeas.ValidationWarn, want)
+	}
+	for _, c := range []*config.ValidationConfig{
+		{Mode: "strict"},
+		// An unquoted off in YAML is false.
+		{Mode: "0"},
+		{Kinds: map[string]string{"virus": "off"}},
+		{Kinds: map[string]string{"note_kind_unspecified": "off"}},
+		{Kinds: map[string]string{"build": "none"}},
+	} {
+		if _, _, err := validationModes(c); err == nil {
+			t.Errorf("validationModes(%+v) got success want error", c)
+		}
+	}
+
+}
+
+func TestLimitRequestBody(t *testing.T) {
+	h := limitRequestBody(&config.ServerConfig{APILimits: &config.APILimitsConfig{MaxRequestBytes: 10}}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	for _, c := range []struct {
+		desc string
+		body string
+		// chunked hides the length of the body.
+		chunked  bool
+		wantCode int
+	}{
+		{desc: "small body", body: "0123456789", wantCode: http.StatusOK},
+		{desc: "large body", body: "0123456789a", wantCode: http.StatusRequestEntityTooLarge},
+		{desc: "large chunked body", body: "0123456789a", chunked: true, wantCode: http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest("POST", "/v1beta1/projects", strings.NewReader(c.body))
+		if c.chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != c.wantCode {
+			t.Errorf("%q: got status %d want %d", c.desc, w.Code, c.wantCode)
+		}
+	}
+
+	// Negative limits are unlimited.
+	if got := maxRequestBytes(&config.APILimitsConfig{MaxRequestBytes: -1}); got != 0 {
+		t.Errorf("maxRequestBytes of negative limit got %d want 0", got)
+	}
+}
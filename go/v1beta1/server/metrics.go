@@ -0,0 +1,338 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/middleware"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsPath is the HTTP path at which Prometheus metrics are served.
+const MetricsPath = "/metrics"
+
+const (
+	// objectCountInterval is how often objects are counted at most, as counting can be expensive.
+	objectCountInterval = time.Minute
+	// objectCountTimeout limits how long counting objects may delay a scrape.
+	objectCountTimeout = 10 * time.Second
+)
+
+var (
+	grpcStartedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_started_total",
+		Help: "Total number of RPCs started on the server.",
+	}, []string{"grpc_type", "grpc_service", "grpc_method"})
+	grpcHandledTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Total number of RPCs completed on the server, regardless of success or failure.",
+	}, []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"})
+	grpcHandlingSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Histogram of response latency of RPCs handled by the server.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"grpc_type", "grpc_service", "grpc_method"})
+
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grafeas_http_requests_total",
+		Help: "Total number of HTTP requests handled by the REST gateway.",
+	}, []string{"code", "method"})
+	httpRequestSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grafeas_http_request_duration_seconds",
+		Help:    "Histogram of response latency of HTTP requests handled by the REST gateway.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"code", "method"})
+
+	storageOperationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grafeas_storage_operation_duration_seconds",
+		Help:    "Histogram of the latency of storage operations.",
+		Buckets: middleware.DefaultLatencyBuckets,
+	}, []string{"backend", "method", "grpc_code"})
+
+	tlsCertificateExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "grafeas_tls_certificate_expiry_timestamp_seconds",
+		Help: "Time at which a certificate the server uses for TLS expires, in seconds since the epoch.",
+	}, []string{"file", "subject"})
+
+	objectsDesc = prometheus.NewDesc("grafeas_objects",
+		"Number of objects in storage.", []string{"kind"}, nil)
+	cacheHitsDesc = prometheus.NewDesc("grafeas_cache_hits_total",
+		"Total number of storage cache hits.", []string{"kind"}, nil)
+	cacheMissesDesc = prometheus.NewDesc("grafeas_cache_misses_total",
+		"Total number of storage cache misses.", []string{"kind"}, nil)
+	cacheEvictionsDesc = prometheus.NewDesc("grafeas_cache_evictions_total",
+		"Total number of entries evicted from the storage cache.", nil, nil)
+	dbMaxOpenDesc = prometheus.NewDesc("grafeas_storage_db_max_open_connections",
+		"Maximum number of open connections to the database.", []string{"backend"}, nil)
+	dbOpenDesc = prometheus.NewDesc("grafeas_storage_db_open_connections",
+		"Number of established connections to the database, in use or idle.", []string{"backend"}, nil)
+	dbInUseDesc = prometheus.NewDesc("grafeas_storage_db_in_use_connections",
+		"Number of database connections currently in use.", []string{"backend"}, nil)
+	dbIdleDesc = prometheus.NewDesc("grafeas_storage_db_idle_connections",
+		"Number of idle database connections.", []string{"backend"}, nil)
+	dbWaitCountDesc = prometheus.NewDesc("grafeas_storage_db_wait_count_total",
+		"Total number of times a database connection was waited for.", []string{"backend"}, nil)
+	dbWaitSecondsDesc = prometheus.NewDesc("grafeas_storage_db_wait_seconds_total",
+		"Total time spent waiting for database connections.", []string{"backend"}, nil)
+	dbMaxIdleClosedDesc = prometheus.NewDesc("grafeas_storage_db_max_idle_closed_total",
+		"Total number of database connections closed due to the idle connection limits.", []string{"backend"}, nil)
+	dbMaxLifetimeClosedDesc = prometheus.NewDesc("grafeas_storage_db_max_lifetime_closed_total",
+		"Total number of database connections closed due to the connection lifetime limit.", []string{"backend"}, nil)
+	middlewareLatencyDesc = prometheus.NewDesc("grafeas_storage_middleware_latency_seconds",
+		"Histogram of the latency of storage methods measured by latency middleware.", []string{"middleware", "method"}, nil)
+)
+
+// metricsEnabled returns whether config enables metrics.
+func metricsEnabled(config *config.ServerConfig) bool {
+	return config.Metrics || config.MetricsAddress != ""
+}
+
+// registerMetrics registers the server metrics, and the metrics of db, which has the storage
+// type backend, with the default Prometheus registry.
+func registerMetrics(backend string, db grafeas.Storage) error {
+	for _, c := range []prometheus.Collector{
+		grpcStartedTotal,
+		grpcHandledTotal,
+		grpcHandlingSeconds,
+		httpRequestsTotal,
+		httpRequestSeconds,
+		storageOperationSeconds,
+		tlsCertificateExpiry,
+		newStorageCollector(backend, db),
+		latencyCollector(storageLatencies(db)),
+	} {
+		if err := prometheus.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serveMetrics serves metrics on a separate listener at address.
+func serveMetrics(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.New(fmt.Sprintf("could not listen to metrics address %s", address))
+	}
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, promhttp.Handler())
+	go func() {
+		if err := http.Serve(l, mux); err != nil {
+			log.Printf("metrics server stopped: %s", err)
+		}
+	}()
+	log.Printf("serving metrics on %s%s", address, MetricsPath)
+	return nil
+}
+
+// grpcMetricsInterceptor counts and times unary RPCs.
+func grpcMetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	service, method := splitMethodName(info.FullMethod)
+	grpcStartedTotal.WithLabelValues("unary", service, method).Inc()
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	grpcHandledTotal.WithLabelValues("unary", service, method, status.Code(err).String()).Inc()
+	grpcHandlingSeconds.WithLabelValues("unary", service, method).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// splitMethodName splits a full gRPC method name, e.g. "/grafeas.v1beta1.GrafeasV1Beta1/GetNote",
+// into its service and method.
+func splitMethodName(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.Index(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
+
+// gatewayHandler returns the REST gateway handler h, counting and timing its requests if config
+// enables metrics.
+func gatewayHandler(config *config.ServerConfig, h http.Handler) http.Handler {
+	if !metricsEnabled(config) {
+		return h
+	}
+	return promhttp.InstrumentHandlerDuration(httpRequestSeconds, promhttp.InstrumentHandlerCounter(httpRequestsTotal, h))
+}
+
+// storageMetricsInterceptor times the operations of the storage of type backend.
+func storageMetricsInterceptor(backend string) middleware.Interceptor {
+	return middleware.InterceptorFunc(func(ctx context.Context, method string, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		storageOperationSeconds.WithLabelValues(backend, method, status.Code(err).String()).Observe(time.Since(start).Seconds())
+		return err
+	})
+}
+
+// interceptedStorage is implemented by storage.InterceptedStore.
+type interceptedStorage interface {
+	Interceptor() middleware.Interceptor
+	Unwrap() grafeas.Storage
+}
+
+// storageLatencies returns the latency middleware that db is wrapped with, the outermost first.
+func storageLatencies(db grafeas.Storage) []*middleware.Latency {
+	var ls []*middleware.Latency
+	var is interceptedStorage
+	for storage.As(db, &is) {
+		for _, i := range middleware.Interceptors(is.Interceptor()) {
+			if l, ok := i.(*middleware.Latency); ok {
+				ls = append(ls, l)
+			}
+		}
+		db = is.Unwrap()
+	}
+	return ls
+}
+
+// latencyCollector collects the histograms of latency middleware, labeled with their position
+// among the latency middleware.
+type latencyCollector []*middleware.Latency
+
+// Describe implements prometheus.Collector.
+func (c latencyCollector) Describe(ch chan<- *prometheus.Desc) {
+	if len(c) > 0 {
+		ch <- middlewareLatencyDesc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c latencyCollector) Collect(ch chan<- prometheus.Metric) {
+	for i, l := range c {
+		for method, h := range l.Histograms() {
+			buckets := make(map[float64]uint64, len(h.Buckets))
+			for j, b := range h.Buckets {
+				buckets[b] = h.Counts[j]
+			}
+			ch <- prometheus.MustNewConstHistogram(middlewareLatencyDesc, h.Count, h.Sum, buckets, strconv.Itoa(i), method)
+		}
+	}
+}
+
+// dbStatser is implemented by storage backed by database/sql, such as storage.PgSQLStore.
+type dbStatser interface {
+	Stats() sql.DBStats
+}
+
+// cacheStatser is implemented by storage.CachingStore.
+type cacheStatser interface {
+	Stats() storage.CacheStats
+}
+
+// storageCollector collects the object counts, connection pool statistics and cache statistics of
+// a storage, as far as it supports them.
+type storageCollector struct {
+	backend string
+	counter storage.ObjectCounter
+	db      dbStatser
+	cache   cacheStatser
+
+	mu        sync.Mutex
+	counts    *storage.ObjectCounts
+	countedAt time.Time
+}
+
+func newStorageCollector(backend string, db grafeas.Storage) *storageCollector {
+	c := &storageCollector{backend: backend}
+	storage.As(db, &c.counter)
+	storage.As(db, &c.db)
+	storage.As(db, &c.cache)
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *storageCollector) Describe(ch chan<- *prometheus.Desc) {
+	if c.counter != nil {
+		ch <- objectsDesc
+	}
+	if c.db != nil {
+		for _, d := range []*prometheus.Desc{dbMaxOpenDesc, dbOpenDesc, dbInUseDesc, dbIdleDesc, dbWaitCountDesc, dbWaitSecondsDesc, dbMaxIdleClosedDesc, dbMaxLifetimeClosedDesc} {
+			ch <- d
+		}
+	}
+	if c.cache != nil {
+		ch <- cacheHitsDesc
+		ch <- cacheMissesDesc
+		ch <- cacheEvictionsDesc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *storageCollector) Collect(ch chan<- prometheus.Metric) {
+	if counts := c.objectCounts(); counts != nil {
+		ch <- prometheus.MustNewConstMetric(objectsDesc, prometheus.GaugeValue, float64(counts.Projects), "project")
+		ch <- prometheus.MustNewConstMetric(objectsDesc, prometheus.GaugeValue, float64(counts.Notes), "note")
+		ch <- prometheus.MustNewConstMetric(objectsDesc, prometheus.GaugeValue, float64(counts.Occurrences), "occurrence")
+	}
+	if c.db != nil {
+		s := c.db.Stats()
+		ch <- prometheus.MustNewConstMetric(dbMaxOpenDesc, prometheus.GaugeValue, float64(s.MaxOpenConnections), c.backend)
+		ch <- prometheus.MustNewConstMetric(dbOpenDesc, prometheus.GaugeValue, float64(s.OpenConnections), c.backend)
+		ch <- prometheus.MustNewConstMetric(dbInUseDesc, prometheus.GaugeValue, float64(s.InUse), c.backend)
+		ch <- prometheus.MustNewConstMetric(dbIdleDesc, prometheus.GaugeValue, float64(s.Idle), c.backend)
+		ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(s.WaitCount), c.backend)
+		ch <- prometheus.MustNewConstMetric(dbWaitSecondsDesc, prometheus.CounterValue, s.WaitDuration.Seconds(), c.backend)
+		ch <- prometheus.MustNewConstMetric(dbMaxIdleClosedDesc, prometheus.CounterValue, float64(s.MaxIdleClosed+s.MaxIdleTimeClosed), c.backend)
+		ch <- prometheus.MustNewConstMetric(dbMaxLifetimeClosedDesc, prometheus.CounterValue, float64(s.MaxLifetimeClosed), c.backend)
+	}
+	if c.cache != nil {
+		s := c.cache.Stats()
+		ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(s.NoteHits), "note")
+		ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(s.OccurrenceHits), "occurrence")
+		ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(s.NoteMisses), "note")
+		ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(s.OccurrenceMisses), "occurrence")
+		ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(s.Evictions))
+	}
+}
+
+// objectCounts returns the object counts, counting again if the last count is older than
+// objectCountInterval, or nil if objects cannot be counted.
+func (c *storageCollector) objectCounts() *storage.ObjectCounts {
+	if c.counter == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts != nil && time.Since(c.countedAt) < objectCountInterval {
+		return c.counts
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), objectCountTimeout)
+	defer cancel()
+	counts, err := c.counter.CountObjects(ctx)
+	if err != nil {
+		log.Printf("Failed to count objects: %v", err)
+		return c.counts
+	}
+	c.counts, c.countedAt = counts, time.Now()
+	return c.counts
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafeas/grafeas/go/middleware"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLatencyCollector(t *testing.T) {
+	s := &storage.Storage{Ps: storage.NewMemStore(), Gs: storage.NewMemStore()}
+	s.Gs = storage.NewInterceptedStore(s.Gs, storageMetricsInterceptor("memstore"))
+	s.Gs = storage.NewInterceptedStore(s.Gs, middleware.Chain(middleware.NewLatency([]float64{1}), middleware.SlowLog(time.Second)))
+	ls := storageLatencies(s)
+	if len(ls) != 1 {
+		t.Fatalf("storageLatencies got %d latency middleware want 1", len(ls))
+	}
+	if _, err := s.Gs.GetNote(context.Background(), "p", "n"); err == nil {
+		t.Fatalf("GetNote got success want not found")
+	}
+
+	r := prometheus.NewPedanticRegistry()
+	if err := r.Register(latencyCollector(ls)); err != nil {
+		t.Fatalf("Register got %v want success", err)
+	}
+	mfs, err := r.Gather()
+	if err != nil || len(mfs) != 1 || len(mfs[0].Metric) != 1 {
+		t.Fatalf("Gather got %v, %v want one metric", mfs, err)
+	}
+	m := mfs[0].Metric[0]
+	if got := m.GetHistogram().GetSampleCount(); mfs[0].GetName() != "grafeas_storage_middleware_latency_seconds" || got != 1 {
+		t.Errorf("Gather got %s with %d samples want grafeas_storage_middleware_latency_seconds with 1", mfs[0].GetName(), got)
+	}
+	labels := map[string]string{}
+	for _, l := range m.Label {
+		labels[l.GetName()] = l.GetValue()
+	}
+	if labels["method"] != "GetNote" || labels["middleware"] != "0" {
+		t.Errorf("Gather got labels %v want method GetNote of middleware 0", labels)
+	}
+}
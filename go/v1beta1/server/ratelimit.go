@@ -0,0 +1,77 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/ratelimit"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+)
+
+// newRateLimits returns the rate limits configured by c.
+func newRateLimits(c *config.RateLimitsConfig) (*ratelimit.Limits, error) {
+	var l ratelimit.Limits
+	for _, b := range []struct {
+		name  string
+		limit *config.RateLimitConfig
+		read  **ratelimit.Limiter
+		write **ratelimit.Limiter
+	}{
+		{"principal", c.Principal, &l.PrincipalRead, &l.PrincipalWrite},
+		{"project", c.Project, &l.ProjectRead, &l.ProjectWrite},
+	} {
+		if b.limit == nil {
+			continue
+		}
+		var err error
+		if *b.read, err = newLimiter(b.limit.Read); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid %s read rate limit: %s", b.name, err))
+		}
+		if *b.write, err = newLimiter(b.limit.Write); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid %s write rate limit: %s", b.name, err))
+		}
+	}
+	return &l, nil
+}
+
+// newLimiter returns the limiter of the token bucket c, or nil if c is nil.
+func newLimiter(c *config.TokenBucketConfig) (*ratelimit.Limiter, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if c.Rate <= 0 {
+		return nil, errors.New(fmt.Sprintf("rate %v must be positive", c.Rate))
+	}
+	if c.Burst < 0 {
+		return nil, errors.New(fmt.Sprintf("burst %d cannot be negative", c.Burst))
+	}
+	return ratelimit.NewLimiter(ratelimit.Limit{Rate: c.Rate, Burst: c.Burst}), nil
+}
+
+// newQuotas returns the quotas configured by c, which are checked against the counts of db.
+func newQuotas(c *config.QuotasConfig, db grafeas.Storage) (*grafeas.Quotas, error) {
+	if c.MaxNotes < 0 || c.MaxOccurrences < 0 {
+		return nil, errors.New("quotas cannot be negative")
+	}
+	var counter grafeas.ProjectCounter
+	if !storage.As(db, &counter) {
+		return nil, errors.New("quotas are configured, but the storage does not count the notes and occurrences of projects")
+	}
+	return &grafeas.Quotas{MaxNotes: c.MaxNotes, MaxOccurrences: c.MaxOccurrences, Counter: counter}, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+)
+
+func TestNewRateLimits(t *testing.T) {
+	l, err := newRateLimits(&config.RateLimitsConfig{
+		Principal: &config.RateLimitConfig{Write: &config.TokenBucketConfig{Rate: 1}},
+	})
+	if err != nil {
+		t.Fatalf("newRateLimits got %v want success", err)
+	}
+	if l.PrincipalWrite == nil || l.PrincipalRead != nil || l.ProjectRead != nil || l.ProjectWrite != nil {
+		t.Errorf("newRateLimits got %+v want only a principal write limiter", l)
+	}
+
+	for _, c := range []*config.RateLimitsConfig{
+		{Principal: &config.RateLimitConfig{Read: &config.TokenBucketConfig{}}},
+		{Project: &config.RateLimitConfig{Write: &config.TokenBucketConfig{Rate: -1}}},
+		{Project: &config.RateLimitConfig{Read: &config.TokenBucketConfig{Rate: 1, Burst: -1}}},
+	} {
+		if _, err := newRateLimits(c); err == nil {
+			t.Errorf("newRateLimits(%+v) got success want error", c)
+		}
+	}
+}
+
+// uncountedStorage is a memstore that does not count the objects of projects.
+type uncountedStorage struct {
+	grafeas.Storage
+}
+
+func TestNewQuotas(t *testing.T) {
+	q, err := newQuotas(&config.QuotasConfig{MaxOccurrences: 10}, storage.NewMemStore())
+	if err != nil {
+		t.Fatalf("newQuotas got %v want success", err)
+	}
+	if q.MaxNotes != 0 || q.MaxOccurrences != 10 || q.Counter == nil {
+		t.Errorf("newQuotas got %+v want 10 occurrences and a counter", q)
+	}
+
+	if _, err := newQuotas(&config.QuotasConfig{MaxNotes: -1}, storage.NewMemStore()); err == nil {
+		t.Errorf("newQuotas of negative quota got success want error")
+	}
+	if _, err := newQuotas(&config.QuotasConfig{MaxNotes: 1}, uncountedStorage{storage.NewMemStore()}); err == nil {
+		t.Errorf("newQuotas of storage that does not count got success want error")
+	}
+}
@@ -18,29 +18,40 @@ package server
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cockroachdb/cmux"
 	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/logging"
+	"github.com/grafeas/grafeas/go/middleware"
 	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/audit"
+	"github.com/grafeas/grafeas/go/v1beta1/auth"
 	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/ratelimit"
 	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
 	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
 	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 )
 
@@ -55,110 +66,412 @@ func StartGrafeas() error {
 	if err != nil {
 		return status.Errorf(codes.Internal, "failed to load cfg file: %s", err)
 	}
+	logger, err := logging.New(cfg.API.Log, os.Stderr)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to create logger: %s", err)
+	}
+	logger.RedirectStdLog()
+	defer logger.Sync()
 	var db grafeas.Storage
 	var proj project.Storage
 
+	if tracingEnabled(cfg.API) {
+		shutdown, err := setupTracing(context.Background(), cfg.API.Tracing)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to set up tracing: %s", err)
+		}
+		// Export pending spans once the storage is closed.
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				log.Printf("failed to export pending spans: %s", err)
+			}
+		}()
+		log.Println("tracing requests")
+	}
+
 	s, err := storage.CreateStorageOfType(cfg.StorageType, cfg.StorageConfig)
 	if err != nil {
 		return status.Errorf(codes.Internal, "failed to create storage: %s", err)
 	}
+	// Trace and time the backend itself, below any middleware and the cache.
+	var instruments []middleware.Interceptor
+	if tracingEnabled(cfg.API) {
+		instruments = append(instruments, middleware.Tracing(cfg.StorageType))
+	}
+	if metricsEnabled(cfg.API) {
+		instruments = append(instruments, storageMetricsInterceptor(cfg.StorageType))
+	}
+	if len(instruments) > 0 {
+		s.Gs = storage.NewInterceptedStore(s.Gs, middleware.Chain(instruments...))
+	}
+	if err := storage.ApplyMiddleware(s, cfg.StorageMiddleware); err != nil {
+		return status.Errorf(codes.Internal, "failed to create storage middleware: %s", err)
+	}
+	// The cache is layered over the middleware, which thus only sees the calls the cache misses.
+	if cfg.Cache != nil {
+		c, err := storage.NewCachingStore(s.Gs, cfg.Cache)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to create cache: %s", err)
+		}
+		s.Gs = c
+		log.Println("caching notes and occurrences")
+	}
 	db = s
 	proj = s
-	if err := run(cfg.API, &db, &proj); err != nil {
-		return status.Errorf(codes.Internal, "internal error: %s", err)
-	} else {
-		return nil
+	if metricsEnabled(cfg.API) {
+		if err := registerMetrics(cfg.StorageType, s); err != nil {
+			return status.Errorf(codes.Internal, "failed to register metrics: %s", err)
+		}
+	}
+	runErr := run(cfg.API, logger, &db, &proj)
+	// Close the storage even if the server failed, so that it can persist its state.
+	log.Println("closing storage")
+	if err := s.Close(); err != nil && runErr == nil {
+		return status.Errorf(codes.Internal, "failed to close storage: %s", err)
+	} else if err != nil {
+		log.Printf("failed to close storage: %s", err)
+	}
+	if runErr != nil {
+		return status.Errorf(codes.Internal, "internal error: %s", runErr)
 	}
+	return nil
 }
 
-// run initializes grpc and grpc gateway api services on the same address
-func run(config *config.ServerConfig, db *grafeas.Storage, proj *project.Storage) error {
-	network, address := "tcp", config.Address
-	if strings.HasPrefix(config.Address, "unix://") {
-		network = "unix"
-		address = strings.TrimPrefix(config.Address, "unix://")
-		// Remove existing socket if found
-		os.Remove(address)
+// defaultShutdownTimeout is how long in-flight requests are drained on shutdown, if the
+// configuration does not say.
+const defaultShutdownTimeout = 30 * time.Second
+
+// run initializes grpc and grpc gateway api services, on the same address unless config has
+// separate listeners for them, and serves them until the process is signaled to stop.
+func run(config *config.ServerConfig, logger *logging.Logger, db *grafeas.Storage, proj *project.Storage) error {
+	var backup backuper
+	if config.BackupEndpoint {
+		// Backups hold all data and are not authorized by Auth, so they are kept off the API port.
+		if config.Admin == nil {
+			return errors.New("backup_endpoint requires the admin listener")
+		}
+		b, ok := storageBackuper(*db)
+		if !ok {
+			return errors.New("backup_endpoint is enabled, but the storage does not support backups")
+		}
+		backup = b
+	}
+	shutdownTimeout := defaultShutdownTimeout
+	if config.ShutdownTimeout != "" {
+		d, err := time.ParseDuration(config.ShutdownTimeout)
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid shutdown_timeout %q: %s", config.ShutdownTimeout, err))
+		}
+		shutdownTimeout = d
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	auth, gwMuxOpts, err := newAuth(ctx, config, *db, logger)
+	if err != nil {
+		return err
+	}
+	grpcOpts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(logging.RequestIDInterceptor)}
+	var gwOpts []grpc.DialOption
+	if tracingEnabled(config) {
+		grpcOpts = append(grpcOpts, grpc.ChainUnaryInterceptor(grpcTracingInterceptor))
+		gwOpts = append(gwOpts, grpc.WithChainUnaryInterceptor(grpcClientTracingInterceptor))
+		auth = &tracedAuth{auth}
+	}
+	if metricsEnabled(config) {
+		grpcOpts = append(grpcOpts, grpc.ChainUnaryInterceptor(grpcMetricsInterceptor))
+	}
+	logger.SetEndUserID(auth.EndUserID)
+	if config.RateLimits != nil {
+		limits, err := newRateLimits(config.RateLimits)
+		if err != nil {
+			return err
+		}
+		grpcOpts = append(grpcOpts, grpc.ChainUnaryInterceptor(ratelimit.Interceptor(limits, auth.EndUserID)))
 	}
-	l, err := net.Listen(network, address)
+	settings, err := newAPISettings(config, *db)
 	if err != nil {
-		return errors.New(fmt.Sprintf("could not listen to address %s", config.Address))
+		return err
 	}
-	log.Printf("starting grpc server on %s", address)
+	grpcOpts = append(grpcOpts, grpc.MaxRecvMsgSize(grpcMaxRecvMsgSize(maxRequestBytes(config.APILimits))))
+	var auditSink audit.Sink
+	if config.Audit != nil {
+		if auditSink, err = newAuditSink(config.Audit, *db); err != nil {
+			return err
+		}
+		if c, ok := auditSink.(io.Closer); ok {
+			defer c.Close()
+		}
+		grpcOpts = append(grpcOpts, grpc.ChainUnaryInterceptor(audit.Interceptor(auditSink, auth.EndUserID)))
+		log.Printf("auditing calls to the %s sink", config.Audit.Sink)
+	}
+	health := newHealthChecker(*db)
 
 	var (
-		apiHandler  http.Handler
-		apiListener net.Listener
-		srv         *http.Server
-		ctx         = context.Background()
-		httpMux     = http.NewServeMux()
-		tcpMux      = cmux.New(l)
+		grpcServer *grpc.Server
+		// grpcOverHTTP is whether gRPC is served by one of servers, which GracefulStop does not
+		// support.
+		grpcOverHTTP bool
+		// servers are drained on shutdown in order, before grpcServer.
+		servers   []*http.Server
+		listeners []net.Listener
+		serveErr  = make(chan error, 4)
+		apiMux    = http.NewServeMux()
+		adminMux  = apiMux
 	)
-
-	tlsConfig, err := tlsClientConfig(config.CAFile)
-	if err != nil {
-		return errors.New(fmt.Sprintf("failed to create tls config %s", err))
+	serve := func(srv *http.Server, l net.Listener) {
+		servers = append(servers, srv)
+		go func() { serveErr <- srv.Serve(l) }()
 	}
 
-	if tlsConfig != nil {
-		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	// Setup the CORS middleware. If `config.CORSAllowedOrigins` is empty, no CORS
+	// Origins will be allowed through.
+	cors := cors.New(cors.Options{
+		AllowedOrigins: config.CORSAllowedOrigins,
+	})
+
+	if config.GRPC != nil || config.REST != nil {
+		if config.GRPC == nil || config.REST == nil {
+			return errors.New("the grpc and rest listeners must be configured together")
+		}
+		grpcTLS, grpcCreds, err := listenerTLS(ctx, config.GRPC, logger, "h2")
 		if err != nil {
-			return errors.New(fmt.Sprintf("failed to load certificate files %s", err))
+			return err
 		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
-		tlsConfig.NextProtos = []string{"h2"}
-
-		apiListener = tls.NewListener(tcpMux.Match(cmux.Any()), tlsConfig)
-		go func() { handleShutdown(tcpMux.Serve()) }()
-
-		grpcServer := newGrpcServer(tlsConfig, db, proj)
-		gwmux, err := newGrpcGatewayServer(ctx, apiListener.Addr().String(), tlsConfig)
+		restTLS, _, err := listenerTLS(ctx, config.REST, logger, "h2", "http/1.1")
 		if err != nil {
 			return err
 		}
+		grpcL, err := listen(config.GRPC.Address)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, grpcL)
+		restL, err := listen(config.REST.Address)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, restL)
 
-		httpMux.Handle("/", gwmux)
-		apiHandler = grpcHandlerFunc(grpcServer, httpMux)
+		grpcServer = newGrpcServer(grpcTLS, db, proj, auth, logger, settings, grpcOpts...)
+		registerServerServices(grpcServer, config, health)
+		if auditSink != nil {
+			registerAuditService(grpcServer, auditSink, auth)
+		}
+		go func() { serveErr <- grpcServer.Serve(grpcL) }()
+		log.Printf("serving gRPC on %s, %s", config.GRPC.Address, tlsDescription(grpcTLS))
 
-		log.Println("grpc server is configured with client certificate authentication")
+		gwmux, err := newGrpcGatewayServer(ctx, dialTarget(grpcL), grpcCreds, gwMuxOpts, gwOpts...)
+		if err != nil {
+			return err
+		}
+		apiMux.Handle("/", tracingHandler(config, gatewayHandler(config, limitRequestBody(config, gwmux))))
+		if restTLS != nil {
+			restL = tls.NewListener(restL, restTLS)
+		}
+		serve(&http.Server{Handler: cors.Handler(apiMux), TLSConfig: restTLS}, restL)
+		log.Printf("serving REST on %s, %s", config.REST.Address, tlsDescription(restTLS))
 	} else {
-		grpcL := tcpMux.Match(cmux.HTTP2())
-		apiListener = tcpMux.Match(cmux.HTTP1())
-		go func() { handleShutdown(tcpMux.Serve()) }()
-
-		grpcServer := newGrpcServer(nil, db, proj)
-		go func() { handleShutdown(grpcServer.Serve(grpcL)) }()
+		tlsConfig, gwCreds, err := listenerTLS(ctx, mainListener(config), logger, "h2")
+		if err != nil {
+			return err
+		}
+		l, err := listen(config.Address)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, l)
+		log.Printf("starting grpc server on %s", strings.TrimPrefix(config.Address, "unix://"))
+
+		var (
+			apiHandler  http.Handler
+			apiListener net.Listener
+			tcpMux      = cmux.New(l)
+		)
+		if tlsConfig != nil {
+			apiListener = tls.NewListener(tcpMux.Match(cmux.Any()), tlsConfig)
+			go func() { handleShutdown(tcpMux.Serve()) }()
+
+			grpcServer = newGrpcServer(tlsConfig, db, proj, auth, logger, settings, grpcOpts...)
+			registerServerServices(grpcServer, config, health)
+			if auditSink != nil {
+				registerAuditService(grpcServer, auditSink, auth)
+			}
+			gwmux, err := newGrpcGatewayServer(ctx, dialTarget(l), gwCreds, gwMuxOpts, gwOpts...)
+			if err != nil {
+				return err
+			}
+
+			apiMux.Handle("/", tracingHandler(config, gatewayHandler(config, limitRequestBody(config, gwmux))))
+			apiHandler = grpcHandlerFunc(grpcServer, apiMux)
+			grpcOverHTTP = true
+		} else {
+			grpcL := tcpMux.Match(cmux.HTTP2())
+			apiListener = tcpMux.Match(cmux.HTTP1())
+			go func() { handleShutdown(tcpMux.Serve()) }()
+
+			grpcServer = newGrpcServer(nil, db, proj, auth, logger, settings, grpcOpts...)
+			registerServerServices(grpcServer, config, health)
+			if auditSink != nil {
+				registerAuditService(grpcServer, auditSink, auth)
+			}
+			go func() { handleShutdown(grpcServer.Serve(grpcL)) }()
+
+			gwmux, err := newGrpcGatewayServer(ctx, dialTarget(l), nil, gwMuxOpts, gwOpts...)
+			if err != nil {
+				return err
+			}
+
+			apiMux.Handle("/", tracingHandler(config, gatewayHandler(config, limitRequestBody(config, gwmux))))
+			apiHandler = apiMux
+		}
+		serve(&http.Server{Handler: cors.Handler(apiHandler), TLSConfig: tlsConfig}, apiListener)
+		log.Printf("grpc server is configured %s", tlsDescription(tlsConfig))
+	}
 
-		gwmux, err := newGrpcGatewayServer(ctx, apiListener.Addr().String(), nil)
+	var adminListener net.Listener
+	if config.Admin != nil {
+		if config.MetricsAddress != "" {
+			return errors.New("metrics_address cannot be used together with the admin listener")
+		}
+		adminTLS, _, err := listenerTLS(ctx, config.Admin, logger, "h2", "http/1.1")
 		if err != nil {
 			return err
 		}
+		if adminListener, err = listen(config.Admin.Address); err != nil {
+			return err
+		}
+		listeners = append(listeners, adminListener)
+		if adminTLS != nil {
+			adminListener = tls.NewListener(adminListener, adminTLS)
+		}
+		adminMux = http.NewServeMux()
+		log.Printf("serving admin endpoints on %s, %s", config.Admin.Address, tlsDescription(adminTLS))
+	}
 
-		httpMux.Handle("/", gwmux)
-		apiHandler = httpMux
+	adminMux.HandleFunc(HealthzPath, healthzHandler)
+	adminMux.HandleFunc(ReadyzPath, health.readyzHandler)
+	healthCtx, stopHealth := context.WithCancel(ctx)
+	defer stopHealth()
+	go health.run(healthCtx)
 
-		log.Println("grpc server is configured without client certificate authentication")
+	if config.MetricsAddress != "" {
+		if err := serveMetrics(config.MetricsAddress); err != nil {
+			return err
+		}
+	} else if config.Metrics {
+		adminMux.Handle(MetricsPath, promhttp.Handler())
+		log.Printf("serving metrics at %s", MetricsPath)
 	}
 
-	// Setup the CORS middleware. If `config.CORSAllowedOrigins` is empty, no CORS
-	// Origins will be allowed through.
-	cors := cors.New(cors.Options{
-		AllowedOrigins: config.CORSAllowedOrigins,
-	})
+	if backup != nil {
+		adminMux.Handle(BackupPath, backupHandler(backup))
+		log.Printf("serving storage backups at %s", BackupPath)
+	}
 
-	srv = &http.Server{
-		Handler:   cors.Handler(apiHandler),
-		TLSConfig: tlsConfig,
+	// Serve the admin endpoints once they are registered. They are drained last, so that the
+	// readiness probe keeps failing while the API drains.
+	if adminListener != nil {
+		serve(&http.Server{Handler: adminMux}, adminListener)
 	}
 
-	// blocking call
-	if err := handleShutdown(srv.Serve(apiListener)); err != nil {
-		return errors.New(fmt.Sprintf("fatal error on shutdown %s", err))
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	select {
+	case err := <-serveErr:
+		if err := handleShutdown(err); err != nil {
+			return errors.New(fmt.Sprintf("fatal error on shutdown %s", err))
+		}
+		log.Println("Grpc API stopped")
+		return nil
+	case s := <-sig:
+		log.Printf("received %s, draining requests for up to %s", s, shutdownTimeout)
+	}
+
+	stopHealth()
+	health.shutdown()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	// Drain HTTP first, as REST requests are forwarded to the gRPC server. With TLS on a single
+	// port, this also drains gRPC, which is then served over HTTP/2.
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to drain HTTP requests: %s", err)
+			srv.Close()
+		}
+	}
+	stopped := make(chan struct{})
+	go func() {
+		if !grpcOverHTTP {
+			grpcServer.GracefulStop()
+		} else {
+			// GracefulStop does not support connections served by an HTTP server.
+			grpcServer.Stop()
+		}
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		log.Println("failed to drain gRPC requests in time, closing connections")
+		grpcServer.Stop()
+		<-stopped
+	}
+	for _, l := range listeners {
+		l.Close()
 	}
 	log.Println("Grpc API stopped")
 	return nil
 }
 
+// listen listens on address, which is host:port or unix:// and the path of a socket.
+func listen(address string) (net.Listener, error) {
+	network, path := "tcp", address
+	if strings.HasPrefix(address, "unix://") {
+		network = "unix"
+		path = strings.TrimPrefix(address, "unix://")
+		// Remove existing socket if found
+		os.Remove(path)
+	}
+	l, err := net.Listen(network, path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("could not listen to address %s", address))
+	}
+	return l, nil
+}
+
+// dialTarget returns the gRPC target to connect to l with.
+func dialTarget(l net.Listener) string {
+	if l.Addr().Network() == "unix" {
+		return "unix://" + l.Addr().String()
+	}
+	return l.Addr().String()
+}
+
+// mainListener returns the configuration of the listener at the address of config.
+func mainListener(c *config.ServerConfig) *config.ListenerConfig {
+	return &config.ListenerConfig{
+		Address:       c.Address,
+		CertFile:      c.CertFile,
+		KeyFile:       c.KeyFile,
+		CAFile:        c.CAFile,
+		ClientAuth:    c.ClientAuth,
+		MinTLSVersion: c.MinTLSVersion,
+		CipherSuites:  c.CipherSuites,
+	}
+}
+
+// tlsDescription describes how a listener with the TLS config c is secured, for the log.
+func tlsDescription(c *tls.Config) string {
+	if c == nil {
+		return "without TLS"
+	}
+	return fmt.Sprintf("with TLS, client authentication %s", c.ClientAuth)
+}
+
 // handleShutdown handles the server shut down error.
 func handleShutdown(err error) error {
 	if err != nil {
@@ -169,22 +482,30 @@ func handleShutdown(err error) error {
 	return nil
 }
 
-func newGrpcServer(tlsConfig *tls.Config, db *grafeas.Storage, proj *project.Storage) *grpc.Server {
-	grpcOpts := []grpc.ServerOption{}
+func newGrpcServer(tlsConfig *tls.Config, db *grafeas.Storage, proj *project.Storage, auth grafeas.Auth, logger grafeas.Logger, settings apiSettings, opts ...grpc.ServerOption) *grpc.Server {
+	grpcOpts := append([]grpc.ServerOption{}, opts...)
 
 	if tlsConfig != nil {
 		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	}
 
 	grpcServer := grpc.NewServer(grpcOpts...)
+	validationMode := settings.validationMode
 	g := grafeas.API{
-		Storage:           *db,
-		Auth:              &grafeas.NoOpAuth{},
-		Filter:            &grafeas.NoOpFilter{},
-		Logger:            &grafeas.NoOpLogger{},
-		EnforceValidation: true,
+		Storage:               *db,
+		Auth:                  auth,
+		Filter:                &grafeas.NoOpFilter{},
+		Logger:                logger,
+		EnforceValidation:     validationMode == grafeas.ValidationEnforce,
+		ValidationModes:       settings.validationModes,
+		DefaultValidationMode: &validationMode,
+		Limits:                settings.limits,
+		Policies:              policyStorage(*db),
+		Quotas:                settings.quotas,
+		SizeLimits:            settings.sizeLimits,
 	}
 	pb.RegisterGrafeasV1Beta1Server(grpcServer, &g)
+	iampb.RegisterIAMPolicyServer(grpcServer, &g)
 
 	gp := project.API{Storage: *proj}
 	prpb.RegisterProjectsServer(grpcServer, &gp)
@@ -192,23 +513,20 @@ func newGrpcServer(tlsConfig *tls.Config, db *grafeas.Storage, proj *project.Sto
 	return grpcServer
 }
 
-func newGrpcGatewayServer(ctx context.Context, listenerAddr string, tlsConfig *tls.Config) (http.Handler, error) {
-	var (
-		gwTLSConfig *tls.Config
-		gwOpts      []grpc.DialOption
-	)
-
-	if tlsConfig != nil {
-		gwTLSConfig = tlsConfig.Clone()
-		gwTLSConfig.InsecureSkipVerify = true
-		gwOpts = append(gwOpts, grpc.WithTransportCredentials(credentials.NewTLS(gwTLSConfig)))
+func newGrpcGatewayServer(ctx context.Context, listenerAddr string, creds credentials.TransportCredentials, muxOpts []runtime.ServeMuxOption, opts ...grpc.DialOption) (http.Handler, error) {
+	gwOpts := append([]grpc.DialOption{}, opts...)
+	if creds != nil {
+		gwOpts = append(gwOpts, grpc.WithTransportCredentials(creds))
 	} else {
 		gwOpts = append(gwOpts, grpc.WithInsecure())
 	}
 
 	// changes json serializer to include empty fields with default values
 	jsonOpt := runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{})
-	gwmux := runtime.NewServeMux(jsonOpt)
+	gwmux := runtime.NewServeMux(append([]runtime.ServeMuxOption{jsonOpt,
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(requestIDOutgoingHeaderMatcher),
+	}, muxOpts...)...)
 
 	conn, err := grpc.DialContext(ctx, listenerAddr, gwOpts...)
 	if err != nil {
@@ -224,9 +542,48 @@ func newGrpcGatewayServer(ctx context.Context, listenerAddr string, tlsConfig *t
 		return nil, errors.New("could not initialize notification grpc gateway")
 	}
 
+	if err := apb.RegisterAuditHandler(ctx, gwmux, conn); err != nil {
+		return nil, errors.New("could not initialize audit grpc gateway")
+	}
+
+	if err := registerIAMPolicyHandlers(gwmux, conn); err != nil {
+		return nil, errors.New(fmt.Sprintf("could not initialize IAM policy grpc gateway: %s", err))
+	}
+
 	return http.Handler(gwmux), nil
 }
 
+// registerServerServices registers the health service and, if config enables it, the reflection
+// service with s.
+func registerServerServices(s *grpc.Server, config *config.ServerConfig, health *healthChecker) {
+	health.register(s)
+	if config.Reflection {
+		reflection.Register(s)
+		log.Println("grpc server reflection is enabled")
+	}
+}
+
+// incomingHeaderMatcher forwards the request ID header of REST requests, in addition to the
+// headers the gateway forwards by default, except for the metadata the gateway adds for Auth.
+func incomingHeaderMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, logging.RequestIDHeader) {
+		return logging.RequestIDHeader, true
+	}
+	if auth.IsGatewayHeader(key) {
+		return "", false
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// requestIDOutgoingHeaderMatcher returns the request ID of RPCs in the request ID header of REST
+// responses, and other metadata as the gateway does by default.
+func requestIDOutgoingHeaderMatcher(key string) (string, bool) {
+	if key == logging.RequestIDHeader {
+		return key, true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
 // grpcHandlerFunc returns an http.Handler that delegates to grpcServer on incoming gRPC
 // connections or otherHandler otherwise. Copied from cockroachdb.
 func grpcHandlerFunc(grpcServer *grpc.Server, otherHandler http.Handler) http.Handler {
@@ -238,30 +595,3 @@ func grpcHandlerFunc(grpcServer *grpc.Server, otherHandler http.Handler) http.Ha
 		}
 	})
 }
-
-// tlsClientConfig initializes a *tls.Config using the given CA. The resulting
-// *tls.Config is meant to be used to configure an HTTP server to do client
-// certificate authentication.
-//
-// If no CA is given, a nil *tls.Config is returned; no client certificate will
-// be required and verified. In other words, authentication will be disabled.
-func tlsClientConfig(caPath string) (*tls.Config, error) {
-	if caPath == "" {
-		return nil, nil
-	}
-
-	caCert, err := ioutil.ReadFile(caPath)
-	if err != nil {
-		return nil, err
-	}
-
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
-
-	tlsConfig := &tls.Config{
-		ClientCAs:  caCertPool,
-		ClientAuth: tls.RequireAndVerifyClientCert,
-	}
-
-	return tlsConfig, nil
-}
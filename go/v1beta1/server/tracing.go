@@ -0,0 +1,256 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/iam"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultServiceName is the service.name of exported spans, if the configuration does not say.
+	defaultServiceName = "grafeas"
+	// tracingShutdownTimeout bounds how long exporting pending spans delays shutdown.
+	tracingShutdownTimeout = 5 * time.Second
+)
+
+// tracer records the spans of the server, with the tracer provider registered with
+// otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/grafeas/grafeas/go/v1beta1/server")
+
+// tracingEnabled returns whether config enables tracing.
+func tracingEnabled(config *config.ServerConfig) bool {
+	return config.Tracing != nil
+}
+
+// setupTracing registers a tracer provider exporting spans to the OTLP collector of config, and
+// the W3C trace context and baggage propagators, with otel. It returns a function that flushes
+// pending spans and stops the exporter.
+func setupTracing(ctx context.Context, config *config.TracingConfig) (func(context.Context) error, error) {
+	var opts []otlptracegrpc.Option
+	if config.Endpoint != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(config.Endpoint))
+	}
+	if config.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("failed to create OTLP exporter: %s", err))
+	}
+	tp, err := newTracerProvider(config, exporter)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
+
+// newTracerProvider returns a tracer provider that samples traces as config says and exports
+// spans in batches to exporter.
+func newTracerProvider(config *config.TracingConfig, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+	ratio := config.SampleRatio
+	if ratio < 0 || ratio > 1 {
+		return nil, errors.New(fmt.Sprintf("sample_ratio %v is not between 0 and 1", ratio))
+	} else if ratio == 0 {
+		ratio = 1
+	}
+	name := config.ServiceName
+	if name == "" {
+		name = defaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(name)))
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	), nil
+}
+
+// tracingHandler returns the REST gateway handler h, tracing its requests if config enables
+// tracing. Requests continue the trace of their traceparent header, if any.
+func tracingHandler(config *config.ServerConfig, h http.Handler) http.Handler {
+	if !tracingEnabled(config) {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", "", r)...),
+		)
+		defer span.End()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(sw.status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(sw.status, trace.SpanKindServer))
+	})
+}
+
+// statusWriter records the status code written to a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// grpcTracingInterceptor traces unary RPCs, continuing the trace propagated in the request
+// metadata, if any.
+func grpcTracingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	ctx, span := tracer.Start(ctx, strings.TrimPrefix(info.FullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(rpcAttributes(info.FullMethod)...),
+	)
+	defer span.End()
+	resp, err := handler(ctx, req)
+	setRPCStatus(span, err)
+	return resp, err
+}
+
+// grpcClientTracingInterceptor traces the unary RPCs the REST gateway makes, propagating their
+// trace in the request metadata.
+func grpcClientTracingInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, span := tracer.Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(rpcAttributes(method)...),
+	)
+	defer span.End()
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	err := invoker(metadata.NewOutgoingContext(ctx, md), method, req, reply, cc, opts...)
+	setRPCStatus(span, err)
+	return err
+}
+
+// rpcAttributes returns the span attributes of the gRPC method fullMethod.
+func rpcAttributes(fullMethod string) []attribute.KeyValue {
+	service, method := splitMethodName(fullMethod)
+	return []attribute.KeyValue{
+		semconv.RPCSystemKey.String("grpc"),
+		semconv.RPCServiceKey.String(service),
+		semconv.RPCMethodKey.String(method),
+	}
+}
+
+// setRPCStatus records the status of an RPC that returned err on span.
+func setRPCStatus(span trace.Span, err error) {
+	s := status.Convert(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(s.Code())))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, s.Message())
+	}
+}
+
+// metadataCarrier adapts gRPC metadata to propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// tracedAuth records a span for every call of an Auth.
+type tracedAuth struct {
+	grafeas.Auth
+}
+
+// CheckAccessAndProject checks access with the traced Auth.
+func (a *tracedAuth) CheckAccessAndProject(ctx context.Context, projectID string, entityID string, p iam.Permission) error {
+	ctx, span := tracer.Start(ctx, "auth.CheckAccessAndProject", trace.WithAttributes(
+		attribute.String("grafeas.project", projectID),
+		attribute.String("grafeas.entity", entityID),
+		attribute.String("grafeas.permission", string(p)),
+	))
+	defer span.End()
+	err := a.Auth.CheckAccessAndProject(ctx, projectID, entityID, p)
+	setAuthStatus(span, err)
+	return err
+}
+
+// EndUserID returns the ID of the user making an API call with the traced Auth.
+func (a *tracedAuth) EndUserID(ctx context.Context) (string, error) {
+	ctx, span := tracer.Start(ctx, "auth.EndUserID")
+	defer span.End()
+	id, err := a.Auth.EndUserID(ctx)
+	setAuthStatus(span, err)
+	return id, err
+}
+
+// PurgePolicy purges policies with the traced Auth.
+func (a *tracedAuth) PurgePolicy(ctx context.Context, projectID string, entityID string, r iam.Resource) error {
+	ctx, span := tracer.Start(ctx, "auth.PurgePolicy", trace.WithAttributes(
+		attribute.String("grafeas.project", projectID),
+		attribute.String("grafeas.entity", entityID),
+		attribute.String("grafeas.resource", string(r)),
+	))
+	defer span.End()
+	err := a.Auth.PurgePolicy(ctx, projectID, entityID, r)
+	setAuthStatus(span, err)
+	return err
+}
+
+// setAuthStatus records the error of an Auth call, if any, on span.
+func setAuthStatus(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, status.Convert(err).Message())
+	}
+}
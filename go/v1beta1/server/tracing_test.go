@@ -0,0 +1,119 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/middleware"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+func TestTracing(t *testing.T) {
+	ctx := context.Background()
+	exporter := tracetest.NewInMemoryExporter()
+	tp, err := newTracerProvider(&config.TracingConfig{}, exporter)
+	if err != nil {
+		t.Fatalf("newTracerProvider got %v want success", err)
+	}
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		_ = tp.Shutdown(ctx)
+	}()
+
+	m := storage.NewMemStore()
+	if _, err := m.CreateNote(ctx, "p", "n", "userID", &pb.Note{}); err != nil {
+		t.Fatalf("CreateNote got %v want success", err)
+	}
+	var (
+		db   grafeas.Storage = storage.NewInterceptedStore(m, middleware.Tracing("memstore"))
+		proj project.Storage = m
+	)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	grpcServer := newGrpcServer(nil, &db, &proj, &tracedAuth{&grafeas.NoOpAuth{}}, &grafeas.NoOpLogger{}, apiSettings{}, grpc.ChainUnaryInterceptor(grpcTracingInterceptor))
+	go grpcServer.Serve(l)
+	defer grpcServer.Stop()
+	gwmux, err := newGrpcGatewayServer(ctx, l.Addr().String(), nil, nil, grpc.WithChainUnaryInterceptor(grpcClientTracingInterceptor))
+	if err != nil {
+		t.Fatalf("newGrpcGatewayServer got %v want success", err)
+	}
+	h := tracingHandler(&config.ServerConfig{Tracing: &config.TracingConfig{}}, gwmux)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/v1beta1/projects/p/notes/n", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET got status %d want 200: %s", rec.Code, rec.Body)
+	}
+
+	if err := tp.ForceFlush(ctx); err != nil {
+		t.Fatalf("ForceFlush got %v want success", err)
+	}
+	spans := map[string]tracetest.SpanStub{}
+	for _, s := range exporter.GetSpans() {
+		if got := s.SpanContext.TraceID().String(); got != traceID {
+			t.Errorf("span %s got trace %s want %s", s.Name, got, traceID)
+		}
+		spans[s.SpanKind.String()+" "+s.Name] = s
+	}
+	// Each span is the child of the previous one.
+	chain := []string{
+		"server HTTP GET",
+		"client grafeas.v1beta1.GrafeasV1Beta1/GetNote",
+		"server grafeas.v1beta1.GrafeasV1Beta1/GetNote",
+		"internal storage.GetNote",
+	}
+	for i, name := range chain {
+		s, ok := spans[name]
+		if !ok {
+			t.Fatalf("spans got %v want a %q span", exporter.GetSpans(), name)
+		}
+		if i > 0 && s.Parent.SpanID() != spans[chain[i-1]].SpanContext.SpanID() {
+			t.Errorf("span %q got parent %s want %q", name, s.Parent.SpanID(), chain[i-1])
+		}
+	}
+	if s, ok := spans["internal auth.CheckAccessAndProject"]; !ok || s.Parent.SpanID() != spans[chain[2]].SpanContext.SpanID() {
+		t.Errorf("spans got %v want an auth.CheckAccessAndProject span in the RPC", exporter.GetSpans())
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	for _, ratio := range []float64{-0.5, 1.5} {
+		if _, err := newTracerProvider(&config.TracingConfig{SampleRatio: ratio}, exporter); err == nil {
+			t.Errorf("newTracerProvider with sample_ratio %v got success want error", ratio)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/name"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	"golang.org/x/net/context"
+	fieldmaskpb "google.golang.org/genproto/protobuf/field_mask"
+)
+
+// defaultCacheSize is the number of notes and occurrences cached if the configuration does not
+// say.
+const defaultCacheSize = 10000
+
+// Unwrapper is implemented by storage that wraps another storage, such as CachingStore.
+type Unwrapper interface {
+	// Unwrap returns the wrapped storage.
+	Unwrap() grafeas.Storage
+}
+
+// As finds the first storage in the chain of storage wrapped by s, starting with s itself, that
+// implements the interface target points to, and if found, sets target to it and returns true.
+// It looks through Storage as returned by CreateStorageOfType and through Unwrappers.
+func As(s grafeas.Storage, target interface{}) bool {
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Interface {
+		panic("storage: target must be a non-nil pointer to an interface")
+	}
+	targetType := val.Type().Elem()
+	for s != nil {
+		if st, ok := s.(*Storage); ok {
+			s = st.Gs
+			continue
+		}
+		if reflect.TypeOf(s).AssignableTo(targetType) {
+			val.Elem().Set(reflect.ValueOf(s))
+			return true
+		}
+		u, ok := s.(Unwrapper)
+		if !ok {
+			return false
+		}
+		s = u.Unwrap()
+	}
+	return false
+}
+
+// CacheStats holds the hit and miss counts of a CachingStore.
+type CacheStats struct {
+	NoteHits         uint64
+	NoteMisses       uint64
+	OccurrenceHits   uint64
+	OccurrenceMisses uint64
+	// Evictions counts entries removed to make room for new ones or because they expired.
+	Evictions uint64
+}
+
+// CachingStore is a read-through cache of notes and occurrences in front of another storage.
+// Notes and occurrences are evicted when they are least recently used or expire, and are
+// invalidated when they are updated or deleted through the same CachingStore. Writes made to the
+// wrapped storage by other means, e.g. by other Grafeas servers sharing a database, are only
+// seen once the cached entries expire.
+type CachingStore struct {
+	grafeas.Storage
+
+	mu          sync.Mutex
+	notes       *lruCache // note name to *gpb.Note
+	occurrences *lruCache // occurrence name to *gpb.Occurrence
+	occNotes    *lruCache // occurrence name to note name, for GetOccurrenceNote
+	// generation is incremented by every invalidation, so that a read that started before an
+	// invalidation does not cache what it read.
+	generation uint64
+	stats      CacheStats
+}
+
+// NewCachingStore returns a CachingStore in front of s.
+func NewCachingStore(s grafeas.Storage, config *config.CacheConfig) (*CachingStore, error) {
+	var ttl time.Duration
+	if config.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(config.TTL); err != nil {
+			return nil, fmt.Errorf("invalid cache ttl %q: %v", config.TTL, err)
+		}
+	}
+	notes, occurrences := config.Notes, config.Occurrences
+	if notes == 0 {
+		notes = defaultCacheSize
+	}
+	if occurrences == 0 {
+		occurrences = defaultCacheSize
+	}
+	if notes < 0 || occurrences < 0 {
+		return nil, fmt.Errorf("invalid cache sizes: %d notes, %d occurrences", notes, occurrences)
+	}
+	return &CachingStore{
+		Storage:     s,
+		notes:       newLRUCache(notes, ttl),
+		occurrences: newLRUCache(occurrences, ttl),
+		occNotes:    newLRUCache(occurrences, ttl),
+	}, nil
+}
+
+// Unwrap returns the storage the cache is in front of.
+func (c *CachingStore) Unwrap() grafeas.Storage {
+	return c.Storage
+}
+
+// Stats returns the hit and miss counts of the cache.
+func (c *CachingStore) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// GetOccurrence gets the specified occurrence from the cache or the wrapped storage.
+func (c *CachingStore) GetOccurrence(ctx context.Context, pID, oID string) (*gpb.Occurrence, error) {
+	key := name.FormatOccurrence(pID, oID)
+	c.mu.Lock()
+	if v, ok := c.get(c.occurrences, key); ok {
+		c.stats.OccurrenceHits++
+		c.mu.Unlock()
+		return proto.Clone(v.(*gpb.Occurrence)).(*gpb.Occurrence), nil
+	}
+	c.stats.OccurrenceMisses++
+	gen := c.generation
+	c.mu.Unlock()
+
+	o, err := c.Storage.GetOccurrence(ctx, pID, oID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if gen == c.generation {
+		c.put(c.occurrences, key, proto.Clone(o))
+		c.put(c.occNotes, key, o.NoteName)
+	}
+	c.mu.Unlock()
+	return o, nil
+}
+
+// UpdateOccurrence updates the specified occurrence in the wrapped storage and invalidates it.
+func (c *CachingStore) UpdateOccurrence(ctx context.Context, pID, oID string, o *gpb.Occurrence, mask *fieldmaskpb.FieldMask) (*gpb.Occurrence, error) {
+	defer c.invalidateOccurrence(pID, oID)
+	return c.Storage.UpdateOccurrence(ctx, pID, oID, o, mask)
+}
+
+// DeleteOccurrence deletes the specified occurrence from the wrapped storage and invalidates it.
+func (c *CachingStore) DeleteOccurrence(ctx context.Context, pID, oID string) error {
+	defer c.invalidateOccurrence(pID, oID)
+	return c.Storage.DeleteOccurrence(ctx, pID, oID)
+}
+
+// GetNote gets the specified note from the cache or the wrapped storage.
+func (c *CachingStore) GetNote(ctx context.Context, pID, nID string) (*gpb.Note, error) {
+	key := name.FormatNote(pID, nID)
+	c.mu.Lock()
+	if v, ok := c.get(c.notes, key); ok {
+		c.stats.NoteHits++
+		c.mu.Unlock()
+		return proto.Clone(v.(*gpb.Note)).(*gpb.Note), nil
+	}
+	c.stats.NoteMisses++
+	gen := c.generation
+	c.mu.Unlock()
+
+	n, err := c.Storage.GetNote(ctx, pID, nID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if gen == c.generation {
+		c.put(c.notes, key, proto.Clone(n))
+	}
+	c.mu.Unlock()
+	return n, nil
+}
+
+// UpdateNote updates the specified note in the wrapped storage and invalidates it.
+func (c *CachingStore) UpdateNote(ctx context.Context, pID, nID string, n *gpb.Note, mask *fieldmaskpb.FieldMask) (*gpb.Note, error) {
+	defer c.invalidateNote(pID, nID)
+	return c.Storage.UpdateNote(ctx, pID, nID, n, mask)
+}
+
+// DeleteNote deletes the specified note from the wrapped storage and invalidates it.
+func (c *CachingStore) DeleteNote(ctx context.Context, pID, nID string) error {
+	defer c.invalidateNote(pID, nID)
+	return c.Storage.DeleteNote(ctx, pID, nID)
+}
+
+// GetOccurrenceNote gets the note for the specified occurrence from the cache or the wrapped
+// storage.
+func (c *CachingStore) GetOccurrenceNote(ctx context.Context, pID, oID string) (*gpb.Note, error) {
+	key := name.FormatOccurrence(pID, oID)
+	c.mu.Lock()
+	if nName, ok := c.get(c.occNotes, key); ok {
+		if v, ok := c.get(c.notes, nName.(string)); ok {
+			c.stats.NoteHits++
+			c.mu.Unlock()
+			return proto.Clone(v.(*gpb.Note)).(*gpb.Note), nil
+		}
+	}
+	c.stats.NoteMisses++
+	gen := c.generation
+	c.mu.Unlock()
+
+	n, err := c.Storage.GetOccurrenceNote(ctx, pID, oID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if gen == c.generation {
+		c.put(c.notes, n.Name, proto.Clone(n))
+		c.put(c.occNotes, key, n.Name)
+	}
+	c.mu.Unlock()
+	return n, nil
+}
+
+func (c *CachingStore) invalidateOccurrence(pID, oID string) {
+	key := name.FormatOccurrence(pID, oID)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.occurrences.remove(key)
+	c.occNotes.remove(key)
+}
+
+func (c *CachingStore) invalidateNote(pID, nID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.notes.remove(name.FormatNote(pID, nID))
+}
+
+// get and put access l and count evictions. c.mu must be held.
+func (c *CachingStore) get(l *lruCache, key string) (interface{}, bool) {
+	v, ok, expired := l.get(key, time.Now())
+	if expired {
+		c.stats.Evictions++
+	}
+	return v, ok
+}
+
+func (c *CachingStore) put(l *lruCache, key string, value interface{}) {
+	if l.put(key, value, time.Now()) {
+		c.stats.Evictions++
+	}
+}
+
+// lruCache is a size-bounded map that evicts the least recently used entry when full, and
+// expires entries ttl after they are added if ttl is not zero. It is not safe for concurrent use.
+type lruCache struct {
+	size  int
+	ttl   time.Duration
+	order *list.List // most recently used first
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// get returns the value for key, and whether an expired entry for key was removed.
+func (l *lruCache) get(key string, now time.Time) (value interface{}, ok, expired bool) {
+	e, ok := l.items[key]
+	if !ok {
+		return nil, false, false
+	}
+	entry := e.Value.(*lruEntry)
+	if l.ttl > 0 && !now.Before(entry.expires) {
+		l.order.Remove(e)
+		delete(l.items, key)
+		return nil, false, true
+	}
+	l.order.MoveToFront(e)
+	return entry.value, true, false
+}
+
+// put adds or replaces the value for key, and returns whether another entry was evicted to make
+// room for it.
+func (l *lruCache) put(key string, value interface{}, now time.Time) bool {
+	if e, ok := l.items[key]; ok {
+		entry := e.Value.(*lruEntry)
+		entry.value, entry.expires = value, now.Add(l.ttl)
+		l.order.MoveToFront(e)
+		return false
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: now.Add(l.ttl)})
+	if l.order.Len() <= l.size {
+		return false
+	}
+	oldest := l.order.Back()
+	l.order.Remove(oldest)
+	delete(l.items, oldest.Value.(*lruEntry).key)
+	return true
+}
+
+func (l *lruCache) remove(key string) {
+	if e, ok := l.items[key]; ok {
+		l.order.Remove(e)
+		delete(l.items, key)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/name"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
+	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBetaCachingStore(t *testing.T) {
+	storage.DoTestStorage(t, func(t *testing.T) (grafeas.Storage, project.Storage, func()) {
+		s := storage.NewMemStore()
+		c, err := storage.NewCachingStore(s, &config.CacheConfig{})
+		if err != nil {
+			t.Fatalf("NewCachingStore got %v want success", err)
+		}
+		return c, s, func() {}
+	})
+}
+
+func TestCachingStore(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemStore()
+	c, err := storage.NewCachingStore(s, &config.CacheConfig{})
+	if err != nil {
+		t.Fatalf("NewCachingStore got %v want success", err)
+	}
+
+	if _, err := c.CreateNote(ctx, "p", "n", "userID", &pb.Note{ShortDescription: "before"}); err != nil {
+		t.Fatalf("CreateNote got %v want success", err)
+	}
+	o, err := c.CreateOccurrence(ctx, "p", "userID", &pb.Occurrence{
+		NoteName: name.FormatNote("p", "n"),
+		Resource: &pb.Resource{Uri: "https://example.com"},
+		Kind:     cpb.NoteKind_BUILD,
+	})
+	if err != nil {
+		t.Fatalf("CreateOccurrence got %v want success", err)
+	}
+	_, oID, err := name.ParseOccurrence(o.Name)
+	if err != nil {
+		t.Fatalf("Error parsing occurrenceID %v", err)
+	}
+
+	// The first read misses and the second hits; changing what a read returned does not change
+	// the cached note.
+	n, err := c.GetNote(ctx, "p", "n")
+	if err != nil {
+		t.Fatalf("GetNote got %v want success", err)
+	}
+	n.ShortDescription = "changed by caller"
+	if n, err := c.GetNote(ctx, "p", "n"); err != nil || n.ShortDescription != "before" {
+		t.Errorf("GetNote got %v, %v, want note with short description %q", n, err, "before")
+	}
+	if _, err := c.GetOccurrenceNote(ctx, "p", oID); err != nil {
+		t.Fatalf("GetOccurrenceNote got %v want success", err)
+	}
+	if _, err := c.GetOccurrenceNote(ctx, "p", oID); err != nil {
+		t.Fatalf("GetOccurrenceNote got %v want success", err)
+	}
+	want := storage.CacheStats{NoteHits: 2, NoteMisses: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats got %+v want %+v", got, want)
+	}
+
+	// Updates through the cache invalidate it.
+	if _, err := c.UpdateNote(ctx, "p", "n", &pb.Note{ShortDescription: "after"}, nil); err != nil {
+		t.Fatalf("UpdateNote got %v want success", err)
+	}
+	if n, err := c.GetNote(ctx, "p", "n"); err != nil || n.ShortDescription != "after" {
+		t.Errorf("GetNote got %v, %v, want note with short description %q", n, err, "after")
+	}
+	if n, err := c.GetOccurrenceNote(ctx, "p", oID); err != nil || n.ShortDescription != "after" {
+		t.Errorf("GetOccurrenceNote got %v, %v, want note with short description %q", n, err, "after")
+	}
+
+	// Deletes through the cache invalidate it.
+	if _, err := c.GetOccurrence(ctx, "p", oID); err != nil {
+		t.Fatalf("GetOccurrence got %v want success", err)
+	}
+	if err := c.DeleteOccurrence(ctx, "p", oID); err != nil {
+		t.Fatalf("DeleteOccurrence got %v want success", err)
+	}
+	if _, err := c.GetOccurrence(ctx, "p", oID); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOccurrence got %v want NotFound", err)
+	}
+	if _, err := c.GetOccurrenceNote(ctx, "p", oID); status.Code(err) != codes.NotFound {
+		t.Errorf("GetOccurrenceNote got %v want NotFound", err)
+	}
+
+	// Writes that bypass the cache are not seen until the entry is evicted.
+	if _, err := s.UpdateNote(ctx, "p", "n", &pb.Note{ShortDescription: "bypassed"}, nil); err != nil {
+		t.Fatalf("UpdateNote got %v want success", err)
+	}
+	if n, err := c.GetNote(ctx, "p", "n"); err != nil || n.ShortDescription != "after" {
+		t.Errorf("GetNote got %v, %v, want cached note with short description %q", n, err, "after")
+	}
+}
+
+func TestCachingStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemStore()
+	c, err := storage.NewCachingStore(s, &config.CacheConfig{Notes: 2, TTL: "50ms"})
+	if err != nil {
+		t.Fatalf("NewCachingStore got %v want success", err)
+	}
+	for _, nID := range []string{"a", "b", "c"} {
+		if _, err := s.CreateNote(ctx, "p", nID, "userID", &pb.Note{}); err != nil {
+			t.Fatalf("CreateNote got %v want success", err)
+		}
+	}
+
+	// Reading a third note evicts the least recently used one, "b".
+	for _, nID := range []string{"a", "b", "a", "c", "a", "b"} {
+		if _, err := c.GetNote(ctx, "p", nID); err != nil {
+			t.Fatalf("GetNote(%s) got %v want success", nID, err)
+		}
+	}
+	want := storage.CacheStats{NoteHits: 2, NoteMisses: 4, Evictions: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats got %+v want %+v", got, want)
+	}
+
+	// Entries expire after the TTL.
+	time.Sleep(60 * time.Millisecond)
+	if _, err := c.GetNote(ctx, "p", "a"); err != nil {
+		t.Fatalf("GetNote(a) got %v want success", err)
+	}
+	want = storage.CacheStats{NoteHits: 2, NoteMisses: 5, Evictions: 3}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats got %+v want %+v", got, want)
+	}
+
+	if _, err := storage.NewCachingStore(s, &config.CacheConfig{TTL: "soon"}); err == nil {
+		t.Errorf("NewCachingStore with invalid ttl got success want error")
+	}
+}
+
+func TestAs(t *testing.T) {
+	s := storage.NewMemStore()
+	c, err := storage.NewCachingStore(s, &config.CacheConfig{})
+	if err != nil {
+		t.Fatalf("NewCachingStore got %v want success", err)
+	}
+	wrapped := &storage.Storage{Ps: s, Gs: c}
+
+	var counter storage.ObjectCounter
+	if !storage.As(wrapped, &counter) || counter != storage.ObjectCounter(s) {
+		t.Errorf("As(ObjectCounter) got %v want the memstore", counter)
+	}
+	var unwrapper storage.Unwrapper
+	if !storage.As(wrapped, &unwrapper) || unwrapper != storage.Unwrapper(c) {
+		t.Errorf("As(Unwrapper) got %v want the cache", unwrapper)
+	}
+	var backuper interface {
+		Backup(w io.Writer) (int64, error)
+	}
+	if storage.As(wrapped, &backuper) {
+		t.Errorf("As(backuper) got %v want false", backuper)
+	}
+}
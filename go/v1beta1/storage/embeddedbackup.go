@@ -0,0 +1,308 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/grafeas/grafeas/go/config"
+)
+
+const (
+	// embeddedStoreFile is the name of the database file in the embedded store directory.
+	embeddedStoreFile = "grafeas.db"
+	// compactTxMaxSize is the number of bytes copied per transaction while compacting.
+	compactTxMaxSize = 64 << 20
+	// embeddedStoreLockTimeout is how long the offline maintenance functions wait for the
+	// database lock before concluding that the database is in use.
+	embeddedStoreLockTimeout = time.Second
+)
+
+// Backup writes a consistent copy of the database to w and returns the number of bytes
+// written. The store remains available for reads and writes while the backup is taken.
+func (m *EmbeddedStore) Backup(w io.Writer) (int64, error) {
+	var n int64
+	err := m.db.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// RestoreEmbeddedStore replaces the database of the embedded store in config.Path with the backup
+// read from r. The backup is verified before it replaces the existing database, and the store
+// must not be in use by a running server.
+func RestoreEmbeddedStore(config *config.EmbeddedStoreConfig, r io.Reader) error {
+	if err := os.MkdirAll(config.Path, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(config.Path, embeddedStoreFile)
+	if _, err := os.Stat(path); err == nil {
+		// Hold the lock of the current database until it has been replaced.
+		db, err := openEmbeddedStoreFile(path)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	tmp, err := ioutil.TempFile(config.Path, embeddedStoreFile+".restore-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write backup: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := CheckEmbeddedStoreBackup(tmp.Name()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// CheckEmbeddedStoreBackup verifies that the file at path is a complete embedded store database.
+func CheckEmbeddedStoreBackup(path string) error {
+	if err := checkEmbeddedStoreFileSize(path); err != nil {
+		return fmt.Errorf("invalid backup %s: %v", path, err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: embeddedStoreLockTimeout, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("invalid backup %s: %v", path, err)
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{bucketProjects, bucketNotes, bucketOccurrences} {
+			if tx.Bucket([]byte(bucket)) == nil {
+				return fmt.Errorf("invalid backup %s: missing bucket %q", path, bucket)
+			}
+		}
+		// Drain the channel so the consistency check completes before the transaction closes.
+		var checkErr error
+		for err := range tx.Check() {
+			if checkErr == nil {
+				checkErr = fmt.Errorf("invalid backup %s: %v", path, err)
+			}
+		}
+		return checkErr
+	})
+}
+
+// checkEmbeddedStoreFileSize returns an error unless the bolt database at path is at least as
+// large as its current meta page says. bolt does not check this and faults when it reads past the
+// end of a truncated file.
+func checkEmbeddedStoreFileSize(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// The meta page follows a 16-byte page header and holds, in native byte order: magic,
+	// version, page size and flags (uint32), then root page, sequence, freelist page, high water
+	// mark page, txid and checksum (uint64). The current meta is the valid one with the higher
+	// txid; the second meta page starts at the page size recorded in the first.
+	const (
+		pageHeaderSize = 16
+		checksumOffset = 56
+		boltMagic      = 0xED0CDAED
+		boltVersion    = 2
+	)
+	var (
+		found    bool
+		txid     uint64
+		wantSize int64
+		offset   int64
+		buf      = make([]byte, pageHeaderSize+checksumOffset+8)
+	)
+	for i := 0; i < 2; i++ {
+		if _, err := f.ReadAt(buf, offset); err != nil {
+			break
+		}
+		m := buf[pageHeaderSize:]
+		h := fnv.New64a()
+		h.Write(m[:checksumOffset])
+		valid := binary.LittleEndian.Uint32(m[0:]) == boltMagic &&
+			binary.LittleEndian.Uint32(m[4:]) == boltVersion &&
+			binary.LittleEndian.Uint64(m[checksumOffset:]) == h.Sum64()
+		if !valid {
+			offset = int64(os.Getpagesize())
+			continue
+		}
+		pageSize := int64(binary.LittleEndian.Uint32(m[8:]))
+		if tx := binary.LittleEndian.Uint64(m[48:]); !found || tx > txid {
+			found, txid = true, tx
+			wantSize = int64(binary.LittleEndian.Uint64(m[40:])) * pageSize
+		}
+		offset = pageSize
+	}
+	if !found {
+		return fmt.Errorf("not a bolt database")
+	}
+	if fi.Size() < wantSize {
+		return fmt.Errorf("file is truncated: %d bytes, want %d", fi.Size(), wantSize)
+	}
+	return nil
+}
+
+// CompactEmbeddedStore rewrites the database of the embedded store in config.Path to reclaim the
+// space left by deleted records, and returns its size before and after compaction. The store
+// must not be in use by a running server.
+func CompactEmbeddedStore(config *config.EmbeddedStoreConfig) (int64, int64, error) {
+	path := filepath.Join(config.Path, embeddedStoreFile)
+	src, err := openEmbeddedStoreFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer src.Close()
+
+	tmpPath := path + ".compact"
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(tmpPath)
+	if err := compactEmbeddedStore(dst, src); err != nil {
+		dst.Close()
+		return 0, 0, fmt.Errorf("failed to compact %s: %v", path, err)
+	}
+	if err := dst.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	before, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	after, err := os.Stat(tmpPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	if after.Size() >= before.Size() {
+		// Small databases can grow when rewritten; keep the original.
+		return before.Size(), before.Size(), nil
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, 0, err
+	}
+	return before.Size(), after.Size(), nil
+}
+
+// openEmbeddedStoreFile opens the database at path for maintenance, failing if a running server
+// holds it.
+func openEmbeddedStoreFile(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: embeddedStoreLockTimeout})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("database %s is in use; stop the server first", path)
+	}
+	return db, err
+}
+
+// compactEmbeddedStore copies every bucket of src into dst, committing every compactTxMaxSize
+// bytes so that large databases are not copied in a single transaction.
+func compactEmbeddedStore(dst, src *bolt.DB) error {
+	c := &compactor{dst: dst}
+	if err := src.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return c.copyBucket([][]byte{name}, b)
+		})
+	}); err != nil {
+		if c.tx != nil {
+			c.tx.Rollback()
+		}
+		return err
+	}
+	if c.tx == nil {
+		return nil
+	}
+	return c.tx.Commit()
+}
+
+type compactor struct {
+	dst  *bolt.DB
+	tx   *bolt.Tx
+	size int64
+}
+
+// copyBucket copies src, including its nested buckets, to the bucket at path in the destination.
+func (c *compactor) copyBucket(path [][]byte, src *bolt.Bucket) error {
+	if _, err := c.bucket(path, 0); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return c.copyBucket(append(path[:len(path):len(path)], k), src.Bucket(k))
+		}
+		b, err := c.bucket(path, int64(len(k)+len(v)))
+		if err != nil {
+			return err
+		}
+		return b.Put(k, v)
+	})
+}
+
+// bucket returns the bucket at path in the current destination transaction, first committing the
+// transaction if adding size bytes to it would exceed compactTxMaxSize.
+func (c *compactor) bucket(path [][]byte, size int64) (*bolt.Bucket, error) {
+	if c.tx != nil && c.size+size > compactTxMaxSize {
+		if err := c.tx.Commit(); err != nil {
+			c.tx = nil
+			return nil, err
+		}
+		c.tx = nil
+	}
+	if c.tx == nil {
+		tx, err := c.dst.Begin(true)
+		if err != nil {
+			return nil, err
+		}
+		c.tx, c.size = tx, 0
+	}
+	c.size += size
+
+	b, err := c.tx.CreateBucketIfNotExists(path[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range path[1:] {
+		if b, err = b.CreateBucketIfNotExists(name); err != nil {
+			return nil, err
+		}
+	}
+	// Keys are copied in order, so pages can be filled completely.
+	b.FillPercent = 1.0
+	return b, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/name"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
+	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
+)
+
+func TestBetaEmbeddedStoreBackupRestoreCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embeddedstore")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	srcCfg := &config.EmbeddedStoreConfig{Path: filepath.Join(dir, "src")}
+	src := storage.NewEmbeddedStore(srcCfg)
+	if _, err := src.CreateProject(ctx, "p", &prpb.Project{Name: name.FormatProject("p")}); err != nil {
+		t.Fatalf("CreateProject got %v want success", err)
+	}
+	var occs []*pb.Occurrence
+	for i := 0; i < 10; i++ {
+		o, err := src.CreateOccurrence(ctx, "p", "userID", &pb.Occurrence{
+			NoteName: "projects/n/notes/a",
+			Resource: &pb.Resource{Uri: "https://example.com"},
+			Kind:     cpb.NoteKind_BUILD,
+		})
+		if err != nil {
+			t.Fatalf("CreateOccurrence got %v want success", err)
+		}
+		occs = append(occs, o)
+	}
+
+	var backup bytes.Buffer
+	n, err := src.Backup(&backup)
+	if err != nil {
+		t.Fatalf("Backup got %v want success", err)
+	}
+	if n != int64(backup.Len()) {
+		t.Errorf("Backup got %d bytes, wrote %d", n, backup.Len())
+	}
+
+	// The source store is still open, so it cannot be restored over or compacted.
+	if err := storage.RestoreEmbeddedStore(srcCfg, bytes.NewReader(backup.Bytes())); err == nil {
+		t.Errorf("RestoreEmbeddedStore of an open store got success, want error")
+	}
+	if _, _, err := storage.CompactEmbeddedStore(srcCfg); err == nil {
+		t.Errorf("CompactEmbeddedStore of an open store got success, want error")
+	}
+
+	if err := storage.RestoreEmbeddedStore(&config.EmbeddedStoreConfig{Path: filepath.Join(dir, "bad")}, bytes.NewReader(backup.Bytes()[:backup.Len()/2])); err == nil {
+		t.Errorf("RestoreEmbeddedStore of a truncated backup got success, want error")
+	}
+
+	dstCfg := &config.EmbeddedStoreConfig{Path: filepath.Join(dir, "dst")}
+	if err := storage.RestoreEmbeddedStore(dstCfg, &backup); err != nil {
+		t.Fatalf("RestoreEmbeddedStore got %v want success", err)
+	}
+	before, after, err := storage.CompactEmbeddedStore(dstCfg)
+	if err != nil {
+		t.Fatalf("CompactEmbeddedStore got %v want success", err)
+	}
+	if after > before {
+		t.Errorf("CompactEmbeddedStore grew the database from %d to %d bytes", before, after)
+	}
+
+	dst := storage.NewEmbeddedStore(dstCfg)
+	if _, err := dst.GetProject(ctx, "p"); err != nil {
+		t.Errorf("GetProject got %v want success", err)
+	}
+	got, _, err := dst.ListNoteOccurrences(ctx, "n", "a", "", "", 100)
+	if err != nil || len(got) != len(occs) {
+		t.Errorf("ListNoteOccurrences got %d occurrences, %v, want %d", len(got), err, len(occs))
+	}
+}
@@ -15,22 +15,28 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
 
 	"github.com/boltdb/bolt"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/uuid"
 	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/filtering/common"
+	"github.com/grafeas/grafeas/go/filtering/operators"
+	"github.com/grafeas/grafeas/go/filtering/parser"
 	"github.com/grafeas/grafeas/go/name"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
 	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
 	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
 	"golang.org/x/net/context"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
 	fieldmaskpb "google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -41,6 +47,17 @@ const (
 	bucketProjects    = "projects"
 	bucketNotes       = "notes"
 	bucketOperations  = "operations"
+	bucketIAMPolicies = "iam_policies"
+	bucketPurgedNotes = "purged_notes"
+	// Audit entries are keyed by project ID, "/" and a sequence number, so that the entries of a
+	// project are a contiguous range in the order they were written.
+	bucketAuditEntries = "audit_entries"
+
+	// The occurrence index buckets hold one nested bucket per project, note name and resource
+	// URI respectively, each mapping occurrence names to occurrence IDs.
+	bucketOccurrencesByProject  = "occurrences_by_project"
+	bucketOccurrencesByNote     = "occurrences_by_note"
+	bucketOccurrencesByResource = "occurrences_by_resource"
 )
 
 var (
@@ -58,7 +75,7 @@ func NewEmbeddedStore(config *config.EmbeddedStoreConfig) *EmbeddedStore {
 	if err := os.MkdirAll(config.Path, 0700); err != nil {
 		log.Fatalf("Failed to create config directory %v", err)
 	}
-	db, err := bolt.Open(filepath.Join(config.Path, "grafeas.db"), 0600, nil)
+	db, err := bolt.Open(filepath.Join(config.Path, embeddedStoreFile), 0600, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -75,6 +92,31 @@ func NewEmbeddedStore(config *config.EmbeddedStoreConfig) *EmbeddedStore {
 		if _, err := tx.CreateBucketIfNotExists([]byte(bucketOperations)); err != nil {
 			return err
 		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(bucketIAMPolicies)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(bucketPurgedNotes)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(bucketAuditEntries)); err != nil {
+			return err
+		}
+		// Databases created before the indexes were introduced are indexed once on open.
+		reindex := tx.Bucket([]byte(bucketOccurrencesByProject)) == nil
+		for _, index := range []string{bucketOccurrencesByProject, bucketOccurrencesByNote, bucketOccurrencesByResource} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(index)); err != nil {
+				return err
+			}
+		}
+		if reindex {
+			return tx.Bucket([]byte(bucketOccurrences)).ForEach(func(k, v []byte) error {
+				var o pb.Occurrence
+				if err := proto.Unmarshal(v, &o); err != nil {
+					return err
+				}
+				return indexOccurrence(tx, string(k), &o)
+			})
+		}
 		return nil
 	}); err != nil {
 		log.Fatal(err)
@@ -105,9 +147,10 @@ func (m *EmbeddedStore) GetProject(ctx context.Context, pID string) (*prpb.Proje
 // start if pageToken is the empty string.
 func (m *EmbeddedStore) ListProjects(ctx context.Context, filter string, pageSize int, pageToken string) ([]*prpb.Project, string, error) {
 	var projects []*prpb.Project
-	m.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucketProjects))
-		err := b.ForEach(func(k, v []byte) error {
+	var nextToken string
+	err := m.db.View(func(tx *bolt.Tx) error {
+		var err error
+		nextToken, err = scanPage(tx.Bucket([]byte(bucketProjects)).Cursor(), nil, pageToken, pageSize, func(k, v []byte) error {
 			var project prpb.Project
 			if err := proto.Unmarshal(v, &project); err != nil {
 				return err
@@ -117,12 +160,7 @@ func (m *EmbeddedStore) ListProjects(ctx context.Context, filter string, pageSiz
 		})
 		return err
 	})
-	sort.Slice(projects, func(i, j int) bool {
-		return projects[i].Name < projects[j].Name
-	})
-	startPos := parsePageToken(pageToken, 0)
-	endPos := min(startPos+pageSize, len(projects))
-	return projects[startPos:endPos], nextPageToken(endPos, len(projects)), nil
+	return projects, nextToken, err
 }
 
 // DeleteProject deletes the specified project from embedded store.
@@ -148,29 +186,13 @@ func (m *EmbeddedStore) GetOccurrence(ctx context.Context, pID, oID string) (*pb
 }
 
 // ListOccurrences returns up to pageSize number of occurrences for this project (pID) beginning
-// at pageToken (or from start if pageToken is the empty string).
+// at pageToken (or from start if pageToken is the empty string). A resource URI equality filter
+// is served from the resource index; other filters are ignored.
 func (m *EmbeddedStore) ListOccurrences(ctx context.Context, pID, filters, pageToken string, pageSize int32) ([]*pb.Occurrence, string, error) {
-	var os []*pb.Occurrence
-	m.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucketOccurrences))
-		err := b.ForEach(func(k, v []byte) error {
-			var o pb.Occurrence
-			if err := proto.Unmarshal(v, &o); err != nil {
-				return err
-			}
-			if strings.HasPrefix(o.Name, fmt.Sprintf("projects/%v", pID)) {
-				os = append(os, &o)
-			}
-			return nil
-		})
-		return err
-	})
-	sort.Slice(os, func(i, j int) bool {
-		return os[i].Name < os[j].Name
-	})
-	startPos := parsePageToken(pageToken, 0)
-	endPos := min(startPos+int(pageSize), len(os))
-	return os[startPos:endPos], nextPageToken(endPos, len(os)), nil
+	if uri, ok := resourceURIFilter(filters); ok {
+		return m.listIndexedOccurrences(bucketOccurrencesByResource, uri, name.FormatOccurrence(pID, ""), pageToken, pageSize)
+	}
+	return m.listIndexedOccurrences(bucketOccurrencesByProject, pID, "", pageToken, pageSize)
 }
 
 // CreateOccurrence creates the specified occurrence in embedded store.
@@ -187,13 +209,25 @@ func (m *EmbeddedStore) CreateOccurrence(ctx context.Context, pID, uID string, o
 		o.CreateTime = ptypes.TimestampNow()
 		o.UpdateTime = o.CreateTime
 		o.Name = name.FormatOccurrence(pID, id)
-		err := m.update(bucketOccurrences, id, true, o)
+		err := m.putOccurrence(id, true, o)
 		return o, err
 	}
 
 	return nil, status.Errorf(codes.AlreadyExists, "Occurrence with ID %q already exists", id)
 }
 
+// ImportOccurrence stores o in embedded store with its name and timestamps, replacing any existing
+// occurrence with the same ID.
+func (m *EmbeddedStore) ImportOccurrence(ctx context.Context, o *pb.Occurrence) error {
+	_, oID, err := name.ParseOccurrence(o.Name)
+	if err != nil {
+		return err
+	}
+	return m.db.Update(func(tx *bolt.Tx) error {
+		return replaceOccurrence(tx, oID, o)
+	})
+}
+
 // BatchCreateOccurrence batch creates the specified occurrences in embedded store.
 func (m *EmbeddedStore) BatchCreateOccurrences(ctx context.Context, pID string, uID string, occs []*pb.Occurrence) ([]*pb.Occurrence, []error) {
 	clonedOccs := []*pb.Occurrence{}
@@ -222,8 +256,9 @@ func (m *EmbeddedStore) UpdateOccurrence(ctx context.Context, pID, oID string, o
 	o = proto.Clone(o).(*pb.Occurrence)
 	// TODO(#312): implement the update operation
 	o.UpdateTime = ptypes.TimestampNow()
+	o.Name = name.FormatOccurrence(pID, oID)
 
-	err := m.update(bucketOccurrences, oID, false, o)
+	err := m.putOccurrence(oID, false, o)
 	if err == errNoKey {
 		return nil, status.Errorf(codes.NotFound, "Occurrence with oID %q does not exist", oID)
 	}
@@ -232,7 +267,7 @@ func (m *EmbeddedStore) UpdateOccurrence(ctx context.Context, pID, oID string, o
 
 // DeleteOccurrence deletes the specified occurrence in embedded store.
 func (m *EmbeddedStore) DeleteOccurrence(ctx context.Context, pID, oID string) error {
-	err := m.delete(bucketOccurrences, oID)
+	err := m.deleteOccurrence(oID)
 	if err == errNoKey {
 		return status.Errorf(codes.NotFound, "Occurrence with oID %q does not exist", oID)
 	}
@@ -257,26 +292,22 @@ func (m *EmbeddedStore) GetNote(ctx context.Context, pID, nID string) (*pb.Note,
 // at pageToken, or from start if pageToken is the empty string.
 func (m *EmbeddedStore) ListNotes(ctx context.Context, pID, filter, pageToken string, pageSize int32) ([]*pb.Note, string, error) {
 	var ns []*pb.Note
-	m.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucketNotes))
-		err := b.ForEach(func(k, v []byte) error {
+	var nextToken string
+	err := m.db.View(func(tx *bolt.Tx) error {
+		// Notes are keyed by name, so the project's notes are a contiguous range.
+		prefix := []byte(name.FormatNote(pID, ""))
+		var err error
+		nextToken, err = scanPage(tx.Bucket([]byte(bucketNotes)).Cursor(), prefix, pageToken, int(pageSize), func(k, v []byte) error {
 			var n pb.Note
 			if err := proto.Unmarshal(v, &n); err != nil {
 				return err
 			}
-			if strings.HasPrefix(n.Name, fmt.Sprintf("projects/%v", pID)) {
-				ns = append(ns, &n)
-			}
+			ns = append(ns, &n)
 			return nil
 		})
 		return err
 	})
-	sort.Slice(ns, func(i, j int) bool {
-		return ns[i].Name < ns[j].Name
-	})
-	startPos := parsePageToken(pageToken, 0)
-	endPos := min(startPos+int(pageSize), len(ns))
-	return ns[startPos:endPos], nextPageToken(endPos, len(ns)), nil
+	return ns, nextToken, err
 }
 
 // CreateNote creates the specified note in embedded store.
@@ -292,6 +323,21 @@ func (m *EmbeddedStore) CreateNote(ctx context.Context, pID, nID, uID string, n
 	return nil, status.Errorf(codes.AlreadyExists, "Note with name %q already exists", n.Name)
 }
 
+// ImportNote stores n in embedded store with its name and timestamps, replacing any existing note
+// with the same name.
+func (m *EmbeddedStore) ImportNote(ctx context.Context, n *pb.Note) error {
+	if _, _, err := name.ParseNote(n.Name); err != nil {
+		return err
+	}
+	return m.db.Update(func(tx *bolt.Tx) error {
+		buf, err := proto.Marshal(n)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(bucketNotes)).Put([]byte(n.Name), buf)
+	})
+}
+
 // BatchCreateNotes batch creates the specified notes in embedded store.
 func (m *EmbeddedStore) BatchCreateNotes(ctx context.Context, pID, uID string, notes map[string]*pb.Note) ([]*pb.Note, []error) {
 	clonedNotes := map[string]*pb.Note{}
@@ -359,33 +405,177 @@ func (m *EmbeddedStore) GetOccurrenceNote(ctx context.Context, pID, oID string)
 // for the project beginning at pageToken, or from start if pageToken is empty.
 func (m *EmbeddedStore) ListNoteOccurrences(ctx context.Context, pID, nID, filter, pageToken string, pageSize int32) ([]*pb.Occurrence, string, error) {
 	// TODO: use filters
-	nName := name.FormatNote(pID, nID)
-	var os []*pb.Occurrence
-	m.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucketOccurrences))
-		err := b.ForEach(func(k, v []byte) error {
-			var o pb.Occurrence
-			if err := proto.Unmarshal(v, &o); err != nil {
-				return err
+	return m.listIndexedOccurrences(bucketOccurrencesByNote, name.FormatNote(pID, nID), "", pageToken, pageSize)
+}
+
+// GetVulnerabilityOccurrencesSummary gets a summary of vulnerability occurrences from storage.
+func (m *EmbeddedStore) GetVulnerabilityOccurrencesSummary(ctx context.Context, projectID, filter string) (*pb.VulnerabilityOccurrencesSummary, error) {
+	return &pb.VulnerabilityOccurrencesSummary{}, nil
+}
+
+// GetIamPolicy gets the IAM policy of the specified resource from embedded store, or nil if it has
+// none.
+func (m *EmbeddedStore) GetIamPolicy(ctx context.Context, resource string) (*iampb.Policy, error) {
+	var p iampb.Policy
+	err := m.get(bucketIAMPolicies, resource, &p)
+	if err == errNoKey {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetIamPolicy replaces the IAM policy of the specified resource in embedded store, if the etag of
+// the current policy is etag or etag is nil.
+func (m *EmbeddedStore) SetIamPolicy(ctx context.Context, resource string, p *iampb.Policy, etag []byte) error {
+	return m.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketIAMPolicies))
+		if etag != nil {
+			var current iampb.Policy
+			if value := b.Get([]byte(resource)); value != nil {
+				if err := proto.Unmarshal(value, &current); err != nil {
+					return err
+				}
 			}
-			if o.NoteName == nName {
-				os = append(os, &o)
+			if !bytes.Equal(current.Etag, etag) {
+				return status.Errorf(codes.Aborted, "IAM policy of %s has been changed concurrently", resource)
 			}
+		}
+		buf, err := proto.Marshal(p)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(resource), buf)
+	})
+}
+
+// DeleteIamPolicy deletes the IAM policy of the specified resource from embedded store.
+func (m *EmbeddedStore) DeleteIamPolicy(ctx context.Context, resource string) error {
+	if err := m.delete(bucketIAMPolicies, resource); err != nil && err != errNoKey {
+		return err
+	}
+	return nil
+}
+
+// AddPurgedNote records the deleted note in embedded store.
+func (m *EmbeddedStore) AddPurgedNote(ctx context.Context, note string) error {
+	return m.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketPurgedNotes)).Put([]byte(note), []byte{})
+	})
+}
+
+// ListPurgedNotes lists the names of the deleted notes recorded in embedded store.
+func (m *EmbeddedStore) ListPurgedNotes(ctx context.Context) ([]string, error) {
+	notes := []string{}
+	err := m.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketPurgedNotes)).ForEach(func(k, v []byte) error {
+			notes = append(notes, string(k))
 			return nil
 		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// DeletePurgedNote deletes the record of the note from embedded store.
+func (m *EmbeddedStore) DeletePurgedNote(ctx context.Context, note string) error {
+	return m.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucketPurgedNotes)).Delete([]byte(note))
+	})
+}
+
+// WriteAuditEntry appends the audit entry to embedded store.
+func (m *EmbeddedStore) WriteAuditEntry(ctx context.Context, e *apb.AuditEntry) error {
+	buf, err := proto.Marshal(e)
+	if err != nil {
 		return err
+	}
+	return m.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketAuditEntries))
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, len(e.Project)+9)
+		copy(key, e.Project+"/")
+		binary.BigEndian.PutUint64(key[len(e.Project)+1:], seq)
+		return b.Put(key, buf)
 	})
-	sort.Slice(os, func(i, j int) bool {
-		return os[i].Name < os[j].Name
+}
+
+// ListAuditEntries returns up to pageSize number of audit entries of the project (pID) beginning
+// at pageToken, oldest first.
+func (m *EmbeddedStore) ListAuditEntries(ctx context.Context, pID, pageToken string, pageSize int32) ([]*apb.AuditEntry, string, error) {
+	var es []*apb.AuditEntry
+	var nextToken string
+	err := m.db.View(func(tx *bolt.Tx) error {
+		var err error
+		nextToken, err = scanPage(tx.Bucket([]byte(bucketAuditEntries)).Cursor(), []byte(pID+"/"), pageToken, int(pageSize), func(k, v []byte) error {
+			var e apb.AuditEntry
+			if err := proto.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			es = append(es, &e)
+			return nil
+		})
+		return err
 	})
-	startPos := parsePageToken(pageToken, 0)
-	endPos := min(startPos+int(pageSize), len(os))
-	return os[startPos:endPos], nextPageToken(endPos, len(os)), nil
+	return es, nextToken, err
 }
 
-// GetVulnerabilityOccurrencesSummary gets a summary of vulnerability occurrences from storage.
-func (m *EmbeddedStore) GetVulnerabilityOccurrencesSummary(ctx context.Context, projectID, filter string) (*pb.VulnerabilityOccurrencesSummary, error) {
-	return &pb.VulnerabilityOccurrencesSummary{}, nil
+// CountObjects returns the numbers of projects, notes and occurrences in the store.
+func (m *EmbeddedStore) CountObjects(ctx context.Context) (*ObjectCounts, error) {
+	var c ObjectCounts
+	err := m.db.View(func(tx *bolt.Tx) error {
+		c.Projects = int64(tx.Bucket([]byte(bucketProjects)).Stats().KeyN)
+		c.Notes = int64(tx.Bucket([]byte(bucketNotes)).Stats().KeyN)
+		c.Occurrences = int64(tx.Bucket([]byte(bucketOccurrences)).Stats().KeyN)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CountNotes returns the number of notes in the specified project in the store.
+func (m *EmbeddedStore) CountNotes(ctx context.Context, pID string) (int64, error) {
+	var c int64
+	err := m.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(name.FormatNote(pID, ""))
+		cur := tx.Bucket([]byte(bucketNotes)).Cursor()
+		for k, _ := cur.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			c++
+		}
+		return nil
+	})
+	return c, err
+}
+
+// CountOccurrences returns the number of occurrences in the specified project in the store.
+func (m *EmbeddedStore) CountOccurrences(ctx context.Context, pID string) (int64, error) {
+	var c int64
+	err := m.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucketOccurrencesByProject)).Bucket([]byte(pID)); b != nil {
+			c = int64(b.Stats().KeyN)
+		}
+		return nil
+	})
+	return c, err
+}
+
+// Close closes the database.
+func (m *EmbeddedStore) Close() error {
+	return m.db.Close()
+}
+
+// CheckHealth returns an error if the database is not open.
+func (m *EmbeddedStore) CheckHealth(ctx context.Context) error {
+	return m.db.View(func(*bolt.Tx) error { return nil })
 }
 
 func (m *EmbeddedStore) update(bucket string, key string, new bool, pb proto.Message) error {
@@ -426,3 +616,190 @@ func (m *EmbeddedStore) delete(bucket string, key string) error {
 		return b.Delete([]byte(key))
 	})
 }
+
+// putOccurrence stores o under id and updates the occurrence indexes in the same transaction.
+func (m *EmbeddedStore) putOccurrence(id string, new bool, o *pb.Occurrence) error {
+	return m.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketOccurrences))
+		value := b.Get([]byte(id))
+		if new && value != nil {
+			return errKeyExists
+		} else if !new && value == nil {
+			return errNoKey
+		}
+		return replaceOccurrence(tx, id, o)
+	})
+}
+
+// replaceOccurrence stores o under id, replacing any existing occurrence and its index entries.
+func replaceOccurrence(tx *bolt.Tx, id string, o *pb.Occurrence) error {
+	b := tx.Bucket([]byte(bucketOccurrences))
+	if value := b.Get([]byte(id)); value != nil {
+		var old pb.Occurrence
+		if err := proto.Unmarshal(value, &old); err != nil {
+			return err
+		}
+		if err := unindexOccurrence(tx, &old); err != nil {
+			return err
+		}
+	}
+	buf, err := proto.Marshal(o)
+	if err != nil {
+		return err
+	}
+	if err := b.Put([]byte(id), buf); err != nil {
+		return err
+	}
+	return indexOccurrence(tx, id, o)
+}
+
+// deleteOccurrence deletes the occurrence with the given id and its index entries in the same
+// transaction.
+func (m *EmbeddedStore) deleteOccurrence(id string) error {
+	return m.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketOccurrences))
+		value := b.Get([]byte(id))
+		if value == nil {
+			return errNoKey
+		}
+		var old pb.Occurrence
+		if err := proto.Unmarshal(value, &old); err != nil {
+			return err
+		}
+		if err := unindexOccurrence(tx, &old); err != nil {
+			return err
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// listIndexedOccurrences returns up to pageSize occurrences from the nested bucket key of the
+// index bucket whose names start with prefix, in name order.
+func (m *EmbeddedStore) listIndexedOccurrences(index, key, prefix, pageToken string, pageSize int32) ([]*pb.Occurrence, string, error) {
+	var os []*pb.Occurrence
+	var nextToken string
+	err := m.db.View(func(tx *bolt.Tx) error {
+		ib := tx.Bucket([]byte(index)).Bucket([]byte(key))
+		if ib == nil {
+			return nil
+		}
+		b := tx.Bucket([]byte(bucketOccurrences))
+		var err error
+		nextToken, err = scanPage(ib.Cursor(), []byte(prefix), pageToken, int(pageSize), func(k, v []byte) error {
+			value := b.Get(v)
+			if value == nil {
+				return status.Errorf(codes.Internal, "Index %s references missing occurrence %q", index, k)
+			}
+			var o pb.Occurrence
+			if err := proto.Unmarshal(value, &o); err != nil {
+				return err
+			}
+			os = append(os, &o)
+			return nil
+		})
+		return err
+	})
+	return os, nextToken, err
+}
+
+// occurrenceIndexKeys returns the nested bucket that o belongs to in each occurrence index.
+func occurrenceIndexKeys(o *pb.Occurrence) map[string]string {
+	keys := map[string]string{}
+	pID, _, err := name.ParseOccurrence(o.Name)
+	if err != nil {
+		// Index entries are keyed by occurrence name.
+		return keys
+	}
+	keys[bucketOccurrencesByProject] = pID
+	if o.NoteName != "" {
+		keys[bucketOccurrencesByNote] = o.NoteName
+	}
+	if uri := o.GetResource().GetUri(); uri != "" {
+		keys[bucketOccurrencesByResource] = uri
+	}
+	return keys
+}
+
+func indexOccurrence(tx *bolt.Tx, id string, o *pb.Occurrence) error {
+	for index, key := range occurrenceIndexKeys(o) {
+		b, err := tx.Bucket([]byte(index)).CreateBucketIfNotExists([]byte(key))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(o.Name), []byte(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unindexOccurrence(tx *bolt.Tx, o *pb.Occurrence) error {
+	for index, key := range occurrenceIndexKeys(o) {
+		parent := tx.Bucket([]byte(index))
+		b := parent.Bucket([]byte(key))
+		if b == nil {
+			continue
+		}
+		if err := b.Delete([]byte(o.Name)); err != nil {
+			return err
+		}
+		// Drop empty nested buckets so that indexes do not grow with deleted keys.
+		if k, _ := b.Cursor().First(); k == nil {
+			if err := parent.DeleteBucket([]byte(key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scanPage calls fn for the keys with the given prefix that fall on the page described by
+// pageToken and pageSize, and returns the token of the following page. Tokens hold the last key of
+// their page, so that each page seeks straight to its first key.
+func scanPage(c *bolt.Cursor, prefix []byte, pageToken string, pageSize int, fn func(k, v []byte) error) (string, error) {
+	k, v := c.Seek(prefix)
+	if pageToken != "" {
+		last, err := base64.RawURLEncoding.DecodeString(pageToken)
+		if err != nil || !bytes.HasPrefix(last, prefix) {
+			return "", status.Errorf(codes.InvalidArgument, "invalid page token %q", pageToken)
+		}
+		if k, v = c.Seek(last); bytes.Equal(k, last) {
+			k, v = c.Next()
+		}
+	}
+	var last []byte
+	for n := 0; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if n == pageSize {
+			return base64.RawURLEncoding.EncodeToString(last), nil
+		}
+		if err := fn(k, v); err != nil {
+			return "", err
+		}
+		last = k
+		n++
+	}
+	return "", nil
+}
+
+// resourceURIFilter returns the URI of a filter consisting of a single resource URI equality.
+func resourceURIFilter(filter string) (string, bool) {
+	if filter == "" {
+		return "", false
+	}
+	parsed, errs := parser.Parse(common.NewStringSource(filter, "filter"))
+	if errs != nil {
+		return "", false
+	}
+	call := parsed.Expr.GetCallExpr()
+	if call == nil || call.Function != operators.Equals || len(call.Args) != 2 {
+		return "", false
+	}
+	if field := fieldPath(call.Args[0]); field != "resource.uri" && field != "resourceUrl" {
+		return "", false
+	}
+	uri, err := constValue(call.Args[1])
+	if err != nil {
+		return "", false
+	}
+	return uri, true
+}
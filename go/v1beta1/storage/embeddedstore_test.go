@@ -15,6 +15,7 @@
 package storage_test
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -23,9 +24,12 @@ import (
 	"testing"
 
 	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/name"
 	"github.com/grafeas/grafeas/go/v1beta1/api"
 	"github.com/grafeas/grafeas/go/v1beta1/project"
 	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
+	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
 )
 
 func TestBetaEmbeddedStore(t *testing.T) {
@@ -42,6 +46,120 @@ func TestBetaEmbeddedStore(t *testing.T) {
 		s := storage.NewEmbeddedStore(&config.EmbeddedStoreConfig{Path: testDir})
 		var g grafeas.Storage = s
 		var gp project.Storage = s
-		return g, gp, func() {}
+		return g, gp, func() { s.Close() }
 	})
 }
+
+func TestBetaEmbeddedStoreIndexes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embeddedstore")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := storage.NewEmbeddedStore(&config.EmbeddedStoreConfig{Path: dir})
+	ctx := context.Background()
+
+	newOcc := func(pID, noteName, uri string) *pb.Occurrence {
+		o, err := s.CreateOccurrence(ctx, pID, "userID", &pb.Occurrence{
+			NoteName: noteName,
+			Resource: &pb.Resource{Uri: uri},
+			Kind:     cpb.NoteKind_BUILD,
+		})
+		if err != nil {
+			t.Fatalf("CreateOccurrence got %v want success", err)
+		}
+		return o
+	}
+	// "p" is a prefix of "p1"; listing one project must not return the other's occurrences.
+	o1 := newOcc("p", "projects/n/notes/a", "https://example.com/1")
+	newOcc("p", "projects/n/notes/a", "https://example.com/2")
+	newOcc("p1", "projects/n/notes/b", "https://example.com/1")
+
+	names := func(os []*pb.Occurrence) []string {
+		var got []string
+		for _, o := range os {
+			got = append(got, o.Name)
+		}
+		return got
+	}
+
+	got, _, err := s.ListOccurrences(ctx, "p", "", "", 100)
+	if err != nil || len(got) != 2 {
+		t.Errorf("ListOccurrences(p) got %v, %v, want 2 occurrences", names(got), err)
+	}
+	got, _, err = s.ListOccurrences(ctx, "p", `resource.uri = "https://example.com/1"`, "", 100)
+	if err != nil || len(got) != 1 || got[0].Name != o1.Name {
+		t.Errorf("ListOccurrences(p, resource filter) got %v, %v, want [%s]", names(got), err, o1.Name)
+	}
+
+	// Moving an occurrence to another note updates the note index.
+	pID, oID, err := name.ParseOccurrence(o1.Name)
+	if err != nil {
+		t.Fatalf("Error parsing projectID and occurrenceID %v", err)
+	}
+	o1.NoteName = "projects/n/notes/b"
+	if _, err := s.UpdateOccurrence(ctx, pID, oID, o1, nil); err != nil {
+		t.Fatalf("UpdateOccurrence got %v want success", err)
+	}
+	for _, tt := range []struct {
+		nID  string
+		want int
+	}{{"a", 1}, {"b", 2}} {
+		got, _, err := s.ListNoteOccurrences(ctx, "n", tt.nID, "", "", 100)
+		if err != nil || len(got) != tt.want {
+			t.Errorf("ListNoteOccurrences(%s) got %v, %v, want %d occurrences", tt.nID, names(got), err, tt.want)
+		}
+	}
+
+	// Deleting an occurrence removes it from every index.
+	if err := s.DeleteOccurrence(ctx, pID, oID); err != nil {
+		t.Fatalf("DeleteOccurrence got %v want success", err)
+	}
+	got, _, err = s.ListOccurrences(ctx, "p", `resource.uri = "https://example.com/1"`, "", 100)
+	if err != nil || len(got) != 0 {
+		t.Errorf("ListOccurrences(p, resource filter) got %v, %v, want none", names(got), err)
+	}
+	got, _, err = s.ListNoteOccurrences(ctx, "n", "b", "", "", 100)
+	if err != nil || len(got) != 1 {
+		t.Errorf("ListNoteOccurrences(b) got %v, %v, want 1 occurrence", names(got), err)
+	}
+}
+
+func TestBetaEmbeddedStorePageTokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embeddedstore")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := storage.NewEmbeddedStore(&config.EmbeddedStoreConfig{Path: dir})
+	ctx := context.Background()
+	for _, nID := range []string{"a", "b", "c", "d", "e"} {
+		n := &pb.Note{Name: name.FormatNote("p", nID), Kind: cpb.NoteKind_BUILD}
+		if _, err := s.CreateNote(ctx, "p", nID, "userID", n); err != nil {
+			t.Fatalf("CreateNote got %v want success", err)
+		}
+	}
+
+	page, token, err := s.ListNotes(ctx, "p", "", "", 2)
+	if err != nil || len(page) != 2 || token == "" {
+		t.Fatalf("ListNotes got %d notes, %q, %v want 2 notes and a page token", len(page), token, err)
+	}
+	// Tokens are keys, so deleting a note of an earlier page does not shift the next one.
+	if err := s.DeleteNote(ctx, "p", "a"); err != nil {
+		t.Fatalf("DeleteNote got %v want success", err)
+	}
+	page, token, err = s.ListNotes(ctx, "p", "", token, 2)
+	if err != nil || len(page) != 2 || page[0].Name != name.FormatNote("p", "c") {
+		t.Fatalf("ListNotes of the second page got %v, %v want notes c and d", page, err)
+	}
+	page, token, err = s.ListNotes(ctx, "p", "", token, 2)
+	if err != nil || len(page) != 1 || token != "" {
+		t.Errorf("ListNotes of the last page got %d notes, %q, %v want 1 note and no page token", len(page), token, err)
+	}
+
+	if _, _, err := s.ListNotes(ctx, "p", "", "not a token", 2); err == nil {
+		t.Errorf("ListNotes with an invalid page token got success want error")
+	}
+}
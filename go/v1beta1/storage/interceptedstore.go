@@ -0,0 +1,212 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/middleware"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	"golang.org/x/net/context"
+	fieldmaskpb "google.golang.org/genproto/protobuf/field_mask"
+)
+
+// InterceptedStore runs an interceptor around every call of another storage.
+type InterceptedStore struct {
+	next        grafeas.Storage
+	interceptor middleware.Interceptor
+}
+
+// NewInterceptedStore returns an InterceptedStore that runs i around every call of s.
+func NewInterceptedStore(s grafeas.Storage, i middleware.Interceptor) *InterceptedStore {
+	return &InterceptedStore{next: s, interceptor: i}
+}
+
+// ApplyMiddleware wraps the grafeas storage of s, as returned by CreateStorageOfType, with the
+// middleware in configs, the first being the outermost. Middleware types must have been registered
+// with middleware.RegisterMiddlewareProvider.
+func ApplyMiddleware(s *Storage, configs []config.MiddlewareConfig) error {
+	i, err := middleware.New(configs)
+	if err != nil {
+		return err
+	}
+	if i != nil {
+		s.Gs = NewInterceptedStore(s.Gs, i)
+	}
+	return nil
+}
+
+// Unwrap returns the intercepted storage.
+func (s *InterceptedStore) Unwrap() grafeas.Storage {
+	return s.next
+}
+
+// Interceptor returns the interceptor run around storage calls.
+func (s *InterceptedStore) Interceptor() middleware.Interceptor {
+	return s.interceptor
+}
+
+// GetOccurrence gets the specified occurrence from the intercepted storage.
+func (s *InterceptedStore) GetOccurrence(ctx context.Context, pID, oID string) (o *gpb.Occurrence, err error) {
+	err = s.interceptor.Intercept(ctx, "GetOccurrence", func(ctx context.Context) error {
+		o, err = s.next.GetOccurrence(ctx, pID, oID)
+		return err
+	})
+	return o, err
+}
+
+// ListOccurrences lists occurrences for the specified project from the intercepted storage.
+func (s *InterceptedStore) ListOccurrences(ctx context.Context, pID, filter, pageToken string, pageSize int32) (occs []*gpb.Occurrence, next string, err error) {
+	err = s.interceptor.Intercept(ctx, "ListOccurrences", func(ctx context.Context) error {
+		occs, next, err = s.next.ListOccurrences(ctx, pID, filter, pageToken, pageSize)
+		return err
+	})
+	return occs, next, err
+}
+
+// CreateOccurrence creates the specified occurrence in the intercepted storage.
+func (s *InterceptedStore) CreateOccurrence(ctx context.Context, pID, uID string, o *gpb.Occurrence) (created *gpb.Occurrence, err error) {
+	err = s.interceptor.Intercept(ctx, "CreateOccurrence", func(ctx context.Context) error {
+		created, err = s.next.CreateOccurrence(ctx, pID, uID, o)
+		return err
+	})
+	return created, err
+}
+
+// BatchCreateOccurrences batch creates the specified occurrences in the intercepted storage.
+// Interceptors see the first error of the batch.
+func (s *InterceptedStore) BatchCreateOccurrences(ctx context.Context, pID string, uID string, occs []*gpb.Occurrence) (created []*gpb.Occurrence, errs []error) {
+	if err := s.interceptor.Intercept(ctx, "BatchCreateOccurrences", func(ctx context.Context) error {
+		created, errs = s.next.BatchCreateOccurrences(ctx, pID, uID, occs)
+		return firstError(errs)
+	}); err != nil && len(errs) == 0 {
+		return nil, []error{err}
+	}
+	return created, errs
+}
+
+// UpdateOccurrence updates the specified occurrence in the intercepted storage.
+func (s *InterceptedStore) UpdateOccurrence(ctx context.Context, pID, oID string, o *gpb.Occurrence, mask *fieldmaskpb.FieldMask) (updated *gpb.Occurrence, err error) {
+	err = s.interceptor.Intercept(ctx, "UpdateOccurrence", func(ctx context.Context) error {
+		updated, err = s.next.UpdateOccurrence(ctx, pID, oID, o, mask)
+		return err
+	})
+	return updated, err
+}
+
+// DeleteOccurrence deletes the specified occurrence from the intercepted storage.
+func (s *InterceptedStore) DeleteOccurrence(ctx context.Context, pID, oID string) error {
+	return s.interceptor.Intercept(ctx, "DeleteOccurrence", func(ctx context.Context) error {
+		return s.next.DeleteOccurrence(ctx, pID, oID)
+	})
+}
+
+// GetNote gets the specified note from the intercepted storage.
+func (s *InterceptedStore) GetNote(ctx context.Context, pID, nID string) (n *gpb.Note, err error) {
+	err = s.interceptor.Intercept(ctx, "GetNote", func(ctx context.Context) error {
+		n, err = s.next.GetNote(ctx, pID, nID)
+		return err
+	})
+	return n, err
+}
+
+// ListNotes lists notes for the specified project from the intercepted storage.
+func (s *InterceptedStore) ListNotes(ctx context.Context, pID, filter, pageToken string, pageSize int32) (ns []*gpb.Note, next string, err error) {
+	err = s.interceptor.Intercept(ctx, "ListNotes", func(ctx context.Context) error {
+		ns, next, err = s.next.ListNotes(ctx, pID, filter, pageToken, pageSize)
+		return err
+	})
+	return ns, next, err
+}
+
+// CreateNote creates the specified note in the intercepted storage.
+func (s *InterceptedStore) CreateNote(ctx context.Context, pID, nID, uID string, n *gpb.Note) (created *gpb.Note, err error) {
+	err = s.interceptor.Intercept(ctx, "CreateNote", func(ctx context.Context) error {
+		created, err = s.next.CreateNote(ctx, pID, nID, uID, n)
+		return err
+	})
+	return created, err
+}
+
+// BatchCreateNotes batch creates the specified notes in the intercepted storage. Interceptors see
+// the first error of the batch.
+func (s *InterceptedStore) BatchCreateNotes(ctx context.Context, pID, uID string, notes map[string]*gpb.Note) (created []*gpb.Note, errs []error) {
+	if err := s.interceptor.Intercept(ctx, "BatchCreateNotes", func(ctx context.Context) error {
+		created, errs = s.next.BatchCreateNotes(ctx, pID, uID, notes)
+		return firstError(errs)
+	}); err != nil && len(errs) == 0 {
+		return nil, []error{err}
+	}
+	return created, errs
+}
+
+// UpdateNote updates the specified note in the intercepted storage.
+func (s *InterceptedStore) UpdateNote(ctx context.Context, pID, nID string, n *gpb.Note, mask *fieldmaskpb.FieldMask) (updated *gpb.Note, err error) {
+	err = s.interceptor.Intercept(ctx, "UpdateNote", func(ctx context.Context) error {
+		updated, err = s.next.UpdateNote(ctx, pID, nID, n, mask)
+		return err
+	})
+	return updated, err
+}
+
+// DeleteNote deletes the specified note from the intercepted storage.
+func (s *InterceptedStore) DeleteNote(ctx context.Context, pID, nID string) error {
+	return s.interceptor.Intercept(ctx, "DeleteNote", func(ctx context.Context) error {
+		return s.next.DeleteNote(ctx, pID, nID)
+	})
+}
+
+// GetOccurrenceNote gets the note for the specified occurrence from the intercepted storage.
+func (s *InterceptedStore) GetOccurrenceNote(ctx context.Context, pID, oID string) (n *gpb.Note, err error) {
+	err = s.interceptor.Intercept(ctx, "GetOccurrenceNote", func(ctx context.Context) error {
+		n, err = s.next.GetOccurrenceNote(ctx, pID, oID)
+		return err
+	})
+	return n, err
+}
+
+// ListNoteOccurrences lists occurrences for the specified note from the intercepted storage.
+func (s *InterceptedStore) ListNoteOccurrences(ctx context.Context, pID, nID, filter, pageToken string, pageSize int32) (occs []*gpb.Occurrence, next string, err error) {
+	err = s.interceptor.Intercept(ctx, "ListNoteOccurrences", func(ctx context.Context) error {
+		occs, next, err = s.next.ListNoteOccurrences(ctx, pID, nID, filter, pageToken, pageSize)
+		return err
+	})
+	return occs, next, err
+}
+
+// GetVulnerabilityOccurrencesSummary gets a summary of vulnerability occurrences from the
+// intercepted storage.
+func (s *InterceptedStore) GetVulnerabilityOccurrencesSummary(ctx context.Context, pID, filter string) (summary *gpb.VulnerabilityOccurrencesSummary, err error) {
+	err = s.interceptor.Intercept(ctx, "GetVulnerabilityOccurrencesSummary", func(ctx context.Context) error {
+		summary, err = s.next.GetVulnerabilityOccurrencesSummary(ctx, pID, filter)
+		return err
+	})
+	return summary, err
+}
+
+// Close closes the intercepted storage.
+func (s *InterceptedStore) Close() error {
+	return s.next.Close()
+}
+
+// firstError returns the first non-nil error in errs.
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
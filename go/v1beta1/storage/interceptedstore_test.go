@@ -0,0 +1,103 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/middleware"
+	"github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var registerMiddlewareOnce sync.Once
+
+func registerMiddleware(t *testing.T) {
+	t.Helper()
+	registerMiddlewareOnce.Do(func() {
+		if err := middleware.RegisterDefaultMiddlewareProviders(); err != nil {
+			t.Fatalf("RegisterDefaultMiddlewareProviders got %v want success", err)
+		}
+	})
+}
+
+func TestBetaInterceptedStore(t *testing.T) {
+	latency := middleware.NewLatency(middleware.DefaultLatencyBuckets)
+	storage.DoTestStorage(t, func(t *testing.T) (grafeas.Storage, project.Storage, func()) {
+		s := storage.NewMemStore()
+		i := middleware.Chain(
+			latency,
+			middleware.SlowLog(time.Minute),
+			middleware.Retry(3, time.Millisecond, []codes.Code{codes.Unavailable}, false),
+		)
+		return storage.NewInterceptedStore(s, i), s, func() {}
+	})
+	if hs := latency.Histograms(); len(hs) == 0 {
+		t.Errorf("Histograms got none want histograms of the methods called")
+	}
+}
+
+func TestApplyMiddleware(t *testing.T) {
+	registerMiddleware(t)
+	ctx := context.Background()
+	m := storage.NewMemStore()
+	s := &storage.Storage{Ps: m, Gs: m}
+	if err := storage.ApplyMiddleware(s, []config.MiddlewareConfig{
+		{Type: "latency"},
+		{Type: "faults", Config: map[string]interface{}{"errorrate": 1, "methods": []string{"GetNote", "BatchCreateNotes"}}},
+	}); err != nil {
+		t.Fatalf("ApplyMiddleware got %v want success", err)
+	}
+
+	if _, err := s.CreateNote(ctx, "p", "n", "userID", &pb.Note{}); err != nil {
+		t.Fatalf("CreateNote got %v want success", err)
+	}
+	if _, err := s.GetNote(ctx, "p", "n"); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetNote got %v want injected Unavailable", err)
+	}
+	if _, errs := s.BatchCreateNotes(ctx, "p", "userID", map[string]*pb.Note{"m": {}}); len(errs) != 1 || status.Code(errs[0]) != codes.Unavailable {
+		t.Errorf("BatchCreateNotes got %v want injected Unavailable", errs)
+	}
+
+	var counter storage.ObjectCounter
+	if !storage.As(s, &counter) || counter != storage.ObjectCounter(m) {
+		t.Errorf("As(ObjectCounter) got %v want the memstore", counter)
+	}
+	var intercepted interface {
+		Interceptor() middleware.Interceptor
+	}
+	if !storage.As(s, &intercepted) {
+		t.Fatalf("As(Interceptor) got false want true")
+	}
+	latency, ok := middleware.Interceptors(intercepted.Interceptor())[0].(*middleware.Latency)
+	if !ok {
+		t.Fatalf("Interceptors()[0] got %T want *middleware.Latency", middleware.Interceptors(intercepted.Interceptor())[0])
+	}
+	if h := latency.Histograms()["CreateNote"]; h.Count != 1 {
+		t.Errorf("Histograms()[CreateNote] got %+v want 1 call", h)
+	}
+
+	if err := storage.ApplyMiddleware(s, []config.MiddlewareConfig{{Type: "unknown"}}); err == nil {
+		t.Errorf("ApplyMiddleware with unknown type got success want error")
+	}
+}
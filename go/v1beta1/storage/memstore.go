@@ -15,6 +15,7 @@
 package storage
 
 import (
+	"bytes"
 	"fmt"
 	"sort"
 	"strconv"
@@ -25,9 +26,11 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/uuid"
 	"github.com/grafeas/grafeas/go/name"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
 	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
 	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
 	"golang.org/x/net/context"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
 	fieldmaskpb "google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -39,6 +42,16 @@ type MemStore struct {
 	occurrencesByID map[string]*gpb.Occurrence
 	notesByName     map[string]*gpb.Note
 	projects        map[string]*prpb.Project
+	policies        map[string]*iampb.Policy
+	purgedNotes     map[string]bool
+	auditEntries    []*apb.AuditEntry
+
+	// The snapshot fields are only set by NewMemStoreWithConfig.
+	snapshotPath  string
+	snapshotMu    sync.Mutex
+	stopSnapshots chan struct{}
+	snapshotsDone chan struct{}
+	closeOnce     sync.Once
 }
 
 // NewMemStore creates a MemStore with all maps initialized.
@@ -47,6 +60,8 @@ func NewMemStore() *MemStore {
 		occurrencesByID: map[string]*gpb.Occurrence{},
 		notesByName:     map[string]*gpb.Note{},
 		projects:        map[string]*prpb.Project{},
+		policies:        map[string]*iampb.Policy{},
+		purgedNotes:     map[string]bool{},
 	}
 }
 
@@ -111,7 +126,9 @@ func (m *MemStore) GetOccurrence(ctx context.Context, pID, oID string) (*gpb.Occ
 		return nil, status.Errorf(codes.NotFound, "Occurrence with ID %s does not Exist", oID)
 	}
 
-	// Set the output-only field before returning
+	// Return a copy, as the stored occurrence must not be modified without the lock, and set
+	// the output-only field before returning
+	o = proto.Clone(o).(*gpb.Occurrence)
 	o.Name = name.FormatOccurrence(pID, oID)
 	return o, nil
 }
@@ -154,7 +171,20 @@ func (m *MemStore) CreateOccurrence(ctx context.Context, pID, uID string, o *gpb
 	o.UpdateTime = o.CreateTime
 	o.Name = name.FormatOccurrence(pID, id)
 	m.occurrencesByID[id] = o
-	return o, nil
+	return proto.Clone(o).(*gpb.Occurrence), nil
+}
+
+// ImportOccurrence stores o in memstore with its name and timestamps, replacing any existing
+// occurrence with the same ID.
+func (m *MemStore) ImportOccurrence(ctx context.Context, o *gpb.Occurrence) error {
+	_, oID, err := name.ParseOccurrence(o.Name)
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.occurrencesByID[oID] = proto.Clone(o).(*gpb.Occurrence)
+	return nil
 }
 
 // BatchCreateOccurrence batch creates the specified occurrences in memstore.
@@ -193,7 +223,7 @@ func (m *MemStore) UpdateOccurrence(ctx context.Context, pID, oID string, o *gpb
 	// TODO(#312): implement the update operation
 	o.UpdateTime = ptypes.TimestampNow()
 	m.occurrencesByID[oID] = o
-	return o, nil
+	return proto.Clone(o).(*gpb.Occurrence), nil
 }
 
 // DeleteOccurrence deletes the specified occurrence in memstore.
@@ -217,7 +247,9 @@ func (m *MemStore) GetNote(ctx context.Context, pID, nID string) (*gpb.Note, err
 		return nil, status.Errorf(codes.NotFound, "Note with name %q does not Exist", nName)
 	}
 
-	// Set the output-only field before returning
+	// Return a copy, as the stored note must not be modified without the lock, and set
+	// the output-only field before returning
+	n = proto.Clone(n).(*gpb.Note)
 	n.Name = name.FormatNote(pID, nID)
 	return n, nil
 }
@@ -256,7 +288,19 @@ func (m *MemStore) CreateNote(ctx context.Context, pID, nID, uID string, n *gpb.
 	n.CreateTime = ptypes.TimestampNow()
 	n.UpdateTime = n.CreateTime
 	m.notesByName[nName] = n
-	return n, nil
+	return proto.Clone(n).(*gpb.Note), nil
+}
+
+// ImportNote stores n in memstore with its name and timestamps, replacing any existing note with
+// the same name.
+func (m *MemStore) ImportNote(ctx context.Context, n *gpb.Note) error {
+	if _, _, err := name.ParseNote(n.Name); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.notesByName[n.Name] = proto.Clone(n).(*gpb.Note)
+	return nil
 }
 
 // BatchCreateNotes batch creates the specified notes in memstore.
@@ -298,7 +342,7 @@ func (m *MemStore) UpdateNote(ctx context.Context, pID, nID string, n *gpb.Note,
 	n.UpdateTime = ptypes.TimestampNow()
 	n.Name = nName
 	m.notesByName[nName] = n
-	return n, nil
+	return proto.Clone(n).(*gpb.Note), nil
 }
 
 // DeleteNote deletes the specified note in memstore.
@@ -359,6 +403,135 @@ func (m *MemStore) GetVulnerabilityOccurrencesSummary(ctx context.Context, proje
 	return &gpb.VulnerabilityOccurrencesSummary{}, nil
 }
 
+// CountObjects returns the numbers of projects, notes and occurrences in memstore.
+func (m *MemStore) CountObjects(ctx context.Context) (*ObjectCounts, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return &ObjectCounts{
+		Projects:    int64(len(m.projects)),
+		Notes:       int64(len(m.notesByName)),
+		Occurrences: int64(len(m.occurrencesByID)),
+	}, nil
+}
+
+// CountNotes returns the number of notes in the specified project in memstore.
+func (m *MemStore) CountNotes(ctx context.Context, pID string) (int64, error) {
+	m.RLock()
+	defer m.RUnlock()
+	var c int64
+	prefix := name.FormatNote(pID, "")
+	for n := range m.notesByName {
+		if strings.HasPrefix(n, prefix) {
+			c++
+		}
+	}
+	return c, nil
+}
+
+// CountOccurrences returns the number of occurrences in the specified project in memstore.
+func (m *MemStore) CountOccurrences(ctx context.Context, pID string) (int64, error) {
+	m.RLock()
+	defer m.RUnlock()
+	var c int64
+	prefix := name.FormatOccurrence(pID, "")
+	for _, o := range m.occurrencesByID {
+		if strings.HasPrefix(o.Name, prefix) {
+			c++
+		}
+	}
+	return c, nil
+}
+
+// GetIamPolicy gets the IAM policy of the specified resource from memstore, or nil if it has none.
+func (m *MemStore) GetIamPolicy(ctx context.Context, resource string) (*iampb.Policy, error) {
+	m.RLock()
+	defer m.RUnlock()
+	p, ok := m.policies[resource]
+	if !ok {
+		return nil, nil
+	}
+	return proto.Clone(p).(*iampb.Policy), nil
+}
+
+// SetIamPolicy replaces the IAM policy of the specified resource in memstore, if the etag of the
+// current policy is etag or etag is nil.
+func (m *MemStore) SetIamPolicy(ctx context.Context, resource string, p *iampb.Policy, etag []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	if etag != nil && !bytes.Equal(m.policies[resource].GetEtag(), etag) {
+		return status.Errorf(codes.Aborted, "IAM policy of %s has been changed concurrently", resource)
+	}
+	m.policies[resource] = proto.Clone(p).(*iampb.Policy)
+	return nil
+}
+
+// DeleteIamPolicy deletes the IAM policy of the specified resource from memstore.
+func (m *MemStore) DeleteIamPolicy(ctx context.Context, resource string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.policies, resource)
+	return nil
+}
+
+// AddPurgedNote records the deleted note in memstore.
+func (m *MemStore) AddPurgedNote(ctx context.Context, note string) error {
+	m.Lock()
+	defer m.Unlock()
+	m.purgedNotes[note] = true
+	return nil
+}
+
+// ListPurgedNotes lists the names of the deleted notes recorded in memstore.
+func (m *MemStore) ListPurgedNotes(ctx context.Context) ([]string, error) {
+	m.RLock()
+	defer m.RUnlock()
+	notes := make([]string, 0, len(m.purgedNotes))
+	for n := range m.purgedNotes {
+		notes = append(notes, n)
+	}
+	sort.Strings(notes)
+	return notes, nil
+}
+
+// DeletePurgedNote deletes the record of the note from memstore.
+func (m *MemStore) DeletePurgedNote(ctx context.Context, note string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.purgedNotes, note)
+	return nil
+}
+
+// WriteAuditEntry appends the audit entry to memstore.
+func (m *MemStore) WriteAuditEntry(ctx context.Context, e *apb.AuditEntry) error {
+	m.Lock()
+	defer m.Unlock()
+	m.auditEntries = append(m.auditEntries, proto.Clone(e).(*apb.AuditEntry))
+	return nil
+}
+
+// ListAuditEntries returns up to pageSize number of audit entries of the project (pID) beginning
+// at pageToken, oldest first.
+func (m *MemStore) ListAuditEntries(ctx context.Context, pID, pageToken string, pageSize int32) ([]*apb.AuditEntry, string, error) {
+	m.RLock()
+	defer m.RUnlock()
+	var es []*apb.AuditEntry
+	for _, e := range m.auditEntries {
+		if e.Project == pID {
+			es = append(es, e)
+		}
+	}
+	startPos := parsePageToken(pageToken, 0)
+	endPos := min(startPos+int(pageSize), len(es))
+	if startPos > endPos {
+		return nil, "", nil
+	}
+	page := make([]*apb.AuditEntry, 0, endPos-startPos)
+	for _, e := range es[startPos:endPos] {
+		page = append(page, proto.Clone(e).(*apb.AuditEntry))
+	}
+	return page, nextPageToken(endPos, len(es)), nil
+}
+
 // Parses the page token to an int. Returns defaultValue if parsing fails
 func parsePageToken(pageToken string, defaultValue int) int {
 	if pageToken == "" {
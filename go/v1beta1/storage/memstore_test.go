@@ -15,11 +15,25 @@
 package storage_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/grafeas/grafeas/go/config"
+	"github.com/grafeas/grafeas/go/name"
 	"github.com/grafeas/grafeas/go/v1beta1/api"
 	"github.com/grafeas/grafeas/go/v1beta1/project"
 	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
+	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
+	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
+	"golang.org/x/net/context"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
 )
 
 func TestBetaMemStore(t *testing.T) {
@@ -31,3 +45,113 @@ func TestBetaMemStore(t *testing.T) {
 	}
 	storage.DoTestStorage(t, createMemStore)
 }
+
+func TestBetaMemStoreWithSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memstore")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var instance int
+	storage.DoTestStorage(t, func(t *testing.T) (grafeas.Storage, project.Storage, func()) {
+		instance++
+		s, err := storage.NewMemStoreWithConfig(&config.MemStoreConfig{
+			SnapshotPath:     filepath.Join(dir, strconv.Itoa(instance), "memstore.snapshot"),
+			SnapshotInterval: "10ms",
+		})
+		if err != nil {
+			t.Fatalf("NewMemStoreWithConfig got %v want success", err)
+		}
+		return s, s, func() {
+			if err := s.Close(); err != nil {
+				t.Errorf("Close got %v want success", err)
+			}
+		}
+	})
+}
+
+func TestBetaMemStoreSnapshotReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memstore")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		desc     string
+		interval string
+		close    bool
+	}{
+		{desc: "saved on close", close: true},
+		{desc: "saved periodically", interval: "10ms"},
+	} {
+		cfg := &config.MemStoreConfig{
+			SnapshotPath:     filepath.Join(dir, tt.desc, "memstore.snapshot"),
+			SnapshotInterval: tt.interval,
+		}
+		s, err := storage.NewMemStoreWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("%q: NewMemStoreWithConfig got %v want success", tt.desc, err)
+		}
+		// Projects created directly in storage may have no name.
+		if _, err := s.CreateProject(ctx, "p", &prpb.Project{}); err != nil {
+			t.Fatalf("%q: CreateProject got %v want success", tt.desc, err)
+		}
+		n, err := s.CreateNote(ctx, "p", "n", "userID", &pb.Note{Kind: cpb.NoteKind_BUILD})
+		if err != nil {
+			t.Fatalf("%q: CreateNote got %v want success", tt.desc, err)
+		}
+		o, err := s.CreateOccurrence(ctx, "p", "userID", &pb.Occurrence{NoteName: n.Name, Kind: cpb.NoteKind_BUILD})
+		if err != nil {
+			t.Fatalf("%q: CreateOccurrence got %v want success", tt.desc, err)
+		}
+		policy := &iampb.Policy{Version: 1, Bindings: []*iampb.Binding{{Role: "viewer", Members: []string{"alice"}}}, Etag: []byte("etag")}
+		if err := s.SetIamPolicy(ctx, n.Name, policy, nil); err != nil {
+			t.Fatalf("%q: SetIamPolicy got %v want success", tt.desc, err)
+		}
+		if err := s.AddPurgedNote(ctx, "projects/p/notes/deleted"); err != nil {
+			t.Fatalf("%q: AddPurgedNote got %v want success", tt.desc, err)
+		}
+		entry := &apb.AuditEntry{Method: "/grafeas.v1beta1.GrafeasV1Beta1/CreateNote", Project: "p", Resource: n.Name, Outcome: "OK"}
+		if err := s.WriteAuditEntry(ctx, entry); err != nil {
+			t.Fatalf("%q: WriteAuditEntry got %v want success", tt.desc, err)
+		}
+		if tt.close {
+			if err := s.Close(); err != nil {
+				t.Fatalf("%q: Close got %v want success", tt.desc, err)
+			}
+		} else {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		reloaded, err := storage.NewMemStoreWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("%q: NewMemStoreWithConfig got %v want success", tt.desc, err)
+		}
+		if _, err := reloaded.GetProject(ctx, "p"); err != nil {
+			t.Errorf("%q: GetProject got %v want success", tt.desc, err)
+		}
+		if got, err := reloaded.GetNote(ctx, "p", "n"); err != nil || !proto.Equal(got, n) {
+			t.Errorf("%q: GetNote got %v, %v, want %v", tt.desc, got, err, n)
+		}
+		_, oID, err := name.ParseOccurrence(o.Name)
+		if err != nil {
+			t.Fatalf("Error parsing projectID and occurrenceID %v", err)
+		}
+		if got, err := reloaded.GetOccurrence(ctx, "p", oID); err != nil || !proto.Equal(got, o) {
+			t.Errorf("%q: GetOccurrence got %v, %v, want %v", tt.desc, got, err, o)
+		}
+		if got, err := reloaded.GetIamPolicy(ctx, n.Name); err != nil || !proto.Equal(got, policy) {
+			t.Errorf("%q: GetIamPolicy got %v, %v, want %v", tt.desc, got, err, policy)
+		}
+		if got, err := reloaded.ListPurgedNotes(ctx); err != nil || len(got) != 1 || got[0] != "projects/p/notes/deleted" {
+			t.Errorf("%q: ListPurgedNotes got %v, %v, want [projects/p/notes/deleted]", tt.desc, got, err)
+		}
+		if got, _, err := reloaded.ListAuditEntries(ctx, "p", "", 10); err != nil || len(got) != 1 || !proto.Equal(got[0], entry) {
+			t.Errorf("%q: ListAuditEntries got %v, %v, want %v", tt.desc, got, err, entry)
+		}
+		s.Close()
+	}
+}
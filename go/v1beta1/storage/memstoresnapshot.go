@@ -0,0 +1,285 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/grafeas/grafeas/go/config"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
+	gpb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	snapshotFormat  = "grafeas-memstore-snapshot"
+	snapshotVersion = 1
+)
+
+// snapshotRecord is a line of a memstore snapshot. The first line holds the format and version,
+// every following line one project, note, occurrence or IAM policy with the key it is stored under,
+// the name of a purged note, or an audit entry, in the order they were written.
+type snapshotRecord struct {
+	Format     string          `json:"format,omitempty"`
+	Version    int             `json:"version,omitempty"`
+	ID         string          `json:"id,omitempty"`
+	Project    json.RawMessage `json:"project,omitempty"`
+	Note       json.RawMessage `json:"note,omitempty"`
+	Occurrence json.RawMessage `json:"occurrence,omitempty"`
+	Policy     json.RawMessage `json:"policy,omitempty"`
+	PurgedNote string          `json:"purged_note,omitempty"`
+	AuditEntry json.RawMessage `json:"audit_entry,omitempty"`
+}
+
+// NewMemStoreWithConfig creates a MemStore that is loaded from the snapshot file in config, if it
+// exists, and saved to it periodically and when the store is closed.
+func NewMemStoreWithConfig(config *config.MemStoreConfig) (*MemStore, error) {
+	m := NewMemStore()
+	if config.SnapshotPath == "" {
+		return m, nil
+	}
+	var interval time.Duration
+	if config.SnapshotInterval != "" {
+		var err error
+		if interval, err = time.ParseDuration(config.SnapshotInterval); err != nil {
+			return nil, fmt.Errorf("invalid snapshotinterval %q: %v", config.SnapshotInterval, err)
+		}
+	}
+
+	m.snapshotPath = config.SnapshotPath
+	if err := m.loadSnapshot(); err != nil {
+		return nil, fmt.Errorf("failed to load snapshot %s: %v", m.snapshotPath, err)
+	}
+	if interval > 0 {
+		m.stopSnapshots = make(chan struct{})
+		m.snapshotsDone = make(chan struct{})
+		go m.saveSnapshots(interval)
+	}
+	return m, nil
+}
+
+// Close stops periodic snapshots and saves a final snapshot, if the store has a snapshot file.
+func (m *MemStore) Close() error {
+	if m.snapshotPath == "" {
+		return nil
+	}
+	m.closeOnce.Do(func() {
+		if m.stopSnapshots != nil {
+			close(m.stopSnapshots)
+			<-m.snapshotsDone
+		}
+	})
+	return m.saveSnapshot()
+}
+
+func (m *MemStore) saveSnapshots(interval time.Duration) {
+	defer close(m.snapshotsDone)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := m.saveSnapshot(); err != nil {
+				log.Printf("Failed to save memstore snapshot: %v", err)
+			}
+		case <-m.stopSnapshots:
+			return
+		}
+	}
+}
+
+// saveSnapshot atomically replaces the snapshot file with the current contents of the store.
+func (m *MemStore) saveSnapshot() error {
+	m.snapshotMu.Lock()
+	defer m.snapshotMu.Unlock()
+
+	// Marshal while holding the read lock, but write the file without it.
+	var buf bytes.Buffer
+	if err := m.marshalSnapshot(&buf); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(m.snapshotPath)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(m.snapshotPath)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := buf.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.snapshotPath)
+}
+
+func (m *MemStore) marshalSnapshot(w io.Writer) error {
+	m.RLock()
+	defer m.RUnlock()
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(snapshotRecord{Format: snapshotFormat, Version: snapshotVersion}); err != nil {
+		return err
+	}
+	for id, p := range m.projects {
+		data, err := protojson.Marshal(proto.MessageV2(p))
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(snapshotRecord{ID: id, Project: data}); err != nil {
+			return err
+		}
+	}
+	for id, n := range m.notesByName {
+		data, err := protojson.Marshal(proto.MessageV2(n))
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(snapshotRecord{ID: id, Note: data}); err != nil {
+			return err
+		}
+	}
+	for id, o := range m.occurrencesByID {
+		data, err := protojson.Marshal(proto.MessageV2(o))
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(snapshotRecord{ID: id, Occurrence: data}); err != nil {
+			return err
+		}
+	}
+	for id, p := range m.policies {
+		data, err := protojson.Marshal(proto.MessageV2(p))
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(snapshotRecord{ID: id, Policy: data}); err != nil {
+			return err
+		}
+	}
+	for n := range m.purgedNotes {
+		if err := enc.Encode(snapshotRecord{PurgedNote: n}); err != nil {
+			return err
+		}
+	}
+	for _, e := range m.auditEntries {
+		data, err := protojson.Marshal(proto.MessageV2(e))
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(snapshotRecord{AuditEntry: data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSnapshot replaces the contents of the store with the snapshot file, if it exists.
+func (m *MemStore) loadSnapshot() error {
+	f, err := os.Open(m.snapshotPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		projects    = map[string]*prpb.Project{}
+		notes       = map[string]*gpb.Note{}
+		occurrences = map[string]*gpb.Occurrence{}
+		policies    = map[string]*iampb.Policy{}
+		purged      = map[string]bool{}
+		audit       []*apb.AuditEntry
+		unmarshal   = protojson.UnmarshalOptions{DiscardUnknown: true}
+	)
+	dec := json.NewDecoder(bufio.NewReader(f))
+	var header snapshotRecord
+	if err := dec.Decode(&header); err != nil {
+		return err
+	}
+	if header.Format != snapshotFormat || header.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot format %q version %d", header.Format, header.Version)
+	}
+	for {
+		var r snapshotRecord
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case r.Project != nil:
+			p := &prpb.Project{}
+			if err := unmarshal.Unmarshal(r.Project, proto.MessageV2(p)); err != nil {
+				return err
+			}
+			projects[r.ID] = p
+		case r.Note != nil:
+			n := &gpb.Note{}
+			if err := unmarshal.Unmarshal(r.Note, proto.MessageV2(n)); err != nil {
+				return err
+			}
+			notes[r.ID] = n
+		case r.Occurrence != nil:
+			o := &gpb.Occurrence{}
+			if err := unmarshal.Unmarshal(r.Occurrence, proto.MessageV2(o)); err != nil {
+				return err
+			}
+			occurrences[r.ID] = o
+		case r.Policy != nil:
+			p := &iampb.Policy{}
+			if err := unmarshal.Unmarshal(r.Policy, proto.MessageV2(p)); err != nil {
+				return err
+			}
+			policies[r.ID] = p
+		case r.PurgedNote != "":
+			purged[r.PurgedNote] = true
+		case r.AuditEntry != nil:
+			e := &apb.AuditEntry{}
+			if err := unmarshal.Unmarshal(r.AuditEntry, proto.MessageV2(e)); err != nil {
+				return err
+			}
+			audit = append(audit, e)
+		}
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.projects, m.notesByName, m.occurrencesByID, m.policies, m.purgedNotes, m.auditEntries = projects, notes, occurrences, policies, purged, audit
+	log.Printf("Loaded %d projects, %d notes, %d occurrences, %d IAM policies and %d audit entries from %s", len(projects), len(notes), len(occurrences), len(policies), len(audit), m.snapshotPath)
+	return nil
+}
@@ -19,52 +19,67 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/fernet/fernet-go"
 	"github.com/golang/protobuf/proto"
-	"github.com/golang/protobuf/ptypes"
-	"github.com/google/uuid"
 	"github.com/grafeas/grafeas/go/config"
-	"github.com/grafeas/grafeas/go/name"
 	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
-	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
 	"github.com/lib/pq"
-	"golang.org/x/net/context"
-	fieldmaskpb "google.golang.org/genproto/protobuf/field_mask"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
 )
 
+// pgDialect is the dialect of PostgreSQL, which the statements in queries.go are written in.
+var pgDialect = &sqlDialect{
+	system:               semconv.DBSystemPostgreSQL,
+	rebind:               func(query string) string { return query },
+	vulnerabilitySummary: vulnerabilitySummary,
+	isUniqueViolation: func(err error) bool {
+		pqErr, ok := err.(*pq.Error)
+		return ok && pqErr.Code == "23505"
+	},
+	isStatementTimeout: func(err error) bool {
+		// query_canceled is raised when statement_timeout expires.
+		pqErr, ok := err.(*pq.Error)
+		return ok && pqErr.Code == "57014"
+	},
+}
+
 type PgSQLStore struct {
 	*sql.DB
-	paginationKey string
+	*sqlStore
 }
 
 func NewPgSQLStore(config *config.PgSQLConfig) (*PgSQLStore, error) {
-	paginationKey := config.PaginationKey
-	if paginationKey == "" {
-		log.Println("pagination key is empty, generating...")
-		var key fernet.Key
-		if err := key.Generate(); err != nil {
-			return nil, fmt.Errorf("failed to generate pagination key, %s", err)
-		}
-		paginationKey = key.Encode()
-	} else {
-		// Validate pagination key
-		_, err := fernet.DecodeKey(paginationKey)
-		if err != nil {
-			return nil, errors.New("invalid pagination key; must be 256-bit URL-safe base64")
-		}
+	paginationKey, err := validatePaginationKey(config.PaginationKey)
+	if err != nil {
+		return nil, err
+	}
+	dbName, err := databaseName(config)
+	if err != nil {
+		return nil, err
+	}
+	adminSource, err := PgSQLSourceString(config, "postgres")
+	if err != nil {
+		return nil, err
 	}
-	if err := createDatabase(CreateSourceString(config.User, config.Password, config.Host, "postgres", config.SSLMode), config.DbName); err != nil {
+	if err := createDatabase(adminSource, dbName); err != nil {
 		return nil, err
 	}
-	db, err := sql.Open("postgres", CreateSourceString(config.User, config.Password, config.Host, config.DbName, config.SSLMode))
+	source, err := PgSQLSourceString(config, dbName)
 	if err != nil {
 		return nil, err
 	}
+	db, err := sql.Open("postgres", source)
+	if err != nil {
+		return nil, err
+	}
+	if err := configurePool(db, config); err != nil {
+		db.Close()
+		return nil, err
+	}
 	if db.Ping() != nil {
 		return nil, errors.New("database server is not alive")
 	}
@@ -72,12 +87,60 @@ func NewPgSQLStore(config *config.PgSQLConfig) (*PgSQLStore, error) {
 		db.Close()
 		return nil, err
 	}
+	if err := migrateTextData(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate data to JSONB, %s", err)
+	}
+	if _, err := db.Exec(createIndexes); err != nil {
+		db.Close()
+		return nil, err
+	}
 	return &PgSQLStore{
-		DB:            db,
-		paginationKey: paginationKey,
+		DB: db,
+		sqlStore: &sqlStore{
+			db:            db,
+			paginationKey: paginationKey,
+			dialect:       pgDialect,
+		},
 	}, nil
 }
 
+// configurePool applies the connection pool settings of config to db.
+func configurePool(db *sql.DB, config *config.PgSQLConfig) error {
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	lifetime, err := parseDuration("connmaxlifetime", config.ConnMaxLifetime)
+	if err != nil {
+		return err
+	}
+	db.SetConnMaxLifetime(lifetime)
+	idleTime, err := parseDuration("connmaxidletime", config.ConnMaxIdleTime)
+	if err != nil {
+		return err
+	}
+	db.SetConnMaxIdleTime(idleTime)
+	return nil
+}
+
+// parseDuration parses the duration configured for the named setting. An empty value is zero.
+func parseDuration(setting, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q, %s", setting, value, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid %s %q, must not be negative", setting, value)
+	}
+	return d, nil
+}
+
 func createDatabase(source, dbName string) error {
 	db, err := sql.Open("postgres", source)
 	if err != nil {
@@ -104,437 +167,146 @@ func createDatabase(source, dbName string) error {
 	return nil
 }
 
-// CreateProject adds the specified project to the store
-func (pg *PgSQLStore) CreateProject(ctx context.Context, pID string, p *prpb.Project) (*prpb.Project, error) {
-	_, err := pg.DB.ExecContext(ctx, insertProject, name.FormatProject(pID))
-	if err, ok := err.(*pq.Error); ok {
-		// Check for unique_violation
-		if err.Code == "23505" {
-			return nil, status.Errorf(codes.AlreadyExists, "Project with name %q already exists", pID)
-		} else {
-			log.Println("Failed to insert Project in database", err)
-			return nil, status.Error(codes.Internal, "Failed to insert Project in database")
+// migrateTextData converts notes and occurrences stored in the protobuf text format by earlier
+// versions of this store into JSONB. Tables that already use JSONB are left untouched.
+func migrateTextData(db *sql.DB) error {
+	tables := []struct {
+		name   string
+		newMsg func() proto.Message
+	}{
+		{"notes", func() proto.Message { return &pb.Note{} }},
+		{"occurrences", func() proto.Message { return &pb.Occurrence{} }},
+	}
+	for _, t := range tables {
+		var dataType string
+		if err := db.QueryRow(dataColumnType, t.name).Scan(&dataType); err != nil {
+			return err
 		}
-	}
-	return p, nil
-}
-
-// DeleteProject deletes the project with the given pID from the store
-func (pg *PgSQLStore) DeleteProject(ctx context.Context, pID string) error {
-	pName := name.FormatProject(pID)
-	result, err := pg.DB.ExecContext(ctx, deleteProject, pName)
-	if err != nil {
-		return status.Error(codes.Internal, "Failed to delete Project from database")
-	}
-	count, err := result.RowsAffected()
-	if err != nil {
-		return status.Error(codes.Internal, "Failed to delete Project from database")
-	}
-	if count == 0 {
-		return status.Errorf(codes.NotFound, "Project with name %q does not Exist", pName)
-	}
-	return nil
-}
-
-// GetProject returns the project with the given pID from the store
-func (pg *PgSQLStore) GetProject(ctx context.Context, pID string) (*prpb.Project, error) {
-	pName := name.FormatProject(pID)
-	var exists bool
-	err := pg.DB.QueryRowContext(ctx, projectExists, pName).Scan(&exists)
-	if err != nil {
-		return nil, status.Error(codes.Internal, "Failed to query Project from database")
-	}
-	if !exists {
-		return nil, status.Errorf(codes.NotFound, "Project with name %q does not Exist", pName)
-	}
-	return &prpb.Project{Name: pName}, nil
-}
-
-// ListProjects returns up to pageSize number of projects beginning at pageToken (or from
-// start if pageToken is the empty string).
-func (pg *PgSQLStore) ListProjects(ctx context.Context, filter string, pageSize int, pageToken string) ([]*prpb.Project, string, error) {
-	count, err := pg.count(ctx, projectCount)
-	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to count Projects from database")
-	}
-	id := decryptInt64(pageToken, pg.paginationKey, 0)
-	rows, err := pg.DB.QueryContext(ctx, listProjects, id, pageSize)
-	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to list Projects from database")
-	}
-	var projects []*prpb.Project
-	var lastID int64
-	for rows.Next() {
-		var name string
-		err := rows.Scan(&lastID, &name)
+		if dataType != "text" {
+			continue
+		}
+		log.Printf("migrating %s from text to JSONB", t.name)
+		tx, err := db.Begin()
 		if err != nil {
-			return nil, "", status.Error(codes.Internal, "Failed to scan Project row")
+			return err
 		}
-		projects = append(projects, &prpb.Project{Name: name})
-	}
-	if count == lastID {
-		return projects, "", nil
-	}
-	encryptedPage, err := encryptInt64(lastID, pg.paginationKey)
-	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to paginate projects")
-	}
-	return projects, encryptedPage, nil
-}
-
-// CreateOccurrence adds the specified occurrence
-func (pg *PgSQLStore) CreateOccurrence(ctx context.Context, pID, uID string, o *pb.Occurrence) (*pb.Occurrence, error) {
-	o = proto.Clone(o).(*pb.Occurrence)
-	o.CreateTime = ptypes.TimestampNow()
-
-	var id string
-	if nr, err := uuid.NewRandom(); err != nil {
-		return nil, status.Error(codes.Internal, "Failed to generate UUID")
-	} else {
-		id = nr.String()
-	}
-	o.Name = fmt.Sprintf("projects/%s/occurrences/%s", pID, id)
-
-	nPID, nID, err := name.ParseNote(o.NoteName)
-	if err != nil {
-		log.Printf("Invalid note name: %v", o.NoteName)
-		return nil, status.Error(codes.InvalidArgument, "Invalid note name")
-	}
-	_, err = pg.DB.ExecContext(ctx, insertOccurrence, pID, id, nPID, nID, proto.MarshalTextString(o))
-	if err, ok := err.(*pq.Error); ok {
-		// Check for unique_violation
-		if err.Code == "23505" {
-			return nil, status.Errorf(codes.AlreadyExists, "Occurrence with name %q already exists", o.Name)
-		} else {
-			log.Println("Failed to insert Occurrence in database", err)
-			return nil, status.Error(codes.Internal, "Failed to insert Occurrence in database")
+		if err := migrateTable(tx, t.name, t.newMsg); err != nil {
+			tx.Rollback()
+			return err
 		}
-	}
-	return o, nil
-}
-
-// BatchCreateOccurrences batch creates the specified occurrences in PostreSQL.
-func (pg *PgSQLStore) BatchCreateOccurrences(ctx context.Context, pID string, uID string, occs []*pb.Occurrence) ([]*pb.Occurrence, []error) {
-	clonedOccs := []*pb.Occurrence{}
-	for _, o := range occs {
-		clonedOccs = append(clonedOccs, proto.Clone(o).(*pb.Occurrence))
-	}
-	occs = clonedOccs
-
-	errs := []error{}
-	created := []*pb.Occurrence{}
-	for _, o := range occs {
-		occ, err := pg.CreateOccurrence(ctx, pID, uID, o)
-		if err != nil {
-			// Occurrence already exists, skipping.
-			continue
-		} else {
-			created = append(created, occ)
+		if err := tx.Commit(); err != nil {
+			return err
 		}
 	}
-
-	return created, errs
-}
-
-// DeleteOccurrence deletes the occurrence with the given pID and oID
-func (pg *PgSQLStore) DeleteOccurrence(ctx context.Context, pID, oID string) error {
-	result, err := pg.DB.ExecContext(ctx, deleteOccurrence, pID, oID)
-	if err != nil {
-		return status.Error(codes.Internal, "Failed to delete Occurrence from database")
-	}
-	count, err := result.RowsAffected()
-	if err != nil {
-		return status.Error(codes.Internal, "Failed to delete Occurrence from database")
-	}
-	if count == 0 {
-		return status.Errorf(codes.NotFound, "Occurrence with name %q/%q does not Exist", pID, oID)
-	}
 	return nil
 }
 
-// UpdateOccurrence updates the existing occurrence with the given projectID and occurrenceID
-func (pg *PgSQLStore) UpdateOccurrence(ctx context.Context, pID, oID string, o *pb.Occurrence, mask *fieldmaskpb.FieldMask) (*pb.Occurrence, error) {
-	o = proto.Clone(o).(*pb.Occurrence)
-	// TODO(#312): implement the update operation
-	o.UpdateTime = ptypes.TimestampNow()
-
-	result, err := pg.DB.ExecContext(ctx, updateOccurrence, proto.MarshalTextString(o), pID, oID)
-	if err != nil {
-		return nil, status.Error(codes.Internal, "Failed to update Occurrence")
-	}
-	count, err := result.RowsAffected()
-	if err != nil {
-		return nil, status.Error(codes.Internal, "Failed to update Occurrence")
-	}
-	if count == 0 {
-		return nil, status.Errorf(codes.NotFound, "Occurrence with name %q/%q does not Exist", pID, oID)
-	}
-	return o, nil
-}
-
-// GetOccurrence returns the occurrence with pID and oID
-func (pg *PgSQLStore) GetOccurrence(ctx context.Context, pID, oID string) (*pb.Occurrence, error) {
-	var data string
-	err := pg.DB.QueryRowContext(ctx, searchOccurrence, pID, oID).Scan(&data)
-	switch {
-	case err == sql.ErrNoRows:
-		return nil, status.Errorf(codes.NotFound, "Occurrence with name %q/%q does not Exist", pID, oID)
-	case err != nil:
-		return nil, status.Error(codes.Internal, "Failed to query Occurrence from database")
-	}
-	var o pb.Occurrence
-	proto.UnmarshalText(data, &o)
-	if err != nil {
-		return nil, status.Error(codes.Internal, "Failed to unmarshal Occurrence from database")
-	}
-	// Set the output-only field before returning
-	o.Name = name.FormatOccurrence(pID, oID)
-	return &o, nil
-}
-
-// ListOccurrences returns up to pageSize number of occurrences for this project beginning
-// at pageToken, or from start if pageToken is the empty string.
-func (pg *PgSQLStore) ListOccurrences(ctx context.Context, pID, filter, pageToken string, pageSize int32) ([]*pb.Occurrence, string, error) {
-	count, err := pg.count(ctx, occurrenceCount, pID)
-	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to count Occurrences from database")
+// migrateTable rewrites the data column of table as JSONB within tx.
+func migrateTable(tx *sql.Tx, table string, newMsg func() proto.Message) error {
+	if _, err := tx.Exec(fmt.Sprintf(addJSONDataColumn, table)); err != nil {
+		return err
 	}
-	id := decryptInt64(pageToken, pg.paginationKey, 0)
-	rows, err := pg.DB.QueryContext(ctx, listOccurrences, pID, id, pageSize)
+	rows, err := tx.Query(fmt.Sprintf(selectTextData, table))
 	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to list Occurrences from database")
+		return err
 	}
-
-	var os []*pb.Occurrence
-	var lastID int64
+	// Rows are read fully before updating, as a connection can't run a statement while another
+	// one has pending results.
+	converted := map[int64]string{}
 	for rows.Next() {
-		var data string
-		err := rows.Scan(&lastID, &data)
-		if err != nil {
-			return nil, "", status.Error(codes.Internal, "Failed to scan Occurrences row")
-		}
-		var o pb.Occurrence
-		proto.UnmarshalText(data, &o)
-		if err != nil {
-			return nil, "", status.Error(codes.Internal, "Failed to unmarshal Occurrence from database")
+		var id int64
+		var text sql.NullString
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return err
 		}
-		os = append(os, &o)
-	}
-	if count == lastID {
-		return os, "", nil
-	}
-	encryptedPage, err := encryptInt64(lastID, pg.paginationKey)
-	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to paginate projects")
-	}
-	return os, encryptedPage, nil
-}
-
-// CreateNote adds the specified note
-func (pg *PgSQLStore) CreateNote(ctx context.Context, pID, nID, uID string, n *pb.Note) (*pb.Note, error) {
-	n = proto.Clone(n).(*pb.Note)
-	nName := name.FormatNote(pID, nID)
-	n.Name = nName
-	n.CreateTime = ptypes.TimestampNow()
-
-	_, err := pg.DB.ExecContext(ctx, insertNote, pID, nID, proto.MarshalTextString(n))
-	if err, ok := err.(*pq.Error); ok {
-		// Check for unique_violation
-		if err.Code == "23505" {
-			return nil, status.Errorf(codes.AlreadyExists, "Note with name %q already exists", n.Name)
-		} else {
-			log.Println("Failed to insert Note in database", err)
-			return nil, status.Error(codes.Internal, "Failed to insert Note in database")
+		m := newMsg()
+		if err := proto.UnmarshalText(text.String, m); err != nil {
+			rows.Close()
+			return fmt.Errorf("%s row %d: %s", table, id, err)
 		}
-	}
-	return n, nil
-}
-
-// BatchCreateNotes batch creates the specified notes in memstore.
-func (pg *PgSQLStore) BatchCreateNotes(ctx context.Context, pID, uID string, notes map[string]*pb.Note) ([]*pb.Note, []error) {
-	clonedNotes := map[string]*pb.Note{}
-	for nID, n := range notes {
-		clonedNotes[nID] = proto.Clone(n).(*pb.Note)
-	}
-	notes = clonedNotes
-
-	errs := []error{}
-	created := []*pb.Note{}
-	for nID, n := range notes {
-		note, err := pg.CreateNote(ctx, pID, nID, uID, n)
+		data, err := marshalData(m)
 		if err != nil {
-			// Note already exists, skipping.
-			continue
-		} else {
-			created = append(created, note)
+			rows.Close()
+			return err
 		}
-
+		converted[id] = data
 	}
-
-	return created, errs
-}
-
-// DeleteNote deletes the note with the given pID and nID
-func (pg *PgSQLStore) DeleteNote(ctx context.Context, pID, nID string) error {
-	result, err := pg.DB.ExecContext(ctx, deleteNote, pID, nID)
-	if err != nil {
-		return status.Error(codes.Internal, "Failed to delete Note from database")
-	}
-	count, err := result.RowsAffected()
-	if err != nil {
-		return status.Error(codes.Internal, "Failed to delete Note from database")
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
 	}
-	if count == 0 {
-		return status.Errorf(codes.NotFound, "Note with name %q/%q does not Exist", pID, nID)
+	for id, data := range converted {
+		if _, err := tx.Exec(fmt.Sprintf(updateJSONData, table), data, id); err != nil {
+			return err
+		}
 	}
-	return nil
+	_, err = tx.Exec(fmt.Sprintf(replaceDataColumn, table))
+	return err
 }
 
-// UpdateNote updates the existing note with the given pID and nID
-func (pg *PgSQLStore) UpdateNote(ctx context.Context, pID, nID string, n *pb.Note, mask *fieldmaskpb.FieldMask) (*pb.Note, error) {
-	n = proto.Clone(n).(*pb.Note)
-	nName := name.FormatNote(pID, nID)
-	n.Name = nName
-	// TODO(#312): implement the update operation
-	n.UpdateTime = ptypes.TimestampNow()
-
-	result, err := pg.DB.ExecContext(ctx, updateNote, proto.MarshalTextString(n), pID, nID)
-	if err != nil {
-		return nil, status.Error(codes.Internal, "Failed to update Note")
-	}
-	count, err := result.RowsAffected()
-	if err != nil {
-		return nil, status.Error(codes.Internal, "Failed to update Note")
+// databaseName returns the name of the database to use, taken from the dbname setting or else from
+// the path of the connection URL.
+func databaseName(config *config.PgSQLConfig) (string, error) {
+	if config.DbName != "" || config.URL == "" {
+		return config.DbName, nil
 	}
-	if count == 0 {
-		return nil, status.Errorf(codes.NotFound, "Note with name %q/%q does not Exist", pID, nID)
-	}
-	return n, nil
-}
-
-// GetNote returns the note with project (pID) and note ID (nID)
-func (pg *PgSQLStore) GetNote(ctx context.Context, pID, nID string) (*pb.Note, error) {
-	var data string
-	err := pg.DB.QueryRowContext(ctx, searchNote, pID, nID).Scan(&data)
-	switch {
-	case err == sql.ErrNoRows:
-		return nil, status.Errorf(codes.NotFound, "Note with name %q/%q does not Exist", pID, nID)
-	case err != nil:
-		return nil, status.Error(codes.Internal, "Failed to query Note from database")
-	}
-	var note pb.Note
-	proto.UnmarshalText(data, &note)
+	u, err := url.Parse(config.URL)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "Failed to unmarshal Note from database")
+		return "", fmt.Errorf("invalid url, %s", err)
 	}
-	// Set the output-only field before returning
-	note.Name = name.FormatNote(pID, nID)
-	return &note, nil
+	return strings.TrimPrefix(u.Path, "/"), nil
 }
 
-// GetOccurrenceNote gets the note for the specified occurrence from PostgreSQL.
-func (pg *PgSQLStore) GetOccurrenceNote(ctx context.Context, pID, oID string) (*pb.Note, error) {
-	o, err := pg.GetOccurrence(ctx, pID, oID)
-	if err != nil {
-		return nil, err
+// PgSQLSourceString generates the DB source URL for the dbName database from config. The URL
+// setting, if any, is used as a base that the other settings override.
+func PgSQLSourceString(config *config.PgSQLConfig, dbName string) (string, error) {
+	u := &url.URL{Scheme: "postgres"}
+	if config.URL != "" {
+		parsed, err := url.Parse(config.URL)
+		if err != nil {
+			return "", fmt.Errorf("invalid url, %s", err)
+		}
+		u = parsed
 	}
-	nPID, nID, err := name.ParseNote(o.NoteName)
-	if err != nil {
-		log.Printf("Error parsing name: %v", o.NoteName)
-		return nil, status.Error(codes.InvalidArgument, "Invalid Note name")
+	if config.Host != "" {
+		u.Host = config.Host
 	}
-	n, err := pg.GetNote(ctx, nPID, nID)
-	if err != nil {
-		return nil, err
+	if config.User != "" {
+		u.User = url.UserPassword(config.User, config.Password)
 	}
-	// Set the output-only field before returning
-	n.Name = name.FormatNote(nPID, nID)
-	return n, nil
-}
+	u.Path = "/" + dbName
 
-// ListNotes returns up to pageSize number of notes for this project (pID) beginning
-// at pageToken (or from start if pageToken is the empty string).
-func (pg *PgSQLStore) ListNotes(ctx context.Context, pID, filter, pageToken string, pageSize int32) ([]*pb.Note, string, error) {
-	count, err := pg.count(ctx, noteCount, pID)
-	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to count Notes from database")
+	q := u.Query()
+	params := map[string]string{
+		"sslmode":     config.SSLMode,
+		"sslcert":     config.SSLCert,
+		"sslkey":      config.SSLKey,
+		"sslrootcert": config.SSLRootCert,
 	}
-	id := decryptInt64(pageToken, pg.paginationKey, 0)
-	rows, err := pg.DB.QueryContext(ctx, listNotes, pID, id, pageSize)
-	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to list Notes from database")
-	}
-
-	var ns []*pb.Note
-	var lastID int64
-	for rows.Next() {
-		var data string
-		err := rows.Scan(&lastID, &data)
-		if err != nil {
-			return nil, "", status.Error(codes.Internal, "Failed to scan Notes row")
-		}
-		var n pb.Note
-		proto.UnmarshalText(data, &n)
-		if err != nil {
-			return nil, "", status.Error(codes.Internal, "Failed to unmarshal Note from database")
+	for k, v := range params {
+		if v != "" {
+			q.Set(k, v)
 		}
-		ns = append(ns, &n)
 	}
-	if count == lastID {
-		return ns, "", nil
-	}
-	encryptedPage, err := encryptInt64(lastID, pg.paginationKey)
+	connectTimeout, err := parseDuration("connecttimeout", config.ConnectTimeout)
 	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to paginate projects")
+		return "", err
 	}
-	return ns, encryptedPage, nil
-}
-
-// ListNoteOccurrences returns up to pageSize number of occurrences on the particular note (nID)
-// for this project (pID) projects beginning at pageToken (or from start if pageToken is the empty string).
-func (pg *PgSQLStore) ListNoteOccurrences(ctx context.Context, pID, nID, filter, pageToken string, pageSize int32) ([]*pb.Occurrence, string, error) {
-	// Verify that note exists
-	if _, err := pg.GetNote(ctx, pID, nID); err != nil {
-		return nil, "", err
-	}
-	count, err := pg.count(ctx, noteOccurrencesCount, pID, nID)
-	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to count Occurrences from database")
+	if connectTimeout > 0 {
+		// connect_timeout is in seconds, round up so that sub-second values are not disabled.
+		q.Set("connect_timeout", strconv.FormatInt(int64((connectTimeout+time.Second-1)/time.Second), 10))
 	}
-	id := decryptInt64(pageToken, pg.paginationKey, 0)
-	rows, err := pg.DB.QueryContext(ctx, listNoteOccurrences, pID, nID, id, pageSize)
+	statementTimeout, err := parseDuration("statementtimeout", config.StatementTimeout)
 	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to list Occurrences from database")
-	}
-
-	var os []*pb.Occurrence
-	var lastID int64
-	for rows.Next() {
-		var data string
-		err := rows.Scan(&lastID, &data)
-		if err != nil {
-			return nil, "", status.Error(codes.Internal, "Failed to scan Occurrences row")
-		}
-		var o pb.Occurrence
-		proto.UnmarshalText(data, &o)
-		if err != nil {
-			return nil, "", status.Error(codes.Internal, "Failed to unmarshal Occurrence from database")
-		}
-		os = append(os, &o)
-	}
-	if count == lastID {
-		return os, "", nil
+		return "", err
 	}
-	encryptedPage, err := encryptInt64(lastID, pg.paginationKey)
-	if err != nil {
-		return nil, "", status.Error(codes.Internal, "Failed to paginate projects")
+	if statementTimeout > 0 {
+		// Unknown parameters are sent to the server as run-time settings.
+		q.Set("statement_timeout", strconv.FormatInt(statementTimeout.Milliseconds(), 10))
 	}
-	return os, encryptedPage, nil
-}
-
-// GetVulnerabilityOccurrencesSummary gets a summary of vulnerability occurrences from storage.
-func (pg *PgSQLStore) GetVulnerabilityOccurrencesSummary(ctx context.Context, projectID, filter string) (*pb.VulnerabilityOccurrencesSummary, error) {
-	return &pb.VulnerabilityOccurrencesSummary{}, nil
+	u.RawQuery = q.Encode()
+	return u.String(), nil
 }
 
 // CreateSourceString generates DB source path.
@@ -544,44 +316,3 @@ func CreateSourceString(user, password, host, dbName, SSLMode string) string {
 	}
 	return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s", user, password, host, dbName, SSLMode)
 }
-
-// count returns the total number of entries for the specified query (assuming SELECT(*) is used)
-func (pg *PgSQLStore) count(ctx context.Context, query string, args ...interface{}) (int64, error) {
-	row := pg.DB.QueryRowContext(ctx, query, args...)
-	var count int64
-	err := row.Scan(&count)
-	if err != nil {
-		return 0, err
-	}
-	return count, err
-}
-
-// Encrypt int64 using provided key
-func encryptInt64(v int64, key string) (string, error) {
-	k, err := fernet.DecodeKey(key)
-	if err != nil {
-		return "", err
-	}
-	bytes, err := fernet.EncryptAndSign([]byte(strconv.FormatInt(v, 10)), k)
-	if err != nil {
-		return "", err
-	}
-	return string(bytes), nil
-}
-
-// Decrypts encrypted int64 using provided key. Returns defaultValue if decryption fails.
-func decryptInt64(encrypted string, key string, defaultValue int64) int64 {
-	k, err := fernet.DecodeKey(key)
-	if err != nil {
-		return defaultValue
-	}
-	bytes := fernet.VerifyAndDecrypt([]byte(encrypted), time.Hour, []*fernet.Key{k})
-	if bytes == nil {
-		return defaultValue
-	}
-	decryptedValue, err := strconv.ParseInt(string(bytes), 10, 64)
-	if err != nil {
-		return defaultValue
-	}
-	return decryptedValue
-}
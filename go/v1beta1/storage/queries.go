@@ -24,14 +24,14 @@ const (
 			id SERIAL PRIMARY KEY,
 			project_name TEXT NOT NULL,
 			note_name TEXT NOT NULL,
-			data TEXT,
+			data JSONB,
 			UNIQUE (project_name, note_name)
 		);
 		CREATE TABLE IF NOT EXISTS occurrences (
 			id SERIAL PRIMARY KEY,
 			project_name TEXT NOT NULL,
 			occurrence_name TEXT NOT NULL,
-			data TEXT,
+			data JSONB,
 			note_id int REFERENCES notes NOT NULL,
 			UNIQUE (project_name, occurrence_name)
 		);
@@ -41,7 +41,51 @@ const (
 			operation_name TEXT NOT NULL,
 			data TEXT,
 			UNIQUE (project_name, operation_name)
-		);`
+		);
+		CREATE TABLE IF NOT EXISTS iam_policies (
+			resource TEXT PRIMARY KEY,
+			etag TEXT NOT NULL,
+			data JSONB
+		);
+		CREATE TABLE IF NOT EXISTS purged_notes (
+			note_name TEXT PRIMARY KEY
+		);
+		CREATE TABLE IF NOT EXISTS audit_entries (
+			id SERIAL PRIMARY KEY,
+			project_name TEXT NOT NULL,
+			data JSONB
+		);
+		CREATE INDEX IF NOT EXISTS audit_entries_project_idx ON audit_entries (project_name, id);`
+
+	// dataColumnType returns the type of the data column of a table, used to detect databases
+	// that still store notes and occurrences in the protobuf text format.
+	dataColumnType = `SELECT data_type FROM information_schema.columns
+	                    WHERE table_schema = current_schema() AND table_name = $1 AND column_name = 'data'`
+
+	// The text to JSONB migration is done row by row in Go, as the text format cannot be parsed
+	// in SQL. The table name is substituted with fmt.Sprintf.
+	addJSONDataColumn = `ALTER TABLE %s ADD COLUMN IF NOT EXISTS data_json JSONB`
+	selectTextData    = `SELECT id, data FROM %s`
+	updateJSONData    = `UPDATE %s SET data_json = $1 WHERE id = $2`
+	replaceDataColumn = `ALTER TABLE %[1]s DROP COLUMN data; ALTER TABLE %[1]s RENAME COLUMN data_json TO data`
+
+	// createIndexes adds generated columns for the fields filters and summaries are computed on,
+	// and indexes them. Requires PostgreSQL 12 or later.
+	createIndexes = `
+		ALTER TABLE notes
+			ADD COLUMN IF NOT EXISTS kind TEXT GENERATED ALWAYS AS (data->>'kind') STORED;
+		ALTER TABLE occurrences
+			ADD COLUMN IF NOT EXISTS kind TEXT GENERATED ALWAYS AS (data->>'kind') STORED,
+			ADD COLUMN IF NOT EXISTS resource_uri TEXT GENERATED ALWAYS AS (data->'resource'->>'uri') STORED,
+			ADD COLUMN IF NOT EXISTS note_name TEXT GENERATED ALWAYS AS (data->>'noteName') STORED,
+			ADD COLUMN IF NOT EXISTS severity TEXT GENERATED ALWAYS AS (data->'vulnerability'->>'effectiveSeverity') STORED;
+		CREATE INDEX IF NOT EXISTS notes_kind_idx ON notes (project_name, kind);
+		CREATE INDEX IF NOT EXISTS notes_data_idx ON notes USING GIN (data jsonb_path_ops);
+		CREATE INDEX IF NOT EXISTS occurrences_kind_idx ON occurrences (project_name, kind);
+		CREATE INDEX IF NOT EXISTS occurrences_resource_uri_idx ON occurrences (project_name, resource_uri);
+		CREATE INDEX IF NOT EXISTS occurrences_note_name_idx ON occurrences (note_name);
+		CREATE INDEX IF NOT EXISTS occurrences_severity_idx ON occurrences (project_name, severity);
+		CREATE INDEX IF NOT EXISTS occurrences_data_idx ON occurrences USING GIN (data jsonb_path_ops);`
 
 	insertProject = `INSERT INTO projects(name) VALUES ($1)`
 	projectExists = `SELECT EXISTS (SELECT 1 FROM projects WHERE name = $1)`
@@ -49,29 +93,133 @@ const (
 	listProjects  = `SELECT id, name FROM projects WHERE id > $1 LIMIT $2`
 	projectCount  = `SELECT COUNT(*) FROM projects`
 
+	allNotesCount       = `SELECT COUNT(*) FROM notes`
+	allOccurrencesCount = `SELECT COUNT(*) FROM occurrences`
+
 	insertOccurrence = `INSERT INTO occurrences(project_name, occurrence_name, note_id, data)
                       VALUES ($1, $2, (SELECT id FROM notes WHERE project_name = $3 AND note_name = $4), $5)`
 	searchOccurrence = `SELECT data FROM occurrences WHERE project_name = $1 AND occurrence_name = $2`
 	updateOccurrence = `UPDATE occurrences SET data = $1 WHERE project_name = $2 AND occurrence_name = $3`
 	deleteOccurrence = `DELETE FROM occurrences WHERE project_name = $1 AND occurrence_name = $2`
-	listOccurrences  = `SELECT id, data FROM occurrences WHERE project_name = $1 AND id > $2 LIMIT $3`
+	listOccurrences  = `SELECT id, data FROM occurrences WHERE project_name = $1 AND id > $2 %s ORDER BY id LIMIT $3`
 	occurrenceCount  = `SELECT COUNT(*) FROM occurrences WHERE project_name = $1`
 
 	insertNote          = `INSERT INTO notes(project_name, note_name, data) VALUES ($1, $2, $3)`
 	searchNote          = `SELECT data FROM notes WHERE project_name = $1 AND note_name = $2`
 	updateNote          = `UPDATE notes SET data = $1 WHERE project_name = $2 AND note_name = $3`
 	deleteNote          = `DELETE FROM notes WHERE project_name = $1 AND note_name = $2`
-	listNotes           = `SELECT id, data FROM notes WHERE project_name = $1 AND id > $2 LIMIT $3`
+	listNotes           = `SELECT id, data FROM notes WHERE project_name = $1 AND id > $2 %s ORDER BY id LIMIT $3`
 	noteCount           = `SELECT COUNT(*) FROM notes WHERE project_name = $1`
 	listNoteOccurrences = `SELECT o.id, o.data FROM occurrences as o, notes as n
 	                         WHERE n.id = o.note_id
 	                           AND n.project_name = $1
 	                           AND n.note_name = $2
-	                           AND o.id > $3
+	                           AND o.id > $3 %s
+	                           ORDER BY o.id
 	                           LIMIT $4`
 
+	// The import queries store notes and occurrences exactly as exported, replacing existing ones
+	// so that imports can be repeated.
+	importNote = `INSERT INTO notes(project_name, note_name, data) VALUES ($1, $2, $3)
+	              ON CONFLICT (project_name, note_name) DO UPDATE SET data = excluded.data`
+	importOccurrence = `INSERT INTO occurrences(project_name, occurrence_name, note_id, data)
+	                    VALUES ($1, $2, (SELECT id FROM notes WHERE project_name = $3 AND note_name = $4), $5)
+	                    ON CONFLICT (project_name, occurrence_name) DO UPDATE SET note_id = excluded.note_id, data = excluded.data`
+
+	// The IAM policies of notes and occurrences are keyed by resource name. Their base64 etags are
+	// kept in a column of their own, so that policies can be replaced only if they did not change.
+	searchIAMPolicy = `SELECT data FROM iam_policies WHERE resource = $1`
+	insertIAMPolicy = `INSERT INTO iam_policies(resource, etag, data) VALUES ($1, $2, $3)`
+	updateIAMPolicy = `UPDATE iam_policies SET etag = $1, data = $2 WHERE resource = $3 AND etag = $4`
+	upsertIAMPolicy = `INSERT INTO iam_policies(resource, etag, data) VALUES ($1, $2, $3)
+	                       ON CONFLICT (resource) DO UPDATE SET etag = excluded.etag, data = excluded.data`
+	deleteIAMPolicy = `DELETE FROM iam_policies WHERE resource = $1`
+
+	// The names of deleted notes, whose bindings in a policy file are revoked.
+	insertPurgedNote = `INSERT INTO purged_notes(note_name) VALUES ($1) ON CONFLICT (note_name) DO NOTHING`
+	listPurgedNotes  = `SELECT note_name FROM purged_notes ORDER BY note_name`
+	deletePurgedNote = `DELETE FROM purged_notes WHERE note_name = $1`
+
+	// Audit entries are only ever appended, and listed in the order they were written.
+	insertAuditEntry = `INSERT INTO audit_entries(project_name, data) VALUES ($1, $2)`
+	listAuditEntries = `SELECT id, data FROM audit_entries WHERE project_name = $1 AND id > $2 ORDER BY id LIMIT $3`
+
 	noteOccurrencesCount = `SELECT COUNT(*) FROM occurrences as o, notes as n
 	                         WHERE n.id = o.note_id
 	                           AND n.project_name = $1
 	                           AND n.note_name = $2`
+
+	// vulnerabilitySummary counts vulnerability occurrences per resource and severity. An
+	// occurrence is fixable if any of its package issues has a fixed version.
+	vulnerabilitySummary = `SELECT resource_uri, COALESCE(severity, ''), COUNT(*),
+	                          COUNT(*) FILTER (WHERE jsonb_path_exists(data,
+	                            '$.vulnerability.packageIssue[*].fixedLocation.version ? (@.kind != "MAXIMUM")'))
+	                          FROM occurrences
+	                          WHERE project_name = $1 AND kind = 'VULNERABILITY' %s
+	                          GROUP BY resource_uri, severity
+	                          ORDER BY resource_uri, severity`
+
+	// createSQLiteTables is the SQLite equivalent of createTables and createIndexes. Data is stored
+	// as JSON text with the filtered fields extracted into generated columns.
+	createSQLiteTables = `
+		CREATE TABLE IF NOT EXISTS projects (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE
+		);
+		CREATE TABLE IF NOT EXISTS notes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			project_name TEXT NOT NULL,
+			note_name TEXT NOT NULL,
+			data TEXT,
+			kind TEXT GENERATED ALWAYS AS (json_extract(data, '$.kind')) VIRTUAL,
+			UNIQUE (project_name, note_name)
+		);
+		CREATE TABLE IF NOT EXISTS occurrences (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			project_name TEXT NOT NULL,
+			occurrence_name TEXT NOT NULL,
+			data TEXT,
+			note_id INTEGER NOT NULL REFERENCES notes,
+			kind TEXT GENERATED ALWAYS AS (json_extract(data, '$.kind')) VIRTUAL,
+			resource_uri TEXT GENERATED ALWAYS AS (json_extract(data, '$.resource.uri')) VIRTUAL,
+			note_name TEXT GENERATED ALWAYS AS (json_extract(data, '$.noteName')) VIRTUAL,
+			severity TEXT GENERATED ALWAYS AS (json_extract(data, '$.vulnerability.effectiveSeverity')) VIRTUAL,
+			UNIQUE (project_name, occurrence_name)
+		);
+		CREATE TABLE IF NOT EXISTS operations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			project_name TEXT NOT NULL,
+			operation_name TEXT NOT NULL,
+			data TEXT,
+			UNIQUE (project_name, operation_name)
+		);
+		CREATE TABLE IF NOT EXISTS iam_policies (
+			resource TEXT PRIMARY KEY,
+			etag TEXT NOT NULL,
+			data TEXT
+		);
+		CREATE TABLE IF NOT EXISTS purged_notes (
+			note_name TEXT PRIMARY KEY
+		);
+		CREATE TABLE IF NOT EXISTS audit_entries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			project_name TEXT NOT NULL,
+			data TEXT
+		);
+		CREATE INDEX IF NOT EXISTS audit_entries_project_idx ON audit_entries (project_name, id);
+		CREATE INDEX IF NOT EXISTS notes_kind_idx ON notes (project_name, kind);
+		CREATE INDEX IF NOT EXISTS occurrences_kind_idx ON occurrences (project_name, kind);
+		CREATE INDEX IF NOT EXISTS occurrences_resource_uri_idx ON occurrences (project_name, resource_uri);
+		CREATE INDEX IF NOT EXISTS occurrences_note_id_idx ON occurrences (note_id);
+		CREATE INDEX IF NOT EXISTS occurrences_note_name_idx ON occurrences (note_name);
+		CREATE INDEX IF NOT EXISTS occurrences_severity_idx ON occurrences (project_name, severity);`
+
+	sqliteVulnerabilitySummary = `SELECT resource_uri, COALESCE(severity, ''), COUNT(*),
+	                                COUNT(*) FILTER (WHERE EXISTS (
+	                                  SELECT 1 FROM json_each(data, '$.vulnerability.packageIssue')
+	                                  WHERE json_extract(value, '$.fixedLocation.version.kind') <> 'MAXIMUM'))
+	                                FROM occurrences
+	                                WHERE project_name = $1 AND kind = 'VULNERABILITY' %s
+	                                GROUP BY resource_uri, severity
+	                                ORDER BY resource_uri, severity`
 )
@@ -0,0 +1,206 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	expr "github.com/grafeas/grafeas/cel"
+	"github.com/grafeas/grafeas/go/filtering/common"
+	"github.com/grafeas/grafeas/go/filtering/operators"
+	"github.com/grafeas/grafeas/go/filtering/parser"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// occurrenceFilterColumns maps the fields that can be used in occurrence list filters to the
+	// indexed columns that hold them.
+	occurrenceFilterColumns = map[string]string{
+		"kind":                            "kind",
+		"resource.uri":                    "resource_uri",
+		"resourceUrl":                     "resource_uri",
+		"noteName":                        "note_name",
+		"severity":                        "severity",
+		"vulnerability.effectiveSeverity": "severity",
+	}
+
+	// noteFilterColumns maps the fields that can be used in note list filters to the indexed
+	// columns that hold them.
+	noteFilterColumns = map[string]string{
+		"kind": "kind",
+	}
+)
+
+// sqlFilter compiles list filters into SQL conditions over a fixed set of columns. Only equality
+// and inequality restrictions combined with AND, OR and NOT are supported; anything else is
+// rejected as an invalid argument rather than silently ignored.
+type sqlFilter struct {
+	// columns maps filter field names to SQL column names.
+	columns map[string]string
+	// placeholder returns the bind parameter for the n-th (1-based) argument of a statement.
+	placeholder func(n int) string
+}
+
+// dollarPlaceholder returns PostgreSQL style bind parameters ($1, $2, ...).
+func dollarPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// qualifiedColumns returns a copy of columns with every column prefixed by the table alias.
+func qualifiedColumns(columns map[string]string, alias string) map[string]string {
+	q := make(map[string]string, len(columns))
+	for field, column := range columns {
+		q[field] = alias + "." + column
+	}
+	return q
+}
+
+// compile returns the SQL condition for filter and its arguments. Bind parameters are numbered
+// starting after argOffset so the condition can be appended to a statement that already has
+// arguments. An empty filter compiles to an empty condition.
+func (f *sqlFilter) compile(filter string, argOffset int) (string, []interface{}, error) {
+	if strings.TrimSpace(filter) == "" {
+		return "", nil, nil
+	}
+	parsed, errs := parser.Parse(common.NewStringSource(filter, "filter"))
+	if errs != nil {
+		return "", nil, status.Errorf(codes.InvalidArgument, "invalid filter %q: %s", filter, errs)
+	}
+	c := &filterCompilation{sqlFilter: f, argOffset: argOffset}
+	cond, err := c.visit(parsed.Expr)
+	if err != nil {
+		return "", nil, status.Errorf(codes.InvalidArgument, "unsupported filter %q: %v", filter, err)
+	}
+	return cond, c.args, nil
+}
+
+// whereAnd is like compile, but returns the condition prefixed with AND so that it can be
+// appended to an existing WHERE clause.
+func (f *sqlFilter) whereAnd(filter string, argOffset int) (string, []interface{}, error) {
+	cond, args, err := f.compile(filter, argOffset)
+	if err != nil || cond == "" {
+		return "", nil, err
+	}
+	return "AND " + cond, args, nil
+}
+
+// filterCompilation holds the state of a single compile call.
+type filterCompilation struct {
+	*sqlFilter
+	argOffset int
+	args      []interface{}
+}
+
+func (c *filterCompilation) visit(e *expr.Expr) (string, error) {
+	call := e.GetCallExpr()
+	if call == nil {
+		return "", fmt.Errorf("expected a restriction, got %q", fieldPath(e))
+	}
+	switch call.Function {
+	case operators.LogicalAnd, operators.Sequence:
+		return c.join(call.Args, " AND ")
+	case operators.LogicalOr:
+		return c.join(call.Args, " OR ")
+	case operators.LogicalNot, operators.Negate:
+		if len(call.Args) != 1 {
+			return "", fmt.Errorf("negation takes exactly one argument")
+		}
+		cond, err := c.visit(call.Args[0])
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + cond, nil
+	case operators.Equals:
+		return c.restriction(call.Args, "=")
+	case operators.NotEquals:
+		return c.restriction(call.Args, "<>")
+	}
+	return "", fmt.Errorf("operator %q is not supported", call.Function)
+}
+
+func (c *filterCompilation) join(args []*expr.Expr, op string) (string, error) {
+	conds := make([]string, 0, len(args))
+	for _, a := range args {
+		cond, err := c.visit(a)
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, cond)
+	}
+	return "(" + strings.Join(conds, op) + ")", nil
+}
+
+func (c *filterCompilation) restriction(args []*expr.Expr, op string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("restriction %q takes exactly two arguments", op)
+	}
+	field := fieldPath(args[0])
+	column, ok := c.columns[field]
+	if !ok {
+		return "", fmt.Errorf("field %q cannot be filtered on", field)
+	}
+	value, err := constValue(args[1])
+	if err != nil {
+		return "", err
+	}
+	c.args = append(c.args, value)
+	ph := c.placeholder(c.argOffset + len(c.args))
+	if op == "<>" {
+		// Rows without the field never equal the value.
+		return fmt.Sprintf("(%s IS NULL OR %s <> %s)", column, column, ph), nil
+	}
+	return fmt.Sprintf("%s = %s", column, ph), nil
+}
+
+// fieldPath returns the dotted field path of an identifier or select expression, or the empty
+// string if e is neither.
+func fieldPath(e *expr.Expr) string {
+	switch {
+	case e.GetIdentExpr() != nil:
+		return e.GetIdentExpr().Name
+	case e.GetSelectExpr() != nil:
+		operand := fieldPath(e.GetSelectExpr().Operand)
+		if operand == "" {
+			return ""
+		}
+		return operand + "." + e.GetSelectExpr().Field
+	}
+	return ""
+}
+
+// constValue returns the value of the right hand side of a restriction. Barewords are treated
+// as strings so that enum values such as kind = VULNERABILITY do not need quoting.
+func constValue(e *expr.Expr) (string, error) {
+	if ident := e.GetIdentExpr(); ident != nil {
+		return ident.Name, nil
+	}
+	c := e.GetConstExpr()
+	if c == nil {
+		return "", fmt.Errorf("expected a constant value")
+	}
+	switch v := c.ConstantKind.(type) {
+	case *expr.Constant_StringValue:
+		return v.StringValue, nil
+	case *expr.Constant_Int64Value:
+		return fmt.Sprint(v.Int64Value), nil
+	case *expr.Constant_Uint64Value:
+		return fmt.Sprint(v.Uint64Value), nil
+	case *expr.Constant_DoubleValue:
+		return fmt.Sprint(v.DoubleValue), nil
+	}
+	return "", fmt.Errorf("unsupported constant %v", c)
+}
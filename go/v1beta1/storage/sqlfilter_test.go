@@ -0,0 +1,114 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSQLFilterCompile(t *testing.T) {
+	f := &sqlFilter{columns: occurrenceFilterColumns, placeholder: dollarPlaceholder}
+	tests := []struct {
+		desc      string
+		filter    string
+		argOffset int
+		wantCond  string
+		wantArgs  []interface{}
+	}{
+		{
+			desc:   "empty filter",
+			filter: "  ",
+		},
+		{
+			desc:      "single equality",
+			filter:    `kind = "VULNERABILITY"`,
+			argOffset: 3,
+			wantCond:  "kind = $4",
+			wantArgs:  []interface{}{"VULNERABILITY"},
+		},
+		{
+			desc:     "bareword value and select field",
+			filter:   `resource.uri = "https://example.com" AND severity = HIGH`,
+			wantCond: "(resource_uri = $1 AND severity = $2)",
+			wantArgs: []interface{}{"https://example.com", "HIGH"},
+		},
+		{
+			desc:     "disjunction and negation",
+			filter:   `noteName = "projects/p/notes/n" OR NOT kind != BUILD`,
+			wantCond: "(note_name = $1 OR NOT (kind IS NULL OR kind <> $2))",
+			wantArgs: []interface{}{"projects/p/notes/n", "BUILD"},
+		},
+		{
+			desc:     "sequence is a conjunction",
+			filter:   `kind = BUILD resourceUrl = "r"`,
+			wantCond: "(kind = $1 AND resource_uri = $2)",
+			wantArgs: []interface{}{"BUILD", "r"},
+		},
+	}
+
+	for _, tt := range tests {
+		cond, args, err := f.compile(tt.filter, tt.argOffset)
+		if err != nil {
+			t.Errorf("%q: compile(%q) got error %v, want success", tt.desc, tt.filter, err)
+			continue
+		}
+		if cond != tt.wantCond {
+			t.Errorf("%q: compile(%q) got condition %q, want %q", tt.desc, tt.filter, cond, tt.wantCond)
+		}
+		if diff := cmp.Diff(tt.wantArgs, args); diff != "" {
+			t.Errorf("%q: compile(%q) returned diff in args (want -> got):\n%s", tt.desc, tt.filter, diff)
+		}
+	}
+}
+
+func TestSQLFilterCompileErrors(t *testing.T) {
+	f := &sqlFilter{columns: occurrenceFilterColumns, placeholder: dollarPlaceholder}
+	for _, filter := range []string{
+		`unknownField = "x"`,
+		`kind > BUILD`,
+		`kind:*`,
+		`kind`,
+		`kind = (`,
+	} {
+		if _, _, err := f.compile(filter, 0); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("compile(%q) got error %v, want %v", filter, err, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestQualifiedColumns(t *testing.T) {
+	f := &sqlFilter{columns: qualifiedColumns(occurrenceFilterColumns, "o"), placeholder: dollarPlaceholder}
+	cond, _, err := f.whereAnd(`kind = VULNERABILITY`, 4)
+	if err != nil {
+		t.Fatalf("whereAnd got error %v, want success", err)
+	}
+	if want := "AND o.kind = $5"; cond != want {
+		t.Errorf("whereAnd got %q, want %q", cond, want)
+	}
+}
+
+func TestSQLFilterWhereAndRejectsUnsupportedFilters(t *testing.T) {
+	f := &sqlFilter{columns: occurrenceFilterColumns, placeholder: dollarPlaceholder}
+	// A partly supported filter would widen the results if its unsupported part were dropped.
+	for _, filter := range []string{`filters_are_yet_to_be_implemented`, `kind = VULNERABILITY AND foo = "y"`} {
+		if _, _, err := f.whereAnd(filter, 0); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("whereAnd(%q) got %v, want InvalidArgument", filter, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/grafeas/grafeas/go/config"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var dollarParam = regexp.MustCompile(`\$(\d+)`)
+
+// sqliteDialect is the dialect of SQLite. Bind parameters are numbered (?1, ?2, ...) rather than
+// named, as SQLite numbers named parameters by their first appearance in a statement.
+var sqliteDialect = &sqlDialect{
+	system: semconv.DBSystemSqlite,
+	rebind: func(query string) string {
+		return dollarParam.ReplaceAllString(query, "?$1")
+	},
+	vulnerabilitySummary: sqliteVulnerabilitySummary,
+	isUniqueViolation: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		if !errors.As(err, &sqliteErr) {
+			return false
+		}
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	},
+	isStatementTimeout: func(err error) bool { return false },
+}
+
+// SQLiteStore is a storage solution for Grafeas based on SQLite, for single node deployments and
+// tests. It shares its SQL layer with PgSQLStore.
+type SQLiteStore struct {
+	*sql.DB
+	*sqlStore
+}
+
+// NewSQLiteStore opens, and creates if necessary, the SQLite database configured in config.
+func NewSQLiteStore(config *config.SQLiteConfig) (*SQLiteStore, error) {
+	paginationKey, err := validatePaginationKey(config.PaginationKey)
+	if err != nil {
+		return nil, err
+	}
+
+	path := config.Path
+	inMemory := path == "" || path == ":memory:"
+	if inMemory {
+		path = ":memory:"
+	} else if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create database directory, %s", err)
+	}
+	// Pragmas are applied to every connection the pool opens.
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	if config.WAL && !inMemory {
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+	db, err := sql.Open("sqlite", path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if inMemory {
+		// Every connection to :memory: opens a new, empty database, so the pool must hold on to a
+		// single connection.
+		db.SetMaxOpenConns(1)
+	}
+	if _, err := db.Exec(createSQLiteTables); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{
+		DB: db,
+		sqlStore: &sqlStore{
+			db:            db,
+			paginationKey: paginationKey,
+			dialect:       sqliteDialect,
+		},
+	}, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafeas/grafeas/go/config"
+	grafeas "github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"github.com/grafeas/grafeas/go/v1beta1/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBetaSQLiteStoreInMemory(t *testing.T) {
+	createSQLiteStore := func(t *testing.T) (grafeas.Storage, project.Storage, func()) {
+		t.Helper()
+		s, err := storage.NewSQLiteStore(&config.SQLiteConfig{Path: ":memory:"})
+		if err != nil {
+			t.Fatalf("Error creating SQLiteStore, %s", err)
+		}
+		return s, s, func() { s.Close() }
+	}
+
+	storage.DoTestStorage(t, createSQLiteStore)
+	storage.DoTestFilters(t, createSQLiteStore)
+}
+
+func TestBetaSQLiteStoreWAL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlitestore")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var instance int32
+	storage.DoTestStorage(t, func(t *testing.T) (grafeas.Storage, project.Storage, func()) {
+		t.Helper()
+		path := filepath.Join(dir, strconv.Itoa(int(atomic.AddInt32(&instance, 1))), "grafeas.db")
+		s, err := storage.NewSQLiteStore(&config.SQLiteConfig{
+			Path:          path,
+			WAL:           true,
+			PaginationKey: "XxoPtCUzrUv4JV5dS+yQ+MdW7yLEJnRMwigVY/bpgtQ=",
+		})
+		if err != nil {
+			t.Fatalf("Error creating SQLiteStore, %s", err)
+		}
+		var mode string
+		if err := s.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+			t.Errorf("journal_mode got %q, %v, want wal", mode, err)
+		}
+		return s, s, func() { s.Close() }
+	})
+}
+
+func TestBetaSQLiteStoreWithInvalidPaginationKey(t *testing.T) {
+	s, err := storage.NewSQLiteStore(&config.SQLiteConfig{PaginationKey: "INVALID_VALUE"})
+	if s != nil {
+		s.Close()
+	}
+	if err == nil {
+		t.Errorf("expected error for invalid pagination key; got none")
+	}
+}
+
+func TestBetaSQLiteStoreTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	s, err := storage.NewSQLiteStore(&config.SQLiteConfig{Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("Error creating SQLiteStore, %s", err)
+	}
+	defer s.Close()
+	if _, err := s.GetNote(context.Background(), "p", "n"); status.Code(err) != codes.NotFound {
+		t.Fatalf("GetNote got %v want NotFound", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) == 0 {
+		t.Fatalf("GetNote recorded no spans")
+	}
+	want := map[attribute.Key]string{"db.system": "sqlite", "db.operation": "SELECT"}
+	for _, a := range spans[len(spans)-1].Attributes() {
+		if v, ok := want[a.Key]; ok && a.Value.AsString() == v {
+			delete(want, a.Key)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("GetNote span got attributes %v, missing %v", spans[len(spans)-1].Attributes(), want)
+	}
+}
@@ -0,0 +1,958 @@
+// Copyright 2022 The Grafeas Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fernet/fernet-go"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/uuid"
+	"github.com/grafeas/grafeas/go/name"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
+	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
+	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
+	vpb "github.com/grafeas/grafeas/proto/v1beta1/vulnerability_go_proto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	fieldmaskpb "google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	occurrenceFilter     = &sqlFilter{columns: occurrenceFilterColumns, placeholder: dollarPlaceholder}
+	noteFilter           = &sqlFilter{columns: noteFilterColumns, placeholder: dollarPlaceholder}
+	noteOccurrenceFilter = &sqlFilter{columns: qualifiedColumns(occurrenceFilterColumns, "o"), placeholder: dollarPlaceholder}
+
+	// sqlTracer records a span for every statement, with the tracer provider registered with
+	// otel.SetTracerProvider.
+	sqlTracer = otel.Tracer("github.com/grafeas/grafeas/go/v1beta1/storage")
+)
+
+// sqlDialect describes how a SQL database differs from the PostgreSQL dialect the statements in
+// queries.go are written in.
+type sqlDialect struct {
+	// system identifies the database in traces.
+	system attribute.KeyValue
+	// rebind translates the $1, $2, ... bind parameters of a statement for the database.
+	rebind func(query string) string
+	// vulnerabilitySummary is the statement counting vulnerabilities per resource and severity.
+	vulnerabilitySummary string
+	// isUniqueViolation reports whether err is a unique constraint violation.
+	isUniqueViolation func(err error) bool
+	// isStatementTimeout reports whether err was raised because a statement timed out.
+	isStatementTimeout func(err error) bool
+}
+
+// sqlStore implements grafeas.Storage and project.Storage on top of a SQL database. Notes and
+// occurrences are stored as JSON with the fields used in filters in indexed columns. Database
+// specific stores embed it.
+type sqlStore struct {
+	db            *sql.DB
+	paginationKey string
+	dialect       *sqlDialect
+}
+
+// validatePaginationKey returns key if it is a valid pagination key, or a newly generated key if
+// key is empty.
+func validatePaginationKey(key string) (string, error) {
+	if key == "" {
+		log.Println("pagination key is empty, generating...")
+		var k fernet.Key
+		if err := k.Generate(); err != nil {
+			return "", fmt.Errorf("failed to generate pagination key, %s", err)
+		}
+		return k.Encode(), nil
+	}
+	if _, err := fernet.DecodeKey(key); err != nil {
+		return "", errors.New("invalid pagination key; must be 256-bit URL-safe base64")
+	}
+	return key, nil
+}
+
+// CreateProject adds the specified project to the store
+func (s *sqlStore) CreateProject(ctx context.Context, pID string, p *prpb.Project) (*prpb.Project, error) {
+	_, err := s.exec(ctx, insertProject, name.FormatProject(pID))
+	if s.dialect.isUniqueViolation(err) {
+		return nil, status.Errorf(codes.AlreadyExists, "Project with name %q already exists", pID)
+	} else if err != nil {
+		log.Println("Failed to insert Project in database", err)
+		return nil, s.queryError(ctx, err, "Failed to insert Project in database")
+	}
+	return p, nil
+}
+
+// DeleteProject deletes the project with the given pID from the store
+func (s *sqlStore) DeleteProject(ctx context.Context, pID string) error {
+	pName := name.FormatProject(pID)
+	result, err := s.exec(ctx, deleteProject, pName)
+	if err != nil {
+		return s.queryError(ctx, err, "Failed to delete Project from database")
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return s.queryError(ctx, err, "Failed to delete Project from database")
+	}
+	if count == 0 {
+		return status.Errorf(codes.NotFound, "Project with name %q does not Exist", pName)
+	}
+	return nil
+}
+
+// GetProject returns the project with the given pID from the store
+func (s *sqlStore) GetProject(ctx context.Context, pID string) (*prpb.Project, error) {
+	pName := name.FormatProject(pID)
+	var exists bool
+	err := s.queryRow(ctx, projectExists, pName).Scan(&exists)
+	if err != nil {
+		return nil, s.queryError(ctx, err, "Failed to query Project from database")
+	}
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "Project with name %q does not Exist", pName)
+	}
+	return &prpb.Project{Name: pName}, nil
+}
+
+// ListProjects returns up to pageSize number of projects beginning at pageToken (or from
+// start if pageToken is the empty string).
+func (s *sqlStore) ListProjects(ctx context.Context, filter string, pageSize int, pageToken string) ([]*prpb.Project, string, error) {
+	count, err := s.count(ctx, projectCount)
+	if err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to count Projects from database")
+	}
+	id := decryptInt64(pageToken, s.paginationKey, 0)
+	rows, err := s.query(ctx, listProjects, id, pageSize)
+	if err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to list Projects from database")
+	}
+	defer rows.Close()
+	var projects []*prpb.Project
+	var lastID int64
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&lastID, &name)
+		if err != nil {
+			return nil, "", s.queryError(ctx, err, "Failed to scan Project row")
+		}
+		projects = append(projects, &prpb.Project{Name: name})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to list Projects from database")
+	}
+	if count == lastID {
+		return projects, "", nil
+	}
+	encryptedPage, err := encryptInt64(lastID, s.paginationKey)
+	if err != nil {
+		return nil, "", status.Error(codes.Internal, "Failed to paginate projects")
+	}
+	return projects, encryptedPage, nil
+}
+
+// CreateOccurrence adds the specified occurrence
+func (s *sqlStore) CreateOccurrence(ctx context.Context, pID, uID string, o *pb.Occurrence) (*pb.Occurrence, error) {
+	o = proto.Clone(o).(*pb.Occurrence)
+	o.CreateTime = ptypes.TimestampNow()
+
+	var id string
+	if nr, err := uuid.NewRandom(); err != nil {
+		return nil, status.Error(codes.Internal, "Failed to generate UUID")
+	} else {
+		id = nr.String()
+	}
+	o.Name = fmt.Sprintf("projects/%s/occurrences/%s", pID, id)
+
+	nPID, nID, err := name.ParseNote(o.NoteName)
+	if err != nil {
+		log.Printf("Invalid note name: %v", o.NoteName)
+		return nil, status.Error(codes.InvalidArgument, "Invalid note name")
+	}
+	data, err := marshalData(o)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to marshal Occurrence")
+	}
+	_, err = s.exec(ctx, insertOccurrence, pID, id, nPID, nID, data)
+	if s.dialect.isUniqueViolation(err) {
+		return nil, status.Errorf(codes.AlreadyExists, "Occurrence with name %q already exists", o.Name)
+	} else if err != nil {
+		log.Println("Failed to insert Occurrence in database", err)
+		return nil, s.queryError(ctx, err, "Failed to insert Occurrence in database")
+	}
+	return o, nil
+}
+
+// ImportOccurrence stores o with its name and timestamps, replacing any existing occurrence with
+// the same name.
+func (s *sqlStore) ImportOccurrence(ctx context.Context, o *pb.Occurrence) error {
+	pID, oID, err := name.ParseOccurrence(o.Name)
+	if err != nil {
+		return err
+	}
+	nPID, nID, err := name.ParseNote(o.NoteName)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Invalid note name %q of occurrence %q", o.NoteName, o.Name)
+	}
+	data, err := marshalData(o)
+	if err != nil {
+		return status.Error(codes.Internal, "Failed to marshal Occurrence")
+	}
+	if _, err := s.exec(ctx, importOccurrence, pID, oID, nPID, nID, data); err != nil {
+		log.Println("Failed to import Occurrence in database", err)
+		return s.queryError(ctx, err, "Failed to import Occurrence in database")
+	}
+	return nil
+}
+
+// BatchCreateOccurrences batch creates the specified occurrences.
+func (s *sqlStore) BatchCreateOccurrences(ctx context.Context, pID string, uID string, occs []*pb.Occurrence) ([]*pb.Occurrence, []error) {
+	clonedOccs := []*pb.Occurrence{}
+	for _, o := range occs {
+		clonedOccs = append(clonedOccs, proto.Clone(o).(*pb.Occurrence))
+	}
+	occs = clonedOccs
+
+	errs := []error{}
+	created := []*pb.Occurrence{}
+	for _, o := range occs {
+		occ, err := s.CreateOccurrence(ctx, pID, uID, o)
+		if err != nil {
+			// Occurrence already exists, skipping.
+			continue
+		} else {
+			created = append(created, occ)
+		}
+	}
+
+	return created, errs
+}
+
+// DeleteOccurrence deletes the occurrence with the given pID and oID
+func (s *sqlStore) DeleteOccurrence(ctx context.Context, pID, oID string) error {
+	result, err := s.exec(ctx, deleteOccurrence, pID, oID)
+	if err != nil {
+		return s.queryError(ctx, err, "Failed to delete Occurrence from database")
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return s.queryError(ctx, err, "Failed to delete Occurrence from database")
+	}
+	if count == 0 {
+		return status.Errorf(codes.NotFound, "Occurrence with name %q/%q does not Exist", pID, oID)
+	}
+	return nil
+}
+
+// UpdateOccurrence updates the existing occurrence with the given projectID and occurrenceID
+func (s *sqlStore) UpdateOccurrence(ctx context.Context, pID, oID string, o *pb.Occurrence, mask *fieldmaskpb.FieldMask) (*pb.Occurrence, error) {
+	o = proto.Clone(o).(*pb.Occurrence)
+	// TODO(#312): implement the update operation
+	o.UpdateTime = ptypes.TimestampNow()
+
+	data, err := marshalData(o)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to marshal Occurrence")
+	}
+	result, err := s.exec(ctx, updateOccurrence, data, pID, oID)
+	if err != nil {
+		return nil, s.queryError(ctx, err, "Failed to update Occurrence")
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, s.queryError(ctx, err, "Failed to update Occurrence")
+	}
+	if count == 0 {
+		return nil, status.Errorf(codes.NotFound, "Occurrence with name %q/%q does not Exist", pID, oID)
+	}
+	return o, nil
+}
+
+// GetOccurrence returns the occurrence with pID and oID
+func (s *sqlStore) GetOccurrence(ctx context.Context, pID, oID string) (*pb.Occurrence, error) {
+	var data string
+	err := s.queryRow(ctx, searchOccurrence, pID, oID).Scan(&data)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, status.Errorf(codes.NotFound, "Occurrence with name %q/%q does not Exist", pID, oID)
+	case err != nil:
+		return nil, s.queryError(ctx, err, "Failed to query Occurrence from database")
+	}
+	var o pb.Occurrence
+	if err := unmarshalData(data, &o); err != nil {
+		return nil, status.Error(codes.Internal, "Failed to unmarshal Occurrence from database")
+	}
+	// Set the output-only field before returning
+	o.Name = name.FormatOccurrence(pID, oID)
+	return &o, nil
+}
+
+// ListOccurrences returns up to pageSize number of occurrences for this project beginning
+// at pageToken, or from start if pageToken is the empty string.
+func (s *sqlStore) ListOccurrences(ctx context.Context, pID, filter, pageToken string, pageSize int32) ([]*pb.Occurrence, string, error) {
+	count, err := s.count(ctx, occurrenceCount, pID)
+	if err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to count Occurrences from database")
+	}
+	where, filterArgs, err := occurrenceFilter.whereAnd(filter, 3)
+	if err != nil {
+		return nil, "", err
+	}
+	id := decryptInt64(pageToken, s.paginationKey, 0)
+	args := append([]interface{}{pID, id, pageSize}, filterArgs...)
+	rows, err := s.query(ctx, fmt.Sprintf(listOccurrences, where), args...)
+	if err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to list Occurrences from database")
+	}
+	defer rows.Close()
+
+	var os []*pb.Occurrence
+	var lastID int64
+	for rows.Next() {
+		var data string
+		err := rows.Scan(&lastID, &data)
+		if err != nil {
+			return nil, "", s.queryError(ctx, err, "Failed to scan Occurrences row")
+		}
+		var o pb.Occurrence
+		if err := unmarshalData(data, &o); err != nil {
+			return nil, "", status.Error(codes.Internal, "Failed to unmarshal Occurrence from database")
+		}
+		os = append(os, &o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to list Occurrences from database")
+	}
+	// A short page means there are no more rows matching the filter.
+	if count == lastID || len(os) < int(pageSize) {
+		return os, "", nil
+	}
+	encryptedPage, err := encryptInt64(lastID, s.paginationKey)
+	if err != nil {
+		return nil, "", status.Error(codes.Internal, "Failed to paginate projects")
+	}
+	return os, encryptedPage, nil
+}
+
+// CreateNote adds the specified note
+func (s *sqlStore) CreateNote(ctx context.Context, pID, nID, uID string, n *pb.Note) (*pb.Note, error) {
+	n = proto.Clone(n).(*pb.Note)
+	nName := name.FormatNote(pID, nID)
+	n.Name = nName
+	n.CreateTime = ptypes.TimestampNow()
+
+	data, err := marshalData(n)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to marshal Note")
+	}
+	_, err = s.exec(ctx, insertNote, pID, nID, data)
+	if s.dialect.isUniqueViolation(err) {
+		return nil, status.Errorf(codes.AlreadyExists, "Note with name %q already exists", n.Name)
+	} else if err != nil {
+		log.Println("Failed to insert Note in database", err)
+		return nil, s.queryError(ctx, err, "Failed to insert Note in database")
+	}
+	return n, nil
+}
+
+// ImportNote stores n with its name and timestamps, replacing any existing note with the same
+// name.
+func (s *sqlStore) ImportNote(ctx context.Context, n *pb.Note) error {
+	pID, nID, err := name.ParseNote(n.Name)
+	if err != nil {
+		return err
+	}
+	data, err := marshalData(n)
+	if err != nil {
+		return status.Error(codes.Internal, "Failed to marshal Note")
+	}
+	if _, err := s.exec(ctx, importNote, pID, nID, data); err != nil {
+		log.Println("Failed to import Note in database", err)
+		return s.queryError(ctx, err, "Failed to import Note in database")
+	}
+	return nil
+}
+
+// BatchCreateNotes batch creates the specified notes.
+func (s *sqlStore) BatchCreateNotes(ctx context.Context, pID, uID string, notes map[string]*pb.Note) ([]*pb.Note, []error) {
+	clonedNotes := map[string]*pb.Note{}
+	for nID, n := range notes {
+		clonedNotes[nID] = proto.Clone(n).(*pb.Note)
+	}
+	notes = clonedNotes
+
+	errs := []error{}
+	created := []*pb.Note{}
+	for nID, n := range notes {
+		note, err := s.CreateNote(ctx, pID, nID, uID, n)
+		if err != nil {
+			// Note already exists, skipping.
+			continue
+		} else {
+			created = append(created, note)
+		}
+
+	}
+
+	return created, errs
+}
+
+// DeleteNote deletes the note with the given pID and nID
+func (s *sqlStore) DeleteNote(ctx context.Context, pID, nID string) error {
+	result, err := s.exec(ctx, deleteNote, pID, nID)
+	if err != nil {
+		return s.queryError(ctx, err, "Failed to delete Note from database")
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return s.queryError(ctx, err, "Failed to delete Note from database")
+	}
+	if count == 0 {
+		return status.Errorf(codes.NotFound, "Note with name %q/%q does not Exist", pID, nID)
+	}
+	return nil
+}
+
+// UpdateNote updates the existing note with the given pID and nID
+func (s *sqlStore) UpdateNote(ctx context.Context, pID, nID string, n *pb.Note, mask *fieldmaskpb.FieldMask) (*pb.Note, error) {
+	n = proto.Clone(n).(*pb.Note)
+	nName := name.FormatNote(pID, nID)
+	n.Name = nName
+	// TODO(#312): implement the update operation
+	n.UpdateTime = ptypes.TimestampNow()
+
+	data, err := marshalData(n)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Failed to marshal Note")
+	}
+	result, err := s.exec(ctx, updateNote, data, pID, nID)
+	if err != nil {
+		return nil, s.queryError(ctx, err, "Failed to update Note")
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, s.queryError(ctx, err, "Failed to update Note")
+	}
+	if count == 0 {
+		return nil, status.Errorf(codes.NotFound, "Note with name %q/%q does not Exist", pID, nID)
+	}
+	return n, nil
+}
+
+// GetNote returns the note with project (pID) and note ID (nID)
+func (s *sqlStore) GetNote(ctx context.Context, pID, nID string) (*pb.Note, error) {
+	var data string
+	err := s.queryRow(ctx, searchNote, pID, nID).Scan(&data)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, status.Errorf(codes.NotFound, "Note with name %q/%q does not Exist", pID, nID)
+	case err != nil:
+		return nil, s.queryError(ctx, err, "Failed to query Note from database")
+	}
+	var note pb.Note
+	if err := unmarshalData(data, &note); err != nil {
+		return nil, status.Error(codes.Internal, "Failed to unmarshal Note from database")
+	}
+	// Set the output-only field before returning
+	note.Name = name.FormatNote(pID, nID)
+	return &note, nil
+}
+
+// GetOccurrenceNote gets the note for the specified occurrence.
+func (s *sqlStore) GetOccurrenceNote(ctx context.Context, pID, oID string) (*pb.Note, error) {
+	o, err := s.GetOccurrence(ctx, pID, oID)
+	if err != nil {
+		return nil, err
+	}
+	nPID, nID, err := name.ParseNote(o.NoteName)
+	if err != nil {
+		log.Printf("Error parsing name: %v", o.NoteName)
+		return nil, status.Error(codes.InvalidArgument, "Invalid Note name")
+	}
+	n, err := s.GetNote(ctx, nPID, nID)
+	if err != nil {
+		return nil, err
+	}
+	// Set the output-only field before returning
+	n.Name = name.FormatNote(nPID, nID)
+	return n, nil
+}
+
+// ListNotes returns up to pageSize number of notes for this project (pID) beginning
+// at pageToken (or from start if pageToken is the empty string).
+func (s *sqlStore) ListNotes(ctx context.Context, pID, filter, pageToken string, pageSize int32) ([]*pb.Note, string, error) {
+	count, err := s.count(ctx, noteCount, pID)
+	if err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to count Notes from database")
+	}
+	where, filterArgs, err := noteFilter.whereAnd(filter, 3)
+	if err != nil {
+		return nil, "", err
+	}
+	id := decryptInt64(pageToken, s.paginationKey, 0)
+	args := append([]interface{}{pID, id, pageSize}, filterArgs...)
+	rows, err := s.query(ctx, fmt.Sprintf(listNotes, where), args...)
+	if err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to list Notes from database")
+	}
+	defer rows.Close()
+
+	var ns []*pb.Note
+	var lastID int64
+	for rows.Next() {
+		var data string
+		err := rows.Scan(&lastID, &data)
+		if err != nil {
+			return nil, "", s.queryError(ctx, err, "Failed to scan Notes row")
+		}
+		var n pb.Note
+		if err := unmarshalData(data, &n); err != nil {
+			return nil, "", status.Error(codes.Internal, "Failed to unmarshal Note from database")
+		}
+		ns = append(ns, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to list Notes from database")
+	}
+	// A short page means there are no more rows matching the filter.
+	if count == lastID || len(ns) < int(pageSize) {
+		return ns, "", nil
+	}
+	encryptedPage, err := encryptInt64(lastID, s.paginationKey)
+	if err != nil {
+		return nil, "", status.Error(codes.Internal, "Failed to paginate projects")
+	}
+	return ns, encryptedPage, nil
+}
+
+// ListNoteOccurrences returns up to pageSize number of occurrences on the particular note (nID)
+// for this project (pID) projects beginning at pageToken (or from start if pageToken is the empty string).
+func (s *sqlStore) ListNoteOccurrences(ctx context.Context, pID, nID, filter, pageToken string, pageSize int32) ([]*pb.Occurrence, string, error) {
+	// Verify that note exists
+	if _, err := s.GetNote(ctx, pID, nID); err != nil {
+		return nil, "", err
+	}
+	count, err := s.count(ctx, noteOccurrencesCount, pID, nID)
+	if err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to count Occurrences from database")
+	}
+	where, filterArgs, err := noteOccurrenceFilter.whereAnd(filter, 4)
+	if err != nil {
+		return nil, "", err
+	}
+	id := decryptInt64(pageToken, s.paginationKey, 0)
+	args := append([]interface{}{pID, nID, id, pageSize}, filterArgs...)
+	rows, err := s.query(ctx, fmt.Sprintf(listNoteOccurrences, where), args...)
+	if err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to list Occurrences from database")
+	}
+	defer rows.Close()
+
+	var os []*pb.Occurrence
+	var lastID int64
+	for rows.Next() {
+		var data string
+		err := rows.Scan(&lastID, &data)
+		if err != nil {
+			return nil, "", s.queryError(ctx, err, "Failed to scan Occurrences row")
+		}
+		var o pb.Occurrence
+		if err := unmarshalData(data, &o); err != nil {
+			return nil, "", status.Error(codes.Internal, "Failed to unmarshal Occurrence from database")
+		}
+		os = append(os, &o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to list Occurrences from database")
+	}
+	// A short page means there are no more rows matching the filter.
+	if count == lastID || len(os) < int(pageSize) {
+		return os, "", nil
+	}
+	encryptedPage, err := encryptInt64(lastID, s.paginationKey)
+	if err != nil {
+		return nil, "", status.Error(codes.Internal, "Failed to paginate projects")
+	}
+	return os, encryptedPage, nil
+}
+
+// GetVulnerabilityOccurrencesSummary gets a summary of vulnerability occurrences from storage.
+func (s *sqlStore) GetVulnerabilityOccurrencesSummary(ctx context.Context, projectID, filter string) (*pb.VulnerabilityOccurrencesSummary, error) {
+	where, filterArgs, err := occurrenceFilter.whereAnd(filter, 1)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]interface{}{projectID}, filterArgs...)
+	rows, err := s.query(ctx, fmt.Sprintf(s.dialect.vulnerabilitySummary, where), args...)
+	if err != nil {
+		return nil, s.queryError(ctx, err, "Failed to summarize Occurrences from database")
+	}
+	defer rows.Close()
+
+	summary := &pb.VulnerabilityOccurrencesSummary{}
+	// Totals across all severities are reported per resource with SEVERITY_UNSPECIFIED.
+	var totals []*pb.VulnerabilityOccurrencesSummary_FixableTotalByDigest
+	totalsByURI := map[string]*pb.VulnerabilityOccurrencesSummary_FixableTotalByDigest{}
+	for rows.Next() {
+		var uri sql.NullString
+		var severity string
+		var total, fixable int64
+		if err := rows.Scan(&uri, &severity, &total, &fixable); err != nil {
+			return nil, s.queryError(ctx, err, "Failed to scan Occurrences summary row")
+		}
+		summary.Counts = append(summary.Counts, &pb.VulnerabilityOccurrencesSummary_FixableTotalByDigest{
+			Resource:     &pb.Resource{Uri: uri.String},
+			Severity:     vpb.Severity(vpb.Severity_value[severity]),
+			FixableCount: fixable,
+			TotalCount:   total,
+		})
+		t, ok := totalsByURI[uri.String]
+		if !ok {
+			t = &pb.VulnerabilityOccurrencesSummary_FixableTotalByDigest{
+				Resource: &pb.Resource{Uri: uri.String},
+				Severity: vpb.Severity_SEVERITY_UNSPECIFIED,
+			}
+			totalsByURI[uri.String] = t
+			totals = append(totals, t)
+		}
+		t.FixableCount += fixable
+		t.TotalCount += total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.queryError(ctx, err, "Failed to summarize Occurrences from database")
+	}
+	summary.Counts = append(summary.Counts, totals...)
+	return summary, nil
+}
+
+// GetIamPolicy gets the IAM policy of the specified resource from storage, or nil if it has none.
+func (s *sqlStore) GetIamPolicy(ctx context.Context, resource string) (*iampb.Policy, error) {
+	var data string
+	err := s.queryRow(ctx, searchIAMPolicy, resource).Scan(&data)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, s.queryError(ctx, err, "Failed to query IAM policy from database")
+	}
+	var p iampb.Policy
+	if err := unmarshalData(data, &p); err != nil {
+		return nil, status.Error(codes.Internal, "Failed to unmarshal IAM policy from database")
+	}
+	return &p, nil
+}
+
+// SetIamPolicy replaces the IAM policy of the specified resource in storage, if the etag of the
+// current policy is etag or etag is nil.
+func (s *sqlStore) SetIamPolicy(ctx context.Context, resource string, p *iampb.Policy, etag []byte) error {
+	data, err := marshalData(p)
+	if err != nil {
+		return status.Error(codes.Internal, "Failed to marshal IAM policy")
+	}
+	newEtag := base64.StdEncoding.EncodeToString(p.Etag)
+	switch {
+	case etag == nil:
+		_, err = s.exec(ctx, upsertIAMPolicy, resource, newEtag, data)
+	case len(etag) == 0:
+		_, err = s.exec(ctx, insertIAMPolicy, resource, newEtag, data)
+		if err != nil && s.dialect.isUniqueViolation(err) {
+			return status.Errorf(codes.Aborted, "IAM policy of %s has been changed concurrently", resource)
+		}
+	default:
+		var result sql.Result
+		result, err = s.exec(ctx, updateIAMPolicy, newEtag, data, resource, base64.StdEncoding.EncodeToString(etag))
+		if err == nil {
+			var count int64
+			if count, err = result.RowsAffected(); err == nil && count == 0 {
+				return status.Errorf(codes.Aborted, "IAM policy of %s has been changed concurrently", resource)
+			}
+		}
+	}
+	if err != nil {
+		return s.queryError(ctx, err, "Failed to store IAM policy in database")
+	}
+	return nil
+}
+
+// DeleteIamPolicy deletes the IAM policy of the specified resource from storage.
+func (s *sqlStore) DeleteIamPolicy(ctx context.Context, resource string) error {
+	if _, err := s.exec(ctx, deleteIAMPolicy, resource); err != nil {
+		return s.queryError(ctx, err, "Failed to delete IAM policy from database")
+	}
+	return nil
+}
+
+// AddPurgedNote records the deleted note in storage.
+func (s *sqlStore) AddPurgedNote(ctx context.Context, note string) error {
+	if _, err := s.exec(ctx, insertPurgedNote, note); err != nil {
+		return s.queryError(ctx, err, "Failed to insert purged note in database")
+	}
+	return nil
+}
+
+// ListPurgedNotes lists the names of the deleted notes recorded in storage.
+func (s *sqlStore) ListPurgedNotes(ctx context.Context) ([]string, error) {
+	rows, err := s.query(ctx, listPurgedNotes)
+	if err != nil {
+		return nil, s.queryError(ctx, err, "Failed to list purged notes from database")
+	}
+	defer rows.Close()
+	notes := []string{}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, s.queryError(ctx, err, "Failed to scan purged note row")
+		}
+		notes = append(notes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.queryError(ctx, err, "Failed to list purged notes from database")
+	}
+	return notes, nil
+}
+
+// DeletePurgedNote deletes the record of the note from storage.
+func (s *sqlStore) DeletePurgedNote(ctx context.Context, note string) error {
+	if _, err := s.exec(ctx, deletePurgedNote, note); err != nil {
+		return s.queryError(ctx, err, "Failed to delete purged note from database")
+	}
+	return nil
+}
+
+// WriteAuditEntry appends the audit entry to storage.
+func (s *sqlStore) WriteAuditEntry(ctx context.Context, e *apb.AuditEntry) error {
+	data, err := marshalData(e)
+	if err != nil {
+		return status.Error(codes.Internal, "Failed to marshal audit entry")
+	}
+	if _, err := s.exec(ctx, insertAuditEntry, e.Project, data); err != nil {
+		return s.queryError(ctx, err, "Failed to insert audit entry in database")
+	}
+	return nil
+}
+
+// ListAuditEntries returns up to pageSize number of audit entries of the project (pID) beginning
+// at pageToken, oldest first.
+func (s *sqlStore) ListAuditEntries(ctx context.Context, pID, pageToken string, pageSize int32) ([]*apb.AuditEntry, string, error) {
+	id := decryptInt64(pageToken, s.paginationKey, 0)
+	rows, err := s.query(ctx, listAuditEntries, pID, id, pageSize)
+	if err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to list audit entries from database")
+	}
+	defer rows.Close()
+
+	var es []*apb.AuditEntry
+	var lastID int64
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&lastID, &data); err != nil {
+			return nil, "", s.queryError(ctx, err, "Failed to scan audit entries row")
+		}
+		var e apb.AuditEntry
+		if err := unmarshalData(data, &e); err != nil {
+			return nil, "", status.Error(codes.Internal, "Failed to unmarshal audit entry from database")
+		}
+		es = append(es, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", s.queryError(ctx, err, "Failed to list audit entries from database")
+	}
+	if len(es) < int(pageSize) {
+		return es, "", nil
+	}
+	encryptedPage, err := encryptInt64(lastID, s.paginationKey)
+	if err != nil {
+		return nil, "", status.Error(codes.Internal, "Failed to paginate audit entries")
+	}
+	return es, encryptedPage, nil
+}
+
+// marshalData encodes a note, occurrence, IAM policy or audit entry for storage in a JSON data
+// column.
+func marshalData(m proto.Message) (string, error) {
+	b, err := protojson.Marshal(proto.MessageV2(m))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// unmarshalData decodes a note, occurrence, IAM policy or audit entry from a JSON data column.
+// Unknown fields are discarded so that rows written by newer versions of the protos can still be
+// read.
+func unmarshalData(data string, m proto.Message) error {
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal([]byte(data), proto.MessageV2(m))
+}
+
+// queryError returns the error to report for a failed statement. Statements that failed because
+// the request deadline passed, the request was canceled or the statement timeout was hit are
+// reported as such, anything else as an Internal error with msg.
+func (s *sqlStore) queryError(ctx context.Context, err error, msg string) error {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return status.Errorf(codes.DeadlineExceeded, "%s: deadline exceeded", msg)
+	case ctx.Err() == context.Canceled:
+		return status.Errorf(codes.Canceled, "%s: request canceled", msg)
+	case s.dialect.isStatementTimeout(err):
+		return status.Errorf(codes.DeadlineExceeded, "%s: statement timeout", msg)
+	}
+	return status.Error(codes.Internal, msg)
+}
+
+// exec runs a statement that returns no rows, translating its bind parameters for the dialect.
+func (s *sqlStore) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := s.startSpan(ctx, query)
+	res, err := s.db.ExecContext(ctx, s.dialect.rebind(query), args...)
+	endSpan(span, err)
+	return res, err
+}
+
+// query runs a statement that returns rows, translating its bind parameters for the dialect.
+func (s *sqlStore) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := s.startSpan(ctx, query)
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+// queryRow runs a statement that returns at most one row, translating its bind parameters for
+// the dialect.
+func (s *sqlStore) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := s.startSpan(ctx, query)
+	row := s.db.QueryRowContext(ctx, s.dialect.rebind(query), args...)
+	endSpan(span, row.Err())
+	return row
+}
+
+// startSpan starts the span of a statement. Spans end when the statement has run, before its rows
+// are read.
+func (s *sqlStore) startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation := "SQL"
+	if f := strings.Fields(query); len(f) > 0 {
+		operation = strings.ToUpper(f[0])
+	}
+	return sqlTracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		s.dialect.system,
+		semconv.DBOperationKey.String(operation),
+		semconv.DBStatementKey.String(query),
+	))
+}
+
+// endSpan ends the span of a statement, recording err unless it is sql.ErrNoRows.
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
+
+// CheckHealth returns an error if the database cannot be reached.
+func (s *sqlStore) CheckHealth(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return status.Errorf(codes.Unavailable, "database is unreachable: %s", err)
+	}
+	return nil
+}
+
+// CountObjects returns the numbers of projects, notes and occurrences in the store.
+func (s *sqlStore) CountObjects(ctx context.Context) (*ObjectCounts, error) {
+	var (
+		c   ObjectCounts
+		err error
+	)
+	if c.Projects, err = s.count(ctx, projectCount); err != nil {
+		return nil, s.queryError(ctx, err, "Failed to count Projects in database")
+	}
+	if c.Notes, err = s.count(ctx, allNotesCount); err != nil {
+		return nil, s.queryError(ctx, err, "Failed to count Notes in database")
+	}
+	if c.Occurrences, err = s.count(ctx, allOccurrencesCount); err != nil {
+		return nil, s.queryError(ctx, err, "Failed to count Occurrences in database")
+	}
+	return &c, nil
+}
+
+// CountNotes returns the number of notes in the specified project in the store.
+func (s *sqlStore) CountNotes(ctx context.Context, pID string) (int64, error) {
+	c, err := s.count(ctx, noteCount, pID)
+	if err != nil {
+		return 0, s.queryError(ctx, err, "Failed to count Notes in database")
+	}
+	return c, nil
+}
+
+// CountOccurrences returns the number of occurrences in the specified project in the store.
+func (s *sqlStore) CountOccurrences(ctx context.Context, pID string) (int64, error) {
+	c, err := s.count(ctx, occurrenceCount, pID)
+	if err != nil {
+		return 0, s.queryError(ctx, err, "Failed to count Occurrences in database")
+	}
+	return c, nil
+}
+
+// count returns the total number of entries for the specified query (assuming SELECT(*) is used)
+func (s *sqlStore) count(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	row := s.queryRow(ctx, query, args...)
+	var count int64
+	err := row.Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, err
+}
+
+// Encrypt int64 using provided key
+func encryptInt64(v int64, key string) (string, error) {
+	k, err := fernet.DecodeKey(key)
+	if err != nil {
+		return "", err
+	}
+	bytes, err := fernet.EncryptAndSign([]byte(strconv.FormatInt(v, 10)), k)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// Decrypts encrypted int64 using provided key. Returns defaultValue if decryption fails.
+func decryptInt64(encrypted string, key string, defaultValue int64) int64 {
+	k, err := fernet.DecodeKey(key)
+	if err != nil {
+		return defaultValue
+	}
+	bytes := fernet.VerifyAndDecrypt([]byte(encrypted), time.Hour, []*fernet.Key{k})
+	if bytes == nil {
+		return defaultValue
+	}
+	decryptedValue, err := strconv.ParseInt(string(bytes), 10, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return decryptedValue
+}
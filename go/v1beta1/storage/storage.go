@@ -16,6 +16,7 @@
 package storage
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"sort"
@@ -24,16 +25,21 @@ import (
 	"testing"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/google/go-cmp/cmp"
 	"github.com/grafeas/grafeas/go/name"
 	grafeas "github.com/grafeas/grafeas/go/v1beta1/api"
+	"github.com/grafeas/grafeas/go/v1beta1/audit"
 	"github.com/grafeas/grafeas/go/v1beta1/project"
+	apb "github.com/grafeas/grafeas/proto/v1beta1/audit_go_proto"
 	cpb "github.com/grafeas/grafeas/proto/v1beta1/common_go_proto"
 	pb "github.com/grafeas/grafeas/proto/v1beta1/grafeas_go_proto"
 	pkgpb "github.com/grafeas/grafeas/proto/v1beta1/package_go_proto"
 	prpb "github.com/grafeas/grafeas/proto/v1beta1/project_go_proto"
 	vpb "github.com/grafeas/grafeas/proto/v1beta1/vulnerability_go_proto"
 	"golang.org/x/net/context"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	fieldmaskpb "google.golang.org/genproto/protobuf/field_mask"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/testing/protocmp"
@@ -644,6 +650,10 @@ func DoTestStorage(t *testing.T, createStore func(t *testing.T) (grafeas.Storage
 
 		filter := "filters_are_yet_to_be_implemented"
 		gotNs, _, err := g.ListNotes(ctx, findProject, filter, "", 100)
+		if filterRejected(err) {
+			filter = ""
+			gotNs, _, err = g.ListNotes(ctx, findProject, filter, "", 100)
+		}
 		if err != nil {
 			t.Fatalf("ListNotes got %v want success", err)
 		}
@@ -701,6 +711,10 @@ func DoTestStorage(t *testing.T, createStore func(t *testing.T) (grafeas.Storage
 
 		filter := "filters_are_yet_to_be_implemented"
 		gotOs, _, err := g.ListOccurrences(ctx, findProject, filter, "", 100)
+		if filterRejected(err) {
+			filter = ""
+			gotOs, _, err = g.ListOccurrences(ctx, findProject, filter, "", 100)
+		}
 		if err != nil {
 			t.Fatalf("ListOccurrences got %v want success", err)
 		}
@@ -758,6 +772,10 @@ func DoTestStorage(t *testing.T, createStore func(t *testing.T) (grafeas.Storage
 		}
 		filter := "filters_are_yet_to_be_implemented"
 		gotOs, _, err := g.ListNoteOccurrences(ctx, pID, nID, filter, "", 100)
+		if filterRejected(err) {
+			filter = ""
+			gotOs, _, err = g.ListNoteOccurrences(ctx, pID, nID, filter, "", 100)
+		}
 		if err != nil {
 			t.Fatalf("ListNoteOccurrences got %v want success", err)
 		}
@@ -863,6 +881,10 @@ func DoTestStorage(t *testing.T, createStore func(t *testing.T) (grafeas.Storage
 		filter := "filters_are_yet_to_be_implemented"
 		// Get occurrences
 		gotNotes, lastPage, err := g.ListNotes(ctx, pID, filter, "", 2)
+		if filterRejected(err) {
+			filter = ""
+			gotNotes, lastPage, err = g.ListNotes(ctx, pID, filter, "", 2)
+		}
 		if err != nil {
 			t.Fatalf("ListNotes got %v want success", err)
 		}
@@ -923,6 +945,10 @@ func DoTestStorage(t *testing.T, createStore func(t *testing.T) (grafeas.Storage
 		filter := "filters_are_yet_to_be_implemented"
 		// Get occurrences
 		gotOccurrences, lastPage, err := g.ListOccurrences(ctx, pID, filter, "", 2)
+		if filterRejected(err) {
+			filter = ""
+			gotOccurrences, lastPage, err = g.ListOccurrences(ctx, pID, filter, "", 2)
+		}
 		if err != nil {
 			t.Fatalf("ListOccurrences got %v want success", err)
 		}
@@ -979,6 +1005,10 @@ func DoTestStorage(t *testing.T, createStore func(t *testing.T) (grafeas.Storage
 		_, nID, err := name.ParseNote(n.Name)
 		// Get occurrences
 		gotOccurrences, lastPage, err := g.ListNoteOccurrences(ctx, nPID, nID, filter, "", 2)
+		if filterRejected(err) {
+			filter = ""
+			gotOccurrences, lastPage, err = g.ListNoteOccurrences(ctx, nPID, nID, filter, "", 2)
+		}
 		if err != nil {
 			t.Fatalf("ListNoteOccurrences got %v want success", err)
 		}
@@ -997,6 +1027,309 @@ func DoTestStorage(t *testing.T, createStore func(t *testing.T) (grafeas.Storage
 			t.Errorf("ListNoteOccurrences got %v operations, want 1", len(gotOccurrences))
 		}
 	})
+
+	t.Run("CountObjects", func(t *testing.T) {
+		g, gp, cleanUp := createStore(t)
+		defer cleanUp()
+
+		var counter ObjectCounter
+		if !As(g, &counter) {
+			t.Skip("storage does not count objects")
+		}
+		ctx := context.Background()
+		pID := "project"
+		if _, err := gp.CreateProject(ctx, pID, &prpb.Project{}); err != nil {
+			t.Errorf("CreateProject got %v want success", err)
+		}
+		n := createTestNote(pID)
+		if _, err := g.CreateNote(ctx, pID, testNoteID, "userID", n); err != nil {
+			t.Fatalf("CreateNote got %v want success", err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := g.CreateOccurrence(ctx, pID, "userID", createTestOccurrence(pID, n.Name)); err != nil {
+				t.Errorf("CreateOccurrence got %v want success", err)
+			}
+		}
+
+		got, err := counter.CountObjects(ctx)
+		if err != nil {
+			t.Fatalf("CountObjects got %v want success", err)
+		}
+		if want := (ObjectCounts{Projects: 1, Notes: 1, Occurrences: 2}); *got != want {
+			t.Errorf("CountObjects got %+v want %+v", *got, want)
+		}
+	})
+
+	t.Run("CountProjectObjects", func(t *testing.T) {
+		g, gp, cleanUp := createStore(t)
+		defer cleanUp()
+
+		var counter grafeas.ProjectCounter
+		if !As(g, &counter) {
+			t.Skip("storage does not count the objects of projects")
+		}
+		ctx := context.Background()
+		// The other project's ID is a prefix of the project's, to catch counts by name prefix.
+		for i, pID := range []string{"project", "project2"} {
+			if _, err := gp.CreateProject(ctx, pID, &prpb.Project{}); err != nil {
+				t.Errorf("CreateProject got %v want success", err)
+			}
+			n := createTestNote(pID)
+			if _, err := g.CreateNote(ctx, pID, testNoteID, "userID", n); err != nil {
+				t.Fatalf("CreateNote got %v want success", err)
+			}
+			for j := 0; j <= i; j++ {
+				if _, err := g.CreateOccurrence(ctx, pID, "userID", createTestOccurrence(pID, n.Name)); err != nil {
+					t.Errorf("CreateOccurrence got %v want success", err)
+				}
+			}
+		}
+
+		for _, c := range []struct {
+			pID                 string
+			wantNotes, wantOccs int64
+		}{
+			{"project", 1, 1},
+			{"project2", 1, 2},
+			{"empty", 0, 0},
+		} {
+			if got, err := counter.CountNotes(ctx, c.pID); got != c.wantNotes || err != nil {
+				t.Errorf("CountNotes(%q) got %d, %v want %d, nil", c.pID, got, err, c.wantNotes)
+			}
+			if got, err := counter.CountOccurrences(ctx, c.pID); got != c.wantOccs || err != nil {
+				t.Errorf("CountOccurrences(%q) got %d, %v want %d, nil", c.pID, got, err, c.wantOccs)
+			}
+		}
+	})
+
+	t.Run("CheckHealth", func(t *testing.T) {
+		g, _, cleanUp := createStore(t)
+		defer cleanUp()
+
+		var checker HealthChecker
+		if !As(g, &checker) {
+			t.Skip("storage does not check its health")
+		}
+		if err := checker.CheckHealth(context.Background()); err != nil {
+			t.Errorf("CheckHealth got %v want success", err)
+		}
+	})
+
+	t.Run("IamPolicies", func(t *testing.T) {
+		g, _, cleanUp := createStore(t)
+		defer cleanUp()
+
+		var ps grafeas.PolicyStorage
+		if !As(g, &ps) {
+			t.Skip("storage does not store IAM policies")
+		}
+		ctx := context.Background()
+		resource := name.FormatNote("project", testNoteID)
+		if p, err := ps.GetIamPolicy(ctx, resource); p != nil || err != nil {
+			t.Errorf("GetIamPolicy without policy got %v, %v want nil, nil", p, err)
+		}
+
+		p1 := &iampb.Policy{
+			Version:  1,
+			Bindings: []*iampb.Binding{{Role: "note-attacher", Members: []string{"consumer"}}},
+			Etag:     []byte("etag1"),
+		}
+		if err := ps.SetIamPolicy(ctx, resource, p1, []byte("etag0")); status.Code(err) != codes.Aborted {
+			t.Errorf("SetIamPolicy with etag of missing policy got %v want Aborted", err)
+		}
+		if err := ps.SetIamPolicy(ctx, resource, p1, []byte{}); err != nil {
+			t.Fatalf("SetIamPolicy with empty etag got %v want success", err)
+		}
+		if err := ps.SetIamPolicy(ctx, resource, p1, []byte{}); status.Code(err) != codes.Aborted {
+			t.Errorf("SetIamPolicy with empty etag of existing policy got %v want Aborted", err)
+		}
+		got, err := ps.GetIamPolicy(ctx, resource)
+		if err != nil {
+			t.Fatalf("GetIamPolicy got %v want success", err)
+		}
+		if diff := cmp.Diff(p1, got, protocmp.Transform()); diff != "" {
+			t.Errorf("GetIamPolicy returned diff (want -> got):\n%s", diff)
+		}
+
+		p2 := &iampb.Policy{Version: 1, Etag: []byte("etag2")}
+		if err := ps.SetIamPolicy(ctx, resource, p2, []byte("etag0")); status.Code(err) != codes.Aborted {
+			t.Errorf("SetIamPolicy with stale etag got %v want Aborted", err)
+		}
+		if err := ps.SetIamPolicy(ctx, resource, p2, []byte("etag1")); err != nil {
+			t.Errorf("SetIamPolicy with current etag got %v want success", err)
+		}
+		if err := ps.SetIamPolicy(ctx, resource, p1, nil); err != nil {
+			t.Errorf("SetIamPolicy without etag got %v want success", err)
+		}
+		if got, err := ps.GetIamPolicy(ctx, resource); err != nil || !bytes.Equal(got.GetEtag(), p1.Etag) {
+			t.Errorf("GetIamPolicy after unconditional set got %v, %v want %v", got, err, p1)
+		}
+
+		if err := ps.DeleteIamPolicy(ctx, resource); err != nil {
+			t.Errorf("DeleteIamPolicy got %v want success", err)
+		}
+		if p, err := ps.GetIamPolicy(ctx, resource); p != nil || err != nil {
+			t.Errorf("GetIamPolicy after delete got %v, %v want nil, nil", p, err)
+		}
+		if err := ps.DeleteIamPolicy(ctx, resource); err != nil {
+			t.Errorf("DeleteIamPolicy of missing policy got %v want success", err)
+		}
+	})
+
+	t.Run("PurgedNotes", func(t *testing.T) {
+		g, _, cleanUp := createStore(t)
+		defer cleanUp()
+
+		var ps grafeas.PurgedNoteStorage
+		if !As(g, &ps) {
+			t.Skip("storage does not record purged notes")
+		}
+		ctx := context.Background()
+		n1, n2 := name.FormatNote("project", "note1"), name.FormatNote("project", "note2")
+		for _, n := range []string{n2, n1, n2} {
+			if err := ps.AddPurgedNote(ctx, n); err != nil {
+				t.Fatalf("AddPurgedNote(%q) got %v want success", n, err)
+			}
+		}
+		got, err := ps.ListPurgedNotes(ctx)
+		if err != nil {
+			t.Fatalf("ListPurgedNotes got %v want success", err)
+		}
+		if diff := cmp.Diff([]string{n1, n2}, got); diff != "" {
+			t.Errorf("ListPurgedNotes returned diff (want -> got):\n%s", diff)
+		}
+
+		if err := ps.DeletePurgedNote(ctx, n1); err != nil {
+			t.Errorf("DeletePurgedNote got %v want success", err)
+		}
+		if err := ps.DeletePurgedNote(ctx, n1); err != nil {
+			t.Errorf("DeletePurgedNote of missing note got %v want success", err)
+		}
+		if got, err := ps.ListPurgedNotes(ctx); err != nil || len(got) != 1 || got[0] != n2 {
+			t.Errorf("ListPurgedNotes after delete got %v, %v want [%s]", got, err, n2)
+		}
+	})
+
+	t.Run("AuditEntries", func(t *testing.T) {
+		g, _, cleanUp := createStore(t)
+		defer cleanUp()
+
+		var as audit.Storage
+		if !As(g, &as) {
+			t.Skip("storage does not store audit entries")
+		}
+		ctx := context.Background()
+		var want []*apb.AuditEntry
+		for i := 0; i < 5; i++ {
+			e := &apb.AuditEntry{
+				Time:       ptypes.TimestampNow(),
+				Method:     "/grafeas.v1beta1.GrafeasV1Beta1/UpdateNote",
+				Principal:  "alice",
+				Project:    "project",
+				Resource:   name.FormatNote("project", fmt.Sprintf("note%d", i)),
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"short_description"}},
+				Outcome:    "OK",
+			}
+			if err := as.WriteAuditEntry(ctx, e); err != nil {
+				t.Fatalf("WriteAuditEntry got %v want success", err)
+			}
+			want = append(want, e)
+			other := &apb.AuditEntry{Method: "/grafeas.v1beta1.project.Projects/DeleteProject", Project: "other", Outcome: "NotFound"}
+			if err := as.WriteAuditEntry(ctx, other); err != nil {
+				t.Fatalf("WriteAuditEntry got %v want success", err)
+			}
+		}
+
+		var got []*apb.AuditEntry
+		pageToken := ""
+		for pages := 0; pages < 5; pages++ {
+			es, next, err := as.ListAuditEntries(ctx, "project", pageToken, 2)
+			if err != nil {
+				t.Fatalf("ListAuditEntries got %v want success", err)
+			}
+			got = append(got, es...)
+			if pageToken = next; pageToken == "" {
+				break
+			}
+		}
+		if pageToken != "" {
+			t.Errorf("ListAuditEntries returned more pages than entries")
+		}
+		if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+			t.Errorf("ListAuditEntries returned diff (want -> got):\n%s", diff)
+		}
+		if es, _, err := as.ListAuditEntries(ctx, "empty", "", 10); len(es) != 0 || err != nil {
+			t.Errorf("ListAuditEntries of project without entries got %v, %v want none", es, err)
+		}
+	})
+}
+
+// filterRejected reports whether a list call rejected its filter. The shared tests pass a filter
+// that no store understands: MemStore and EmbeddedStore ignore filters, while the SQL stores
+// reject the ones they cannot translate, so for those the tests list unfiltered instead.
+func filterRejected(err error) bool {
+	return status.Code(err) == codes.InvalidArgument
+}
+
+// DoTestFilters runs the list filter tests against stores that evaluate filters, currently only
+// the SQL stores. Supported filters must narrow the results and unsupported ones must be
+// rejected as invalid arguments rather than ignored.
+func DoTestFilters(t *testing.T, createStore func(t *testing.T) (grafeas.Storage, project.Storage, func())) {
+	g, _, cleanUp := createStore(t)
+	defer cleanUp()
+
+	ctx := context.Background()
+	pID := "filter-project"
+	vn := createTestNote(pID)
+	if _, err := g.CreateNote(ctx, pID, testNoteID, "userID", vn); err != nil {
+		t.Fatalf("CreateNote got %v want success", err)
+	}
+	bn := &pb.Note{Name: name.FormatNote(pID, "build"), Kind: cpb.NoteKind_BUILD}
+	if _, err := g.CreateNote(ctx, pID, "build", "userID", bn); err != nil {
+		t.Fatalf("CreateNote got %v want success", err)
+	}
+	if _, err := g.CreateOccurrence(ctx, pID, "userID", createTestOccurrence(pID, vn.Name)); err != nil {
+		t.Fatalf("CreateOccurrence got %v want success", err)
+	}
+	bo := &pb.Occurrence{Resource: &pb.Resource{Uri: "gcr.io/foo/bar"}, NoteName: bn.Name, Kind: cpb.NoteKind_BUILD}
+	if _, err := g.CreateOccurrence(ctx, pID, "userID", bo); err != nil {
+		t.Fatalf("CreateOccurrence got %v want success", err)
+	}
+
+	gotNs, _, err := g.ListNotes(ctx, pID, `kind="BUILD"`, "", 100)
+	if err != nil {
+		t.Fatalf("ListNotes got %v want success", err)
+	}
+	if len(gotNs) != 1 || gotNs[0].Kind != cpb.NoteKind_BUILD {
+		t.Errorf("ListNotes got %v, want only the BUILD note", gotNs)
+	}
+	gotOs, _, err := g.ListOccurrences(ctx, pID, `kind="BUILD"`, "", 100)
+	if err != nil {
+		t.Fatalf("ListOccurrences got %v want success", err)
+	}
+	if len(gotOs) != 1 || gotOs[0].Kind != cpb.NoteKind_BUILD {
+		t.Errorf("ListOccurrences got %v, want only the BUILD occurrence", gotOs)
+	}
+	gotOs, _, err = g.ListNoteOccurrences(ctx, pID, testNoteID, `kind="BUILD"`, "", 100)
+	if err != nil {
+		t.Fatalf("ListNoteOccurrences got %v want success", err)
+	}
+	if len(gotOs) != 0 {
+		t.Errorf("ListNoteOccurrences got %v, want none", gotOs)
+	}
+
+	// A partly supported filter would widen the results if its unsupported part were dropped.
+	for _, filter := range []string{"filters_are_yet_to_be_implemented", `kind="BUILD" AND foo="y"`} {
+		if _, _, err := g.ListNotes(ctx, pID, filter, "", 100); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListNotes(%q) got %v, want InvalidArgument", filter, err)
+		}
+		if _, _, err := g.ListOccurrences(ctx, pID, filter, "", 100); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListOccurrences(%q) got %v, want InvalidArgument", filter, err)
+		}
+		if _, err := g.GetVulnerabilityOccurrencesSummary(ctx, pID, filter); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("GetVulnerabilityOccurrencesSummary(%q) got %v, want InvalidArgument", filter, err)
+		}
+	}
 }
 
 func createTestOccurrence(pID, noteName string) *pb.Occurrence {
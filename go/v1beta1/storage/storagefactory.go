@@ -21,6 +21,7 @@ import (
 	"github.com/grafeas/grafeas/go/config"
 	"github.com/grafeas/grafeas/go/v1beta1/api"
 	"github.com/grafeas/grafeas/go/v1beta1/project"
+	"golang.org/x/net/context"
 )
 
 // use type aliasing to get multiple inheritance even though both interfaces are called Storage
@@ -34,6 +35,25 @@ type Storage struct {
 	Gs
 }
 
+// ObjectCounts holds the numbers of objects in storage.
+type ObjectCounts struct {
+	Projects    int64
+	Notes       int64
+	Occurrences int64
+}
+
+// ObjectCounter is implemented by storage that can count the objects it holds, e.g. for metrics.
+type ObjectCounter interface {
+	CountObjects(ctx context.Context) (*ObjectCounts, error)
+}
+
+// HealthChecker is implemented by storage that depends on a resource that can become unavailable,
+// such as a database server. Storage that does not implement it is always healthy.
+type HealthChecker interface {
+	// CheckHealth returns an error if the storage cannot serve requests.
+	CheckHealth(ctx context.Context) error
+}
+
 var registeredStorageTypeProviders = map[string]func(storageType string, storageConfig *config.StorageConfiguration) (*Storage, error){}
 
 // RegisterStorageTypeProvider registers a new provider to create a specific type of Storage
@@ -62,7 +82,18 @@ func memstoreStorageTypeProvider(storageType string, storageConfig *config.Stora
 		return nil, errors.New(fmt.Sprintf("Unknown storage type %s, must be 'memstore'", storageType))
 	}
 
-	s := NewMemStore()
+	// The memstore section is optional.
+	var storeConfig config.MemStoreConfig
+	if storageConfig != nil {
+		if err := config.ConvertGenericConfigToSpecificType(storageConfig, &storeConfig); err != nil {
+			return nil, errors.New(fmt.Sprintf("Unable to create MemStoreConfig, %s", err))
+		}
+	}
+
+	s, err := NewMemStoreWithConfig(&storeConfig)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to create MemStore, %s", err))
+	}
 	storage := &Storage{
 		Ps: s,
 		Gs: s,
@@ -93,6 +124,32 @@ func embeddedStorageTypeProvider(storageType string, storageConfig *config.Stora
 	return storage, nil
 }
 
+// sqliteStorageTypeProvider returns a SQLite storage instance
+func sqliteStorageTypeProvider(storageType string, storageConfig *config.StorageConfiguration) (*Storage, error) {
+	if storageType != "sqlite" {
+		return nil, errors.New(fmt.Sprintf("Unknown storage type %s, must be 'sqlite'", storageType))
+	}
+
+	var storeConfig config.SQLiteConfig
+
+	err := config.ConvertGenericConfigToSpecificType(storageConfig, &storeConfig)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to create SQLiteConfig, %s", err))
+	}
+
+	s, err := NewSQLiteStore(&storeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := &Storage{
+		Ps: s,
+		Gs: s,
+	}
+
+	return storage, nil
+}
+
 // postgresStorageTypeProvider returns a postgres storage instance
 // TODO(#341) move this function to a separate project
 func postgresStorageTypeProvider(storageType string, storageConfig *config.StorageConfiguration) (*Storage, error) {
@@ -120,7 +177,7 @@ func postgresStorageTypeProvider(storageType string, storageConfig *config.Stora
 	return storage, nil
 }
 
-// RegisterDefaultStorageTypeProviders adds support for memstore, embedded and Postgres storage types
+// RegisterDefaultStorageTypeProviders adds support for memstore, embedded, SQLite and Postgres storage types
 // TODO(#341) remove support for Postgres and move to a separate Register...() implementation in a separate project
 func RegisterDefaultStorageTypeProviders() error {
 	err := RegisterStorageTypeProvider("memstore", memstoreStorageTypeProvider)
@@ -133,6 +190,11 @@ func RegisterDefaultStorageTypeProviders() error {
 		return err
 	}
 
+	err = RegisterStorageTypeProvider("sqlite", sqliteStorageTypeProvider)
+	if err != nil {
+		return err
+	}
+
 	// TODO(#341) move this function invocation to a separate function within a separate project
 	err = RegisterStorageTypeProvider("postgres", postgresStorageTypeProvider)
 	if err != nil {
@@ -10,7 +10,7 @@
 // ==================
 //
 // We generate grafeas.swagger.json and project.swagger.json for backwards
-// compatibility. A merged Swagger file is also generated in swagger/merged that
+// compatibility, and audit.swagger.json for the audit service. A merged Swagger file is also generated in swagger/merged that
 // merges both services into a single Swagger file for easier client generation.
 //
 // NOTE: You should only generate Swagger for a proto if it contains any
@@ -22,6 +22,9 @@
 //go:generate swagger project.proto
 //go:generate mv project.swagger.json swagger
 
+//go:generate swagger audit.proto
+//go:generate mv audit.swagger.json swagger
+
 //go:generate mkdir swagger/merged
 //go:generate swagger --openapiv2_opt=allow_merge=true,merge_file_name=grafeas grafeas.proto project.proto
 //go:generate mv grafeas.swagger.json swagger/merged
@@ -104,6 +107,13 @@
 //go:generate mv project_grpc.pb.go project_go_proto
 //go:generate mv project.pb.gw.go project_go_proto
 
+//go:generate protoc audit.proto
+//go:generate rm -rf audit_go_proto
+//go:generate mkdir audit_go_proto
+//go:generate mv audit.pb.go audit_go_proto
+//go:generate mv audit_grpc.pb.go audit_go_proto
+//go:generate mv audit.pb.gw.go audit_go_proto
+
 //go:generate protoc spdx.proto
 //go:generate rm -rf spdx_go_proto
 //go:generate mkdir spdx_go_proto